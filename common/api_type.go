@@ -65,6 +65,8 @@ func ChannelType2APIType(channelType int) (int, bool) {
 		apiType = constant.APITypeCoze
 	case constant.ChannelTypeJimeng:
 		apiType = constant.APITypeJimeng
+	case constant.ChannelTypeSelfHosted:
+		apiType = constant.APITypeSelfHosted
 	}
 	if apiType == -1 {
 		return constant.APITypeOpenAI, false
@@ -0,0 +1,137 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ScheduleWindow 以 cron 表达式（分 时 日 月 周）描述的时间窗口，当前时间所在的分钟满足表达式即处于窗口内，
+// 例如 "* 9-17 * * 1-5" 表示工作日 9:00 至 17:59
+type ScheduleWindow struct {
+	Cron     string `json:"cron"`
+	Timezone string `json:"timezone,omitempty"` // IANA 时区名，如 Asia/Shanghai，为空时使用服务器时区
+}
+
+// CronSchedule 解析后的时间窗口
+type CronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+	location                      *time.Location
+}
+
+// 解析结果缓存的最大条数，超过后清空重建，避免配置反复修改时缓存无限增长
+const maxCompiledSchedules = 1024
+
+var (
+	compiledSchedules     = make(map[string]*CronSchedule) // cron|timezone -> *CronSchedule
+	compiledSchedulesLock sync.RWMutex
+)
+
+// ParseSchedule 解析时间窗口，解析结果会被缓存
+func ParseSchedule(window ScheduleWindow) (*CronSchedule, error) {
+	cacheKey := window.Cron + "|" + window.Timezone
+	compiledSchedulesLock.RLock()
+	cached, ok := compiledSchedules[cacheKey]
+	compiledSchedulesLock.RUnlock()
+	if ok {
+		return cached, nil
+	}
+	fields := strings.Fields(window.Cron)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 fields", window.Cron)
+	}
+	schedule := &CronSchedule{location: time.Local}
+	if window.Timezone != "" {
+		location, err := time.LoadLocation(window.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %s", window.Timezone, err.Error())
+		}
+		schedule.location = location
+	}
+	var err error
+	if schedule.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, err
+	}
+	if schedule.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, err
+	}
+	if schedule.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, err
+	}
+	if schedule.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, err
+	}
+	if schedule.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, err
+	}
+	// 周日可以写作 0 或 7
+	if schedule.dow&(1<<7) != 0 {
+		schedule.dow |= 1
+	}
+	schedule.domAny = fields[2] == "*"
+	schedule.dowAny = fields[4] == "*"
+	compiledSchedulesLock.Lock()
+	if len(compiledSchedules) >= maxCompiledSchedules {
+		compiledSchedules = make(map[string]*CronSchedule)
+	}
+	compiledSchedules[cacheKey] = schedule
+	compiledSchedulesLock.Unlock()
+	return schedule, nil
+}
+
+// parseCronField 解析单个字段，支持 *、数字、a-b 范围、/n 步长与逗号分隔的列表
+func parseCronField(field string, min int, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[idx+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid cron step %q", part)
+			}
+			part = part[:idx]
+		}
+		start, end := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if start, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid cron value %q", part)
+			}
+			end = start
+			if len(bounds) == 2 {
+				if end, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("invalid cron value %q", part)
+				}
+			} else if step > 1 {
+				end = max
+			}
+		}
+		if start < min || end > max || start > end {
+			return 0, fmt.Errorf("cron value %q out of range %d-%d", part, min, max)
+		}
+		for value := start; value <= end; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+	return bits, nil
+}
+
+// Contains 判断时间是否处于窗口内
+func (s *CronSchedule) Contains(t time.Time) bool {
+	t = t.In(s.location)
+	if s.minute&(1<<uint(t.Minute())) == 0 || s.hour&(1<<uint(t.Hour())) == 0 || s.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	// 与 cron 一致：日与周同时限定时满足其一即可
+	if s.domAny || s.dowAny {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
@@ -0,0 +1,65 @@
+package common
+
+import (
+	"testing"
+	"time"
+)
+
+func cronBits(values ...int) uint64 {
+	var bits uint64
+	for _, v := range values {
+		bits |= 1 << uint(v)
+	}
+	return bits
+}
+
+func TestParseCronField(t *testing.T) {
+	cases := []struct {
+		field    string
+		min, max int
+		want     uint64
+	}{
+		{"*", 0, 6, cronBits(0, 1, 2, 3, 4, 5, 6)},
+		{"5", 0, 59, cronBits(5)},
+		{"9-12", 0, 23, cronBits(9, 10, 11, 12)},
+		{"*/15", 0, 59, cronBits(0, 15, 30, 45)},
+		{"10/20", 0, 59, cronBits(10, 30, 50)},
+		{"1-10/3", 1, 31, cronBits(1, 4, 7, 10)},
+		{"1,3,5-6", 0, 7, cronBits(1, 3, 5, 6)},
+	}
+	for _, c := range cases {
+		got, err := parseCronField(c.field, c.min, c.max)
+		if err != nil {
+			t.Errorf("parseCronField(%q) failed: %s", c.field, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("parseCronField(%q) = %b, want %b", c.field, got, c.want)
+		}
+	}
+}
+
+func TestParseCronFieldInvalid(t *testing.T) {
+	for _, field := range []string{"", "a", "60", "5-3", "1-2-3", "*/0", "*/x", "0-24"} {
+		if _, err := parseCronField(field, 0, 23); err == nil {
+			t.Errorf("parseCronField(%q) expected error", field)
+		}
+	}
+}
+
+func TestScheduleContains(t *testing.T) {
+	schedule, err := ParseSchedule(ScheduleWindow{Cron: "* 9-17 * * 1-5", Timezone: "UTC"})
+	if err != nil {
+		t.Fatalf("parse schedule failed: %s", err)
+	}
+	// 2024-01-01 为周一
+	if !schedule.Contains(time.Date(2024, 1, 1, 9, 30, 0, 0, time.UTC)) {
+		t.Errorf("monday 9:30 should be inside the window")
+	}
+	if schedule.Contains(time.Date(2024, 1, 1, 18, 0, 0, 0, time.UTC)) {
+		t.Errorf("monday 18:00 should be outside the window")
+	}
+	if schedule.Contains(time.Date(2024, 1, 6, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("saturday should be outside the window")
+	}
+}
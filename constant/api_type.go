@@ -31,5 +31,6 @@ const (
 	APITypeXai
 	APITypeCoze
 	APITypeJimeng
+	APITypeSelfHosted
 	APITypeDummy // this one is only for count, do not add any channel after this
 )
@@ -15,6 +15,7 @@ const (
 	ContextKeyTokenSpecificChannelId ContextKey = "specific_channel_id"
 	ContextKeyTokenModelLimitEnabled ContextKey = "token_model_limit_enabled"
 	ContextKeyTokenModelLimit        ContextKey = "token_model_limit"
+	ContextKeyTokenCallbackUrl       ContextKey = "token_callback_url"
 
 	/* channel related keys */
 	ContextKeyChannelId                ContextKey = "channel_id"
@@ -31,6 +32,9 @@ const (
 	ContextKeyChannelIsMultiKey        ContextKey = "channel_is_multi_key"
 	ContextKeyChannelMultiKeyIndex     ContextKey = "channel_multi_key_index"
 	ContextKeyChannelKey               ContextKey = "channel_key"
+	ContextKeyChannelSlot              ContextKey = "channel_slot"      // 当前请求占用并发位的渠道 id
+	ContextKeyChannelPinnedId          ContextKey = "pinned_channel_id" // 请求引用的文件或缓存所在的渠道 id
+	ContextKeyChannelPinnedKeyIndex    ContextKey = "pinned_key_index"  // 创建请求引用的文件或缓存时使用的密钥位置
 
 	/* user related keys */
 	ContextKeyUserId      ContextKey = "id"
@@ -1,5 +1,7 @@
 package constant
 
+import "strings"
+
 type TaskPlatform string
 
 const (
@@ -7,11 +9,16 @@ const (
 	TaskPlatformMidjourney              = "mj"
 	TaskPlatformKling      TaskPlatform = "kling"
 	TaskPlatformJimeng     TaskPlatform = "jimeng"
+	// TaskPlatformClaudeBatch Anthropic Message Batches，批处理结束后按实际用量结算
+	TaskPlatformClaudeBatch TaskPlatform = "claude_batch"
 )
 
 const (
 	SunoActionMusic  = "MUSIC"
 	SunoActionLyrics = "LYRICS"
+	SunoActionExtend = "EXTEND"
+	SunoActionCover  = "COVER"
+	SunoActionStems  = "STEMS"
 
 	TaskActionGenerate     = "generate"
 	TaskActionTextGenerate = "textGenerate"
@@ -21,3 +28,17 @@ var SunoModel2Action = map[string]string{
 	"suno_music":  SunoActionMusic,
 	"suno_lyrics": SunoActionLyrics,
 }
+
+// MusicModelPlatforms 音乐生成模型前缀对应的任务平台，新增音乐平台时在此注册即可通过 /v1/audio/music 调用
+var MusicModelPlatforms = map[string]TaskPlatform{
+	"suno": TaskPlatformSuno,
+}
+
+func GetMusicTaskPlatform(modelName string) (TaskPlatform, bool) {
+	for prefix, platform := range MusicModelPlatforms {
+		if strings.HasPrefix(modelName, prefix) {
+			return platform, true
+		}
+	}
+	return "", false
+}
@@ -6,7 +6,12 @@ import (
 	"net/http"
 	"one-api/common"
 	"one-api/constant"
+	"one-api/dto"
 	"one-api/model"
+	"one-api/relay/channel/ollama"
+	"one-api/relay/channel/openai"
+	"one-api/relay/channel/selfhosted"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -141,6 +146,7 @@ func GetAllChannels(c *gin.Context) {
 	for _, r := range results {
 		typeCounts[r.Type] = r.Count
 	}
+	model.FillChannelInFlight(channelData)
 	common.ApiSuccess(c, gin.H{
 		"items":       channelData,
 		"total":       total,
@@ -168,6 +174,32 @@ func FetchUpstreamModels(c *gin.Context) {
 	if channel.GetBaseURL() != "" {
 		baseURL = channel.GetBaseURL()
 	}
+	if channel.Type == constant.ChannelTypeOllama {
+		ids, err := ollama.FetchOllamaModels(baseURL, channel.Key)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "",
+			"data":    ids,
+		})
+		return
+	}
+	if channel.Type == constant.ChannelTypeAzure {
+		deployments, err := openai.FetchAzureDeployments(baseURL, channel.Key, channel.Other, channel.GetSetting())
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "",
+			"data":    azureDeploymentModels(deployments),
+		})
+		return
+	}
 	url := fmt.Sprintf("%s/v1/models", baseURL)
 	switch channel.Type {
 	case constant.ChannelTypeGemini:
@@ -206,6 +238,52 @@ func FetchUpstreamModels(c *gin.Context) {
 	})
 }
 
+// PullOllamaModel 让 Ollama 渠道下载指定模型
+func PullOllamaModel(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	var req struct {
+		Model string `json:"model"`
+	}
+	if err = c.ShouldBindJSON(&req); err != nil || req.Model == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "模型名称不能为空",
+		})
+		return
+	}
+
+	channel, err := model.GetChannelById(id, true)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if channel.Type != constant.ChannelTypeOllama {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "仅支持 Ollama 渠道",
+		})
+		return
+	}
+	baseURL := constant.ChannelBaseURLs[channel.Type]
+	if channel.GetBaseURL() != "" {
+		baseURL = channel.GetBaseURL()
+	}
+	result, err := ollama.PullOllamaModel(baseURL, channel.Key, req.Model)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    result,
+	})
+}
+
 func FixChannelsAbilities(c *gin.Context) {
 	success, fails, err := model.FixAbility()
 	if err != nil {
@@ -318,6 +396,7 @@ func SearchChannels(c *gin.Context) {
 	}
 
 	pagedData := channelData[startIdx:endIdx]
+	model.FillChannelInFlight(pagedData)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -390,6 +469,88 @@ func validateChannel(channel *model.Channel, isAdd bool) error {
 	return nil
 }
 
+// probeSelfHostedCapabilities 保存自部署渠道时通过渠道代理探测上游能力并写入渠道设置，手动填写的能力不会被覆盖
+func probeSelfHostedCapabilities(channel *model.Channel, baseURL string, key string) error {
+	if channel.Type != constant.ChannelTypeSelfHosted {
+		return nil
+	}
+	setting := channel.GetSetting()
+	if setting.Capabilities != nil && setting.Capabilities.Manual {
+		return nil
+	}
+	modelName := ""
+	if models := channel.GetModels(); len(models) > 0 {
+		modelName = models[0]
+	}
+	key = strings.TrimSpace(strings.Split(key, "\n")[0])
+	capabilities, err := selfhosted.ProbeCapabilities(baseURL, key, setting.Proxy, modelName)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to probe capabilities of channel %s: %s", channel.Name, err.Error()))
+		return err
+	}
+	setting.Capabilities = capabilities
+	channel.SetSetting(setting)
+	return nil
+}
+
+// DiscoverAzureDeployments 列出 Azure 渠道的部署并写入部署映射，手动填写的映射不会被覆盖
+func DiscoverAzureDeployments(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	channel, err := model.GetChannelById(id, true)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if channel.Type != constant.ChannelTypeAzure {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "仅支持 Azure 渠道",
+		})
+		return
+	}
+	setting := channel.GetSetting()
+	deployments, err := openai.FetchAzureDeployments(channel.GetBaseURL(), channel.Key, channel.Other, setting)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if setting.Azure == nil {
+		setting.Azure = &dto.AzureSetting{}
+	}
+	mapping := openai.AzureDeploymentMapping(deployments)
+	for modelName, deployment := range setting.Azure.Deployments {
+		if deployment.Manual {
+			mapping[modelName] = deployment
+		}
+	}
+	setting.Azure.Deployments = mapping
+	channel.SetSetting(setting)
+	if err = channel.Update(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    setting.Azure.Deployments,
+	})
+}
+
+// azureDeploymentModels 返回部署映射中的模型名
+func azureDeploymentModels(deployments []dto.AzureDeployment) []string {
+	mapping := openai.AzureDeploymentMapping(deployments)
+	models := make([]string, 0, len(mapping))
+	for modelName := range mapping {
+		models = append(models, modelName)
+	}
+	sort.Strings(models)
+	return models
+}
+
 type AddChannelRequest struct {
 	Mode         string                `json:"mode"`
 	MultiKeyMode constant.MultiKeyMode `json:"multi_key_mode"`
@@ -499,6 +660,13 @@ func AddChannel(c *gin.Context) {
 		return
 	}
 
+	message := ""
+	if len(keys) > 0 {
+		if err := probeSelfHostedCapabilities(addChannelRequest.Channel, addChannelRequest.Channel.GetBaseURL(), keys[0]); err != nil {
+			message = "渠道已保存，但能力探测失败：" + err.Error()
+		}
+	}
+
 	channels := make([]model.Channel, 0, len(keys))
 	for _, key := range keys {
 		if key == "" {
@@ -515,7 +683,7 @@ func AddChannel(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": "",
+		"message": message,
 	})
 	return
 }
@@ -704,6 +872,26 @@ func UpdateChannel(c *gin.Context) {
 	if channel.MultiKeyMode != nil && *channel.MultiKeyMode != "" {
 		channel.ChannelInfo.MultiKeyMode = constant.MultiKeyMode(*channel.MultiKeyMode)
 	}
+
+	message := ""
+	if channel.Type == constant.ChannelTypeSelfHosted {
+		if channel.Setting == nil {
+			channel.Setting = originChannel.Setting
+		}
+		baseURL := channel.GetBaseURL()
+		if baseURL == "" {
+			baseURL = originChannel.GetBaseURL()
+		}
+		key := channel.Key
+		if key == "" {
+			if keyChannel, err := model.GetChannelById(channel.Id, true); err == nil {
+				key = keyChannel.Key
+			}
+		}
+		if err := probeSelfHostedCapabilities(&channel.Channel, baseURL, key); err != nil {
+			message = "渠道已保存，但能力探测失败：" + err.Error()
+		}
+	}
 	err = channel.Update()
 	if err != nil {
 		common.ApiError(c, err)
@@ -713,7 +901,7 @@ func UpdateChannel(c *gin.Context) {
 	channel.Key = ""
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": "",
+		"message": message,
 		"data":    channel,
 	})
 	return
@@ -724,6 +912,8 @@ func FetchModels(c *gin.Context) {
 		BaseURL string `json:"base_url"`
 		Type    int    `json:"type"`
 		Key     string `json:"key"`
+		Other   string `json:"other"`
+		Setting string `json:"setting"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -739,6 +929,48 @@ func FetchModels(c *gin.Context) {
 		baseURL = constant.ChannelBaseURLs[req.Type]
 	}
 
+	if req.Type == constant.ChannelTypeOllama {
+		models, err := ollama.FetchOllamaModels(baseURL, strings.Split(strings.TrimSpace(req.Key), "\n")[0])
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    models,
+		})
+		return
+	}
+
+	if req.Type == constant.ChannelTypeAzure {
+		var setting dto.ChannelSettings
+		if req.Setting != "" {
+			if err := common.UnmarshalJsonStr(req.Setting, &setting); err != nil {
+				c.JSON(http.StatusOK, gin.H{
+					"success": false,
+					"message": "渠道额外设置必须是合法的 JSON 格式",
+				})
+				return
+			}
+		}
+		deployments, err := openai.FetchAzureDeployments(baseURL, req.Key, req.Other, setting)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    azureDeploymentModels(deployments),
+		})
+		return
+	}
+
 	client := &http.Client{}
 	url := fmt.Sprintf("%s/v1/models", baseURL)
 
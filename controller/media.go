@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetMedia 通过签名链接访问网关保存的生成结果
+func GetMedia(c *gin.Context) {
+	key := c.Param("key")
+	expires, _ := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if !service.VerifyMediaSignature(key, expires, c.Query("signature")) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "invalid_or_expired_signature",
+		})
+		return
+	}
+	asset, err := model.GetMediaAssetByKey(key)
+	if err != nil || (asset.ExpiresAt > 0 && asset.ExpiresAt < time.Now().Unix()) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "media_not_found",
+		})
+		return
+	}
+	store, err := service.GetMediaStore(asset.Backend)
+	if err != nil {
+		common.SysError("failed to get media store: " + err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "media_store_unavailable",
+		})
+		return
+	}
+	reader, err := store.Open(c.Request.Context(), asset.Key)
+	if err != nil {
+		common.SysError("failed to open media: " + err.Error())
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "media_not_found",
+		})
+		return
+	}
+	defer reader.Close()
+	c.Writer.Header().Set("Content-Type", asset.ContentType)
+	c.Writer.Header().Set("Content-Length", strconv.FormatInt(asset.Size, 10))
+	c.Writer.Header().Set("Cache-Control", "private, max-age="+strconv.FormatInt(max(expires-time.Now().Unix(), 0), 10))
+	c.Writer.WriteHeader(http.StatusOK)
+	_, _ = io.Copy(c.Writer, reader)
+}
@@ -8,10 +8,12 @@ import (
 	"io"
 	"net/http"
 	"one-api/common"
+	"one-api/constant"
 	"one-api/dto"
 	"one-api/model"
 	"one-api/service"
 	"one-api/setting"
+	"one-api/setting/operation_setting"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -21,17 +23,29 @@ func UpdateMidjourneyTaskBulk() {
 	//imageModel := "midjourney"
 	ctx := context.TODO()
 	for {
-		time.Sleep(time.Duration(15) * time.Second)
+		time.Sleep(taskPollTick)
 
-		tasks := model.GetAllUnFinishTasks()
+		poll, cursor := service.ShouldPollTasks(constant.TaskPlatformMidjourney)
+		if !poll {
+			continue
+		}
+		batchSize := operation_setting.GetTaskUpdateSetting().GetBatchSize(constant.TaskPlatformMidjourney)
+		tasks := model.GetUnFinishTasksAfter(int(cursor), batchSize)
 		if len(tasks) == 0 {
+			service.FinishTaskPoll(constant.TaskPlatformMidjourney, false, 0)
 			continue
 		}
+		// 不足一批时下一轮从头开始
+		nextCursor := int64(0)
+		if len(tasks) == batchSize {
+			nextCursor = int64(tasks[len(tasks)-1].Id)
+		}
 
 		common.LogInfo(ctx, fmt.Sprintf("检测到未完成的任务数有: %v", len(tasks)))
 		taskChannelM := make(map[int][]string)
 		taskM := make(map[string]*model.Midjourney)
 		nullTaskIds := make([]int, 0)
+		snapshots := make(map[*model.Midjourney]string, len(tasks))
 		for _, task := range tasks {
 			if task.MjId == "" {
 				// 统计失败的未完成任务
@@ -40,6 +54,7 @@ func UpdateMidjourneyTaskBulk() {
 			}
 			taskM[task.MjId] = task
 			taskChannelM[task.ChannelId] = append(taskChannelM[task.ChannelId], task.MjId)
+			snapshots[task] = task.Status + task.Progress
 		}
 		if len(nullTaskIds) > 0 {
 			err := model.MjBulkUpdateByTaskIds(nullTaskIds, map[string]any{
@@ -52,122 +67,144 @@ func UpdateMidjourneyTaskBulk() {
 				common.LogInfo(ctx, fmt.Sprintf("Fix null mj_id task success: %v", nullTaskIds))
 			}
 		}
-		if len(taskChannelM) == 0 {
-			continue
-		}
 
 		for channelId, taskIds := range taskChannelM {
-			common.LogInfo(ctx, fmt.Sprintf("渠道 #%d 未完成的任务有: %d", channelId, len(taskIds)))
-			if len(taskIds) == 0 {
-				continue
-			}
-			midjourneyChannel, err := model.CacheGetChannel(channelId)
-			if err != nil {
-				common.LogError(ctx, fmt.Sprintf("CacheGetChannel: %v", err))
-				err := model.MjBulkUpdate(taskIds, map[string]any{
-					"fail_reason": fmt.Sprintf("获取渠道信息失败，请联系管理员，渠道ID：%d", channelId),
-					"status":      "FAILURE",
-					"progress":    "100%",
-				})
-				if err != nil {
-					common.LogInfo(ctx, fmt.Sprintf("UpdateMidjourneyTask error: %v", err))
-				}
-				continue
+			updateMidjourneyChannelTasks(ctx, channelId, taskIds, taskM)
+		}
+		changed := len(nullTaskIds) > 0
+		for task, snapshot := range snapshots {
+			if task.Status+task.Progress != snapshot {
+				changed = true
+				break
 			}
-			requestUrl := fmt.Sprintf("%s/mj/task/list-by-condition", *midjourneyChannel.BaseURL)
+		}
+		service.FinishTaskPoll(constant.TaskPlatformMidjourney, changed, nextCursor)
+	}
+}
 
-			body, _ := json.Marshal(map[string]any{
-				"ids": taskIds,
-			})
-			req, err := http.NewRequest("POST", requestUrl, bytes.NewBuffer(body))
-			if err != nil {
-				common.LogError(ctx, fmt.Sprintf("Get Task error: %v", err))
-				continue
-			}
-			// 设置超时时间
-			timeout := time.Second * 15
-			ctx, cancel := context.WithTimeout(context.Background(), timeout)
-			// 使用带有超时的 context 创建新的请求
-			req = req.WithContext(ctx)
-			req.Header.Set("Content-Type", "application/json")
-			req.Header.Set("mj-api-secret", midjourneyChannel.Key)
-			resp, err := service.GetHttpClient().Do(req)
-			if err != nil {
-				common.LogError(ctx, fmt.Sprintf("Get Task Do req error: %v", err))
-				continue
-			}
-			if resp.StatusCode != http.StatusOK {
-				common.LogError(ctx, fmt.Sprintf("Get Task status code: %d", resp.StatusCode))
-				continue
-			}
-			responseBody, err := io.ReadAll(resp.Body)
-			if err != nil {
-				common.LogError(ctx, fmt.Sprintf("Get Task parse body error: %v", err))
-				continue
-			}
-			var responseItems []dto.MidjourneyDto
-			err = json.Unmarshal(responseBody, &responseItems)
-			if err != nil {
-				common.LogError(ctx, fmt.Sprintf("Get Task parse body error2: %v, body: %s", err, string(responseBody)))
-				continue
-			}
-			resp.Body.Close()
-			req.Body.Close()
-			cancel()
+func updateMidjourneyChannelTasks(ctx context.Context, channelId int, taskIds []string, taskM map[string]*model.Midjourney) {
+	common.LogInfo(ctx, fmt.Sprintf("渠道 #%d 未完成的任务有: %d", channelId, len(taskIds)))
+	if len(taskIds) == 0 {
+		return
+	}
+	midjourneyChannel, err := model.CacheGetChannel(channelId)
+	if err != nil {
+		common.LogError(ctx, fmt.Sprintf("CacheGetChannel: %v", err))
+		err := model.MjBulkUpdate(taskIds, map[string]any{
+			"fail_reason": fmt.Sprintf("获取渠道信息失败，请联系管理员，渠道ID：%d", channelId),
+			"status":      "FAILURE",
+			"progress":    "100%",
+		})
+		if err != nil {
+			common.LogInfo(ctx, fmt.Sprintf("UpdateMidjourneyTask error: %v", err))
+		}
+		return
+	}
+	requestUrl := fmt.Sprintf("%s/mj/task/list-by-condition", *midjourneyChannel.BaseURL)
+
+	body, _ := json.Marshal(map[string]any{
+		"ids": taskIds,
+	})
+	req, err := http.NewRequest("POST", requestUrl, bytes.NewBuffer(body))
+	if err != nil {
+		common.LogError(ctx, fmt.Sprintf("Get Task error: %v", err))
+		return
+	}
+	// 设置超时时间
+	timeout := time.Second * 15
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	// 使用带有超时的 context 创建新的请求
+	req = req.WithContext(timeoutCtx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("mj-api-secret", midjourneyChannel.Key)
+	resp, err := service.GetHttpClient().Do(req)
+	if err != nil {
+		common.LogError(ctx, fmt.Sprintf("Get Task Do req error: %v", err))
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		common.LogError(ctx, fmt.Sprintf("Get Task status code: %d", resp.StatusCode))
+		return
+	}
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		common.LogError(ctx, fmt.Sprintf("Get Task parse body error: %v", err))
+		return
+	}
+	var responseItems []dto.MidjourneyDto
+	err = json.Unmarshal(responseBody, &responseItems)
+	if err != nil {
+		common.LogError(ctx, fmt.Sprintf("Get Task parse body error2: %v, body: %s", err, string(responseBody)))
+		return
+	}
+
+	for _, responseItem := range responseItems {
+		task := taskM[responseItem.MjId]
+		if task == nil {
+			continue
+		}
 
-			for _, responseItem := range responseItems {
-				task := taskM[responseItem.MjId]
+		useTime := (time.Now().UnixNano() / int64(time.Millisecond)) - task.SubmitTime
+		// 如果时间超过一小时，且进度不是100%，则认为任务失败
+		if useTime > 3600000 && task.Progress != "100%" {
+			responseItem.FailReason = "上游任务超时（超过1小时）"
+			responseItem.Status = "FAILURE"
+		}
+		applyMidjourneyTaskUpdate(ctx, task, responseItem)
+	}
+}
 
-				useTime := (time.Now().UnixNano() / int64(time.Millisecond)) - task.SubmitTime
-				// 如果时间超过一小时，且进度不是100%，则认为任务失败
-				if useTime > 3600000 && task.Progress != "100%" {
-					responseItem.FailReason = "上游任务超时（超过1小时）"
-					responseItem.Status = "FAILURE"
-				}
-				if !checkMjTaskNeedUpdate(task, responseItem) {
-					continue
-				}
-				task.Code = 1
-				task.Progress = responseItem.Progress
-				task.PromptEn = responseItem.PromptEn
-				task.State = responseItem.State
-				task.SubmitTime = responseItem.SubmitTime
-				task.StartTime = responseItem.StartTime
-				task.FinishTime = responseItem.FinishTime
-				task.ImageUrl = responseItem.ImageUrl
-				task.Status = responseItem.Status
-				task.FailReason = responseItem.FailReason
-				if responseItem.Properties != nil {
-					propertiesStr, _ := json.Marshal(responseItem.Properties)
-					task.Properties = string(propertiesStr)
-				}
-				if responseItem.Buttons != nil {
-					buttonStr, _ := json.Marshal(responseItem.Buttons)
-					task.Buttons = string(buttonStr)
-				}
-				shouldReturnQuota := false
-				if (task.Progress != "100%" && responseItem.FailReason != "") || (task.Progress == "100%" && task.Status == "FAILURE") {
-					common.LogInfo(ctx, task.MjId+" 构建失败，"+task.FailReason)
-					task.Progress = "100%"
-					if task.Quota != 0 {
-						shouldReturnQuota = true
-					}
-				}
-				err = task.Update()
-				if err != nil {
-					common.LogError(ctx, "UpdateMidjourneyTask task error: "+err.Error())
-				} else {
-					if shouldReturnQuota {
-						err = model.IncreaseUserQuota(task.UserId, task.Quota, false)
-						if err != nil {
-							common.LogError(ctx, "fail to increase user quota: "+err.Error())
-						}
-						logContent := fmt.Sprintf("构图失败 %s，补偿 %s", task.MjId, common.LogQuota(task.Quota))
-						model.RecordLog(task.UserId, model.LogTypeSystem, logContent)
-					}
-				}
+// applyMidjourneyTaskUpdate 将上游返回的任务状态写入任务，轮询与回调共用
+func applyMidjourneyTaskUpdate(ctx context.Context, task *model.Midjourney, responseItem dto.MidjourneyDto) {
+	if !checkMjTaskNeedUpdate(task, responseItem) {
+		return
+	}
+	oldStatus := task.Status
+	task.Code = 1
+	task.Progress = responseItem.Progress
+	task.PromptEn = responseItem.PromptEn
+	task.State = responseItem.State
+	task.SubmitTime = responseItem.SubmitTime
+	task.StartTime = responseItem.StartTime
+	task.FinishTime = responseItem.FinishTime
+	task.ImageUrl = responseItem.ImageUrl
+	task.Status = responseItem.Status
+	task.FailReason = responseItem.FailReason
+	if responseItem.Properties != nil {
+		propertiesStr, _ := json.Marshal(responseItem.Properties)
+		task.Properties = string(propertiesStr)
+	}
+	if responseItem.Buttons != nil {
+		buttonStr, _ := json.Marshal(responseItem.Buttons)
+		task.Buttons = string(buttonStr)
+	}
+	shouldReturnQuota := false
+	if (task.Progress != "100%" && responseItem.FailReason != "") || (task.Progress == "100%" && task.Status == "FAILURE") {
+		common.LogInfo(ctx, task.MjId+" 构建失败，"+task.FailReason)
+		task.Progress = "100%"
+		if task.Quota != 0 {
+			shouldReturnQuota = true
+		}
+	}
+	updated, err := task.UpdateWithStatus(oldStatus)
+	if err != nil {
+		common.LogError(ctx, "UpdateMidjourneyTask task error: "+err.Error())
+	} else if updated {
+		// 只有成功写入本次状态变化的一方退款，避免回调与轮询重复退款
+		if shouldReturnQuota && oldStatus != "FAILURE" {
+			err = model.IncreaseUserQuota(task.UserId, task.Quota, false)
+			if err != nil {
+				common.LogError(ctx, "fail to increase user quota: "+err.Error())
 			}
+			logContent := fmt.Sprintf("构图失败 %s，补偿 %s", task.MjId, common.LogQuota(task.Quota))
+			model.RecordLog(task.UserId, model.LogTypeSystem, logContent)
 		}
+		if task.Status == "SUCCESS" && oldStatus != "SUCCESS" && storeMidjourneyMediaAsync(ctx, task, oldStatus) {
+			return
+		}
+		notifyMidjourneyWebhook(task, oldStatus)
 	}
 }
 
@@ -231,6 +268,10 @@ func GetAllMidjourney(c *gin.Context) {
 			midjourney.ImageUrl = setting.ServerAddress + "/mj/image/" + midjourney.MjId
 			items[i] = midjourney
 		}
+	} else {
+		for _, midjourney := range items {
+			midjourney.ImageUrl = service.ResolveMediaUrl(midjourney.ImageUrl)
+		}
 	}
 	pageInfo.SetTotal(int(total))
 	pageInfo.SetItems(items)
@@ -256,6 +297,10 @@ func GetUserMidjourney(c *gin.Context) {
 			midjourney.ImageUrl = setting.ServerAddress + "/mj/image/" + midjourney.MjId
 			items[i] = midjourney
 		}
+	} else {
+		for _, midjourney := range items {
+			midjourney.ImageUrl = service.ResolveMediaUrl(midjourney.ImageUrl)
+		}
 	}
 	pageInfo.SetTotal(int(total))
 	pageInfo.SetItems(items)
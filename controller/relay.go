@@ -17,6 +17,7 @@ import (
 	relayconstant "one-api/relay/constant"
 	"one-api/relay/helper"
 	"one-api/service"
+	"one-api/setting/operation_setting"
 	"one-api/types"
 	"strings"
 
@@ -114,6 +115,17 @@ func Relay(c *gin.Context) {
 	}
 }
 
+// RelayModeration 审核后端为 chat 或 local 时由网关自行处理，否则按普通请求转发到渠道
+func RelayModeration(c *gin.Context) {
+	if !operation_setting.GetModerationSetting().UseLocalModeration() {
+		Relay(c)
+		return
+	}
+	if newAPIError := relay.ModerationHelper(c); newAPIError != nil {
+		abortWithOpenAIError(c, newAPIError)
+	}
+}
+
 var upgrader = websocket.Upgrader{
 	Subprotocols: []string{"realtime"}, // WS 握手支持的协议，如果有使用 Sec-WebSocket-Protocol，则必须在此声明对应的 Protocol TODO add other protocol
 	CheckOrigin: func(r *http.Request) bool {
@@ -134,6 +146,13 @@ func WssRelay(c *gin.Context) {
 
 	relayMode := relayconstant.Path2RelayMode(c.Request.URL.Path)
 	requestId := c.GetString(common.RequestIdKey)
+	if pipeline, ok := operation_setting.GetRealtimeBridgeSetting().GetPipeline(c.Query("model")); ok {
+		if newAPIError := relay.RealtimeBridgeHelper(c, ws, pipeline); newAPIError != nil {
+			newAPIError.SetMessage(common.MessageWithRequestId(newAPIError.Error(), requestId))
+			helper.WssError(c, ws, newAPIError.ToOpenAIError())
+		}
+		return
+	}
 	group := c.GetString("group")
 	//wss://api.openai.com/v1/realtime?model=gpt-4o-realtime-preview-2024-10-01
 	originalModel := c.GetString("original_model")
@@ -287,6 +306,10 @@ func shouldRetry(c *gin.Context, openaiErr *types.NewAPIError, retryTimes int) b
 	if _, ok := c.Get("specific_channel_id"); ok {
 		return false
 	}
+	// 引用文件或缓存的请求只能发往资源所在的渠道
+	if common.GetContextKeyInt(c, constant.ContextKeyChannelPinnedId) != 0 {
+		return false
+	}
 	if openaiErr.StatusCode == http.StatusTooManyRequests {
 		return true
 	}
@@ -428,7 +451,7 @@ func RelayTask(c *gin.Context) {
 func taskRelayHandler(c *gin.Context, relayMode int) *dto.TaskError {
 	var err *dto.TaskError
 	switch relayMode {
-	case relayconstant.RelayModeSunoFetch, relayconstant.RelayModeSunoFetchByID, relayconstant.RelayModeKlingFetchByID:
+	case relayconstant.RelayModeSunoFetch, relayconstant.RelayModeSunoFetchByID, relayconstant.RelayModeKlingFetchByID, relayconstant.RelayModeMusicFetchByID:
 		err = relay.RelayTaskFetch(c, relayMode)
 	default:
 		err = relay.RelayTaskSubmit(c, relayMode)
@@ -446,6 +469,10 @@ func shouldRetryTaskRelay(c *gin.Context, channelId int, taskErr *dto.TaskError,
 	if _, ok := c.Get("specific_channel_id"); ok {
 		return false
 	}
+	// 引用文件或缓存的请求只能发往资源所在的渠道
+	if common.GetContextKeyInt(c, constant.ContextKeyChannelPinnedId) != 0 {
+		return false
+	}
 	if taskErr.StatusCode == http.StatusTooManyRequests {
 		return true
 	}
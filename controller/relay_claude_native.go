@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/relay"
+	"one-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+func abortWithClaudeError(c *gin.Context, newAPIError *types.NewAPIError) {
+	newAPIError.SetMessage(common.MessageWithRequestId(newAPIError.Error(), c.GetString(common.RequestIdKey)))
+	c.JSON(newAPIError.StatusCode, gin.H{
+		"type":  "error",
+		"error": newAPIError.ToClaudeError(),
+	})
+}
+
+func RelayClaudeCountTokens(c *gin.Context) {
+	if newAPIError := relay.ClaudeCountTokensHelper(c); newAPIError != nil {
+		abortWithClaudeError(c, newAPIError)
+	}
+}
+
+// RelayClaudeBatch Anthropic Message Batches
+func RelayClaudeBatch(c *gin.Context) {
+	var newAPIError *types.NewAPIError
+	switch {
+	case c.Request.Method == http.MethodPost && c.Param("id") == "":
+		newAPIError = relay.ClaudeBatchSubmitHelper(c)
+	case c.Request.Method == http.MethodPost:
+		newAPIError = relay.ClaudeBatchCancelHelper(c)
+	case c.Request.Method == http.MethodDelete:
+		newAPIError = relay.ClaudeBatchDeleteHelper(c)
+	case c.Param("id") == "":
+		newAPIError = relay.ClaudeBatchListHelper(c)
+	case c.FullPath() == "/v1/messages/batches/:id/results":
+		newAPIError = relay.ClaudeBatchResultsHelper(c)
+	default:
+		newAPIError = relay.ClaudeBatchFetchHelper(c)
+	}
+	if newAPIError != nil {
+		abortWithClaudeError(c, newAPIError)
+	}
+}
+
+// RelayClaudeFiles Anthropic Files API，未携带 anthropic-version 请求头的 OpenAI 文件接口仍未实现
+func RelayClaudeFiles(c *gin.Context) {
+	if c.Request.Header.Get("anthropic-version") == "" {
+		RelayNotImplemented(c)
+		return
+	}
+	var newAPIError *types.NewAPIError
+	switch {
+	case c.Request.Method == http.MethodPost:
+		newAPIError = relay.ClaudeFileUploadHelper(c)
+	case c.Param("id") == "":
+		newAPIError = relay.ClaudeFileListHelper(c)
+	case c.FullPath() == "/v1/files/:id/content":
+		newAPIError = relay.ClaudeFilePassthroughHelper(c, "/content")
+	default:
+		newAPIError = relay.ClaudeFilePassthroughHelper(c, "")
+	}
+	if newAPIError != nil {
+		abortWithClaudeError(c, newAPIError)
+	}
+}
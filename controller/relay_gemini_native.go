@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"one-api/relay"
+	"one-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+func abortWithOpenAIError(c *gin.Context, newAPIError *types.NewAPIError) {
+	newAPIError.SetMessage(common.MessageWithRequestId(newAPIError.Error(), c.GetString(common.RequestIdKey)))
+	c.JSON(newAPIError.StatusCode, gin.H{
+		"error": newAPIError.ToOpenAIError(),
+	})
+}
+
+// RelayGeminiCachedContents Gemini 上下文缓存
+func RelayGeminiCachedContents(c *gin.Context) {
+	var newAPIError *types.NewAPIError
+	switch {
+	case c.Request.Method == http.MethodPost:
+		newAPIError = relay.GeminiCacheCreateHelper(c)
+	case c.Param("id") == "":
+		newAPIError = relay.GeminiResourceListHelper(c, model.UpstreamFilePlatformGeminiCache, "cachedContents")
+	default:
+		newAPIError = relay.GeminiCachePassthroughHelper(c)
+	}
+	if newAPIError != nil {
+		abortWithOpenAIError(c, newAPIError)
+	}
+}
+
+// RelayGeminiFiles Gemini Files API
+func RelayGeminiFiles(c *gin.Context) {
+	var newAPIError *types.NewAPIError
+	switch {
+	case c.FullPath() == "/upload/v1beta/files":
+		newAPIError = relay.GeminiFileUploadHelper(c)
+	case c.Param("id") == "":
+		newAPIError = relay.GeminiResourceListHelper(c, model.UpstreamFilePlatformGeminiFile, "files")
+	default:
+		newAPIError = relay.GeminiFilePassthroughHelper(c)
+	}
+	if newAPIError != nil {
+		abortWithOpenAIError(c, newAPIError)
+	}
+}
@@ -12,6 +12,8 @@ import (
 	"one-api/dto"
 	"one-api/model"
 	"one-api/relay"
+	"one-api/service"
+	"one-api/setting/operation_setting"
 	"sort"
 	"strconv"
 	"time"
@@ -20,53 +22,81 @@ import (
 	"github.com/samber/lo"
 )
 
+// 轮询调度的检查间隔，各平台实际的轮询间隔由 service.ShouldPollTasks 决定
+const taskPollTick = 5 * time.Second
+
 func UpdateTaskBulk() {
 	//revocer
 	//imageModel := "midjourney"
 	for {
-		time.Sleep(time.Duration(15) * time.Second)
-		common.SysLog("任务进度轮询开始")
+		time.Sleep(taskPollTick)
 		ctx := context.TODO()
-		allTasks := model.GetAllUnFinishSyncTasks(500)
-		platformTask := make(map[constant.TaskPlatform][]*model.Task)
-		for _, t := range allTasks {
-			platformTask[t.Platform] = append(platformTask[t.Platform], t)
-		}
-		for platform, tasks := range platformTask {
-			if len(tasks) == 0 {
+		for _, platform := range model.GetUnFinishSyncTaskPlatforms() {
+			poll, cursor := service.ShouldPollTasks(string(platform))
+			if !poll {
 				continue
 			}
-			taskChannelM := make(map[int][]string)
-			taskM := make(map[string]*model.Task)
-			nullTaskIds := make([]int64, 0)
-			for _, task := range tasks {
-				if task.TaskID == "" {
-					// 统计失败的未完成任务
-					nullTaskIds = append(nullTaskIds, task.ID)
-					continue
-				}
-				taskM[task.TaskID] = task
-				taskChannelM[task.ChannelId] = append(taskChannelM[task.ChannelId], task.TaskID)
-			}
-			if len(nullTaskIds) > 0 {
-				err := model.TaskBulkUpdateByID(nullTaskIds, map[string]any{
-					"status":   "FAILURE",
-					"progress": "100%",
-				})
-				if err != nil {
-					common.LogError(ctx, fmt.Sprintf("Fix null task_id task error: %v", err))
-				} else {
-					common.LogInfo(ctx, fmt.Sprintf("Fix null task_id task success: %v", nullTaskIds))
-				}
-			}
-			if len(taskChannelM) == 0 {
-				continue
+			batchSize := operation_setting.GetTaskUpdateSetting().GetBatchSize(string(platform))
+			tasks := model.GetUnFinishSyncTasksAfter(platform, cursor, batchSize)
+			// 不足一批时下一轮从头开始
+			nextCursor := int64(0)
+			if len(tasks) == batchSize {
+				nextCursor = tasks[len(tasks)-1].ID
 			}
+			common.SysLog(fmt.Sprintf("任务进度轮询开始，平台 %s，任务数 %d", platform, len(tasks)))
+			changed := updatePlatformTasks(ctx, platform, tasks)
+			service.FinishTaskPoll(string(platform), changed, nextCursor)
+		}
+	}
+}
 
-			UpdateTaskByPlatform(platform, taskChannelM, taskM)
+// updatePlatformTasks 轮询一批任务的进度，返回是否有任务状态或进度发生变化
+func updatePlatformTasks(ctx context.Context, platform constant.TaskPlatform, tasks []*model.Task) bool {
+	if len(tasks) == 0 {
+		return false
+	}
+	pending := failTimeoutTasks(ctx, string(platform), tasks)
+	timeoutChanged := len(pending) < len(tasks)
+	tasks = pending
+	taskChannelM := make(map[int][]string)
+	taskM := make(map[string]*model.Task)
+	nullTaskIds := make([]int64, 0)
+	snapshots := make(map[*model.Task]string, len(tasks))
+	for _, task := range tasks {
+		if task.TaskID == "" {
+			// 统计失败的未完成任务
+			nullTaskIds = append(nullTaskIds, task.ID)
+			continue
 		}
-		common.SysLog("任务进度轮询完成")
+		taskM[task.TaskID] = task
+		taskChannelM[task.ChannelId] = append(taskChannelM[task.ChannelId], task.TaskID)
+		snapshots[task] = string(task.Status) + task.Progress
 	}
+	if len(nullTaskIds) > 0 {
+		err := model.TaskBulkUpdateByID(nullTaskIds, map[string]any{
+			"status":   "FAILURE",
+			"progress": "100%",
+		})
+		if err != nil {
+			common.LogError(ctx, fmt.Sprintf("Fix null task_id task error: %v", err))
+		} else {
+			common.LogInfo(ctx, fmt.Sprintf("Fix null task_id task success: %v", nullTaskIds))
+		}
+	}
+	if len(taskChannelM) == 0 {
+		return timeoutChanged || len(nullTaskIds) > 0
+	}
+
+	UpdateTaskByPlatform(platform, taskChannelM, taskM)
+	if timeoutChanged {
+		return true
+	}
+	for task, snapshot := range snapshots {
+		if string(task.Status)+task.Progress != snapshot {
+			return true
+		}
+	}
+	return false
 }
 
 func UpdateTaskByPlatform(platform constant.TaskPlatform, taskChannelM map[int][]string, taskM map[string]*model.Task) {
@@ -77,6 +107,8 @@ func UpdateTaskByPlatform(platform constant.TaskPlatform, taskChannelM map[int][
 		_ = UpdateSunoTaskAll(context.Background(), taskChannelM, taskM)
 	case constant.TaskPlatformKling, constant.TaskPlatformJimeng:
 		_ = UpdateVideoTaskAll(context.Background(), platform, taskChannelM, taskM)
+	case constant.TaskPlatformClaudeBatch:
+		_ = UpdateClaudeBatchTaskAll(context.Background(), taskChannelM, taskM)
 	default:
 		common.SysLog("未知平台")
 	}
@@ -144,44 +176,64 @@ func updateSunoTaskAll(ctx context.Context, channelId int, taskIds []string, tas
 
 	for _, responseItem := range responseItems.Data {
 		task := taskM[responseItem.TaskID]
-		if !checkTaskNeedUpdate(task, responseItem) {
+		if task == nil {
 			continue
 		}
+		applySunoTaskUpdate(ctx, task, responseItem)
+	}
+	return nil
+}
 
-		task.Status = lo.If(model.TaskStatus(responseItem.Status) != "", model.TaskStatus(responseItem.Status)).Else(task.Status)
-		task.FailReason = lo.If(responseItem.FailReason != "", responseItem.FailReason).Else(task.FailReason)
-		task.SubmitTime = lo.If(responseItem.SubmitTime != 0, responseItem.SubmitTime).Else(task.SubmitTime)
-		task.StartTime = lo.If(responseItem.StartTime != 0, responseItem.StartTime).Else(task.StartTime)
-		task.FinishTime = lo.If(responseItem.FinishTime != 0, responseItem.FinishTime).Else(task.FinishTime)
-		if responseItem.FailReason != "" || task.Status == model.TaskStatusFailure {
-			common.LogInfo(ctx, task.TaskID+" 构建失败，"+task.FailReason)
-			task.Progress = "100%"
-			//err = model.CacheUpdateUserQuota(task.UserId) ?
-			if err != nil {
-				common.LogError(ctx, "error update user quota cache: "+err.Error())
-			} else {
-				quota := task.Quota
-				if quota != 0 {
-					err = model.IncreaseUserQuota(task.UserId, quota, false)
-					if err != nil {
-						common.LogError(ctx, "fail to increase user quota: "+err.Error())
-					}
-					logContent := fmt.Sprintf("异步任务执行失败 %s，补偿 %s", task.TaskID, common.LogQuota(quota))
-					model.RecordLog(task.UserId, model.LogTypeSystem, logContent)
-				}
-			}
-		}
-		if responseItem.Status == model.TaskStatusSuccess {
-			task.Progress = "100%"
-		}
-		task.Data = responseItem.Data
+// applySunoTaskUpdate 将上游返回的 suno 任务状态写入任务，轮询与回调共用
+func applySunoTaskUpdate(ctx context.Context, task *model.Task, responseItem dto.SunoDataResponse) {
+	if !checkTaskNeedUpdate(task, responseItem) {
+		return
+	}
+	oldStatus := task.Status
 
-		err = task.Update()
-		if err != nil {
-			common.SysError("UpdateMidjourneyTask task error: " + err.Error())
+	task.Status = lo.If(model.TaskStatus(responseItem.Status) != "", model.TaskStatus(responseItem.Status)).Else(task.Status)
+	task.FailReason = lo.If(responseItem.FailReason != "", responseItem.FailReason).Else(task.FailReason)
+	task.SubmitTime = lo.If(responseItem.SubmitTime != 0, responseItem.SubmitTime).Else(task.SubmitTime)
+	task.StartTime = lo.If(responseItem.StartTime != 0, responseItem.StartTime).Else(task.StartTime)
+	task.FinishTime = lo.If(responseItem.FinishTime != 0, responseItem.FinishTime).Else(task.FinishTime)
+	failed := responseItem.FailReason != "" || task.Status == model.TaskStatusFailure
+	if failed {
+		common.LogInfo(ctx, task.TaskID+" 构建失败，"+task.FailReason)
+		task.Status = model.TaskStatusFailure
+		task.Progress = "100%"
+	}
+	task.Data = responseItem.Data
+	if responseItem.Status == model.TaskStatusSuccess {
+		task.Progress = "100%"
+	}
+
+	updated, err := task.UpdateWithStatus(oldStatus)
+	if err != nil {
+		common.SysError("UpdateMidjourneyTask task error: " + err.Error())
+		return
+	}
+	if !updated || isTaskFinished(string(oldStatus)) {
+		// 状态已被回调或轮询的另一方更新，或任务此前已结束
+		return
+	}
+	if failed && handleTaskFailure(ctx, task) {
+		return
+	}
+	if task.Status == model.TaskStatusSuccess {
+		service.SettleTaskClipQuota(ctx, task, service.CountSunoClips(task.Data))
+		if storeSunoTaskMediaAsync(ctx, task, oldStatus) {
+			return
 		}
 	}
-	return nil
+	notifyTaskWebhook(task, oldStatus)
+}
+
+// 需要保存到网关的 suno 生成结果字段
+var sunoMediaFields = map[string]string{
+	"audio_url":       "audio",
+	"video_url":       "video",
+	"image_url":       "image",
+	"image_large_url": "image",
 }
 
 func checkTaskNeedUpdate(oldTask *model.Task, newTask dto.SunoDataResponse) bool {
@@ -242,6 +294,7 @@ func GetAllTask(c *gin.Context) {
 	}
 
 	items := model.TaskGetAllTasks(pageInfo.GetStartIdx(), pageInfo.GetPageSize(), queryParams)
+	resolveTaskMediaUrls(items)
 	total := model.TaskCountAllTasks(queryParams)
 	pageInfo.SetTotal(int(total))
 	pageInfo.SetItems(items)
@@ -266,8 +319,17 @@ func GetUserTask(c *gin.Context) {
 	}
 
 	items := model.TaskGetAllUserTask(userId, pageInfo.GetStartIdx(), pageInfo.GetPageSize(), queryParams)
+	resolveTaskMediaUrls(items)
 	total := model.TaskCountAllUserTask(userId, queryParams)
 	pageInfo.SetTotal(int(total))
 	pageInfo.SetItems(items)
 	common.ApiSuccess(c, pageInfo)
 }
+
+// resolveTaskMediaUrls 将任务中保存到网关的生成结果替换为签名链接
+func resolveTaskMediaUrls(tasks []*model.Task) {
+	for _, task := range tasks {
+		task.FailReason = service.ResolveMediaUrl(task.FailReason)
+		task.Data = service.ResolveMediaRefs(task.Data)
+	}
+}
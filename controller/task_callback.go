@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/relay"
+	"one-api/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TaskCallback 接收上游推送的任务状态并立即更新任务，轮询作为兜底
+func TaskCallback(c *gin.Context) {
+	platform := c.Param("platform")
+	channelId, err := strconv.Atoi(c.Param("channel_id"))
+	if err != nil || !service.IsTaskCallbackEnabled(platform) ||
+		!service.VerifyTaskCallbackSignature(platform, channelId, c.Param("nonce"), c.Param("signature")) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": "invalid callback signature",
+		})
+		return
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	nonce := c.Param("nonce")
+	switch platform {
+	case constant.TaskPlatformMidjourney:
+		err = handleMidjourneyCallback(c, channelId, nonce, body)
+	case string(constant.TaskPlatformSuno):
+		err = handleSunoCallback(c, channelId, nonce, body)
+	case string(constant.TaskPlatformKling):
+		err = handleVideoCallback(c, constant.TaskPlatformKling, channelId, nonce, body)
+	}
+	if err != nil {
+		common.LogError(c, fmt.Sprintf("task callback from %s channel #%d failed: %s", platform, channelId, err.Error()))
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func handleMidjourneyCallback(c *gin.Context, channelId int, nonce string, body []byte) error {
+	var item dto.MidjourneyDto
+	if err := json.Unmarshal(body, &item); err != nil {
+		return err
+	}
+	task := model.GetByOnlyMJId(item.MjId)
+	if task == nil || task.ChannelId != channelId || task.CallbackNonce != nonce {
+		return errors.New("task not found")
+	}
+	applyMidjourneyTaskUpdate(c, task, item)
+	return nil
+}
+
+func handleSunoCallback(c *gin.Context, channelId int, nonce string, body []byte) error {
+	var items []dto.SunoDataResponse
+	var response dto.TaskResponse[[]dto.SunoDataResponse]
+	if err := json.Unmarshal(body, &response); err == nil && len(response.Data) > 0 {
+		items = response.Data
+	} else {
+		// 兼容直接推送单个任务的格式
+		var single dto.TaskResponse[dto.SunoDataResponse]
+		if err := json.Unmarshal(body, &single); err == nil && single.Data.TaskID != "" {
+			items = append(items, single.Data)
+		} else {
+			var item dto.SunoDataResponse
+			if err := json.Unmarshal(body, &item); err != nil {
+				return err
+			}
+			items = append(items, item)
+		}
+	}
+	for _, item := range items {
+		task, exist, err := model.GetByOnlyTaskId(item.TaskID)
+		if err != nil {
+			return err
+		}
+		if !exist || task.ChannelId != channelId || task.Platform != constant.TaskPlatformSuno || task.CallbackNonce != nonce {
+			return fmt.Errorf("task %s not found", item.TaskID)
+		}
+		applySunoTaskUpdate(c, task, item)
+	}
+	return nil
+}
+
+func handleVideoCallback(c *gin.Context, platform constant.TaskPlatform, channelId int, nonce string, body []byte) error {
+	adaptor := relay.GetTaskAdaptor(platform)
+	if adaptor == nil {
+		return errors.New("adaptor not found")
+	}
+	// 回调只推送任务对象时按查询接口的格式包装
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return err
+	}
+	if _, ok := payload["data"]; !ok {
+		wrapped, err := json.Marshal(map[string]any{
+			"code": 0,
+			"data": json.RawMessage(body),
+		})
+		if err != nil {
+			return err
+		}
+		body = wrapped
+	}
+	taskResult, err := adaptor.ParseTaskResult(body)
+	if err != nil {
+		return err
+	}
+	task, exist, err := model.GetByOnlyTaskId(taskResult.TaskID)
+	if err != nil {
+		return err
+	}
+	if !exist || task.ChannelId != channelId || task.Platform != platform || task.CallbackNonce != nonce {
+		return fmt.Errorf("task %s not found", taskResult.TaskID)
+	}
+	return applyVideoTaskResult(c, task, taskResult, body)
+}
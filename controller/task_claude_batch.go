@@ -0,0 +1,29 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"one-api/common"
+	"one-api/model"
+	"one-api/relay"
+)
+
+func UpdateClaudeBatchTaskAll(ctx context.Context, taskChannelM map[int][]string, taskM map[string]*model.Task) error {
+	for channelId, taskIds := range taskChannelM {
+		channel, err := model.CacheGetChannel(channelId)
+		if err != nil {
+			common.LogError(ctx, fmt.Sprintf("渠道 #%d 获取失败，跳过 Claude 批处理轮询: %s", channelId, err.Error()))
+			continue
+		}
+		for _, taskId := range taskIds {
+			task := taskM[taskId]
+			if task == nil {
+				continue
+			}
+			if _, err = relay.FetchClaudeBatch(ctx, nil, channel, task); err != nil {
+				common.LogError(ctx, fmt.Sprintf("Claude 批处理 %s 更新失败: %s", taskId, err.Error()))
+			}
+		}
+	}
+	return nil
+}
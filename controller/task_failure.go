@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"one-api/common"
+	"one-api/model"
+	"one-api/relay"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"time"
+)
+
+// handleTaskFailure 按任务失败策略处理失败任务：上游临时错误时重新提交到其他渠道，否则按失败类型退还额度。
+// 调用方需先通过 UpdateWithStatus 写入失败状态，只有写入成功的一方处理，避免重复退款。
+// 返回 true 表示任务已重新提交，调用方不应再发送失败通知
+func handleTaskFailure(ctx context.Context, task *model.Task) bool {
+	failureSetting := operation_setting.GetTaskFailureSetting()
+	failureClass := service.ClassifyTaskFailure(task.FailReason)
+	if failureClass == operation_setting.TaskFailureClassRetryable && failureSetting.RetryEnabled &&
+		task.Properties.RetryCount < failureSetting.MaxRetries {
+		failedChannelId := task.ChannelId
+		failReason := task.FailReason
+		if err := relay.ResubmitTask(ctx, task); err != nil {
+			common.LogError(ctx, fmt.Sprintf("resubmit task %s failed: %s", task.GetClientTaskID(), err.Error()))
+		} else {
+			logContent := fmt.Sprintf("异步任务 %s 在渠道 #%d 失败（%s），已重新提交到渠道 #%d", task.GetClientTaskID(), failedChannelId, failReason, task.ChannelId)
+			model.RecordLog(task.UserId, model.LogTypeSystem, logContent)
+			return true
+		}
+	}
+	service.RefundTaskQuota(ctx, task, failureClass)
+	return false
+}
+
+// failTimeoutTasks 将超过期限仍未完成的任务判定为失败并退还额度，返回未超时的任务
+func failTimeoutTasks(ctx context.Context, platform string, tasks []*model.Task) []*model.Task {
+	timeoutSeconds := operation_setting.GetTaskFailureSetting().GetTimeoutSeconds(platform)
+	if timeoutSeconds == 0 {
+		return tasks
+	}
+	now := time.Now().Unix()
+	pending := tasks[:0]
+	for _, task := range tasks {
+		if task.CreatedAt == 0 || now-task.CreatedAt < timeoutSeconds || task.TaskID == "" {
+			pending = append(pending, task)
+			continue
+		}
+		oldStatus := task.Status
+		task.Status = model.TaskStatusFailure
+		task.Progress = "100%"
+		task.FinishTime = now
+		task.FailReason = fmt.Sprintf("任务超时（超过 %d 分钟未完成）", timeoutSeconds/60)
+		common.LogInfo(ctx, fmt.Sprintf("Task %s timed out", task.TaskID))
+		// 先按原状态写入失败，写入成功的一方才退款，避免与回调或轮询重复处理
+		updated, err := task.UpdateWithStatus(oldStatus)
+		if err != nil {
+			common.SysError("update timeout task error: " + err.Error())
+			continue
+		}
+		if !updated {
+			continue
+		}
+		service.RefundTaskQuota(ctx, task, operation_setting.TaskFailureClassTimeout)
+		notifyTaskWebhook(task, oldStatus)
+	}
+	return pending
+}
@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupTaskFailureTest 准备内存数据库与一个用户、令牌，返回用户 id 与令牌 id
+func setupTaskFailureTest(t *testing.T) (int, int) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite failed: %s", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	if err = db.AutoMigrate(&model.User{}, &model.Token{}, &model.Log{}, &model.Task{}); err != nil {
+		t.Fatalf("migrate failed: %s", err)
+	}
+	oldDB, oldLogDB, oldRedisEnabled := model.DB, model.LOG_DB, common.RedisEnabled
+	failureSetting := operation_setting.GetTaskFailureSetting()
+	oldSetting := *failureSetting
+	model.DB, model.LOG_DB, common.RedisEnabled = db, db, false
+	t.Cleanup(func() {
+		model.DB, model.LOG_DB, common.RedisEnabled = oldDB, oldLogDB, oldRedisEnabled
+		*failureSetting = oldSetting
+	})
+
+	user := &model.User{Username: "task_user", Password: "password", Quota: 1000, Status: 1}
+	if err = db.Create(user).Error; err != nil {
+		t.Fatalf("create user failed: %s", err)
+	}
+	token := &model.Token{UserId: user.Id, Key: "task-token", Name: "task", RemainQuota: 1000, Status: 1}
+	if err = db.Create(token).Error; err != nil {
+		t.Fatalf("create token failed: %s", err)
+	}
+	return user.Id, token.Id
+}
+
+func getTaskFailureQuotas(t *testing.T, userId int, tokenId int) (int, int) {
+	t.Helper()
+	var user model.User
+	var token model.Token
+	model.DB.First(&user, userId)
+	model.DB.First(&token, tokenId)
+	return user.Quota, token.RemainQuota
+}
+
+func TestFailTimeoutTasks(t *testing.T) {
+	userId, tokenId := setupTaskFailureTest(t)
+	failureSetting := operation_setting.GetTaskFailureSetting()
+	failureSetting.TimeoutMinutes = 60
+	failureSetting.RefundRatios = map[string]float64{operation_setting.TaskFailureClassTimeout: 0.5}
+
+	now := time.Now().Unix()
+	newTask := func(taskId string, createdAt int64) *model.Task {
+		task := &model.Task{
+			TaskID:     taskId,
+			UserId:     userId,
+			Status:     model.TaskStatusInProgress,
+			Quota:      300,
+			CreatedAt:  createdAt,
+			Properties: model.Properties{TokenId: tokenId},
+		}
+		if err := task.Insert(); err != nil {
+			t.Fatalf("insert task failed: %s", err)
+		}
+		return task
+	}
+	expired := newTask("expired", now-2*3600)
+	recent := newTask("recent", now-60)
+	unsubmitted := newTask("", now-2*3600)
+	// 轮询开始前读到的同一个任务，此时已被其他请求判定超时
+	stale := *expired
+
+	pending := failTimeoutTasks(context.Background(), "suno", []*model.Task{expired, recent, unsubmitted})
+	if len(pending) != 2 || pending[0] != recent || pending[1] != unsubmitted {
+		t.Fatalf("unexpected pending tasks: %+v", pending)
+	}
+	var stored model.Task
+	model.DB.First(&stored, expired.ID)
+	if stored.Status != model.TaskStatusFailure || stored.FailReason == "" {
+		t.Errorf("expired task status %s reason %q", stored.Status, stored.FailReason)
+	}
+	if userQuota, tokenQuota := getTaskFailureQuotas(t, userId, tokenId); userQuota != 1150 || tokenQuota != 1150 {
+		t.Errorf("after refund user quota %d token quota %d, want 1150", userQuota, tokenQuota)
+	}
+
+	// 过期的副本不会再次退款
+	failTimeoutTasks(context.Background(), "suno", []*model.Task{&stale})
+	if userQuota, tokenQuota := getTaskFailureQuotas(t, userId, tokenId); userQuota != 1150 || tokenQuota != 1150 {
+		t.Errorf("stale copy refunded again: user quota %d token quota %d", userQuota, tokenQuota)
+	}
+
+	// 平台超时为 0 时不判定超时
+	failureSetting.PlatformTimeoutMinutes = map[string]int{"suno": 0}
+	if pending := failTimeoutTasks(context.Background(), "suno", []*model.Task{recent}); len(pending) != 1 {
+		t.Errorf("disabled platform timeout should keep tasks pending")
+	}
+}
+
+func TestHandleTaskFailureRefund(t *testing.T) {
+	userId, tokenId := setupTaskFailureTest(t)
+	failureSetting := operation_setting.GetTaskFailureSetting()
+	failureSetting.RetryEnabled = false
+	failureSetting.RefundRatios = map[string]float64{
+		operation_setting.TaskFailureClassContent:   0,
+		operation_setting.TaskFailureClassRetryable: 1,
+		operation_setting.TaskFailureClassOther:     0.5,
+	}
+
+	cases := []struct {
+		reason     string
+		wantRefund int
+	}{
+		{"prompt rejected by content policy", 0},
+		{"upstream service busy", 200},
+		{"unknown error", 100},
+	}
+	wantQuota := 1000
+	for _, c := range cases {
+		task := &model.Task{
+			TaskID:     "task_" + c.reason,
+			UserId:     userId,
+			Status:     model.TaskStatusFailure,
+			FailReason: c.reason,
+			Quota:      200,
+			Properties: model.Properties{TokenId: tokenId},
+		}
+		if resubmitted := handleTaskFailure(context.Background(), task); resubmitted {
+			t.Errorf("%s: resubmitted while retry is disabled", c.reason)
+		}
+		wantQuota += c.wantRefund
+		if userQuota, tokenQuota := getTaskFailureQuotas(t, userId, tokenId); userQuota != wantQuota || tokenQuota != wantQuota {
+			t.Errorf("%s: user quota %d token quota %d, want %d", c.reason, userQuota, tokenQuota, wantQuota)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"one-api/common"
+	"one-api/model"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+// 任务生成结果的媒体在后台保存，下载与上传大文件不阻塞其他任务的轮询与回调；
+// 保存完成后只回写结果字段，再发送完成通知，使 webhook 中的链接为网关地址
+
+func storeVideoTaskMediaAsync(ctx context.Context, task *model.Task, oldStatus model.TaskStatus) bool {
+	mediaSetting := operation_setting.GetMediaStorageSetting()
+	if task.Properties.VideoResult == nil || !(mediaSetting.IsEnabledFor("video") || mediaSetting.IsEnabledFor("image")) {
+		return false
+	}
+	ctx = context.WithoutCancel(ctx)
+	gopool.Go(func() {
+		videoResult := task.Properties.VideoResult
+		if ref, err := service.StoreMediaFromUrl(ctx, task.UserId, "video", videoResult.Url); err != nil {
+			common.LogError(ctx, fmt.Sprintf("Failed to store video of task %s: %s", task.TaskID, err.Error()))
+		} else {
+			videoResult.Url = ref
+			task.FailReason = ref
+		}
+		if videoResult.CoverUrl != "" {
+			if ref, err := service.StoreMediaFromUrl(ctx, task.UserId, "image", videoResult.CoverUrl); err != nil {
+				common.LogError(ctx, fmt.Sprintf("Failed to store cover of task %s: %s", task.TaskID, err.Error()))
+			} else {
+				videoResult.CoverUrl = ref
+			}
+		}
+		if err := task.UpdateResult(); err != nil {
+			common.LogError(ctx, fmt.Sprintf("Failed to update media of task %s: %s", task.TaskID, err.Error()))
+		}
+		notifyTaskWebhook(task, oldStatus)
+	})
+	return true
+}
+
+func storeSunoTaskMediaAsync(ctx context.Context, task *model.Task, oldStatus model.TaskStatus) bool {
+	if !operation_setting.GetMediaStorageSetting().Enabled {
+		return false
+	}
+	ctx = context.WithoutCancel(ctx)
+	gopool.Go(func() {
+		task.Data = service.StoreMediaInJSON(ctx, task.UserId, task.Data, sunoMediaFields)
+		if err := task.UpdateResult(); err != nil {
+			common.LogError(ctx, fmt.Sprintf("Failed to update media of task %s: %s", task.TaskID, err.Error()))
+		}
+		notifyTaskWebhook(task, oldStatus)
+	})
+	return true
+}
+
+func storeMidjourneyMediaAsync(ctx context.Context, task *model.Midjourney, oldStatus string) bool {
+	if !operation_setting.GetMediaStorageSetting().IsEnabledFor("image") || task.ImageUrl == "" {
+		return false
+	}
+	ctx = context.WithoutCancel(ctx)
+	gopool.Go(func() {
+		ref, err := service.StoreMediaFromUrl(ctx, task.UserId, "image", task.ImageUrl)
+		if err != nil {
+			common.LogError(ctx, fmt.Sprintf("store midjourney image %s failed: %s", task.MjId, err.Error()))
+		} else {
+			task.ImageUrl = ref
+			if err = task.UpdateImageUrl(); err != nil {
+				common.LogError(ctx, fmt.Sprintf("update midjourney image %s failed: %s", task.MjId, err.Error()))
+			}
+		}
+		notifyMidjourneyWebhook(task, oldStatus)
+	})
+	return true
+}
@@ -6,9 +6,11 @@ import (
 	"io"
 	"one-api/common"
 	"one-api/constant"
+	"one-api/dto"
 	"one-api/model"
 	"one-api/relay"
 	"one-api/relay/channel"
+	relaycommon "one-api/relay/common"
 	"time"
 )
 
@@ -85,6 +87,13 @@ func updateVideoSingleTask(ctx context.Context, adaptor channel.TaskAdaptor, cha
 	//	return fmt.Errorf("video task fetch failed for task %s", taskId)
 	//}
 
+	return applyVideoTaskResult(ctx, task, taskResult, responseBody)
+}
+
+// applyVideoTaskResult 将解析后的视频任务结果写入任务，轮询与回调共用
+func applyVideoTaskResult(ctx context.Context, task *model.Task, taskResult *relaycommon.TaskInfo, responseBody []byte) error {
+	taskId := task.TaskID
+	oldStatus := task.Status
 	now := time.Now().Unix()
 	if taskResult.Status == "" {
 		return fmt.Errorf("task %s status is empty", taskId)
@@ -106,6 +115,11 @@ func updateVideoSingleTask(ctx context.Context, adaptor channel.TaskAdaptor, cha
 			task.FinishTime = now
 		}
 		task.FailReason = taskResult.Url
+		task.Properties.VideoResult = &dto.VideoTaskResult{
+			Url:      taskResult.Url,
+			CoverUrl: taskResult.CoverUrl,
+			Duration: taskResult.Duration,
+		}
 	case model.TaskStatusFailure:
 		task.Status = model.TaskStatusFailure
 		task.Progress = "100%"
@@ -114,14 +128,6 @@ func updateVideoSingleTask(ctx context.Context, adaptor channel.TaskAdaptor, cha
 		}
 		task.FailReason = taskResult.Reason
 		common.LogInfo(ctx, fmt.Sprintf("Task %s failed: %s", task.TaskID, task.FailReason))
-		quota := task.Quota
-		if quota != 0 {
-			if err := model.IncreaseUserQuota(task.UserId, quota, false); err != nil {
-				common.LogError(ctx, "Failed to increase user quota: "+err.Error())
-			}
-			logContent := fmt.Sprintf("Video async task failed %s, refund %s", task.TaskID, common.LogQuota(quota))
-			model.RecordLog(task.UserId, model.LogTypeSystem, logContent)
-		}
 	default:
 		return fmt.Errorf("unknown task status %s for task %s", taskResult.Status, taskId)
 	}
@@ -130,9 +136,22 @@ func updateVideoSingleTask(ctx context.Context, adaptor channel.TaskAdaptor, cha
 	}
 
 	task.Data = responseBody
-	if err := task.Update(); err != nil {
+	updated, err := task.UpdateWithStatus(oldStatus)
+	if err != nil {
 		common.SysError("UpdateVideoTask task error: " + err.Error())
+		return nil
+	}
+	if !updated || isTaskFinished(string(oldStatus)) {
+		// 状态已被回调或轮询的另一方更新，或任务此前已结束
+		return nil
+	}
+	if task.Status == model.TaskStatusFailure && handleTaskFailure(ctx, task) {
+		return nil
+	}
+	if task.Status == model.TaskStatusSuccess && storeVideoTaskMediaAsync(ctx, task, oldStatus) {
+		return nil
 	}
+	notifyTaskWebhook(task, oldStatus)
 
 	return nil
 }
@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	"one-api/relay"
+	"one-api/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func isTaskFinished(status string) bool {
+	return status == model.TaskStatusSuccess || status == model.TaskStatusFailure
+}
+
+// notifyTaskWebhook 任务进入 SUCCESS 或 FAILURE 时通知客户端
+func notifyTaskWebhook(task *model.Task, oldStatus model.TaskStatus) {
+	if task.CallbackUrl == "" || isTaskFinished(string(oldStatus)) || !isTaskFinished(string(task.Status)) {
+		return
+	}
+	service.EnqueueTaskWebhook(task.UserId, string(task.Platform), task.GetClientTaskID(), task.CallbackUrl, string(task.Status), relay.TaskModel2Dto(task))
+}
+
+func notifyMidjourneyWebhook(task *model.Midjourney, oldStatus string) {
+	if task.CallbackUrl == "" || isTaskFinished(oldStatus) || !isTaskFinished(task.Status) {
+		return
+	}
+	service.EnqueueTaskWebhook(task.UserId, constant.TaskPlatformMidjourney, task.MjId, task.CallbackUrl, task.Status, relay.MidjourneyModel2Dto(task))
+}
+
+func GetTaskWebhookDeliveries(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	queryParams := model.TaskWebhookQueryParams{
+		UserId: userId,
+		TaskId: c.Query("task_id"),
+		Status: c.Query("status"),
+	}
+	items, total, err := model.GetTaskWebhookDeliveries(pageInfo.GetStartIdx(), pageInfo.GetPageSize(), queryParams)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(items)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func RedeliverTaskWebhook(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	delivery, err := service.RedeliverTaskWebhook(id)
+	if delivery == nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err != nil {
+		common.ApiErrorMsg(c, "重新投递失败: "+err.Error())
+		return
+	}
+	common.ApiSuccess(c, delivery)
+}
@@ -1,9 +1,12 @@
 package controller
 
 import (
+	"context"
 	"net/http"
 	"one-api/common"
 	"one-api/model"
+	"one-api/relay"
+	"one-api/service"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -96,6 +99,10 @@ func AddToken(c *gin.Context) {
 		})
 		return
 	}
+	if err := service.ValidateTaskWebhookUrl(c.GetInt("id"), token.CallbackUrl); err != nil {
+		common.ApiError(c, err)
+		return
+	}
 	key, err := common.GenerateKey()
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -118,6 +125,7 @@ func AddToken(c *gin.Context) {
 		ModelLimits:        token.ModelLimits,
 		AllowIps:           token.AllowIps,
 		Group:              token.Group,
+		CallbackUrl:        token.CallbackUrl,
 	}
 	err = cleanToken.Insert()
 	if err != nil {
@@ -139,6 +147,8 @@ func DeleteToken(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	// 清理令牌在上游创建的 Gemini 缓存与文件
+	go relay.CleanupTokenGeminiResources(context.Background(), userId, []int{id})
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -162,6 +172,10 @@ func UpdateToken(c *gin.Context) {
 		})
 		return
 	}
+	if err := service.ValidateTaskWebhookUrl(userId, token.CallbackUrl); err != nil {
+		common.ApiError(c, err)
+		return
+	}
 	cleanToken, err := model.GetTokenByIds(token.Id, userId)
 	if err != nil {
 		common.ApiError(c, err)
@@ -195,6 +209,7 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.ModelLimits = token.ModelLimits
 		cleanToken.AllowIps = token.AllowIps
 		cleanToken.Group = token.Group
+		cleanToken.CallbackUrl = token.CallbackUrl
 	}
 	err = cleanToken.Update()
 	if err != nil {
@@ -228,6 +243,7 @@ func DeleteTokenBatch(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	go relay.CleanupTokenGeminiResources(context.Background(), userId, tokenBatch.Ids)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -853,6 +853,7 @@ type UpdateUserSettingRequest struct {
 	NotificationEmail          string  `json:"notification_email,omitempty"`
 	AcceptUnsetModelRatioModel bool    `json:"accept_unset_model_ratio_model"`
 	RecordIpLog                bool    `json:"record_ip_log"`
+	MediaRetentionDays         int     `json:"media_retention_days"`
 }
 
 func UpdateUserSetting(c *gin.Context) {
@@ -902,6 +903,14 @@ func UpdateUserSetting(c *gin.Context) {
 		}
 	}
 
+	if req.MediaRetentionDays < 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "生成结果保存天数不能为负数",
+		})
+		return
+	}
+
 	// 如果是邮件类型，验证邮箱地址
 	if req.QuotaWarningType == dto.NotifyTypeEmail && req.NotificationEmail != "" {
 		// 验证邮箱格式
@@ -927,14 +936,17 @@ func UpdateUserSetting(c *gin.Context) {
 		QuotaWarningThreshold: req.QuotaWarningThreshold,
 		AcceptUnsetRatioModel: req.AcceptUnsetModelRatioModel,
 		RecordIpLog:           req.RecordIpLog,
+		MediaRetentionDays:    req.MediaRetentionDays,
+		// webhook 密钥同时用于任务完成通知的签名，未提交时保留原值
+		WebhookSecret: user.GetSetting().WebhookSecret,
+	}
+	if req.WebhookSecret != "" {
+		settings.WebhookSecret = req.WebhookSecret
 	}
 
 	// 如果是webhook类型,添加webhook相关设置
 	if req.QuotaWarningType == dto.NotifyTypeWebhook {
 		settings.WebhookUrl = req.WebhookUrl
-		if req.WebhookSecret != "" {
-			settings.WebhookSecret = req.WebhookSecret
-		}
 	}
 
 	// 如果提供了通知邮箱，添加到设置中
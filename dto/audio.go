@@ -6,6 +6,7 @@ type AudioRequest struct {
 	Voice          string  `json:"voice"`
 	Speed          float64 `json:"speed,omitempty"`
 	ResponseFormat string  `json:"response_format,omitempty"`
+	StreamFormat   string  `json:"stream_format,omitempty"` // audio 或 sse，设置后流式返回音频
 }
 
 type AudioResponse struct {
@@ -18,6 +19,7 @@ type WhisperVerboseJSONResponse struct {
 	Duration float64   `json:"duration,omitempty"`
 	Text     string    `json:"text,omitempty"`
 	Segments []Segment `json:"segments,omitempty"`
+	Words    []Word    `json:"words,omitempty"`
 }
 
 type Segment struct {
@@ -32,3 +34,9 @@ type Segment struct {
 	CompressionRatio float64 `json:"compression_ratio"`
 	NoSpeechProb     float64 `json:"no_speech_prob"`
 }
+
+type Word struct {
+	Word  string  `json:"word"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
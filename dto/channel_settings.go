@@ -1,7 +1,71 @@
 package dto
 
+import "one-api/common"
+
 type ChannelSettings struct {
-	ForceFormat       bool   `json:"force_format,omitempty"`
-	ThinkingToContent bool   `json:"thinking_to_content,omitempty"`
-	Proxy             string `json:"proxy"`
+	ForceFormat               bool                          `json:"force_format,omitempty"`
+	ThinkingToContent         bool                          `json:"thinking_to_content,omitempty"`
+	Proxy                     string                        `json:"proxy"`
+	CompletionsEmulation      bool                          `json:"completions_emulation,omitempty"`       // 将 completions 请求转换为 chat 请求
+	MaxConcurrency            int                           `json:"max_concurrency,omitempty"`             // 渠道在每个节点上同时处理的最大请求数，0 表示不限制
+	Schedules                 []common.ScheduleWindow       `json:"schedules,omitempty"`                   // 渠道可用时段，满足任一时段即可用，为空表示始终可用
+	VertexModels              map[string]VertexModelSetting `json:"vertex_models,omitempty"`               // Vertex AI 渠道中模型名到发布方与模型版本的映射
+	Capabilities              *ChannelCapabilities          `json:"capabilities,omitempty"`                // 自部署渠道的能力描述，保存渠道时自动探测
+	ToolCallEmulation         bool                          `json:"tool_call_emulation,omitempty"`         // 将工具定义注入提示词，并从模型输出中解析 tool_calls
+	StructuredOutputEmulation bool                          `json:"structured_output_emulation,omitempty"` // 将 json_schema 注入提示词，并校验响应是否符合
+	Azure                     *AzureSetting                 `json:"azure,omitempty"`                       // Azure OpenAI 渠道的鉴权方式与部署映射
+}
+
+// Azure 渠道的鉴权方式
+const (
+	AzureAuthTypeApiKey  = "api_key"
+	AzureAuthTypeEntraId = "entra_id" // Entra ID 客户端凭据，渠道密钥格式为 client_id:client_secret
+)
+
+// AzureSetting Azure OpenAI / Azure AI Foundry 渠道设置
+type AzureSetting struct {
+	AuthType       string                     `json:"auth_type,omitempty"`       // 为空时使用 api_key
+	TenantId       string                     `json:"tenant_id,omitempty"`       // Entra ID 租户
+	SubscriptionId string                     `json:"subscription_id,omitempty"` // 填写订阅与资源组时通过管理 API 发现部署，需要 Entra ID 鉴权
+	ResourceGroup  string                     `json:"resource_group,omitempty"`
+	AccountName    string                     `json:"account_name,omitempty"` // Azure OpenAI 资源名，为空时从 base url 中获取
+	Serverless     bool                       `json:"serverless,omitempty"`   // Azure AI Foundry serverless 端点，按 OpenAI 路径直接调用，不使用部署
+	Deployments    map[string]AzureDeployment `json:"deployments,omitempty"`  // 模型名到部署的映射，通过发现部署写入
+}
+
+// AzureDeployment Azure OpenAI 部署
+type AzureDeployment struct {
+	Deployment   string `json:"deployment"`
+	Model        string `json:"model,omitempty"` // 部署的基础模型
+	ModelVersion string `json:"model_version,omitempty"`
+	ApiVersion   string `json:"api_version,omitempty"` // 部署单独使用的 api-version，为空时使用渠道的 api_version
+	Manual       bool   `json:"manual,omitempty"`      // 为 true 时不会被发现部署的结果覆盖
+}
+
+// VertexModelSetting Vertex AI 模型花园中的模型
+type VertexModelSetting struct {
+	Publisher string `json:"publisher"`     // 发布方，如 google、anthropic、meta、mistralai
+	Model     string `json:"model"`         // Vertex 上的模型 id，可带版本，如 claude-sonnet-4@20250514
+	Api       string `json:"api,omitempty"` // 调用方式：gemini、claude、openapi（OpenAI 兼容接口）、embedding、imagen，为空时按发布方与模型名推断
+}
+
+// 自部署渠道的结构化输出方式
+const (
+	GuidedDecodingNone       = ""
+	GuidedDecodingJsonSchema = "json_schema" // OpenAI 格式的 response_format
+	GuidedDecodingGuidedJson = "guided_json" // vLLM 旧版本的 guided_json 参数
+	GuidedDecodingTgi        = "tgi"         // TGI 的 response_format: {"type": "json", "value": schema}
+)
+
+// ChannelCapabilities 自部署 OpenAI 兼容服务（vLLM、TGI、llama.cpp 等）支持的能力，不支持的字段在转发前移除
+type ChannelCapabilities struct {
+	Server         string   `json:"server"`                // 探测到的服务类型：vllm、tgi、llama.cpp、unknown
+	Models         []string `json:"models,omitempty"`      // /v1/models 返回的模型
+	MaxContext     int      `json:"max_context,omitempty"` // 最大上下文长度
+	Tools          bool     `json:"tools"`
+	Vision         bool     `json:"vision"`
+	StreamOptions  bool     `json:"stream_options"`
+	GuidedDecoding string   `json:"guided_decoding"`
+	Manual         bool     `json:"manual,omitempty"`    // 为 true 时保存渠道不再重新探测，使用手动填写的能力
+	ProbedAt       int64    `json:"probed_at,omitempty"` // 探测时间
 }
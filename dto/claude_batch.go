@@ -0,0 +1,74 @@
+package dto
+
+import "encoding/json"
+
+// ClaudeCountTokensResponse /v1/messages/count_tokens 的响应
+type ClaudeCountTokensResponse struct {
+	InputTokens int `json:"input_tokens"`
+}
+
+// ClaudeBatchRequest 创建 Message Batch 的请求
+type ClaudeBatchRequest struct {
+	Requests []ClaudeBatchRequestItem `json:"requests"`
+}
+
+type ClaudeBatchRequestItem struct {
+	CustomId string          `json:"custom_id"`
+	Params   json.RawMessage `json:"params"` // 与 /v1/messages 的请求体相同，原样转发
+}
+
+// Claude 批处理状态
+const (
+	ClaudeBatchStatusInProgress = "in_progress"
+	ClaudeBatchStatusCanceling  = "canceling"
+	ClaudeBatchStatusEnded      = "ended"
+)
+
+// ClaudeBatch Message Batch 对象
+type ClaudeBatch struct {
+	Id                string                   `json:"id"`
+	Type              string                   `json:"type"`
+	ProcessingStatus  string                   `json:"processing_status"`
+	RequestCounts     ClaudeBatchRequestCounts `json:"request_counts"`
+	EndedAt           *string                  `json:"ended_at"`
+	CreatedAt         string                   `json:"created_at"`
+	ExpiresAt         string                   `json:"expires_at"`
+	CancelInitiatedAt *string                  `json:"cancel_initiated_at"`
+	ResultsUrl        *string                  `json:"results_url"`
+}
+
+type ClaudeBatchRequestCounts struct {
+	Processing int `json:"processing"`
+	Succeeded  int `json:"succeeded"`
+	Errored    int `json:"errored"`
+	Canceled   int `json:"canceled"`
+	Expired    int `json:"expired"`
+}
+
+// ClaudeBatchResult 批处理结果文件（JSONL）中的一行
+type ClaudeBatchResult struct {
+	CustomId string `json:"custom_id"`
+	Result   struct {
+		Type    string          `json:"type"` // succeeded、errored、canceled、expired
+		Message *ClaudeResponse `json:"message,omitempty"`
+	} `json:"result"`
+}
+
+// ClaudeListResponse Anthropic 列表接口的响应
+type ClaudeListResponse struct {
+	Data    []json.RawMessage `json:"data"`
+	HasMore bool              `json:"has_more"`
+	FirstId *string           `json:"first_id"`
+	LastId  *string           `json:"last_id"`
+}
+
+// ClaudeFile Files API 返回的文件信息
+type ClaudeFile struct {
+	Id           string `json:"id"`
+	Type         string `json:"type"`
+	Filename     string `json:"filename"`
+	MimeType     string `json:"mime_type"`
+	SizeBytes    int64  `json:"size_bytes"`
+	CreatedAt    string `json:"created_at"`
+	Downloadable bool   `json:"downloadable,omitempty"`
+}
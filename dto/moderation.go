@@ -0,0 +1,88 @@
+package dto
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ModerationCategories 与 omni-moderation 一致的审核类别
+var ModerationCategories = []string{
+	"harassment",
+	"harassment/threatening",
+	"hate",
+	"hate/threatening",
+	"illicit",
+	"illicit/violent",
+	"self-harm",
+	"self-harm/intent",
+	"self-harm/instructions",
+	"sexual",
+	"sexual/minors",
+	"violence",
+	"violence/graphic",
+}
+
+type ModerationRequest struct {
+	Model string `json:"model"`
+	Input any    `json:"input"`
+}
+
+// ParseInput 文本数组的每一项单独审核，多模态数组中的文本部分合并为一项审核；
+// 网关自行审核时只支持文本，包含图片等其他类型时返回错误
+func (r ModerationRequest) ParseInput() ([]string, error) {
+	switch input := r.Input.(type) {
+	case string:
+		return []string{input}, nil
+	case []any:
+		texts := make([]string, 0, len(input))
+		var parts []string
+		for _, item := range input {
+			switch v := item.(type) {
+			case string:
+				texts = append(texts, v)
+			case map[string]any:
+				if v["type"] != "text" {
+					return nil, fmt.Errorf("moderation input type %v is not supported", v["type"])
+				}
+				if text, ok := v["text"].(string); ok {
+					parts = append(parts, text)
+				}
+			}
+		}
+		if len(parts) > 0 {
+			texts = append(texts, strings.Join(parts, "\n"))
+		}
+		return texts, nil
+	}
+	return nil, nil
+}
+
+type ModerationResult struct {
+	Flagged        bool               `json:"flagged"`
+	Categories     map[string]bool    `json:"categories"`
+	CategoryScores map[string]float64 `json:"category_scores"`
+}
+
+// NewModerationResult 按得分与阈值生成审核结果，未给出得分的标准类别记为 0
+func NewModerationResult(scores map[string]float64, threshold float64) ModerationResult {
+	result := ModerationResult{
+		Categories:     make(map[string]bool, len(ModerationCategories)),
+		CategoryScores: make(map[string]float64, len(ModerationCategories)),
+	}
+	for _, category := range ModerationCategories {
+		result.CategoryScores[category] = 0
+		result.Categories[category] = false
+	}
+	for category, score := range scores {
+		result.CategoryScores[category] = score
+		result.Categories[category] = score > 0 && score >= threshold
+		result.Flagged = result.Flagged || result.Categories[category]
+	}
+	return result
+}
+
+type ModerationResponse struct {
+	Id      string             `json:"id"`
+	Model   string             `json:"model"`
+	Results []ModerationResult `json:"results"`
+}
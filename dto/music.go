@@ -0,0 +1,17 @@
+package dto
+
+// MusicRequest 统一音乐生成任务请求
+type MusicRequest struct {
+	Model        string  `json:"model,omitempty"`
+	Action       string  `json:"action,omitempty"`      // music, extend, cover, stems，默认为 music
+	Prompt       string  `json:"prompt,omitempty"`      // 自定义歌词
+	Description  string  `json:"description,omitempty"` // 灵感模式的歌曲描述
+	Title        string  `json:"title,omitempty"`
+	Tags         string  `json:"tags,omitempty"`    // 歌曲风格
+	Version      string  `json:"version,omitempty"` // 上游模型版本
+	Instrumental bool    `json:"instrumental,omitempty"`
+	TaskId       string  `json:"task_id,omitempty"` // extend、cover、stems 的源歌曲所属任务
+	ClipId       string  `json:"clip_id,omitempty"` // extend、cover、stems 的源歌曲
+	ContinueAt   float64 `json:"continue_at,omitempty"`
+	CallbackUrl  string  `json:"callback_url,omitempty"`
+}
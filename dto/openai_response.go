@@ -183,6 +183,23 @@ type CompletionsStreamResponse struct {
 	} `json:"choices"`
 }
 
+// CompletionsResponse /v1/completions 与 /v1/edits 的响应格式
+type CompletionsResponse struct {
+	Id      string              `json:"id,omitempty"`
+	Object  string              `json:"object"`
+	Created int64               `json:"created"`
+	Model   string              `json:"model,omitempty"`
+	Choices []CompletionsChoice `json:"choices"`
+	Usage   *Usage              `json:"usage,omitempty"`
+}
+
+type CompletionsChoice struct {
+	Text         string  `json:"text"`
+	Index        int     `json:"index"`
+	Logprobs     any     `json:"logprobs"`
+	FinishReason *string `json:"finish_reason"`
+}
+
 type Usage struct {
 	PromptTokens         int `json:"prompt_tokens"`
 	CompletionTokens     int `json:"completion_tokens"`
@@ -18,6 +18,10 @@ type SunoSubmitReq struct {
 	TaskID               string  `json:"task_id,omitempty"`
 	ContinueClipId       string  `json:"continue_clip_id,omitempty"`
 	MakeInstrumental     bool    `json:"make_instrumental"`
+	Task                 string  `json:"task,omitempty"`          // extend, cover, gen_stem
+	CoverClipId          string  `json:"cover_clip_id,omitempty"` // 翻唱的源歌曲
+	ClipId               string  `json:"clip_id,omitempty"`       // 分轨的源歌曲
+	NotifyHook           string  `json:"notify_hook,omitempty"`
 }
 
 type FetchReq struct {
@@ -88,6 +92,8 @@ type TaskDto struct {
 	FinishTime int64           `json:"finish_time"`
 	Progress   string          `json:"progress"`
 	Data       json.RawMessage `json:"data"`
+
+	Result *VideoTaskResult `json:"result,omitempty"` // 视频任务的统一结果
 }
 
 type SunoGoAPISubmitReq struct {
@@ -108,6 +114,9 @@ type SunoGoAPISubmitReqInput struct {
 	TaskID               string  `json:"task_id"`
 	ContinueClipId       string  `json:"continue_clip_id"`
 	MakeInstrumental     bool    `json:"make_instrumental"`
+	Task                 string  `json:"task,omitempty"`          // extend, cover, gen_stem
+	CoverClipId          string  `json:"cover_clip_id,omitempty"` // 翻唱的源歌曲
+	ClipId               string  `json:"clip_id,omitempty"`       // 分轨的源歌曲
 }
 
 type GoAPITaskResponse[T any] struct {
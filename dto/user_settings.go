@@ -8,6 +8,7 @@ type UserSetting struct {
 	NotificationEmail     string  `json:"notification_email,omitempty"`             // NotificationEmail 通知邮箱地址
 	AcceptUnsetRatioModel bool    `json:"accept_unset_model_ratio_model,omitempty"` // AcceptUnsetRatioModel 是否接受未设置价格的模型
 	RecordIpLog           bool    `json:"record_ip_log,omitempty"`                  // 是否记录请求和错误日志IP
+	MediaRetentionDays    int     `json:"media_retention_days,omitempty"`           // MediaRetentionDays 生成结果保存天数，只能短于系统设置
 }
 
 var (
@@ -1,18 +1,66 @@
 package dto
 
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
 type VideoRequest struct {
 	Model          string         `json:"model,omitempty" example:"kling-v1"`                                                                                                                                    // Model/style ID
 	Prompt         string         `json:"prompt,omitempty" example:"宇航员站起身走了"`                                                                                                                                   // Text prompt
-	Image          string         `json:"image,omitempty" example:"https://h2.inkwai.com/bs2/upload-ylab-stunt/se/ai_portal_queue_mmu_image_upscale_aiweb/3214b798-e1b4-4b00-b7af-72b5b0417420_raw_image_0.jpg"` // Image input (URL/Base64)
+	NegativePrompt string         `json:"negative_prompt,omitempty"`                                                                                                                                             // Negative prompt
+	Image          string         `json:"image,omitempty" example:"https://h2.inkwai.com/bs2/upload-ylab-stunt/se/ai_portal_queue_mmu_image_upscale_aiweb/3214b798-e1b4-4b00-b7af-72b5b0417420_raw_image_0.jpg"` // Image input (URL/Base64), image-to-video when set
+	ImageTail      string         `json:"image_tail,omitempty"`                                                                                                                                                  // Last frame image (URL/Base64)
+	Mode           string         `json:"mode,omitempty" example:"std"`                                                                                                                                          // Generation mode, e.g. std / pro
 	Duration       float64        `json:"duration" example:"5.0"`                                                                                                                                                // Video duration (seconds)
+	AspectRatio    string         `json:"aspect_ratio,omitempty" example:"16:9"`                                                                                                                                 // Aspect ratio
+	Resolution     string         `json:"resolution,omitempty" example:"720p"`                                                                                                                                   // Resolution tier, e.g. 720p / 1080p
+	Size           string         `json:"size,omitempty" example:"1280x720"`                                                                                                                                     // Size in {width}x{height}, used when aspect_ratio is empty
 	Width          int            `json:"width" example:"512"`                                                                                                                                                   // Video width
 	Height         int            `json:"height" example:"512"`                                                                                                                                                  // Video height
 	Fps            int            `json:"fps,omitempty" example:"30"`                                                                                                                                            // Video frame rate
+	CfgScale       *float64       `json:"cfg_scale,omitempty" example:"0.5"`                                                                                                                                     // Prompt relevance
 	Seed           int            `json:"seed,omitempty" example:"20231234"`                                                                                                                                     // Random seed
 	N              int            `json:"n,omitempty" example:"1"`                                                                                                                                               // Number of videos to generate
 	ResponseFormat string         `json:"response_format,omitempty" example:"url"`                                                                                                                               // Response format
 	User           string         `json:"user,omitempty" example:"user-1234"`                                                                                                                                    // User identifier
-	Metadata       map[string]any `json:"metadata,omitempty"`                                                                                                                                                    // Vendor-specific/custom params (e.g. negative_prompt, style, quality_level, etc.)
+	CallbackUrl    string         `json:"callback_url,omitempty"`                                                                                                                                                // Task completion webhook
+	Metadata       map[string]any `json:"metadata,omitempty"`                                                                                                                                                    // Vendor-specific/custom params not covered above
+}
+
+// GetAspectRatio 返回请求的宽高比，未指定时按 size 或 width/height 从 candidates 中选择比例一致的一项
+func (r *VideoRequest) GetAspectRatio(candidates []string) (string, error) {
+	if r.AspectRatio != "" {
+		if !slices.Contains(candidates, r.AspectRatio) {
+			return "", fmt.Errorf("aspect_ratio must be one of %s", strings.Join(candidates, ", "))
+		}
+		return r.AspectRatio, nil
+	}
+	width, height := r.Width, r.Height
+	if r.Size != "" {
+		if _, err := fmt.Sscanf(r.Size, "%dx%d", &width, &height); err != nil {
+			return "", errors.New("size must be in the format of {width}x{height}")
+		}
+	}
+	if width <= 0 || height <= 0 {
+		return "", nil
+	}
+	for _, candidate := range candidates {
+		var w, h int
+		if _, err := fmt.Sscanf(candidate, "%d:%d", &w, &h); err == nil && width*h == height*w {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("size %dx%d is not supported, aspect ratio must be one of %s", width, height, strings.Join(candidates, ", "))
+}
+
+// VideoTaskResult 统一的视频任务结果，各平台解析任务结果时填充
+type VideoTaskResult struct {
+	Url      string  `json:"url"`
+	CoverUrl string  `json:"cover_url,omitempty"`
+	Duration float64 `json:"duration,omitempty"`
 }
 
 // VideoResponse 视频生成提交任务后的响应
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"net/http"
@@ -11,6 +12,8 @@ import (
 	relayconstant "one-api/relay/constant"
 	"one-api/service"
 	"one-api/setting"
+	"one-api/setting/model_setting"
+	"one-api/setting/operation_setting"
 	"one-api/setting/ratio_setting"
 	"one-api/types"
 	"strconv"
@@ -27,6 +30,8 @@ type ModelRequest struct {
 
 func Distribute() func(c *gin.Context) {
 	return func(c *gin.Context) {
+		// 请求结束后释放占用的渠道并发位
+		defer model.ReleaseChannelSlot(c)
 		allowIpsMap := common.GetContextKeyStringMap(c, constant.ContextKeyTokenAllowIps)
 		if len(allowIpsMap) != 0 {
 			clientIp := c.ClientIP()
@@ -36,12 +41,12 @@ func Distribute() func(c *gin.Context) {
 			}
 		}
 		var channel *model.Channel
-		channelId, ok := common.GetContextKey(c, constant.ContextKeyTokenSpecificChannelId)
 		modelRequest, shouldSelectChannel, err := getModelRequest(c)
 		if err != nil {
 			abortWithOpenAiMessage(c, http.StatusBadRequest, "Invalid request, "+err.Error())
 			return
 		}
+		channelId, ok := common.GetContextKey(c, constant.ContextKeyTokenSpecificChannelId)
 		userGroup := common.GetContextKeyString(c, constant.ContextKeyUserGroup)
 		tokenGroup := common.GetContextKeyString(c, constant.ContextKeyTokenGroup)
 		if tokenGroup != "" {
@@ -75,11 +80,20 @@ func Distribute() func(c *gin.Context) {
 				abortWithOpenAiMessage(c, http.StatusForbidden, "该渠道已被禁用")
 				return
 			}
+			if !channel.IsScheduledAt(time.Now()) {
+				abortWithOpenAiMessage(c, http.StatusServiceUnavailable, "该渠道当前不在可用时段")
+				return
+			}
+			if err := model.AcquireChannelSlot(c, channel); err != nil {
+				abortWithOpenAiMessage(c, http.StatusServiceUnavailable, err.Error())
+				return
+			}
 		} else {
 			// Select a channel for the user
 			// check token model mapping
 			modelLimitEnable := common.GetContextKeyBool(c, constant.ContextKeyTokenModelLimitEnabled)
-			if modelLimitEnable {
+			// 查询批处理、文件等不选择渠道且不指定模型的请求不受模型限制
+			if modelLimitEnable && (shouldSelectChannel || modelRequest.Model != "") {
 				s, ok := common.GetContextKey(c, constant.ContextKeyTokenModelLimit)
 				var tokenModelLimit map[string]bool
 				if ok {
@@ -99,7 +113,15 @@ func Distribute() func(c *gin.Context) {
 				}
 			}
 
-			if shouldSelectChannel {
+			// 引用文件或缓存的请求使用资源所在的渠道，仍需通过模型限制、分组与模型校验
+			pinnedChannelId := common.GetContextKeyInt(c, constant.ContextKeyChannelPinnedId)
+			if shouldSelectChannel && pinnedChannelId != 0 {
+				channel, _, err = model.CacheGetPinnedChannel(c, userGroup, modelRequest.Model, pinnedChannelId)
+				if err != nil {
+					abortWithOpenAiMessage(c, http.StatusServiceUnavailable, err.Error())
+					return
+				}
+			} else if shouldSelectChannel {
 				var selectGroup string
 				channel, selectGroup, err = model.CacheGetRandomSatisfiedChannel(c, userGroup, modelRequest.Model, 0)
 				if err != nil {
@@ -191,6 +213,59 @@ func getModelRequest(c *gin.Context) (*ModelRequest, bool, error) {
 		}
 		c.Set("platform", platform)
 		c.Set("relay_mode", relayMode)
+	} else if strings.HasPrefix(c.Request.URL.Path, "/v1/audio/music") {
+		relayMode := relayconstant.Path2RelayMusic(c.Request.Method, c.Request.URL.Path)
+		if relayMode == relayconstant.RelayModeMusicFetchByID {
+			shouldSelectChannel = false
+		} else {
+			err = common.UnmarshalBodyReusable(c, &modelRequest)
+			modelRequest.Model = common.GetStringIfEmpty(modelRequest.Model, "suno_music")
+			platform, ok := constant.GetMusicTaskPlatform(modelRequest.Model)
+			if !ok {
+				return nil, false, fmt.Errorf("不支持的音乐模型 %s", modelRequest.Model)
+			}
+			c.Set("platform", string(platform))
+		}
+		c.Set("relay_mode", relayMode)
+	} else if strings.HasPrefix(c.Request.URL.Path, "/v1/messages/batches") {
+		// 只有创建批处理需要选择渠道，其余操作使用批处理所属的渠道
+		if c.Request.Method == http.MethodPost && c.Request.URL.Path == "/v1/messages/batches" {
+			var batchRequest struct {
+				Requests []struct {
+					Params ModelRequest `json:"params"`
+				} `json:"requests"`
+			}
+			err = common.UnmarshalBodyReusable(c, &batchRequest)
+			if len(batchRequest.Requests) > 0 {
+				modelRequest.Model = batchRequest.Requests[0].Params.Model
+			}
+		} else {
+			shouldSelectChannel = false
+		}
+	} else if strings.HasPrefix(c.Request.URL.Path, "/v1/files") {
+		// Anthropic Files API 上传时按配置的模型选择渠道，其余操作使用文件所属的渠道
+		if c.Request.Method == http.MethodPost && c.Request.Header.Get("anthropic-version") != "" {
+			modelRequest.Model = model_setting.GetClaudeSettings().FilesModel
+		} else {
+			shouldSelectChannel = false
+		}
+	} else if strings.HasPrefix(c.Request.URL.Path, "/v1beta/cachedContents") {
+		// 只有创建缓存需要选择渠道，其余操作使用缓存所属的渠道
+		if c.Request.Method == http.MethodPost {
+			err = common.UnmarshalBodyReusable(c, &modelRequest)
+			modelRequest.Model = strings.TrimPrefix(modelRequest.Model, "models/")
+		} else {
+			shouldSelectChannel = false
+		}
+	} else if strings.HasPrefix(c.Request.URL.Path, "/v1beta/files") {
+		shouldSelectChannel = false
+	} else if strings.HasPrefix(c.Request.URL.Path, "/upload/v1beta/files") {
+		// 开始上传时按配置的模型选择渠道，后续分片使用上传地址中的渠道
+		if c.Query("upload_id") == "" {
+			modelRequest.Model = model_setting.GetGeminiSettings().FilesModel
+		} else {
+			shouldSelectChannel = false
+		}
 	} else if strings.HasPrefix(c.Request.URL.Path, "/v1beta/models/") || strings.HasPrefix(c.Request.URL.Path, "/v1/models/") {
 		// Gemini API 路径处理: /v1beta/models/gemini-2.0-flash:generateContent
 		relayMode := relayconstant.RelayModeGemini
@@ -208,11 +283,45 @@ func getModelRequest(c *gin.Context) (*ModelRequest, bool, error) {
 	if strings.HasPrefix(c.Request.URL.Path, "/v1/realtime") {
 		//wss://api.openai.com/v1/realtime?model=gpt-4o-realtime-preview-2024-10-01
 		modelRequest.Model = c.Query("model")
+		if _, ok := operation_setting.GetRealtimeBridgeSetting().GetPipeline(modelRequest.Model); ok {
+			// 桥接模式由各组件模型分别选择渠道
+			shouldSelectChannel = false
+		}
+	}
+	if c.Request.URL.Path == "/v1/messages" || c.Request.URL.Path == "/v1/messages/count_tokens" {
+		// 引用 Files API 文件的请求必须发往上传该文件的渠道
+		if requestBody, _ := common.GetRequestBody(c); bytes.Contains(requestBody, []byte(`"file_id"`)) {
+			file, err := service.ClaudeFileResource(c.GetInt("id"), requestBody)
+			if err != nil {
+				return nil, false, err
+			}
+			if file != nil {
+				common.SetContextKey(c, constant.ContextKeyChannelPinnedId, file.ChannelId)
+				common.SetContextKey(c, constant.ContextKeyChannelPinnedKeyIndex, file.KeyIndex)
+			}
+		}
+	}
+	if (strings.HasPrefix(c.Request.URL.Path, "/v1beta/models/") || c.Request.URL.Path == "/v1beta/cachedContents") && c.Request.Method == http.MethodPost {
+		// 引用上下文缓存或 Files API 文件的请求必须发往创建它们的渠道
+		if requestBody, _ := common.GetRequestBody(c); bytes.Contains(requestBody, []byte(`"cachedContent"`)) || bytes.Contains(requestBody, []byte(`/v1beta/files/`)) {
+			resource, err := service.GeminiResource(c.GetInt("id"), requestBody)
+			if err != nil {
+				return nil, false, err
+			}
+			if resource != nil {
+				common.SetContextKey(c, constant.ContextKeyChannelPinnedId, resource.ChannelId)
+				common.SetContextKey(c, constant.ContextKeyChannelPinnedKeyIndex, resource.KeyIndex)
+			}
+		}
 	}
 	if strings.HasPrefix(c.Request.URL.Path, "/v1/moderations") {
 		if modelRequest.Model == "" {
 			modelRequest.Model = "text-moderation-stable"
 		}
+		// chat 与 local 后端由网关完成审核，无需 moderation 渠道
+		if operation_setting.GetModerationSetting().UseLocalModeration() {
+			shouldSelectChannel = false
+		}
 	}
 	if strings.HasSuffix(c.Request.URL.Path, "embeddings") {
 		if modelRequest.Model == "" {
@@ -224,7 +333,7 @@ func getModelRequest(c *gin.Context) (*ModelRequest, bool, error) {
 	} else if strings.HasPrefix(c.Request.URL.Path, "/v1/images/edits") {
 		modelRequest.Model = common.GetStringIfEmpty(c.PostForm("model"), "gpt-image-1")
 	}
-	if strings.HasPrefix(c.Request.URL.Path, "/v1/audio") {
+	if strings.HasPrefix(c.Request.URL.Path, "/v1/audio") && !strings.HasPrefix(c.Request.URL.Path, "/v1/audio/music") {
 		relayMode := relayconstant.RelayModeAudioSpeech
 		if strings.HasPrefix(c.Request.URL.Path, "/v1/audio/speech") {
 			modelRequest.Model = common.GetStringIfEmpty(modelRequest.Model, "tts-1")
@@ -272,6 +381,11 @@ func SetupContextForSelectedChannel(c *gin.Context, channel *model.Channel, mode
 	if newAPIError != nil {
 		return newAPIError
 	}
+	// 引用文件或缓存的请求使用创建它们的密钥
+	if common.GetContextKeyInt(c, constant.ContextKeyChannelPinnedId) == channel.Id {
+		index = common.GetContextKeyInt(c, constant.ContextKeyChannelPinnedKeyIndex)
+		key = channel.GetKeyByIndex(index)
+	}
 	if channel.ChannelInfo.IsMultiKey {
 		common.SetContextKey(c, constant.ContextKeyChannelIsMultiKey, true)
 		common.SetContextKey(c, constant.ContextKeyChannelMultiKeyIndex, index)
@@ -6,6 +6,7 @@ import (
 	"io"
 	"one-api/common"
 	"one-api/constant"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -26,6 +27,17 @@ func KlingRequestConvert() func(c *gin.Context) {
 			"prompt":   prompt,
 			"metadata": originalReq,
 		}
+		// 可灵原生参数映射到统一视频请求的字段，用于校验与计费
+		for _, key := range []string{"negative_prompt", "image", "image_tail", "mode", "aspect_ratio", "cfg_scale", "callback_url"} {
+			if value, ok := originalReq[key]; ok {
+				unifiedReq[key] = value
+			}
+		}
+		if duration, ok := originalReq["duration"].(string); ok {
+			if seconds, err := strconv.ParseFloat(duration, 64); err == nil {
+				unifiedReq["duration"] = seconds
+			}
+		}
 
 		jsonData, err := json.Marshal(unifiedReq)
 		if err != nil {
@@ -12,6 +12,7 @@ import (
 	"one-api/types"
 	"strings"
 	"sync"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -46,6 +47,7 @@ type Channel struct {
 	ParamOverride     *string `json:"param_override" gorm:"type:text"`
 	// add after v0.8.5
 	ChannelInfo ChannelInfo `json:"channel_info" gorm:"type:json"`
+	InFlight    int         `json:"in_flight" gorm:"-"` // 正在处理的请求数，仅用于接口展示
 }
 
 type ChannelInfo struct {
@@ -88,6 +90,19 @@ func (channel *Channel) getKeys() []string {
 	return keys
 }
 
+// GetKeyByIndex 返回多密钥渠道中指定位置的密钥，用于访问由该密钥创建的上游资源，位置无效时重新选择密钥
+func (channel *Channel) GetKeyByIndex(index int) string {
+	if !channel.ChannelInfo.IsMultiKey {
+		return channel.Key
+	}
+	keys := channel.getKeys()
+	if index >= 0 && index < len(keys) {
+		return keys[index]
+	}
+	key, _, _ := channel.GetNextEnabledKey()
+	return key
+}
+
 func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
 	// If not in multi-key mode, return the original key string directly.
 	if !channel.ChannelInfo.IsMultiKey {
@@ -783,9 +798,40 @@ func (channel *Channel) ValidateSettings() error {
 			return err
 		}
 	}
+	if channelParams.MaxConcurrency < 0 {
+		return errors.New("max_concurrency must not be negative")
+	}
+	for _, window := range channelParams.Schedules {
+		if _, err := common.ParseSchedule(window); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// IsScheduledAt 判断渠道在指定时间是否处于可用时段
+func (channel *Channel) IsScheduledAt(t time.Time) bool {
+	return scheduleContains(channel.GetSetting().Schedules, t)
+}
+
+func scheduleContains(windows []common.ScheduleWindow, t time.Time) bool {
+	if len(windows) == 0 {
+		return true
+	}
+	for _, window := range windows {
+		schedule, err := common.ParseSchedule(window)
+		if err != nil {
+			// 配置无效的时段不限制渠道可用性
+			common.SysError(fmt.Sprintf("invalid channel schedule %q: %s", window.Cron, err.Error()))
+			return true
+		}
+		if schedule.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
 func (channel *Channel) GetSetting() dto.ChannelSettings {
 	setting := dto.ChannelSettings{}
 	if channel.Setting != nil && *channel.Setting != "" {
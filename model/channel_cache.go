@@ -6,6 +6,7 @@ import (
 	"math/rand"
 	"one-api/common"
 	"one-api/setting"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -16,6 +17,7 @@ import (
 
 var group2model2channels map[string]map[string][]int // enabled channel
 var channelsIDM map[int]*Channel                     // all channels include disabled
+var channelSchedules map[int][]*common.CronSchedule  // channels with availability schedules
 var channelSyncLock sync.RWMutex
 
 func InitChannelCache() {
@@ -34,6 +36,7 @@ func InitChannelCache() {
 	for _, ability := range abilities {
 		groups[ability.Group] = true
 	}
+	newChannelSchedules := make(map[int][]*common.CronSchedule)
 	newGroup2model2channels := make(map[string]map[string][]int)
 	for group := range groups {
 		newGroup2model2channels[group] = make(map[string][]int)
@@ -42,6 +45,9 @@ func InitChannelCache() {
 		if channel.Status != common.ChannelStatusEnabled {
 			continue // skip disabled channels
 		}
+		if schedules := compileChannelSchedules(channel); len(schedules) > 0 {
+			newChannelSchedules[channel.Id] = schedules
+		}
 		groups := strings.Split(channel.Group, ",")
 		for _, group := range groups {
 			models := strings.Split(channel.Models, ",")
@@ -67,10 +73,48 @@ func InitChannelCache() {
 	channelSyncLock.Lock()
 	group2model2channels = newGroup2model2channels
 	channelsIDM = newChannelId2channel
+	channelSchedules = newChannelSchedules
 	channelSyncLock.Unlock()
 	common.SysLog("channels synced from database")
 }
 
+// compileChannelSchedules 解析渠道的可用时段，配置无效时记录错误并视为始终可用
+func compileChannelSchedules(channel *Channel) []*common.CronSchedule {
+	windows := channel.GetSetting().Schedules
+	schedules := make([]*common.CronSchedule, 0, len(windows))
+	for _, window := range windows {
+		schedule, err := common.ParseSchedule(window)
+		if err != nil {
+			common.SysError(fmt.Sprintf("invalid schedule for channel #%d: %s", channel.Id, err.Error()))
+			return nil
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules
+}
+
+// filterScheduledChannels 过滤掉当前不在可用时段内的渠道，调用方需持有 channelSyncLock
+func filterScheduledChannels(channels []int, now time.Time) []int {
+	if len(channelSchedules) == 0 {
+		return channels
+	}
+	available := make([]int, 0, len(channels))
+	for _, channelId := range channels {
+		schedules, ok := channelSchedules[channelId]
+		if !ok {
+			available = append(available, channelId)
+			continue
+		}
+		for _, schedule := range schedules {
+			if schedule.Contains(now) {
+				available = append(available, channelId)
+				break
+			}
+		}
+	}
+	return available
+}
+
 func SyncChannelCache(frequency int) {
 	for {
 		time.Sleep(time.Duration(frequency) * time.Second)
@@ -83,6 +127,9 @@ func CacheGetRandomSatisfiedChannel(c *gin.Context, group string, model string,
 	var channel *Channel
 	var err error
 	selectGroup := group
+	// 重新选择渠道前释放之前占用的并发位
+	ReleaseChannelSlot(c)
+	var saturated *channelsSaturatedError
 	if group == "auto" {
 		if len(setting.AutoGroups) == 0 {
 			return nil, selectGroup, errors.New("auto groups is not enabled")
@@ -91,8 +138,11 @@ func CacheGetRandomSatisfiedChannel(c *gin.Context, group string, model string,
 			if common.DebugEnabled {
 				println("autoGroup:", autoGroup)
 			}
-			channel, _ = getRandomSatisfiedChannel(autoGroup, model, retry)
+			channel, err = getRandomSatisfiedChannel(autoGroup, model, retry)
 			if channel == nil {
+				if saturated == nil && errors.As(err, &saturated) {
+					selectGroup = autoGroup
+				}
 				continue
 			} else {
 				c.Set("auto_group", autoGroup)
@@ -103,18 +153,69 @@ func CacheGetRandomSatisfiedChannel(c *gin.Context, group string, model string,
 				break
 			}
 		}
+		if channel == nil && saturated != nil {
+			channel, err = waitChannelSlot(c, saturated.channelIds)
+			if err != nil {
+				return nil, selectGroup, err
+			}
+			c.Set("auto_group", selectGroup)
+		}
 	} else {
 		channel, err = getRandomSatisfiedChannel(group, model, retry)
+		if errors.As(err, &saturated) {
+			channel, err = waitChannelSlot(c, saturated.channelIds)
+		}
 		if err != nil {
 			return nil, group, err
 		}
 	}
+	if channel != nil {
+		holdChannelSlot(c, channel.Id)
+	}
 	if channel == nil {
 		return nil, group, errors.New("channel not found")
 	}
 	return channel, selectGroup, nil
 }
 
+// CacheGetPinnedChannel 返回请求引用的文件或缓存所在的渠道，渠道需在请求分组内启用、支持该模型并处于可用时段
+func CacheGetPinnedChannel(c *gin.Context, group string, model string, channelId int) (*Channel, string, error) {
+	channel, err := CacheGetChannel(channelId)
+	if err != nil {
+		return nil, group, err
+	}
+	if channel.Status != common.ChannelStatusEnabled {
+		return nil, group, fmt.Errorf("渠道# %d，已被禁用", channelId)
+	}
+	groups := []string{group}
+	if group == "auto" {
+		groups = setting.AutoGroups
+	}
+	selectGroup := ""
+	for _, candidate := range groups {
+		if slices.Contains(channel.GetGroups(), candidate) {
+			selectGroup = candidate
+			break
+		}
+	}
+	if selectGroup == "" {
+		return nil, group, fmt.Errorf("引用的资源所在渠道# %d 不属于分组 %s", channelId, group)
+	}
+	if !slices.Contains(channel.GetModels(), model) {
+		return nil, selectGroup, fmt.Errorf("引用的资源所在渠道# %d 不支持模型 %s", channelId, model)
+	}
+	if !channel.IsScheduledAt(time.Now()) {
+		return nil, selectGroup, fmt.Errorf("引用的资源所在渠道# %d 当前不在可用时段", channelId)
+	}
+	if err = AcquireChannelSlot(c, channel); err != nil {
+		return nil, selectGroup, err
+	}
+	if group == "auto" {
+		c.Set("auto_group", selectGroup)
+	}
+	return channel, selectGroup, nil
+}
+
 func getRandomSatisfiedChannel(group string, model string, retry int) (*Channel, error) {
 	if strings.HasPrefix(model, "gpt-4-gizmo") {
 		model = "gpt-4-gizmo-*"
@@ -125,12 +226,22 @@ func getRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 
 	// if memory cache is disabled, get channel directly from database
 	if !common.MemoryCacheEnabled {
-		return GetRandomSatisfiedChannel(group, model, retry)
+		channel, err := GetRandomSatisfiedChannel(group, model, retry)
+		if err != nil || channel == nil {
+			return channel, err
+		}
+		if !channel.IsScheduledAt(time.Now()) {
+			return nil, errors.New("channel not found")
+		}
+		if !tryAcquireChannelSlot(channel) {
+			return nil, &channelsSaturatedError{channelIds: []int{channel.Id}}
+		}
+		return channel, nil
 	}
 
 	channelSyncLock.RLock()
 	defer channelSyncLock.RUnlock()
-	channels := group2model2channels[group][model]
+	channels := filterScheduledChannels(group2model2channels[group][model], time.Now())
 
 	if len(channels) == 0 {
 		return nil, errors.New("channel not found")
@@ -138,6 +249,9 @@ func getRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 
 	if len(channels) == 1 {
 		if channel, ok := channelsIDM[channels[0]]; ok {
+			if !tryAcquireChannelSlot(channel) {
+				return nil, &channelsSaturatedError{channelIds: []int{channel.Id}}
+			}
 			return channel, nil
 		}
 		return nil, fmt.Errorf("数据库一致性错误，渠道# %d 不存在，请联系管理员修复", channels[0])
@@ -164,10 +278,12 @@ func getRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 
 	// get the priority for the given retry number
 	var targetChannels []*Channel
+	var targetChannelIds []int
 	for _, channelId := range channels {
 		if channel, ok := channelsIDM[channelId]; ok {
 			if channel.GetPriority() == targetPriority {
 				targetChannels = append(targetChannels, channel)
+				targetChannelIds = append(targetChannelIds, channelId)
 			}
 		} else {
 			return nil, fmt.Errorf("数据库一致性错误，渠道# %d 不存在，请联系管理员修复", channelId)
@@ -176,23 +292,29 @@ func getRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 
 	// 平滑系数
 	smoothingFactor := 10
-	// Calculate the total weight of all channels up to endIdx
-	totalWeight := 0
-	for _, channel := range targetChannels {
-		totalWeight += channel.GetWeight() + smoothingFactor
-	}
-	// Generate a random value in the range [0, totalWeight)
-	randomWeight := rand.Intn(totalWeight)
+	// 按权重随机选择，已达到最大并发数的渠道从候选中移除后重新选择
+	for len(targetChannels) > 0 {
+		// Calculate the total weight of all channels up to endIdx
+		totalWeight := 0
+		for _, channel := range targetChannels {
+			totalWeight += channel.GetWeight() + smoothingFactor
+		}
+		// Generate a random value in the range [0, totalWeight)
+		randomWeight := rand.Intn(totalWeight)
 
-	// Find a channel based on its weight
-	for _, channel := range targetChannels {
-		randomWeight -= channel.GetWeight() + smoothingFactor
-		if randomWeight < 0 {
-			return channel, nil
+		// Find a channel based on its weight
+		for i, channel := range targetChannels {
+			randomWeight -= channel.GetWeight() + smoothingFactor
+			if randomWeight < 0 {
+				if tryAcquireChannelSlot(channel) {
+					return channel, nil
+				}
+				targetChannels = append(targetChannels[:i:i], targetChannels[i+1:]...)
+				break
+			}
 		}
 	}
-	// return null if no channel is not found
-	return nil, errors.New("channel not found")
+	return nil, &channelsSaturatedError{channelIds: targetChannelIds}
 }
 
 func CacheGetChannel(id int) (*Channel, error) {
@@ -0,0 +1,179 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/setting/operation_setting"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 渠道并发控制：记录各渠道正在处理的请求数，选择渠道时跳过已达到 max_concurrency 的渠道，
+// 同优先级渠道全部满载时请求按先后顺序排队，渠道释放的并发位直接交给最早等待该渠道的请求。
+// 计数只保存在当前进程内，多节点部署时每个节点分别限制，max_concurrency 应按单个节点的份额配置
+
+var (
+	channelInFlight     = make(map[int]int)
+	channelSlotWaiters  []*channelSlotWaiter
+	channelInFlightLock sync.Mutex
+)
+
+type channelSlotWaiter struct {
+	channelIds map[int]bool
+	ready      chan int // 分配到的渠道 id
+}
+
+// channelsSaturatedError 候选渠道均已达到最大并发数
+type channelsSaturatedError struct {
+	channelIds []int
+}
+
+func (e *channelsSaturatedError) Error() string {
+	return "所有可用渠道均已达到最大并发数"
+}
+
+// GetChannelInFlight 获取渠道正在处理的请求数
+func GetChannelInFlight(id int) int {
+	channelInFlightLock.Lock()
+	defer channelInFlightLock.Unlock()
+	return channelInFlight[id]
+}
+
+// FillChannelInFlight 为渠道列表填充正在处理的请求数
+func FillChannelInFlight(channels []*Channel) {
+	channelInFlightLock.Lock()
+	defer channelInFlightLock.Unlock()
+	for _, channel := range channels {
+		if channel != nil {
+			channel.InFlight = channelInFlight[channel.Id]
+		}
+	}
+}
+
+// tryAcquireChannelSlot 渠道未满载时占用一个并发位
+func tryAcquireChannelSlot(channel *Channel) bool {
+	maxConcurrency := channel.GetSetting().MaxConcurrency
+	channelInFlightLock.Lock()
+	defer channelInFlightLock.Unlock()
+	if maxConcurrency > 0 && channelInFlight[channel.Id] >= maxConcurrency {
+		return false
+	}
+	channelInFlight[channel.Id]++
+	return true
+}
+
+func releaseChannelSlotById(id int) {
+	channelInFlightLock.Lock()
+	defer channelInFlightLock.Unlock()
+	releaseChannelSlotLocked(id)
+}
+
+// releaseChannelSlotLocked 调用方需持有 channelInFlightLock
+func releaseChannelSlotLocked(id int) {
+	for i, waiter := range channelSlotWaiters {
+		if waiter.channelIds[id] {
+			// 并发位直接转交给排队的请求，正在处理的请求数不变
+			channelSlotWaiters = append(channelSlotWaiters[:i], channelSlotWaiters[i+1:]...)
+			waiter.ready <- id
+			return
+		}
+	}
+	if channelInFlight[id] > 1 {
+		channelInFlight[id]--
+	} else {
+		delete(channelInFlight, id)
+	}
+}
+
+// holdChannelSlot 记录当前请求占用的并发位
+func holdChannelSlot(c *gin.Context, id int) {
+	if c == nil {
+		releaseChannelSlotById(id)
+		return
+	}
+	common.SetContextKey(c, constant.ContextKeyChannelSlot, id)
+}
+
+// ReleaseChannelSlot 释放当前请求占用的渠道并发位，请求结束或重新选择渠道时调用
+func ReleaseChannelSlot(c *gin.Context) {
+	if c == nil {
+		return
+	}
+	id := common.GetContextKeyInt(c, constant.ContextKeyChannelSlot)
+	if id == 0 {
+		return
+	}
+	common.SetContextKey(c, constant.ContextKeyChannelSlot, 0)
+	releaseChannelSlotById(id)
+}
+
+// AcquireChannelSlot 为令牌或资源直接指定的渠道占用并发位，满载时与其他请求一样排队等待
+func AcquireChannelSlot(c *gin.Context, channel *Channel) error {
+	ReleaseChannelSlot(c)
+	if !tryAcquireChannelSlot(channel) {
+		if _, err := waitChannelSlot(c, []int{channel.Id}); err != nil {
+			return err
+		}
+	}
+	holdChannelSlot(c, channel.Id)
+	return nil
+}
+
+// waitChannelSlot 排队等待候选渠道中任意一个释放并发位
+func waitChannelSlot(c *gin.Context, channelIds []int) (*Channel, error) {
+	concurrencySetting := operation_setting.GetChannelConcurrencySetting()
+	if !concurrencySetting.QueueEnabled || concurrencySetting.QueueTimeoutSeconds <= 0 {
+		return nil, &channelsSaturatedError{channelIds: channelIds}
+	}
+	waiter := &channelSlotWaiter{
+		channelIds: make(map[int]bool, len(channelIds)),
+		ready:      make(chan int, 1),
+	}
+	for _, id := range channelIds {
+		waiter.channelIds[id] = true
+	}
+	channelInFlightLock.Lock()
+	if concurrencySetting.MaxQueueLength > 0 && len(channelSlotWaiters) >= concurrencySetting.MaxQueueLength {
+		channelInFlightLock.Unlock()
+		return nil, errors.New("所有可用渠道均已达到最大并发数，且排队请求过多")
+	}
+	channelSlotWaiters = append(channelSlotWaiters, waiter)
+	channelInFlightLock.Unlock()
+
+	var done <-chan struct{}
+	if c != nil && c.Request != nil {
+		done = c.Request.Context().Done()
+	}
+	timer := time.NewTimer(time.Duration(concurrencySetting.QueueTimeoutSeconds) * time.Second)
+	defer timer.Stop()
+	select {
+	case id := <-waiter.ready:
+		channel, err := CacheGetChannel(id)
+		if err != nil {
+			releaseChannelSlotById(id)
+			return nil, fmt.Errorf("数据库一致性错误，渠道# %d 不存在，请联系管理员修复", id)
+		}
+		return channel, nil
+	case <-timer.C:
+	case <-done:
+	}
+	channelInFlightLock.Lock()
+	for i, w := range channelSlotWaiters {
+		if w == waiter {
+			channelSlotWaiters = append(channelSlotWaiters[:i], channelSlotWaiters[i+1:]...)
+			break
+		}
+	}
+	channelInFlightLock.Unlock()
+	// 超时的同时可能已被分配了并发位，归还给其他请求
+	select {
+	case id := <-waiter.ready:
+		releaseChannelSlotById(id)
+	default:
+	}
+	return nil, fmt.Errorf("所有可用渠道均已达到最大并发数，排队等待 %d 秒后仍无空闲渠道", concurrencySetting.QueueTimeoutSeconds)
+}
@@ -0,0 +1,189 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"one-api/common"
+	"one-api/constant"
+	"one-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setupConcurrencyChannels 在内存缓存中准备同优先级的渠道，maxConcurrency 为 0 表示不限制
+func setupConcurrencyChannels(t *testing.T, maxConcurrency map[int]int) {
+	t.Helper()
+	oldMemoryCache := common.MemoryCacheEnabled
+	oldGroup2model2channels, oldChannelsIDM, oldSchedules := group2model2channels, channelsIDM, channelSchedules
+	oldSetting := *operation_setting.GetChannelConcurrencySetting()
+	t.Cleanup(func() {
+		common.MemoryCacheEnabled = oldMemoryCache
+		group2model2channels, channelsIDM, channelSchedules = oldGroup2model2channels, oldChannelsIDM, oldSchedules
+		*operation_setting.GetChannelConcurrencySetting() = oldSetting
+		channelInFlightLock.Lock()
+		channelInFlight = make(map[int]int)
+		channelSlotWaiters = nil
+		channelInFlightLock.Unlock()
+	})
+
+	common.MemoryCacheEnabled = true
+	channelSchedules = nil
+	channelsIDM = make(map[int]*Channel)
+	var ids []int
+	for id, limit := range maxConcurrency {
+		setting := fmt.Sprintf(`{"max_concurrency":%d}`, limit)
+		channelsIDM[id] = &Channel{Id: id, Status: common.ChannelStatusEnabled, Setting: &setting}
+		ids = append(ids, id)
+	}
+	group2model2channels = map[string]map[string][]int{"default": {"test-model": ids}}
+	channelInFlightLock.Lock()
+	channelInFlight = make(map[int]int)
+	channelSlotWaiters = nil
+	channelInFlightLock.Unlock()
+}
+
+func waitForQueueLength(t *testing.T, length int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		channelInFlightLock.Lock()
+		n := len(channelSlotWaiters)
+		channelInFlightLock.Unlock()
+		if n == length {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("queue length did not reach %d", length)
+}
+
+func TestGetRandomSatisfiedChannelSkipsSaturated(t *testing.T) {
+	setupConcurrencyChannels(t, map[int]int{1: 1, 2: 0})
+	if !tryAcquireChannelSlot(channelsIDM[1]) {
+		t.Fatal("first slot of channel 1 should be available")
+	}
+	for i := 0; i < 50; i++ {
+		channel, err := getRandomSatisfiedChannel("default", "test-model", 0)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if channel.Id != 2 {
+			t.Fatalf("selected saturated channel %d", channel.Id)
+		}
+		releaseChannelSlotById(channel.Id)
+	}
+}
+
+func TestGetRandomSatisfiedChannelAllSaturated(t *testing.T) {
+	// 全部满载时返回候选渠道，由调用方排队
+	setupConcurrencyChannels(t, map[int]int{1: 1})
+	tryAcquireChannelSlot(channelsIDM[1])
+	_, err := getRandomSatisfiedChannel("default", "test-model", 0)
+	saturated, ok := err.(*channelsSaturatedError)
+	if !ok || len(saturated.channelIds) != 1 || saturated.channelIds[0] != 1 {
+		t.Fatalf("expected saturated error for channel 1, got %v", err)
+	}
+}
+
+func TestWaitChannelSlotFIFO(t *testing.T) {
+	setupConcurrencyChannels(t, map[int]int{1: 1})
+	tryAcquireChannelSlot(channelsIDM[1])
+
+	order := make(chan string, 2)
+	for _, name := range []string{"first", "second"} {
+		name := name
+		go func() {
+			channel, err := waitChannelSlot(nil, []int{1})
+			if err != nil || channel.Id != 1 {
+				order <- "error"
+				return
+			}
+			order <- name
+		}()
+		waitForQueueLength(t, map[string]int{"first": 1, "second": 2}[name])
+	}
+
+	releaseChannelSlotById(1)
+	if got := <-order; got != "first" {
+		t.Fatalf("first release went to %s", got)
+	}
+	// 并发位直接转交，正在处理的请求数保持为 1
+	if n := GetChannelInFlight(1); n != 1 {
+		t.Fatalf("in flight = %d after hand-off, want 1", n)
+	}
+	releaseChannelSlotById(1)
+	if got := <-order; got != "second" {
+		t.Fatalf("second release went to %s", got)
+	}
+	releaseChannelSlotById(1)
+	if n := GetChannelInFlight(1); n != 0 {
+		t.Fatalf("in flight = %d after all releases, want 0", n)
+	}
+}
+
+func TestWaitChannelSlotReturnsSlotHandedAfterTimeout(t *testing.T) {
+	setupConcurrencyChannels(t, map[int]int{1: 1})
+	tryAcquireChannelSlot(channelsIDM[1])
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil).WithContext(ctx)
+	result := make(chan error, 1)
+	go func() {
+		_, err := waitChannelSlot(c, []int{1})
+		result <- err
+	}()
+	waitForQueueLength(t, 1)
+
+	// 请求取消后、移出队列前，渠道恰好把并发位转交给了该请求
+	channelInFlightLock.Lock()
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	releaseChannelSlotLocked(1)
+	channelInFlightLock.Unlock()
+
+	if err := <-result; err == nil {
+		t.Fatal("expected cancelled waiter to fail")
+	}
+	if n := GetChannelInFlight(1); n != 0 {
+		t.Fatalf("in flight = %d, handed slot was not returned", n)
+	}
+}
+
+func TestWaitChannelSlotQueueDisabled(t *testing.T) {
+	setupConcurrencyChannels(t, map[int]int{1: 1})
+	operation_setting.GetChannelConcurrencySetting().QueueEnabled = false
+	if _, err := waitChannelSlot(nil, []int{1}); err == nil {
+		t.Fatal("expected saturated error when queue is disabled")
+	}
+}
+
+func TestCacheGetRandomSatisfiedChannelReleasesOnRetry(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupConcurrencyChannels(t, map[int]int{1: 1, 2: 1})
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	for retry := 0; retry < 5; retry++ {
+		channel, _, err := CacheGetRandomSatisfiedChannel(c, "default", "test-model", retry)
+		if err != nil {
+			t.Fatalf("retry %d: unexpected error: %s", retry, err)
+		}
+		if held := common.GetContextKeyInt(c, constant.ContextKeyChannelSlot); held != channel.Id {
+			t.Fatalf("retry %d: context holds slot of channel %d, want %d", retry, held, channel.Id)
+		}
+		// 重新选择前释放上一次占用的并发位，任一时刻只占用一个
+		if total := GetChannelInFlight(1) + GetChannelInFlight(2); total != 1 {
+			t.Fatalf("retry %d: total in flight = %d, want 1", retry, total)
+		}
+	}
+	ReleaseChannelSlot(c)
+	if total := GetChannelInFlight(1) + GetChannelInFlight(2); total != 0 {
+		t.Fatalf("total in flight = %d after release, want 0", total)
+	}
+}
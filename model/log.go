@@ -192,12 +192,64 @@ func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams)
 		common.LogError(c, "failed to record log: "+err.Error())
 	}
 	if common.DataExportEnabled {
+		cacheHit, cacheMiss := getResponseCacheStat(params.Other)
 		gopool.Go(func() {
-			LogQuotaData(userId, username, params.ModelName, params.Quota, common.GetTimestamp(), params.PromptTokens+params.CompletionTokens)
+			LogQuotaData(userId, username, params.ModelName, params.Quota, common.GetTimestamp(), params.PromptTokens+params.CompletionTokens, cacheHit, cacheMiss)
 		})
 	}
 }
 
+// RecordBackgroundConsumeLog 记录异步任务、上游资源等在后台结算或退款产生的消费日志，退款时 quota 为负数
+func RecordBackgroundConsumeLog(ctx context.Context, userId int, params RecordConsumeLogParams) {
+	if !common.LogConsumeEnabled {
+		return
+	}
+	username, _ := GetUsernameById(userId, false)
+	log := &Log{
+		UserId:    userId,
+		Username:  username,
+		CreatedAt: common.GetTimestamp(),
+		Type:      LogTypeConsume,
+		Content:   params.Content,
+		TokenName: params.TokenName,
+		ModelName: params.ModelName,
+		Quota:     params.Quota,
+		ChannelId: params.ChannelId,
+		TokenId:   params.TokenId,
+		Group:     params.Group,
+		Other:     common.MapToJsonStr(params.Other),
+	}
+	if err := LOG_DB.Create(log).Error; err != nil {
+		common.LogError(ctx, "failed to record log: "+err.Error())
+	}
+	if common.DataExportEnabled {
+		gopool.Go(func() {
+			LogQuotaData(userId, username, params.ModelName, params.Quota, common.GetTimestamp(), 0, 0, 0)
+		})
+	}
+}
+
+// getResponseCacheStat 从消费日志的 other 信息中统计响应缓存的命中情况
+func getResponseCacheStat(other map[string]interface{}) (int, int) {
+	if other == nil {
+		return 0, 0
+	}
+	// 语义缓存在精确缓存未命中后检索，以语义缓存的结果为准
+	if state, ok := other["semantic_cache"].(string); ok {
+		if state == "hit" {
+			return 1, 0
+		}
+		return 0, 1
+	}
+	if hit, ok := other["response_cache_hit"].(bool); ok && hit {
+		return 1, 0
+	}
+	if miss, ok := other["response_cache_miss"].(bool); ok && miss {
+		return 0, 1
+	}
+	return 0, 0
+}
+
 func GetAllLogs(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, startIdx int, num int, channel int, group string) (logs []*Log, total int64, err error) {
 	var tx *gorm.DB
 	if logType == LogTypeUnknown {
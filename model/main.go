@@ -251,6 +251,9 @@ func migrateDB() error {
 		&QuotaData{},
 		&Task{},
 		&Setup{},
+		&MediaAsset{},
+		&TaskWebhookDelivery{},
+		&UpstreamFile{},
 	)
 	if err != nil {
 		return err
@@ -277,6 +280,9 @@ func migrateDBFast() error {
 		{&QuotaData{}, "QuotaData"},
 		{&Task{}, "Task"},
 		{&Setup{}, "Setup"},
+		{&MediaAsset{}, "MediaAsset"},
+		{&TaskWebhookDelivery{}, "TaskWebhookDelivery"},
+		{&UpstreamFile{}, "UpstreamFile"},
 	}
 	// 动态计算migration数量，确保errChan缓冲区足够大
 	errChan := make(chan error, len(migrations))
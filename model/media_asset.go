@@ -0,0 +1,45 @@
+package model
+
+// MediaAsset 网关本地保存的生成结果（图片、视频、音频）
+type MediaAsset struct {
+	Id          int    `json:"id"`
+	UserId      int    `json:"user_id" gorm:"index"`
+	Key         string `json:"key" gorm:"type:varchar(64);uniqueIndex"`
+	Kind        string `json:"kind" gorm:"type:varchar(16)"`
+	Backend     string `json:"backend" gorm:"type:varchar(16)"`
+	ContentType string `json:"content_type" gorm:"type:varchar(128)"`
+	Size        int64  `json:"size"`
+	SourceUrl   string `json:"source_url" gorm:"type:text"`
+	CreatedAt   int64  `json:"created_at" gorm:"bigint"`
+	ExpiresAt   int64  `json:"expires_at" gorm:"bigint;index"` // 0 表示永久保存
+}
+
+func (asset *MediaAsset) Insert() error {
+	return DB.Create(asset).Error
+}
+
+func (asset *MediaAsset) Delete() error {
+	return DB.Delete(asset).Error
+}
+
+func GetMediaAssetByKey(key string) (*MediaAsset, error) {
+	var asset MediaAsset
+	err := DB.Where(&MediaAsset{Key: key}).First(&asset).Error
+	if err != nil {
+		return nil, err
+	}
+	return &asset, nil
+}
+
+// GetUserMediaUsage 获取用户已占用的存储空间（字节）
+func GetUserMediaUsage(userId int) (int64, error) {
+	var usage int64
+	err := DB.Model(&MediaAsset{}).Where("user_id = ?", userId).Select("COALESCE(SUM(size), 0)").Scan(&usage).Error
+	return usage, err
+}
+
+func GetExpiredMediaAssets(now int64, limit int) ([]*MediaAsset, error) {
+	var assets []*MediaAsset
+	err := DB.Where("expires_at > 0 AND expires_at <= ?", now).Order("id").Limit(limit).Find(&assets).Error
+	return assets, err
+}
@@ -1,28 +1,30 @@
 package model
 
 type Midjourney struct {
-	Id          int    `json:"id"`
-	Code        int    `json:"code"`
-	UserId      int    `json:"user_id" gorm:"index"`
-	Action      string `json:"action" gorm:"type:varchar(40);index"`
-	MjId        string `json:"mj_id" gorm:"index"`
-	Prompt      string `json:"prompt"`
-	PromptEn    string `json:"prompt_en"`
-	Description string `json:"description"`
-	State       string `json:"state"`
-	SubmitTime  int64  `json:"submit_time" gorm:"index"`
-	StartTime   int64  `json:"start_time" gorm:"index"`
-	FinishTime  int64  `json:"finish_time" gorm:"index"`
-	ImageUrl    string `json:"image_url"`
-	VideoUrl    string `json:"video_url"`
-	VideoUrls   string `json:"video_urls"`
-	Status      string `json:"status" gorm:"type:varchar(20);index"`
-	Progress    string `json:"progress" gorm:"type:varchar(30);index"`
-	FailReason  string `json:"fail_reason"`
-	ChannelId   int    `json:"channel_id"`
-	Quota       int    `json:"quota"`
-	Buttons     string `json:"buttons"`
-	Properties  string `json:"properties"`
+	Id            int    `json:"id"`
+	Code          int    `json:"code"`
+	UserId        int    `json:"user_id" gorm:"index"`
+	Action        string `json:"action" gorm:"type:varchar(40);index"`
+	MjId          string `json:"mj_id" gorm:"index"`
+	Prompt        string `json:"prompt"`
+	PromptEn      string `json:"prompt_en"`
+	Description   string `json:"description"`
+	State         string `json:"state"`
+	SubmitTime    int64  `json:"submit_time" gorm:"index"`
+	StartTime     int64  `json:"start_time" gorm:"index"`
+	FinishTime    int64  `json:"finish_time" gorm:"index"`
+	ImageUrl      string `json:"image_url"`
+	VideoUrl      string `json:"video_url"`
+	VideoUrls     string `json:"video_urls"`
+	Status        string `json:"status" gorm:"type:varchar(20);index"`
+	Progress      string `json:"progress" gorm:"type:varchar(30);index"`
+	FailReason    string `json:"fail_reason"`
+	ChannelId     int    `json:"channel_id"`
+	Quota         int    `json:"quota"`
+	Buttons       string `json:"buttons"`
+	Properties    string `json:"properties"`
+	CallbackUrl   string `json:"callback_url" gorm:"type:varchar(512)"` // 任务完成后通知客户端的地址
+	CallbackNonce string `json:"-" gorm:"type:varchar(32)"`             // 上游回调地址中的随机串，回调只能更新对应的任务
 }
 
 // TaskQueryParams 用于包含所有搜索条件的结构体，可以根据需求添加更多字段
@@ -101,6 +103,16 @@ func GetAllUnFinishTasks() []*Midjourney {
 	return tasks
 }
 
+// GetUnFinishTasksAfter 按 id 分批获取未完成的任务
+func GetUnFinishTasksAfter(afterId int, limit int) []*Midjourney {
+	var tasks []*Midjourney
+	err := DB.Where("progress != ? AND id > ?", "100%", afterId).Limit(limit).Order("id").Find(&tasks).Error
+	if err != nil {
+		return nil
+	}
+	return tasks
+}
+
 func GetByOnlyMJId(mjId string) *Midjourney {
 	var mj *Midjourney
 	var err error
@@ -157,6 +169,17 @@ func (midjourney *Midjourney) Update() error {
 	return err
 }
 
+// UpdateWithStatus 仅当数据库中的任务状态仍为 oldStatus 时更新，返回是否更新成功
+func (midjourney *Midjourney) UpdateWithStatus(oldStatus string) (bool, error) {
+	result := DB.Model(midjourney).Where("status = ?", oldStatus).Select("*").Updates(midjourney)
+	return result.RowsAffected == 1, result.Error
+}
+
+// UpdateImageUrl 图片异步保存到网关后回写链接
+func (midjourney *Midjourney) UpdateImageUrl() error {
+	return DB.Model(midjourney).Update("image_url", midjourney.ImageUrl).Error
+}
+
 func MjBulkUpdate(mjIds []string, params map[string]any) error {
 	return DB.Model(&Midjourney{}).
 		Where("mj_id in (?)", mjIds).
@@ -4,6 +4,7 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"one-api/constant"
+	"one-api/dto"
 	commonRelay "one-api/relay/common"
 	"time"
 )
@@ -39,6 +40,10 @@ type Task struct {
 	Properties Properties            `json:"properties" gorm:"type:json"`
 
 	Data json.RawMessage `json:"data" gorm:"type:json"`
+
+	CallbackUrl   string `json:"callback_url" gorm:"type:varchar(512)"`        // 任务完成后通知客户端的地址
+	CallbackNonce string `json:"-" gorm:"type:varchar(32)"`                    // 上游回调地址中的随机串，回调只能更新对应的任务
+	ClientTaskID  string `json:"client_task_id" gorm:"type:varchar(50);index"` // 重新提交到其他渠道前的任务 id，客户端始终使用该 id 查询
 }
 
 func (t *Task) SetData(data any) {
@@ -46,6 +51,14 @@ func (t *Task) SetData(data any) {
 	t.Data = json.RawMessage(b)
 }
 
+// GetClientTaskID 返回客户端提交时拿到的任务 id
+func (t *Task) GetClientTaskID() string {
+	if t.ClientTaskID != "" {
+		return t.ClientTaskID
+	}
+	return t.TaskID
+}
+
 func (t *Task) GetData(v any) error {
 	err := json.Unmarshal(t.Data, &v)
 	return err
@@ -53,6 +66,28 @@ func (t *Task) GetData(v any) error {
 
 type Properties struct {
 	Input string `json:"input"`
+
+	// 重新提交任务所需的原始请求
+	Request     string `json:"request,omitempty"`
+	RequestPath string `json:"request_path,omitempty"`
+	Model       string `json:"model,omitempty"`
+	Group       string `json:"group,omitempty"`
+	Action      string `json:"action,omitempty"`
+	RetryCount  int    `json:"retry_count,omitempty"`
+
+	// 提交任务的令牌，后台结算与退款时同步调整令牌额度
+	TokenId int `json:"token_id,omitempty"`
+
+	VideoResult *dto.VideoTaskResult `json:"video_result,omitempty"` // 视频任务的统一结果
+	ClipQuota   int                  `json:"clip_quota,omitempty"`   // 按歌曲计费时单首歌曲的额度
+
+	// 按用量结算的任务（如 Claude 批处理）提交时的倍率
+	GroupRatio    float64 `json:"group_ratio,omitempty"`
+	BatchDiscount float64 `json:"batch_discount,omitempty"`
+	// 上游模型名到计费模型名的映射
+	ModelMapping map[string]string `json:"model_mapping,omitempty"`
+	// 多密钥渠道中创建批处理使用的密钥，查询与取消时使用同一密钥
+	KeyIndex int `json:"key_index,omitempty"`
 }
 
 func (m *Properties) Scan(val interface{}) error {
@@ -86,6 +121,9 @@ func InitTask(platform constant.TaskPlatform, relayInfo *commonRelay.TaskRelayIn
 		ChannelId:  relayInfo.ChannelId,
 		Platform:   platform,
 	}
+	if !relayInfo.IsPlayground {
+		t.Properties.TokenId = relayInfo.TokenId
+	}
 	return t
 }
 
@@ -181,6 +219,26 @@ func GetAllUnFinishSyncTasks(limit int) []*Task {
 	return tasks
 }
 
+// GetUnFinishSyncTaskPlatforms 获取存在未完成任务的平台
+func GetUnFinishSyncTaskPlatforms() []constant.TaskPlatform {
+	var platforms []constant.TaskPlatform
+	err := DB.Model(&Task{}).Where("progress != ?", "100%").Distinct("platform").Pluck("platform", &platforms).Error
+	if err != nil {
+		return nil
+	}
+	return platforms
+}
+
+// GetUnFinishSyncTasksAfter 按 id 分批获取平台未完成的任务
+func GetUnFinishSyncTasksAfter(platform constant.TaskPlatform, afterId int64, limit int) []*Task {
+	var tasks []*Task
+	err := DB.Where("platform = ? AND progress != ? AND id > ?", platform, "100%", afterId).Limit(limit).Order("id").Find(&tasks).Error
+	if err != nil {
+		return nil
+	}
+	return tasks
+}
+
 func GetByOnlyTaskId(taskId string) (*Task, bool, error) {
 	if taskId == "" {
 		return nil, false, nil
@@ -201,7 +259,7 @@ func GetByTaskId(userId int, taskId string) (*Task, bool, error) {
 	}
 	var task *Task
 	var err error
-	err = DB.Where("user_id = ? and task_id = ?", userId, taskId).
+	err = DB.Where("user_id = ? and (task_id = ? or client_task_id = ?)", userId, taskId, taskId).
 		First(&task).Error
 	exist, err := RecordExist(err)
 	if err != nil {
@@ -216,7 +274,7 @@ func GetByTaskIds(userId int, taskIds []any) ([]*Task, error) {
 	}
 	var task []*Task
 	var err error
-	err = DB.Where("user_id = ? and task_id in (?)", userId, taskIds).
+	err = DB.Where("user_id = ? and (task_id in (?) or client_task_id in (?))", userId, taskIds, taskIds).
 		Find(&task).Error
 	if err != nil {
 		return nil, err
@@ -240,6 +298,24 @@ func (Task *Task) Update() error {
 	return err
 }
 
+// UpdateWithStatus 仅当数据库中的任务状态仍为 oldStatus 时更新，回调与轮询同时处理同一次状态变化时只有一方成功，
+// 退款、结算等只应在返回 true 时执行
+func (Task *Task) UpdateWithStatus(oldStatus TaskStatus) (bool, error) {
+	result := DB.Model(Task).Where("status = ?", oldStatus).Select("*").Updates(Task)
+	return result.RowsAffected == 1, result.Error
+}
+
+// UpdateQuotaWithOld 仅当数据库中的额度仍为 oldQuota 时更新实际扣费额度，保证同一任务只结算一次
+func (Task *Task) UpdateQuotaWithOld(oldQuota int) (bool, error) {
+	result := DB.Model(Task).Where("quota = ?", oldQuota).Update("quota", Task.Quota)
+	return result.RowsAffected == 1, result.Error
+}
+
+// UpdateResult 只更新生成结果字段，生成结果的媒体异步保存完成后调用
+func (Task *Task) UpdateResult() error {
+	return DB.Model(Task).Select("fail_reason", "properties", "data").Updates(Task).Error
+}
+
 func TaskBulkUpdate(TaskIds []string, params map[string]any) error {
 	if len(TaskIds) == 0 {
 		return nil
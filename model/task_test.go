@@ -0,0 +1,102 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTaskTestDB(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite failed: %s", err)
+	}
+	// 每个连接各自对应一个内存数据库
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	if err = db.AutoMigrate(&Task{}, &Midjourney{}); err != nil {
+		t.Fatalf("migrate failed: %s", err)
+	}
+	oldDB := DB
+	DB = db
+	t.Cleanup(func() { DB = oldDB })
+}
+
+func TestTaskUpdateWithStatus(t *testing.T) {
+	setupTaskTestDB(t)
+	task := &Task{TaskID: "task_1", Status: TaskStatusSubmitted, Quota: 100}
+	if err := task.Insert(); err != nil {
+		t.Fatalf("insert failed: %s", err)
+	}
+
+	// 回调与轮询读到同一个旧状态，只有先写入的一方成功
+	callback, poller := *task, *task
+	callback.Status = TaskStatusSuccess
+	poller.Status = TaskStatusFailure
+	cases := []struct {
+		name        string
+		task        *Task
+		oldStatus   TaskStatus
+		wantUpdated bool
+	}{
+		{"first transition", &callback, TaskStatusSubmitted, true},
+		{"stale transition", &poller, TaskStatusSubmitted, false},
+		{"next transition from current status", &Task{ID: task.ID, TaskID: "task_1", Status: TaskStatusSuccess, Progress: "100%", Quota: 100}, TaskStatusSuccess, true},
+	}
+	for _, c := range cases {
+		updated, err := c.task.UpdateWithStatus(c.oldStatus)
+		if err != nil || updated != c.wantUpdated {
+			t.Errorf("%s: updated %v err %v, want %v", c.name, updated, err, c.wantUpdated)
+		}
+	}
+	var stored Task
+	DB.First(&stored, task.ID)
+	if stored.Status != TaskStatusSuccess || stored.Progress != "100%" {
+		t.Errorf("stored task status %s progress %s", stored.Status, stored.Progress)
+	}
+}
+
+func TestTaskUpdateQuotaWithOld(t *testing.T) {
+	setupTaskTestDB(t)
+	task := &Task{TaskID: "task_1", Status: TaskStatusSuccess, Quota: 100}
+	if err := task.Insert(); err != nil {
+		t.Fatalf("insert failed: %s", err)
+	}
+	first, second := *task, *task
+	first.Quota, second.Quota = 80, 60
+	if updated, err := first.UpdateQuotaWithOld(100); err != nil || !updated {
+		t.Fatalf("first settlement: updated %v err %v", updated, err)
+	}
+	if updated, err := second.UpdateQuotaWithOld(100); err != nil || updated {
+		t.Fatalf("second settlement should be rejected: updated %v err %v", updated, err)
+	}
+	var stored Task
+	DB.First(&stored, task.ID)
+	if stored.Quota != 80 {
+		t.Errorf("stored quota = %d, want 80", stored.Quota)
+	}
+}
+
+func TestMidjourneyUpdateWithStatus(t *testing.T) {
+	setupTaskTestDB(t)
+	mj := &Midjourney{MjId: "mj_1", Status: "SUBMITTED", Progress: "0%"}
+	if err := mj.Insert(); err != nil {
+		t.Fatalf("insert failed: %s", err)
+	}
+	callback, poller := *mj, *mj
+	callback.Status, callback.Progress = "SUCCESS", "100%"
+	poller.Status, poller.Progress = "FAILURE", "0%"
+	if updated, err := callback.UpdateWithStatus("SUBMITTED"); err != nil || !updated {
+		t.Fatalf("callback transition: updated %v err %v", updated, err)
+	}
+	if updated, err := poller.UpdateWithStatus("SUBMITTED"); err != nil || updated {
+		t.Fatalf("stale transition should be dropped: updated %v err %v", updated, err)
+	}
+	var stored Midjourney
+	DB.First(&stored, mj.Id)
+	if stored.Status != "SUCCESS" {
+		t.Errorf("stored status = %s, want SUCCESS", stored.Status)
+	}
+}
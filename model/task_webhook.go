@@ -0,0 +1,95 @@
+package model
+
+import (
+	"one-api/common"
+
+	"gorm.io/gorm"
+)
+
+const (
+	TaskWebhookStatusPending = "pending"
+	TaskWebhookStatusSuccess = "success"
+	TaskWebhookStatusFailed  = "failed"
+)
+
+// TaskWebhookDelivery 任务完成通知的投递记录
+type TaskWebhookDelivery struct {
+	Id          int    `json:"id"`
+	UserId      int    `json:"user_id" gorm:"index"`
+	Platform    string `json:"platform" gorm:"type:varchar(30)"`
+	TaskId      string `json:"task_id" gorm:"type:varchar(191);index"`
+	Url         string `json:"url" gorm:"type:varchar(512)"`
+	Payload     string `json:"payload" gorm:"type:text"`
+	Status      string `json:"status" gorm:"type:varchar(20);index"`
+	Attempts    int    `json:"attempts"`
+	StatusCode  int    `json:"status_code"`
+	LastError   string `json:"last_error" gorm:"type:text"`
+	NextRetryAt int64  `json:"next_retry_at" gorm:"bigint;index"`
+	CreatedAt   int64  `json:"created_at" gorm:"bigint;index"`
+	UpdatedAt   int64  `json:"updated_at" gorm:"bigint"`
+}
+
+type TaskWebhookQueryParams struct {
+	UserId int
+	TaskId string
+	Status string
+}
+
+func (delivery *TaskWebhookDelivery) Insert() error {
+	delivery.CreatedAt = common.GetTimestamp()
+	delivery.UpdatedAt = delivery.CreatedAt
+	return DB.Create(delivery).Error
+}
+
+func (delivery *TaskWebhookDelivery) Update() error {
+	delivery.UpdatedAt = common.GetTimestamp()
+	return DB.Save(delivery).Error
+}
+
+func GetTaskWebhookDeliveryById(id int) (*TaskWebhookDelivery, error) {
+	var delivery TaskWebhookDelivery
+	err := DB.First(&delivery, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// GetDueTaskWebhookDeliveries 获取到达重试时间的待投递记录
+func GetDueTaskWebhookDeliveries(now int64, limit int) []*TaskWebhookDelivery {
+	var deliveries []*TaskWebhookDelivery
+	err := DB.Where("status = ? AND next_retry_at <= ?", TaskWebhookStatusPending, now).
+		Order("next_retry_at").Limit(limit).Find(&deliveries).Error
+	if err != nil {
+		return nil
+	}
+	return deliveries
+}
+
+func buildTaskWebhookQuery(queryParams TaskWebhookQueryParams) *gorm.DB {
+	query := DB.Model(&TaskWebhookDelivery{})
+	if queryParams.UserId != 0 {
+		query = query.Where("user_id = ?", queryParams.UserId)
+	}
+	if queryParams.TaskId != "" {
+		query = query.Where("task_id = ?", queryParams.TaskId)
+	}
+	if queryParams.Status != "" {
+		query = query.Where("status = ?", queryParams.Status)
+	}
+	return query
+}
+
+func GetTaskWebhookDeliveries(startIdx int, num int, queryParams TaskWebhookQueryParams) ([]*TaskWebhookDelivery, int64, error) {
+	var deliveries []*TaskWebhookDelivery
+	var total int64
+	err := buildTaskWebhookQuery(queryParams).Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = buildTaskWebhookQuery(queryParams).Order("id desc").Limit(num).Offset(startIdx).Find(&deliveries).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return deliveries, total, nil
+}
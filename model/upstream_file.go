@@ -0,0 +1,71 @@
+package model
+
+import "time"
+
+// 上游文件所属平台
+const (
+	UpstreamFilePlatformClaude      = "claude"
+	UpstreamFilePlatformGeminiFile  = "gemini_file"
+	UpstreamFilePlatformGeminiCache = "gemini_cache"
+)
+
+// UpstreamFile 用户上传到上游渠道的文件或创建的上下文缓存，用于校验归属并把引用它的请求发往创建时的渠道
+type UpstreamFile struct {
+	Id        int    `json:"id"`
+	UserId    int    `json:"user_id" gorm:"index"`
+	TokenId   int    `json:"token_id" gorm:"index"`
+	ChannelId int    `json:"channel_id" gorm:"index"`
+	Platform  string `json:"platform" gorm:"type:varchar(16);index"`
+	FileId    string `json:"file_id" gorm:"type:varchar(128);index"`
+	Filename  string `json:"filename" gorm:"type:varchar(255)"`
+	MimeType  string `json:"mime_type" gorm:"type:varchar(128)"`
+	Size      int64  `json:"size"`
+	Data      string `json:"data" gorm:"type:text"` // 上游返回的文件信息
+	CreatedAt int64  `json:"created_at" gorm:"bigint"`
+	ExpiresAt int64  `json:"expires_at" gorm:"bigint;index"` // 0 表示不过期
+	KeyIndex  int    `json:"key_index"`                      // 多密钥渠道中创建资源使用的密钥，后续操作使用同一密钥
+
+	// 上下文缓存按存储时长计费
+	ModelName           string  `json:"model_name" gorm:"type:varchar(128)"`
+	StorageQuotaPerHour float64 `json:"storage_quota_per_hour"`
+}
+
+func (file *UpstreamFile) Insert() error {
+	return DB.Create(file).Error
+}
+
+func (file *UpstreamFile) Update() error {
+	return DB.Save(file).Error
+}
+
+func (file *UpstreamFile) Delete() error {
+	return DB.Delete(file).Error
+}
+
+// GetUserUpstreamFile 获取用户在指定平台上传的文件
+func GetUserUpstreamFile(userId int, platform string, fileId string) (*UpstreamFile, error) {
+	var file UpstreamFile
+	err := DB.Where("user_id = ? AND platform = ? AND file_id = ?", userId, platform, fileId).First(&file).Error
+	if err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// GetUserUpstreamFiles 按上传时间倒序获取用户在指定平台上传的未过期文件
+func GetUserUpstreamFiles(userId int, platform string, limit int) ([]*UpstreamFile, error) {
+	var files []*UpstreamFile
+	err := DB.Where("user_id = ? AND platform = ?", userId, platform).
+		Where("expires_at = 0 OR expires_at > ?", time.Now().Unix()).
+		Order("id desc").Limit(limit).Find(&files).Error
+	return files, err
+}
+
+// GetTokensUpstreamFiles 获取用户通过指定令牌在指定平台创建的未过期文件
+func GetTokensUpstreamFiles(userId int, tokenIds []int, platforms []string) ([]*UpstreamFile, error) {
+	var files []*UpstreamFile
+	err := DB.Where("user_id = ? AND token_id IN (?) AND platform IN (?)", userId, tokenIds, platforms).
+		Where("expires_at = 0 OR expires_at > ?", time.Now().Unix()).
+		Find(&files).Error
+	return files, err
+}
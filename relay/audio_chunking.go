@@ -0,0 +1,309 @@
+package relay
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"one-api/types"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 转写请求中由网关处理、不转发给上游的表单字段
+var transcriptionGatewayFields = map[string]bool{
+	"sample_rate": true,
+}
+
+// prepareTranscriptionAudio 转码或切分上传的音频，需要分段转写时返回预处理结果，否则原请求继续按原流程转发
+func prepareTranscriptionAudio(c *gin.Context) (*service.PreparedAudio, *types.NewAPIError) {
+	transcodeSetting := operation_setting.GetAudioTranscodeSetting()
+	if !transcodeSetting.Enabled {
+		return nil, nil
+	}
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		return nil, types.NewError(fmt.Errorf("file is required"), types.ErrorCodeInvalidRequest)
+	}
+	data, err := io.ReadAll(file)
+	_ = file.Close()
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeReadRequestBodyFailed)
+	}
+	sampleRate := transcodeSetting.PcmSampleRate
+	if value := c.Request.PostForm.Get("sample_rate"); value != "" {
+		sampleRate, err = strconv.Atoi(value)
+		if err != nil || sampleRate <= 0 {
+			return nil, types.NewError(fmt.Errorf("invalid sample_rate: %s", value), types.ErrorCodeInvalidRequest)
+		}
+	}
+
+	prepared, err := service.PrepareTranscriptionAudio(data, header.Filename, sampleRate)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeInvalidRequest)
+	}
+	if prepared == nil || len(prepared.Chunks) > 0 {
+		return prepared, nil
+	}
+	// 仅需转码时替换请求中的文件，之后按原流程转发
+	body, contentType, err := buildTranscriptionForm(c.Request.PostForm, nil, prepared.Filename, prepared.Data)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeConvertRequestFailed)
+	}
+	c.Request.Header.Set("Content-Type", contentType)
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	c.Request.MultipartForm = nil
+	c.Request.PostForm = nil
+	c.Request.Form = nil
+	c.Set(common.KeyRequestBody, body)
+	if err := c.Request.ParseMultipartForm(32 << 20); err != nil {
+		return nil, types.NewError(err, types.ErrorCodeConvertRequestFailed)
+	}
+	return nil, nil
+}
+
+// buildTranscriptionForm 以原请求的表单字段和新的音频文件构造 multipart 请求体，overrides 中的字段替换原值
+func buildTranscriptionForm(form map[string][]string, overrides map[string]string, filename string, data []byte) ([]byte, string, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for key, values := range form {
+		if transcriptionGatewayFields[key] {
+			continue
+		}
+		if _, ok := overrides[key]; ok {
+			continue
+		}
+		for _, value := range values {
+			_ = writer.WriteField(key, value)
+		}
+	}
+	for key, value := range overrides {
+		_ = writer.WriteField(key, value)
+	}
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := part.Write(data); err != nil {
+		return nil, "", err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return body.Bytes(), writer.FormDataContentType(), nil
+}
+
+// relayChunkedTranscription 将切分后的音频并发提交到多个渠道转写，按原音频时间轴拼接结果，按总时长计费
+func relayChunkedTranscription(c *gin.Context, info *relaycommon.RelayInfo, audioRequest *dto.AudioRequest, prepared *service.PreparedAudio) (*dto.Usage, *types.NewAPIError) {
+	responseFormat := audioRequest.ResponseFormat
+	upstreamFormat := "json"
+	switch responseFormat {
+	case "verbose_json", "srt", "vtt":
+		// 需要时间轴的格式统一按 verbose_json 转写后再拼接
+		upstreamFormat = "verbose_json"
+	}
+	overrides := map[string]string{
+		"model":           info.OriginModelName,
+		"response_format": upstreamFormat,
+		"stream":          "false",
+	}
+
+	chunks := prepared.Chunks
+	results := make([]*dto.WhisperVerboseJSONResponse, len(chunks))
+	errs := make([]*types.NewAPIError, len(chunks))
+	contexts := make([]*gin.Context, len(chunks))
+	for i, chunk := range chunks {
+		body, contentType, err := buildTranscriptionForm(c.Request.PostForm, overrides, chunk.Filename, chunk.Data)
+		if err != nil {
+			return nil, types.NewError(err, types.ErrorCodeConvertRequestFailed)
+		}
+		ctx, _, err := newInternalContext(c, c.Request.URL.Path, contentType, body)
+		if err != nil {
+			return nil, types.NewError(err, types.ErrorCodeConvertRequestFailed)
+		}
+		ctx.Set(common.KeyRequestBody, body)
+		contexts[i] = ctx
+	}
+
+	concurrency := max(operation_setting.GetAudioTranscodeSetting().Concurrency, 1)
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := range chunks {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+			results[i], errs[i] = transcribeAudioChunk(contexts[i], info, upstreamFormat)
+		}(i)
+	}
+	wg.Wait()
+	for i, apiErr := range errs {
+		if apiErr != nil {
+			apiErr.SetMessage(fmt.Sprintf("transcribe chunk %d failed: %s", i, apiErr.Error()))
+			return nil, apiErr
+		}
+	}
+
+	merged := &dto.WhisperVerboseJSONResponse{
+		Task:     "transcribe",
+		Duration: prepared.Duration,
+	}
+	var texts []string
+	for i, result := range results {
+		offset := chunks[i].Offset
+		if merged.Language == "" {
+			merged.Language = result.Language
+		}
+		if result.Task != "" {
+			merged.Task = result.Task
+		}
+		if text := strings.TrimSpace(result.Text); text != "" {
+			texts = append(texts, text)
+		}
+		for _, segment := range result.Segments {
+			segment.Id = len(merged.Segments)
+			segment.Start += offset
+			segment.End += offset
+			segment.Seek += int(offset * 100)
+			merged.Segments = append(merged.Segments, segment)
+		}
+		for _, word := range result.Words {
+			word.Start += offset
+			word.End += offset
+			merged.Words = append(merged.Words, word)
+		}
+	}
+	merged.Text = strings.Join(texts, " ")
+
+	if apiErr := writeTranscriptionResponse(c, info, responseFormat, merged); apiErr != nil {
+		return nil, apiErr
+	}
+	// 与按文件时长计费的规则一致：1 分钟相当于 1k tokens
+	tokens := int(math.Round(math.Ceil(prepared.Duration) / 60.0 * 1000))
+	return &dto.Usage{PromptTokens: tokens, TotalTokens: tokens}, nil
+}
+
+// transcribeAudioChunk 在随机选择的渠道上转写一段音频，不单独计费
+func transcribeAudioChunk(ctx *gin.Context, info *relaycommon.RelayInfo, upstreamFormat string) (*dto.WhisperVerboseJSONResponse, *types.NewAPIError) {
+	defer model.ReleaseChannelSlot(ctx)
+	if apiErr := setupInternalChannel(ctx, info.UsingGroup, info.OriginModelName); apiErr != nil {
+		return nil, apiErr
+	}
+	if err := ctx.Request.ParseMultipartForm(32 << 20); err != nil {
+		return nil, types.NewError(err, types.ErrorCodeInvalidRequest)
+	}
+	chunkInfo := relaycommon.GenRelayInfoOpenAIAudio(ctx)
+	chunkRequest := &dto.AudioRequest{
+		Model:          info.OriginModelName,
+		ResponseFormat: upstreamFormat,
+	}
+	if err := helper.ModelMappedHelper(ctx, chunkInfo, chunkRequest); err != nil {
+		return nil, types.NewError(err, types.ErrorCodeChannelModelMappedError)
+	}
+	adaptor := GetAdaptor(chunkInfo.ApiType)
+	if adaptor == nil {
+		return nil, types.NewError(fmt.Errorf("invalid api type: %d", chunkInfo.ApiType), types.ErrorCodeInvalidApiType)
+	}
+	adaptor.Init(chunkInfo)
+	requestBody, err := adaptor.ConvertAudioRequest(ctx, chunkInfo, *chunkRequest)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeConvertRequestFailed)
+	}
+	resp, err := adaptor.DoRequest(ctx, chunkInfo, requestBody)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeDoRequestFailed)
+	}
+	httpResp, ok := resp.(*http.Response)
+	if !ok || httpResp == nil {
+		return nil, types.NewError(fmt.Errorf("unexpected response"), types.ErrorCodeBadResponse)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		newAPIError := service.RelayErrorHandler(httpResp, false)
+		service.ResetStatusCode(newAPIError, ctx.GetString("status_code_mapping"))
+		return nil, newAPIError
+	}
+	defer common.CloseResponseBodyGracefully(httpResp)
+	responseBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeReadResponseBodyFailed)
+	}
+	result := &dto.WhisperVerboseJSONResponse{}
+	if err := common.Unmarshal(responseBody, result); err != nil {
+		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	return result, nil
+}
+
+// writeTranscriptionResponse 按客户端要求的格式输出拼接后的转写结果
+func writeTranscriptionResponse(c *gin.Context, info *relaycommon.RelayInfo, responseFormat string, result *dto.WhisperVerboseJSONResponse) *types.NewAPIError {
+	if info.IsStream {
+		helper.SetEventStreamHeaders(c)
+		for i, segment := range result.Segments {
+			delta := segment.Text
+			if i == 0 {
+				delta = strings.TrimLeft(delta, " ")
+			}
+			_ = helper.ObjectData(c, map[string]string{"type": "transcript.text.delta", "delta": delta})
+		}
+		if len(result.Segments) == 0 {
+			_ = helper.ObjectData(c, map[string]string{"type": "transcript.text.delta", "delta": result.Text})
+		}
+		_ = helper.ObjectData(c, map[string]string{"type": "transcript.text.done", "text": result.Text})
+		return nil
+	}
+	switch responseFormat {
+	case "text":
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(result.Text))
+	case "srt", "vtt":
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(formatSubtitles(result.Segments, responseFormat == "vtt")))
+	case "verbose_json":
+		c.JSON(http.StatusOK, result)
+	default:
+		c.JSON(http.StatusOK, dto.AudioResponse{Text: result.Text})
+	}
+	return nil
+}
+
+func formatSubtitles(segments []dto.Segment, vtt bool) string {
+	var sb strings.Builder
+	if vtt {
+		sb.WriteString("WEBVTT\n\n")
+	}
+	for i, segment := range segments {
+		if !vtt {
+			sb.WriteString(strconv.Itoa(i + 1))
+			sb.WriteString("\n")
+		}
+		sb.WriteString(formatSubtitleTime(segment.Start, vtt))
+		sb.WriteString(" --> ")
+		sb.WriteString(formatSubtitleTime(segment.End, vtt))
+		sb.WriteString("\n")
+		sb.WriteString(strings.TrimSpace(segment.Text))
+		sb.WriteString("\n\n")
+	}
+	return sb.String()
+}
+
+func formatSubtitleTime(seconds float64, vtt bool) string {
+	millis := int64(math.Round(seconds * 1000))
+	separator := ","
+	if vtt {
+		separator = "."
+	}
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", millis/3600000, millis/60000%60, millis/1000%60, separator, millis%1000)
+}
@@ -35,6 +35,7 @@ func getAndValidAudioRequest(c *gin.Context, info *relaycommon.RelayInfo) (*dto.
 				return nil, err
 			}
 		}
+		info.IsStream = audioRequest.StreamFormat != ""
 	default:
 		err = c.Request.ParseForm()
 		if err != nil {
@@ -52,6 +53,7 @@ func getAndValidAudioRequest(c *gin.Context, info *relaycommon.RelayInfo) (*dto.
 		if audioRequest.ResponseFormat == "" {
 			audioRequest.ResponseFormat = "json"
 		}
+		info.IsStream = formData.Get("stream") == "true"
 	}
 	return audioRequest, nil
 }
@@ -93,6 +95,24 @@ func AudioHelper(c *gin.Context) (newAPIError *types.NewAPIError) {
 		return types.NewError(err, types.ErrorCodeChannelModelMappedError)
 	}
 
+	if relayInfo.RelayMode != relayconstant.RelayModeAudioSpeech {
+		// 上游不支持的格式先转码，超过上游限制的音频切分后分段转写
+		var prepared *service.PreparedAudio
+		prepared, openaiErr = prepareTranscriptionAudio(c)
+		if openaiErr != nil {
+			return openaiErr
+		}
+		if prepared != nil {
+			var usage *dto.Usage
+			usage, openaiErr = relayChunkedTranscription(c, relayInfo, audioRequest, prepared)
+			if openaiErr != nil {
+				return openaiErr
+			}
+			postConsumeQuota(c, relayInfo, usage, preConsumedQuota, userQuota, priceData, fmt.Sprintf("音频分段转写 %d 段，总时长 %.1f 秒", len(prepared.Chunks), prepared.Duration))
+			return nil
+		}
+	}
+
 	adaptor := GetAdaptor(relayInfo.ApiType)
 	if adaptor == nil {
 		return types.NewError(fmt.Errorf("invalid api type: %d", relayInfo.ApiType), types.ErrorCodeInvalidApiType)
@@ -16,6 +16,7 @@ import (
 const (
 	RequestModeCompletion = 1
 	RequestModeMessage    = 2
+	RequestModeConverse   = 3
 )
 
 type Adaptor struct {
@@ -56,6 +57,18 @@ func (a *Adaptor) ConvertOpenAIRequest(c *gin.Context, info *relaycommon.RelayIn
 		return nil, errors.New("request is nil")
 	}
 
+	// 非 Anthropic 模型使用 Converse API
+	if !isAnthropicModel(awsModelID(request.Model)) {
+		converseReq, err := requestOpenAI2Converse(request)
+		if err != nil {
+			return nil, err
+		}
+		a.RequestMode = RequestModeConverse
+		c.Set("request_model", request.Model)
+		c.Set("converted_request", converseReq)
+		return converseReq, nil
+	}
+
 	var claudeReq *dto.ClaudeRequest
 	var err error
 	claudeReq, err = claude.RequestOpenAI2ClaudeMessage(*request)
@@ -86,6 +99,14 @@ func (a *Adaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, request
 }
 
 func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (usage any, err *types.NewAPIError) {
+	if a.RequestMode == RequestModeConverse {
+		if info.IsStream {
+			err, usage = awsConverseStreamHandler(c, info)
+		} else {
+			err, usage = awsConverseHandler(c, info)
+		}
+		return
+	}
 	if info.IsStream {
 		err, usage = awsStreamHandler(c, resp, info, a.RequestMode)
 	} else {
@@ -13,6 +13,15 @@ var awsModelIDMap = map[string]string{
 	"claude-3-7-sonnet-20250219": "anthropic.claude-3-7-sonnet-20250219-v1:0",
 	"claude-sonnet-4-20250514":   "anthropic.claude-sonnet-4-20250514-v1:0",
 	"claude-opus-4-20250514":     "anthropic.claude-opus-4-20250514-v1:0",
+	"nova-micro-v1:0":            "amazon.nova-micro-v1:0",
+	"nova-lite-v1:0":             "amazon.nova-lite-v1:0",
+	"nova-pro-v1:0":              "amazon.nova-pro-v1:0",
+	"llama3-1-8b-instruct":       "meta.llama3-1-8b-instruct-v1:0",
+	"llama3-1-70b-instruct":      "meta.llama3-1-70b-instruct-v1:0",
+	"llama3-3-70b-instruct":      "meta.llama3-3-70b-instruct-v1:0",
+	"mistral-large-2407":         "mistral.mistral-large-2407-v1:0",
+	"command-r-plus":             "cohere.command-r-plus-v1:0",
+	"command-r":                  "cohere.command-r-v1:0",
 }
 
 var awsModelCanCrossRegionMap = map[string]map[string]bool{
@@ -54,6 +63,24 @@ var awsModelCanCrossRegionMap = map[string]map[string]bool{
 	"anthropic.claude-opus-4-20250514-v1:0": {
 		"us": true,
 	},
+	"amazon.nova-micro-v1:0": {
+		"us": true,
+		"eu": true,
+		"ap": true,
+	},
+	"amazon.nova-lite-v1:0": {
+		"us": true,
+		"eu": true,
+		"ap": true,
+	},
+	"amazon.nova-pro-v1:0": {
+		"us": true,
+		"eu": true,
+		"ap": true,
+	},
+	"meta.llama3-3-70b-instruct-v1:0": {
+		"us": true,
+	},
 }
 
 var awsRegionCrossModelPrefixMap = map[string]string{
@@ -0,0 +1,453 @@
+package aws
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/types"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+	bedrockruntimeTypes "github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// Converse API 以统一的消息格式调用 Llama、Mistral、Cohere、Nova 等非 Anthropic 模型
+
+// isAnthropicModel 判断 Bedrock 模型是否使用 Claude 格式的 InvokeModel 接口，跨区域推理的模型 id 带有区域前缀
+func isAnthropicModel(awsModelId string) bool {
+	return strings.HasPrefix(awsModelId, "anthropic.") || strings.Contains(awsModelId, ".anthropic.")
+}
+
+func requestOpenAI2Converse(request *dto.GeneralOpenAIRequest) (*bedrockruntime.ConverseInput, error) {
+	converseReq := &bedrockruntime.ConverseInput{}
+
+	inferenceConfig := &bedrockruntimeTypes.InferenceConfiguration{}
+	maxTokens := request.MaxCompletionTokens
+	if maxTokens == 0 {
+		maxTokens = request.MaxTokens
+	}
+	if maxTokens > 0 {
+		inferenceConfig.MaxTokens = aws.Int32(int32(maxTokens))
+	}
+	if request.Temperature != nil {
+		inferenceConfig.Temperature = aws.Float32(float32(*request.Temperature))
+	}
+	if request.TopP > 0 {
+		inferenceConfig.TopP = aws.Float32(float32(request.TopP))
+	}
+	switch stop := request.Stop.(type) {
+	case string:
+		inferenceConfig.StopSequences = []string{stop}
+	case []interface{}:
+		for _, s := range stop {
+			if str, ok := s.(string); ok {
+				inferenceConfig.StopSequences = append(inferenceConfig.StopSequences, str)
+			}
+		}
+	}
+	converseReq.InferenceConfig = inferenceConfig
+
+	if len(request.Tools) > 0 {
+		toolConfig := &bedrockruntimeTypes.ToolConfiguration{}
+		for _, tool := range request.Tools {
+			parameters := tool.Function.Parameters
+			if parameters == nil {
+				parameters = map[string]any{"type": "object", "properties": map[string]any{}}
+			}
+			spec := bedrockruntimeTypes.ToolSpecification{
+				Name:        aws.String(tool.Function.Name),
+				InputSchema: &bedrockruntimeTypes.ToolInputSchemaMemberJson{Value: document.NewLazyDocument(parameters)},
+			}
+			if tool.Function.Description != "" {
+				spec.Description = aws.String(tool.Function.Description)
+			}
+			toolConfig.Tools = append(toolConfig.Tools, &bedrockruntimeTypes.ToolMemberToolSpec{Value: spec})
+		}
+		toolConfig.ToolChoice = toolChoiceOpenAI2Converse(request.ToolChoice)
+		converseReq.ToolConfig = toolConfig
+	}
+
+	for _, message := range request.Messages {
+		switch message.Role {
+		case "system", "developer":
+			if text := message.StringContent(); text != "" {
+				converseReq.System = append(converseReq.System, &bedrockruntimeTypes.SystemContentBlockMemberText{Value: text})
+			}
+		case "tool":
+			block := &bedrockruntimeTypes.ContentBlockMemberToolResult{
+				Value: bedrockruntimeTypes.ToolResultBlock{
+					ToolUseId: aws.String(message.ToolCallId),
+					Content: []bedrockruntimeTypes.ToolResultContentBlock{
+						&bedrockruntimeTypes.ToolResultContentBlockMemberText{Value: message.StringContent()},
+					},
+				},
+			}
+			converseReq.Messages = appendConverseMessage(converseReq.Messages, bedrockruntimeTypes.ConversationRoleUser, block)
+		case "assistant":
+			var blocks []bedrockruntimeTypes.ContentBlock
+			if text := message.StringContent(); text != "" {
+				blocks = append(blocks, &bedrockruntimeTypes.ContentBlockMemberText{Value: text})
+			}
+			for _, toolCall := range message.ParseToolCalls() {
+				input := make(map[string]any)
+				if toolCall.Function.Arguments != "" {
+					if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &input); err != nil {
+						return nil, fmt.Errorf("tool call %s arguments is not a json object", toolCall.Function.Name)
+					}
+				}
+				blocks = append(blocks, &bedrockruntimeTypes.ContentBlockMemberToolUse{
+					Value: bedrockruntimeTypes.ToolUseBlock{
+						ToolUseId: aws.String(toolCall.ID),
+						Name:      aws.String(toolCall.Function.Name),
+						Input:     document.NewLazyDocument(input),
+					},
+				})
+			}
+			converseReq.Messages = appendConverseMessage(converseReq.Messages, bedrockruntimeTypes.ConversationRoleAssistant, blocks...)
+		default:
+			blocks, err := contentOpenAI2Converse(message)
+			if err != nil {
+				return nil, err
+			}
+			converseReq.Messages = appendConverseMessage(converseReq.Messages, bedrockruntimeTypes.ConversationRoleUser, blocks...)
+		}
+	}
+	// Converse 要求第一条消息来自用户
+	if len(converseReq.Messages) > 0 && converseReq.Messages[0].Role != bedrockruntimeTypes.ConversationRoleUser {
+		converseReq.Messages = append([]bedrockruntimeTypes.Message{{
+			Role:    bedrockruntimeTypes.ConversationRoleUser,
+			Content: []bedrockruntimeTypes.ContentBlock{&bedrockruntimeTypes.ContentBlockMemberText{Value: "..."}},
+		}}, converseReq.Messages...)
+	}
+	return converseReq, nil
+}
+
+// appendConverseMessage Converse 要求用户与助手消息交替出现，相邻的同角色消息合并为一条
+func appendConverseMessage(messages []bedrockruntimeTypes.Message, role bedrockruntimeTypes.ConversationRole, blocks ...bedrockruntimeTypes.ContentBlock) []bedrockruntimeTypes.Message {
+	if len(blocks) == 0 {
+		return messages
+	}
+	if len(messages) > 0 && messages[len(messages)-1].Role == role {
+		messages[len(messages)-1].Content = append(messages[len(messages)-1].Content, blocks...)
+		return messages
+	}
+	return append(messages, bedrockruntimeTypes.Message{Role: role, Content: blocks})
+}
+
+func contentOpenAI2Converse(message dto.Message) ([]bedrockruntimeTypes.ContentBlock, error) {
+	if message.IsStringContent() {
+		text := message.StringContent()
+		if text == "" {
+			return nil, nil
+		}
+		return []bedrockruntimeTypes.ContentBlock{&bedrockruntimeTypes.ContentBlockMemberText{Value: text}}, nil
+	}
+	var blocks []bedrockruntimeTypes.ContentBlock
+	for _, content := range message.ParseContent() {
+		switch content.Type {
+		case dto.ContentTypeText:
+			if content.Text != "" {
+				blocks = append(blocks, &bedrockruntimeTypes.ContentBlockMemberText{Value: content.Text})
+			}
+		case dto.ContentTypeImageURL:
+			image, err := imageOpenAI2Converse(content.GetImageMedia().Url)
+			if err != nil {
+				return nil, err
+			}
+			blocks = append(blocks, &bedrockruntimeTypes.ContentBlockMemberImage{Value: *image})
+		}
+	}
+	return blocks, nil
+}
+
+func imageOpenAI2Converse(imageUrl string) (*bedrockruntimeTypes.ImageBlock, error) {
+	var mimeType, base64Data string
+	if strings.HasPrefix(imageUrl, "http") {
+		fileData, err := service.GetFileBase64FromUrl(imageUrl)
+		if err != nil {
+			return nil, fmt.Errorf("get file base64 from url failed: %s", err.Error())
+		}
+		mimeType, base64Data = fileData.MimeType, fileData.Base64Data
+	} else {
+		_, format, data, err := service.DecodeBase64ImageData(imageUrl)
+		if err != nil {
+			return nil, err
+		}
+		mimeType, base64Data = "image/"+format, data
+	}
+	data, err := base64.StdEncoding.DecodeString(base64Data)
+	if err != nil {
+		return nil, fmt.Errorf("decode image data failed: %s", err.Error())
+	}
+	format := bedrockruntimeTypes.ImageFormat(strings.TrimPrefix(mimeType, "image/"))
+	if format == "jpg" {
+		format = bedrockruntimeTypes.ImageFormatJpeg
+	}
+	return &bedrockruntimeTypes.ImageBlock{
+		Format: format,
+		Source: &bedrockruntimeTypes.ImageSourceMemberBytes{Value: data},
+	}, nil
+}
+
+func toolChoiceOpenAI2Converse(toolChoice any) bedrockruntimeTypes.ToolChoice {
+	switch choice := toolChoice.(type) {
+	case string:
+		switch choice {
+		case "auto":
+			return &bedrockruntimeTypes.ToolChoiceMemberAuto{}
+		case "required":
+			return &bedrockruntimeTypes.ToolChoiceMemberAny{}
+		}
+	case map[string]any:
+		if function, ok := choice["function"].(map[string]any); ok {
+			if name, ok := function["name"].(string); ok && name != "" {
+				return &bedrockruntimeTypes.ToolChoiceMemberTool{Value: bedrockruntimeTypes.SpecificToolChoice{Name: aws.String(name)}}
+			}
+		}
+	}
+	return nil
+}
+
+func stopReasonConverse2OpenAI(reason bedrockruntimeTypes.StopReason) string {
+	switch reason {
+	case bedrockruntimeTypes.StopReasonToolUse:
+		return "tool_calls"
+	case bedrockruntimeTypes.StopReasonMaxTokens:
+		return "length"
+	case "guardrail_intervened", bedrockruntimeTypes.StopReasonContentFiltered:
+		return "content_filter"
+	default:
+		return "stop"
+	}
+}
+
+func usageConverse2OpenAI(tokenUsage *bedrockruntimeTypes.TokenUsage) *dto.Usage {
+	usage := &dto.Usage{}
+	if tokenUsage == nil {
+		return usage
+	}
+	usage.PromptTokens = int(aws.ToInt32(tokenUsage.InputTokens))
+	usage.CompletionTokens = int(aws.ToInt32(tokenUsage.OutputTokens))
+	usage.TotalTokens = int(aws.ToInt32(tokenUsage.TotalTokens))
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	return usage
+}
+
+func responseConverse2OpenAI(output *bedrockruntime.ConverseOutput, id string, model string) (*dto.OpenAITextResponse, error) {
+	message := dto.Message{Role: "assistant"}
+	var text strings.Builder
+	var toolCalls []dto.ToolCallResponse
+	if outputMessage, ok := output.Output.(*bedrockruntimeTypes.ConverseOutputMemberMessage); ok {
+		for _, block := range outputMessage.Value.Content {
+			switch v := block.(type) {
+			case *bedrockruntimeTypes.ContentBlockMemberText:
+				text.WriteString(v.Value)
+			case *bedrockruntimeTypes.ContentBlockMemberToolUse:
+				arguments := []byte("{}")
+				if v.Value.Input != nil {
+					var err error
+					arguments, err = v.Value.Input.MarshalSmithyDocument()
+					if err != nil {
+						return nil, err
+					}
+				}
+				toolCalls = append(toolCalls, dto.ToolCallResponse{
+					ID:   aws.ToString(v.Value.ToolUseId),
+					Type: "function",
+					Function: dto.FunctionResponse{
+						Name:      aws.ToString(v.Value.Name),
+						Arguments: string(arguments),
+					},
+				})
+			}
+		}
+	}
+	message.SetStringContent(text.String())
+	if len(toolCalls) > 0 {
+		message.SetToolCalls(toolCalls)
+	}
+	return &dto.OpenAITextResponse{
+		Id:      id,
+		Model:   model,
+		Object:  "chat.completion",
+		Created: common.GetTimestamp(),
+		Choices: []dto.OpenAITextResponseChoice{
+			{
+				Index:        0,
+				Message:      message,
+				FinishReason: stopReasonConverse2OpenAI(output.StopReason),
+			},
+		},
+		Usage: *usageConverse2OpenAI(output.Usage),
+	}, nil
+}
+
+func getConverseRequest(c *gin.Context) (*bedrockruntime.ConverseInput, error) {
+	converseReq, ok := c.Get("converted_request")
+	if !ok {
+		return nil, errors.New("aws converse request not found")
+	}
+	input, ok := converseReq.(*bedrockruntime.ConverseInput)
+	if !ok {
+		return nil, errors.New("aws converse request not found")
+	}
+	return input, nil
+}
+
+func awsConverseHandler(c *gin.Context, info *relaycommon.RelayInfo) (*types.NewAPIError, *dto.Usage) {
+	awsCli, err := newAwsClient(c, info)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeChannelAwsClientError), nil
+	}
+	converseReq, err := getConverseRequest(c)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeInvalidRequest), nil
+	}
+	input := *converseReq
+	input.ModelId = aws.String(resolveAwsModelId(awsCli, c.GetString("request_model")))
+
+	awsResp, err := awsCli.Converse(c.Request.Context(), &input)
+	if err != nil {
+		return types.NewError(errors.Wrap(err, "Converse"), types.ErrorCodeChannelAwsClientError), nil
+	}
+	fullResponse, err := responseConverse2OpenAI(awsResp, helper.GetResponseID(c), info.UpstreamModelName)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeBadResponseBody), nil
+	}
+	if fullResponse.Usage.TotalTokens == 0 {
+		fullResponse.Usage = *service.ResponseText2Usage(fullResponse.Choices[0].Message.StringContent(), info.UpstreamModelName, info.PromptTokens)
+	}
+	jsonResponse, err := common.Marshal(fullResponse)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeBadResponseBody), nil
+	}
+	c.Writer.Header().Set("Content-Type", "application/json")
+	c.Writer.WriteHeader(http.StatusOK)
+	_, _ = c.Writer.Write(jsonResponse)
+	return nil, &fullResponse.Usage
+}
+
+func awsConverseStreamHandler(c *gin.Context, info *relaycommon.RelayInfo) (*types.NewAPIError, *dto.Usage) {
+	awsCli, err := newAwsClient(c, info)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeChannelAwsClientError), nil
+	}
+	converseReq, err := getConverseRequest(c)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeInvalidRequest), nil
+	}
+	input := &bedrockruntime.ConverseStreamInput{
+		ModelId:                      aws.String(resolveAwsModelId(awsCli, c.GetString("request_model"))),
+		Messages:                     converseReq.Messages,
+		System:                       converseReq.System,
+		InferenceConfig:              converseReq.InferenceConfig,
+		ToolConfig:                   converseReq.ToolConfig,
+		AdditionalModelRequestFields: converseReq.AdditionalModelRequestFields,
+	}
+
+	awsResp, err := awsCli.ConverseStream(c.Request.Context(), input)
+	if err != nil {
+		return types.NewError(errors.Wrap(err, "ConverseStream"), types.ErrorCodeChannelAwsClientError), nil
+	}
+	stream := awsResp.GetStream()
+	defer stream.Close()
+
+	helper.SetEventStreamHeaders(c)
+	responseId := helper.GetResponseID(c)
+	createdTime := common.GetTimestamp()
+	model := info.UpstreamModelName
+	newChunk := func() *dto.ChatCompletionsStreamResponse {
+		return &dto.ChatCompletionsStreamResponse{
+			Id:      responseId,
+			Object:  "chat.completion.chunk",
+			Created: createdTime,
+			Model:   model,
+			Choices: []dto.ChatCompletionsStreamResponseChoice{{Index: 0}},
+		}
+	}
+
+	var usage *dto.Usage
+	var responseText strings.Builder
+	finishReason := "stop"
+	// 内容块序号到工具调用序号的映射
+	toolIndexes := make(map[int32]int)
+	for event := range stream.Events() {
+		switch v := event.(type) {
+		case *bedrockruntimeTypes.ConverseStreamOutputMemberMessageStart:
+			info.SetFirstResponseTime()
+			chunk := newChunk()
+			chunk.Choices[0].Delta.Role = "assistant"
+			chunk.Choices[0].Delta.SetContentString("")
+			_ = helper.ObjectData(c, chunk)
+		case *bedrockruntimeTypes.ConverseStreamOutputMemberContentBlockStart:
+			toolUse, ok := v.Value.Start.(*bedrockruntimeTypes.ContentBlockStartMemberToolUse)
+			if !ok {
+				continue
+			}
+			toolIndex := len(toolIndexes)
+			toolIndexes[aws.ToInt32(v.Value.ContentBlockIndex)] = toolIndex
+			toolCall := dto.ToolCallResponse{
+				ID:   aws.ToString(toolUse.Value.ToolUseId),
+				Type: "function",
+				Function: dto.FunctionResponse{
+					Name: aws.ToString(toolUse.Value.Name),
+				},
+			}
+			toolCall.SetIndex(toolIndex)
+			chunk := newChunk()
+			chunk.Choices[0].Delta.ToolCalls = []dto.ToolCallResponse{toolCall}
+			_ = helper.ObjectData(c, chunk)
+		case *bedrockruntimeTypes.ConverseStreamOutputMemberContentBlockDelta:
+			info.SetFirstResponseTime()
+			chunk := newChunk()
+			switch delta := v.Value.Delta.(type) {
+			case *bedrockruntimeTypes.ContentBlockDeltaMemberText:
+				responseText.WriteString(delta.Value)
+				chunk.Choices[0].Delta.SetContentString(delta.Value)
+			case *bedrockruntimeTypes.ContentBlockDeltaMemberToolUse:
+				arguments := aws.ToString(delta.Value.Input)
+				responseText.WriteString(arguments)
+				toolCall := dto.ToolCallResponse{
+					Function: dto.FunctionResponse{Arguments: arguments},
+				}
+				toolCall.SetIndex(toolIndexes[aws.ToInt32(v.Value.ContentBlockIndex)])
+				chunk.Choices[0].Delta.ToolCalls = []dto.ToolCallResponse{toolCall}
+			default:
+				continue
+			}
+			_ = helper.ObjectData(c, chunk)
+		case *bedrockruntimeTypes.ConverseStreamOutputMemberMessageStop:
+			finishReason = stopReasonConverse2OpenAI(v.Value.StopReason)
+		case *bedrockruntimeTypes.ConverseStreamOutputMemberMetadata:
+			usage = usageConverse2OpenAI(v.Value.Usage)
+		case *bedrockruntimeTypes.ConverseStreamOutputMemberContentBlockStop:
+		default:
+			common.SysError(fmt.Sprintf("unknown converse stream event: %T", v))
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return types.NewError(errors.Wrap(err, "ConverseStream"), types.ErrorCodeBadResponse), nil
+	}
+
+	if usage == nil || usage.TotalTokens == 0 {
+		usage = service.ResponseText2Usage(responseText.String(), model, info.PromptTokens)
+	}
+	_ = helper.ObjectData(c, helper.GenerateStopResponse(responseId, createdTime, model, finishReason))
+	if info.ShouldIncludeUsage {
+		_ = helper.ObjectData(c, helper.GenerateFinalUsageResponse(responseId, createdTime, model, *usage))
+	}
+	helper.Done(c)
+	return nil, usage
+}
@@ -0,0 +1,216 @@
+package aws
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream"
+	"github.com/gin-gonic/gin"
+)
+
+const testPngDataUrl = "data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNk+M9QDwADhgGAWjR9awAAAABJRU5ErkJggg=="
+
+// newBedrockStandIn 启动一个模拟 Bedrock Runtime 的本地 HTTP 服务，记录收到的请求路径与请求体
+func newBedrockStandIn(t *testing.T, handler func(w http.ResponseWriter, r *http.Request, body map[string]any)) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256") {
+			t.Errorf("request is not signed with sigv4: %q", r.Header.Get("Authorization"))
+		}
+		raw, _ := io.ReadAll(r.Body)
+		body := make(map[string]any)
+		if err := json.Unmarshal(raw, &body); err != nil {
+			t.Errorf("invalid request body: %s", err)
+		}
+		handler(w, r, body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newConverseTestContext(t *testing.T, baseUrl string, request *dto.GeneralOpenAIRequest) (*gin.Context, *httptest.ResponseRecorder, *Adaptor, *relaycommon.RelayInfo) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	testEndpoint = baseUrl
+	t.Cleanup(func() { testEndpoint = "" })
+	info := &relaycommon.RelayInfo{
+		ApiKey:             "ak|sk|us-east-1",
+		UpstreamModelName:  request.Model,
+		IsStream:           request.Stream,
+		ShouldIncludeUsage: request.Stream,
+	}
+	adaptor := &Adaptor{}
+	adaptor.Init(info)
+	if _, err := adaptor.ConvertOpenAIRequest(c, info, request); err != nil {
+		t.Fatalf("convert request: %s", err)
+	}
+	return c, recorder, adaptor, info
+}
+
+func TestConverseWithToolsAndImages(t *testing.T) {
+	server := newBedrockStandIn(t, func(w http.ResponseWriter, r *http.Request, body map[string]any) {
+		if r.URL.Path != "/model/meta.llama3-1-70b-instruct-v1:0/converse" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		messages := body["messages"].([]any)
+		if len(messages) != 3 {
+			t.Fatalf("expected 3 alternating messages, got %d", len(messages))
+		}
+		userContent := messages[0].(map[string]any)["content"].([]any)
+		image := userContent[1].(map[string]any)["image"].(map[string]any)
+		if image["format"] != "png" || image["source"].(map[string]any)["bytes"] == "" {
+			t.Errorf("unexpected image block %v", image)
+		}
+		toolUse := messages[1].(map[string]any)["content"].([]any)[0].(map[string]any)["toolUse"].(map[string]any)
+		if toolUse["toolUseId"] != "call_1" || toolUse["input"].(map[string]any)["city"] != "Paris" {
+			t.Errorf("unexpected tool use block %v", toolUse)
+		}
+		toolResult := messages[2].(map[string]any)["content"].([]any)[0].(map[string]any)["toolResult"].(map[string]any)
+		if toolResult["toolUseId"] != "call_1" {
+			t.Errorf("unexpected tool result block %v", toolResult)
+		}
+		if body["system"].([]any)[0].(map[string]any)["text"] != "be brief" {
+			t.Errorf("unexpected system %v", body["system"])
+		}
+		tools := body["toolConfig"].(map[string]any)["tools"].([]any)
+		if tools[0].(map[string]any)["toolSpec"].(map[string]any)["name"] != "get_weather" {
+			t.Errorf("unexpected tools %v", tools)
+		}
+		if body["inferenceConfig"].(map[string]any)["maxTokens"] != float64(128) {
+			t.Errorf("unexpected inference config %v", body["inferenceConfig"])
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"output":{"message":{"role":"assistant","content":[{"text":"Checking again."},{"toolUse":{"toolUseId":"call_2","name":"get_weather","input":{"city":"Lyon"}}}]}},"stopReason":"tool_use","usage":{"inputTokens":21,"outputTokens":9,"totalTokens":30},"metrics":{"latencyMs":12}}`))
+	})
+
+	request := &dto.GeneralOpenAIRequest{
+		Model:     "llama3-1-70b-instruct",
+		MaxTokens: 128,
+		Messages: []dto.Message{
+			{Role: "system", Content: "be brief"},
+			{Role: "user", Content: []any{
+				map[string]any{"type": "text", "text": "What is the weather here?"},
+				map[string]any{"type": "image_url", "image_url": map[string]any{"url": testPngDataUrl}},
+			}},
+			{Role: "assistant", Content: "", ToolCalls: json.RawMessage(`[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]`)},
+			{Role: "tool", ToolCallId: "call_1", Content: "sunny"},
+		},
+		Tools: []dto.ToolCallRequest{{
+			Type: "function",
+			Function: dto.FunctionRequest{
+				Name:       "get_weather",
+				Parameters: map[string]any{"type": "object", "properties": map[string]any{"city": map[string]any{"type": "string"}}},
+			},
+		}},
+		ToolChoice: "auto",
+	}
+	c, recorder, adaptor, info := newConverseTestContext(t, server.URL, request)
+	usage, apiErr := adaptor.DoResponse(c, nil, info)
+	if apiErr != nil {
+		t.Fatalf("converse failed: %s", apiErr.Error())
+	}
+	if u := usage.(*dto.Usage); u.PromptTokens != 21 || u.CompletionTokens != 9 || u.TotalTokens != 30 {
+		t.Errorf("unexpected usage %+v", u)
+	}
+	var response dto.OpenAITextResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("invalid response: %s", err)
+	}
+	choice := response.Choices[0]
+	if choice.FinishReason != "tool_calls" || choice.Message.StringContent() != "Checking again." {
+		t.Errorf("unexpected choice %+v", choice)
+	}
+	toolCalls := choice.Message.ParseToolCalls()
+	if len(toolCalls) != 1 || toolCalls[0].ID != "call_2" || toolCalls[0].Function.Arguments != `{"city":"Lyon"}` {
+		t.Errorf("unexpected tool calls %+v", toolCalls)
+	}
+}
+
+func writeConverseStreamEvent(t *testing.T, w io.Writer, eventType string, payload string) {
+	t.Helper()
+	message := eventstream.Message{
+		Headers: eventstream.Headers{
+			{Name: ":message-type", Value: eventstream.StringValue("event")},
+			{Name: ":event-type", Value: eventstream.StringValue(eventType)},
+			{Name: ":content-type", Value: eventstream.StringValue("application/json")},
+		},
+		Payload: []byte(payload),
+	}
+	if err := eventstream.NewEncoder().Encode(w, message); err != nil {
+		t.Fatalf("encode event: %s", err)
+	}
+}
+
+func TestConverseStream(t *testing.T) {
+	server := newBedrockStandIn(t, func(w http.ResponseWriter, r *http.Request, body map[string]any) {
+		if r.URL.Path != "/model/us.amazon.nova-pro-v1:0/converse-stream" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		var buf bytes.Buffer
+		writeConverseStreamEvent(t, &buf, "messageStart", `{"role":"assistant"}`)
+		writeConverseStreamEvent(t, &buf, "contentBlockDelta", `{"contentBlockIndex":0,"delta":{"text":"Hel"}}`)
+		writeConverseStreamEvent(t, &buf, "contentBlockDelta", `{"contentBlockIndex":0,"delta":{"text":"lo"}}`)
+		writeConverseStreamEvent(t, &buf, "contentBlockStop", `{"contentBlockIndex":0}`)
+		writeConverseStreamEvent(t, &buf, "contentBlockStart", `{"contentBlockIndex":1,"start":{"toolUse":{"toolUseId":"call_9","name":"lookup"}}}`)
+		writeConverseStreamEvent(t, &buf, "contentBlockDelta", `{"contentBlockIndex":1,"delta":{"toolUse":{"input":"{\"q\":1}"}}}`)
+		writeConverseStreamEvent(t, &buf, "contentBlockStop", `{"contentBlockIndex":1}`)
+		writeConverseStreamEvent(t, &buf, "messageStop", `{"stopReason":"tool_use"}`)
+		writeConverseStreamEvent(t, &buf, "metadata", `{"usage":{"inputTokens":7,"outputTokens":4,"totalTokens":11},"metrics":{"latencyMs":5}}`)
+		w.Header().Set("Content-Type", "application/vnd.amazon.eventstream")
+		_, _ = w.Write(buf.Bytes())
+	})
+
+	request := &dto.GeneralOpenAIRequest{
+		Model:    "nova-pro-v1:0",
+		Stream:   true,
+		Messages: []dto.Message{{Role: "user", Content: "hi"}},
+	}
+	c, recorder, adaptor, info := newConverseTestContext(t, server.URL, request)
+	usage, apiErr := adaptor.DoResponse(c, nil, info)
+	if apiErr != nil {
+		t.Fatalf("converse stream failed: %s", apiErr.Error())
+	}
+	if u := usage.(*dto.Usage); u.PromptTokens != 7 || u.CompletionTokens != 4 {
+		t.Errorf("unexpected usage %+v", u)
+	}
+
+	var content strings.Builder
+	var arguments strings.Builder
+	var finishReason string
+	var sawUsage bool
+	for _, line := range strings.Split(recorder.Body.String(), "\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok || data == "[DONE]" {
+			continue
+		}
+		var chunk dto.ChatCompletionsStreamResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatalf("invalid chunk %q: %s", data, err)
+		}
+		if chunk.Usage != nil {
+			sawUsage = chunk.Usage.TotalTokens == 11
+		}
+		for _, choice := range chunk.Choices {
+			content.WriteString(choice.Delta.GetContentString())
+			for _, toolCall := range choice.Delta.ToolCalls {
+				arguments.WriteString(toolCall.Function.Arguments)
+			}
+			if choice.FinishReason != nil {
+				finishReason = *choice.FinishReason
+			}
+		}
+	}
+	if content.String() != "Hello" || arguments.String() != `{"q":1}` || finishReason != "tool_calls" || !sawUsage {
+		t.Errorf("unexpected stream: content=%q arguments=%q finish=%q usage=%v", content.String(), arguments.String(), finishReason, sawUsage)
+	}
+}
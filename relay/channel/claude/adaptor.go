@@ -62,6 +62,10 @@ func (a *Adaptor) SetupRequestHeader(c *gin.Context, req *http.Header, info *rel
 		anthropicVersion = "2023-06-01"
 	}
 	req.Set("anthropic-version", anthropicVersion)
+	// 引用 Files API 文件等功能需要客户端指定的 beta
+	if beta := c.Request.Header.Get("anthropic-beta"); beta != "" {
+		req.Set("anthropic-beta", beta)
+	}
 	model_setting.GetClaudeSettings().WriteHeaders(info.OriginModelName, req)
 	return nil
 }
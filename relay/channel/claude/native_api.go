@@ -0,0 +1,77 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/model"
+	"one-api/service"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NativeApiRequest 直接调用 Anthropic 原生接口（count_tokens、Message Batches、Files）所需的渠道信息
+type NativeApiRequest struct {
+	BaseURL     string
+	Key         string
+	Proxy       string
+	Method      string
+	Path        string
+	Body        io.Reader
+	ContentType string
+	Beta        string // 客户端未指定 anthropic-beta 时使用
+}
+
+// NewNativeApiRequest 使用渠道的地址、代理与创建资源时使用的密钥构造请求
+func NewNativeApiRequest(channel *model.Channel, keyIndex int, method string, path string) *NativeApiRequest {
+	baseURL := channel.GetBaseURL()
+	if baseURL == "" {
+		baseURL = "https://api.anthropic.com"
+	}
+	return &NativeApiRequest{
+		BaseURL: baseURL,
+		Key:     channel.GetKeyByIndex(keyIndex),
+		Proxy:   channel.GetSetting().Proxy,
+		Method:  method,
+		Path:    path,
+	}
+}
+
+// Do 发送请求，透传客户端的 anthropic-version 与 anthropic-beta 请求头，c 为空时用于后台轮询
+func (r *NativeApiRequest) Do(c *gin.Context) (*http.Response, error) {
+	ctx := context.Background()
+	anthropicVersion, beta := "", ""
+	if c != nil {
+		ctx = c.Request.Context()
+		anthropicVersion = c.Request.Header.Get("anthropic-version")
+		beta = c.Request.Header.Get("anthropic-beta")
+	}
+	req, err := http.NewRequestWithContext(ctx, r.Method, strings.TrimSuffix(r.BaseURL, "/")+r.Path, r.Body)
+	if err != nil {
+		return nil, err
+	}
+	if r.ContentType != "" {
+		req.Header.Set("Content-Type", r.ContentType)
+	}
+	req.Header.Set("x-api-key", r.Key)
+	if anthropicVersion == "" {
+		anthropicVersion = "2023-06-01"
+	}
+	req.Header.Set("anthropic-version", anthropicVersion)
+	if beta == "" {
+		beta = r.Beta
+	}
+	if beta != "" {
+		req.Header.Set("anthropic-beta", beta)
+	}
+	client := service.GetHttpClient()
+	if r.Proxy != "" {
+		client, err = service.NewProxyHttpClient(r.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("new proxy http client failed: %w", err)
+		}
+	}
+	return client.Do(req)
+}
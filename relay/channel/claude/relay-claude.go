@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"one-api/common"
+	"one-api/constant"
 	"one-api/dto"
 	"one-api/relay/channel/openrouter"
 	relaycommon "one-api/relay/common"
@@ -22,6 +23,9 @@ const (
 	WebSearchMaxUsesLow    = 1
 	WebSearchMaxUsesMedium = 5
 	WebSearchMaxUsesHigh   = 10
+
+	// StructuredOutputToolName response_format 为 json_schema 时强制调用的工具名
+	StructuredOutputToolName = "structured_output"
 )
 
 func stopReasonClaude2OpenAI(reason string) string {
@@ -226,6 +230,21 @@ func RequestOpenAI2ClaudeMessage(textRequest dto.GeneralOpenAIRequest) (*dto.Cla
 		}
 	}
 
+	// response_format 为 json_schema 时以 schema 作为工具参数并强制调用，响应中再还原为文本
+	if schema := structuredOutputSchema(textRequest); schema != nil {
+		claudeRequest.AddTool(&dto.Tool{
+			Name:        StructuredOutputToolName,
+			Description: "Respond with a JSON value that conforms to the input schema.",
+			InputSchema: schema,
+		})
+		if claudeRequest.Thinking == nil {
+			claudeRequest.ToolChoice = &dto.ClaudeToolChoice{Type: "tool", Name: StructuredOutputToolName}
+		} else {
+			// 开启思考时不支持强制调用工具
+			claudeRequest.ToolChoice = &dto.ClaudeToolChoice{Type: "auto"}
+		}
+	}
+
 	if textRequest.Stop != nil {
 		// stop maybe string/array string, convert to array string
 		switch textRequest.Stop.(type) {
@@ -396,6 +415,19 @@ func RequestOpenAI2ClaudeMessage(textRequest dto.GeneralOpenAIRequest) (*dto.Cla
 	return &claudeRequest, nil
 }
 
+// structuredOutputSchema 返回需要通过工具实现的 json_schema，已指定工具或 schema 不是 object 时返回 nil
+func structuredOutputSchema(textRequest dto.GeneralOpenAIRequest) map[string]any {
+	if len(textRequest.Tools) > 0 || textRequest.ResponseFormat == nil || textRequest.ResponseFormat.Type != "json_schema" ||
+		textRequest.ResponseFormat.JsonSchema == nil {
+		return nil
+	}
+	schema, ok := textRequest.ResponseFormat.JsonSchema.Schema.(map[string]any)
+	if !ok || schema["type"] != "object" {
+		return nil
+	}
+	return schema
+}
+
 func StreamResponseClaude2OpenAI(reqMode int, claudeResponse *dto.ClaudeResponse) *dto.ChatCompletionsStreamResponse {
 	var response dto.ChatCompletionsStreamResponse
 	response.Object = "chat.completion.chunk"
@@ -497,6 +529,7 @@ func ResponseClaude2OpenAI(reqMode int, claudeResponse *dto.ClaudeResponse) *dto
 	}
 	tools := make([]dto.ToolCallResponse, 0)
 	thinkingContent := ""
+	structuredOutput := false
 
 	if reqMode == RequestModeCompletion {
 		choice := dto.OpenAITextResponseChoice{
@@ -515,6 +548,11 @@ func ResponseClaude2OpenAI(reqMode int, claudeResponse *dto.ClaudeResponse) *dto
 			switch message.Type {
 			case "tool_use":
 				args, _ := json.Marshal(message.Input)
+				if message.Name == StructuredOutputToolName {
+					responseText = string(args)
+					structuredOutput = true
+					continue
+				}
 				tools = append(tools, dto.ToolCallResponse{
 					ID:   message.Id,
 					Type: "function", // compatible with other OpenAI derivative applications
@@ -538,6 +576,9 @@ func ResponseClaude2OpenAI(reqMode int, claudeResponse *dto.ClaudeResponse) *dto
 		},
 		FinishReason: stopReasonClaude2OpenAI(claudeResponse.StopReason),
 	}
+	if structuredOutput && len(tools) == 0 {
+		choice.FinishReason = constant.FinishReasonStop
+	}
 	choice.SetStringContent(responseText)
 	if len(responseThinking) > 0 {
 		choice.ReasoningContent = responseThinking
@@ -559,6 +600,8 @@ type ClaudeResponseInfo struct {
 	ResponseText strings.Builder
 	Usage        *dto.Usage
 	Done         bool
+	// StructuredOutput 正在输出结构化输出工具的参数
+	StructuredOutput bool
 }
 
 func FormatClaudeResponseInfo(requestMode int, claudeResponse *dto.ClaudeResponse, oaiResponse *dto.ChatCompletionsStreamResponse, claudeInfo *ClaudeResponseInfo) bool {
@@ -630,6 +673,9 @@ func HandleStreamResponseData(c *gin.Context, info *relaycommon.RelayInfo, claud
 		helper.ClaudeChunkData(c, claudeResponse, data)
 	} else if info.RelayFormat == relaycommon.RelayFormatOpenAI {
 		response := StreamResponseClaude2OpenAI(requestMode, &claudeResponse)
+		if response != nil {
+			convertStructuredOutputChunk(&claudeResponse, response, claudeInfo)
+		}
 
 		if !FormatClaudeResponseInfo(requestMode, &claudeResponse, response, claudeInfo) {
 			return nil
@@ -643,6 +689,33 @@ func HandleStreamResponseData(c *gin.Context, info *relaycommon.RelayInfo, claud
 	return nil
 }
 
+// convertStructuredOutputChunk 将结构化输出工具的参数增量改写为文本增量
+func convertStructuredOutputChunk(claudeResponse *dto.ClaudeResponse, response *dto.ChatCompletionsStreamResponse, claudeInfo *ClaudeResponseInfo) {
+	if claudeResponse.Type == "content_block_start" && claudeResponse.ContentBlock != nil &&
+		claudeResponse.ContentBlock.Type == "tool_use" && claudeResponse.ContentBlock.Name == StructuredOutputToolName {
+		claudeInfo.StructuredOutput = true
+	}
+	if !claudeInfo.StructuredOutput {
+		return
+	}
+	for i := range response.Choices {
+		choice := &response.Choices[i]
+		if len(choice.Delta.ToolCalls) > 0 {
+			var content strings.Builder
+			for _, toolCall := range choice.Delta.ToolCalls {
+				content.WriteString(toolCall.Function.Arguments)
+			}
+			choice.Delta.ToolCalls = nil
+			choice.Delta.SetContentString(content.String())
+			claudeInfo.ResponseText.WriteString(content.String())
+		}
+		if choice.FinishReason != nil && *choice.FinishReason == constant.FinishReasonToolCalls {
+			finishReason := constant.FinishReasonStop
+			choice.FinishReason = &finishReason
+		}
+	}
+}
+
 func HandleStreamFinalResponse(c *gin.Context, info *relaycommon.RelayInfo, claudeInfo *ClaudeResponseInfo, requestMode int) {
 
 	if requestMode == RequestModeCompletion {
@@ -36,7 +36,11 @@ func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInf
 	if !strings.HasPrefix(info.UpstreamModelName, "imagen") {
 		return nil, errors.New("not supported model for image generation")
 	}
+	return ImageRequestOpenAI2Imagen(request), nil
+}
 
+// ImageRequestOpenAI2Imagen 将 OpenAI 图片生成请求转换为 Imagen predict 请求
+func ImageRequestOpenAI2Imagen(request dto.ImageRequest) GeminiImageRequest {
 	// convert size to aspect ratio
 	aspectRatio := "1:1" // default aspect ratio
 	switch request.Size {
@@ -46,6 +50,10 @@ func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInf
 		aspectRatio = "9:16"
 	case "1792x1024":
 		aspectRatio = "16:9"
+	case "896x1280":
+		aspectRatio = "3:4"
+	case "1280x896":
+		aspectRatio = "4:3"
 	}
 
 	// build gemini imagen request
@@ -62,7 +70,7 @@ func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInf
 		},
 	}
 
-	return geminiRequest, nil
+	return geminiRequest
 }
 
 func (a *Adaptor) Init(info *relaycommon.RelayInfo) {
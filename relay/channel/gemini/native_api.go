@@ -0,0 +1,65 @@
+package gemini
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/constant"
+	"one-api/model"
+	"one-api/service"
+	"strings"
+)
+
+// NativeApiRequest 直接调用 Gemini 原生接口（cachedContents、files）所需的渠道信息
+type NativeApiRequest struct {
+	BaseURL string
+	Key     string
+	Proxy   string
+	Method  string
+	Path    string // 包含版本与查询参数，如 /v1beta/cachedContents
+	Body    io.Reader
+	Header  http.Header
+	// 上传文件时透传请求体长度
+	ContentLength int64
+}
+
+// NewNativeApiRequest 使用渠道的地址、代理与创建资源时使用的密钥构造请求
+func NewNativeApiRequest(channel *model.Channel, keyIndex int, method string, path string) *NativeApiRequest {
+	baseURL := channel.GetBaseURL()
+	if baseURL == "" {
+		baseURL = constant.ChannelBaseURLs[constant.ChannelTypeGemini]
+	}
+	return &NativeApiRequest{
+		BaseURL: baseURL,
+		Key:     channel.GetKeyByIndex(keyIndex),
+		Proxy:   channel.GetSetting().Proxy,
+		Method:  method,
+		Path:    path,
+		Header:  http.Header{},
+	}
+}
+
+func (r *NativeApiRequest) Do(ctx context.Context) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, r.Method, strings.TrimSuffix(r.BaseURL, "/")+r.Path, r.Body)
+	if err != nil {
+		return nil, err
+	}
+	if r.ContentLength > 0 {
+		req.ContentLength = r.ContentLength
+	}
+	for key, values := range r.Header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	req.Header.Set("x-goog-api-key", r.Key)
+	client := service.GetHttpClient()
+	if r.Proxy != "" {
+		client, err = service.NewProxyHttpClient(r.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("new proxy http client failed: %w", err)
+		}
+	}
+	return client.Do(req)
+}
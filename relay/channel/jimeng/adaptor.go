@@ -13,6 +13,8 @@ import (
 	relaycommon "one-api/relay/common"
 	relayconstant "one-api/relay/constant"
 	"one-api/types"
+	"strconv"
+	"strings"
 )
 
 type Adaptor struct {
@@ -70,6 +72,10 @@ func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInf
 	if request.ResponseFormat == "" || request.ResponseFormat == "url" {
 		payload.ReturnURL = true // Default to returning image URLs
 	}
+	if width, height, ok := strings.Cut(request.Size, "x"); ok {
+		payload.Width, _ = strconv.Atoi(width)
+		payload.Height, _ = strconv.Atoi(height)
+	}
 
 	if len(request.ExtraFields) > 0 {
 		if err := json.Unmarshal(request.ExtraFields, &payload); err != nil {
@@ -40,6 +40,8 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 	switch info.RelayMode {
 	case relayconstant.RelayModeEmbeddings:
 		return info.BaseUrl + "/api/embed", nil
+	case relayconstant.RelayModeChatCompletions:
+		return info.BaseUrl + "/api/chat", nil
 	default:
 		return relaycommon.GetFullRequestURL(info.BaseUrl, info.RequestURLPath, info.ChannelType), nil
 	}
@@ -55,6 +57,9 @@ func (a *Adaptor) ConvertOpenAIRequest(c *gin.Context, info *relaycommon.RelayIn
 	if request == nil {
 		return nil, errors.New("request is nil")
 	}
+	if info.RelayMode == relayconstant.RelayModeChatCompletions {
+		return requestOpenAI2OllamaChat(*request)
+	}
 	return requestOpenAI2Ollama(*request)
 }
 
@@ -76,6 +81,14 @@ func (a *Adaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, request
 }
 
 func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (usage any, err *types.NewAPIError) {
+	if info.RelayMode == relayconstant.RelayModeChatCompletions {
+		if info.IsStream {
+			usage, err = ollamaChatStreamHandler(c, info, resp)
+		} else {
+			usage, err = ollamaChatHandler(c, info, resp)
+		}
+		return
+	}
 	if info.IsStream {
 		usage, err = openai.OaiStreamHandler(c, info, resp)
 	} else {
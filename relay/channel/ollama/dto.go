@@ -1,6 +1,9 @@
 package ollama
 
-import "one-api/dto"
+import (
+	"encoding/json"
+	"one-api/dto"
+)
 
 type OllamaRequest struct {
 	Model            string                `json:"model,omitempty"`
@@ -30,6 +33,7 @@ type Options struct {
 	PresencePenalty  float64  `json:"presence_penalty,omitempty"`
 	NumPredict       int      `json:"num_predict,omitempty"`
 	NumCtx           int      `json:"num_ctx,omitempty"`
+	Stop             []string `json:"stop,omitempty"`
 }
 
 type OllamaEmbeddingRequest struct {
@@ -42,4 +46,63 @@ type OllamaEmbeddingResponse struct {
 	Error     string      `json:"error,omitempty"`
 	Model     string      `json:"model"`
 	Embedding [][]float64 `json:"embeddings,omitempty"`
+	// 输入 token 数
+	PromptEvalCount int `json:"prompt_eval_count,omitempty"`
+}
+
+type OllamaChatRequest struct {
+	Model     string                `json:"model"`
+	Messages  []OllamaChatMessage   `json:"messages"`
+	Tools     []dto.ToolCallRequest `json:"tools,omitempty"`
+	Format    any                   `json:"format,omitempty"` // "json" 或 JSON Schema
+	Options   *Options              `json:"options,omitempty"`
+	Stream    bool                  `json:"stream"` // Ollama 默认流式，需要显式传 false
+	KeepAlive json.RawMessage       `json:"keep_alive,omitempty"`
+}
+
+type OllamaChatMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Thinking  string           `json:"thinking,omitempty"`
+	Images    []string         `json:"images,omitempty"` // 不带 data: 前缀的 base64
+	ToolCalls []OllamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+type OllamaToolCall struct {
+	Function OllamaToolCallFunction `json:"function"`
+}
+
+type OllamaToolCallFunction struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// OllamaChatResponse /api/chat 的响应，流式时每行一个对象，最后一行 done 为 true
+type OllamaChatResponse struct {
+	Model           string            `json:"model"`
+	CreatedAt       string            `json:"created_at"`
+	Message         OllamaChatMessage `json:"message"`
+	Done            bool              `json:"done"`
+	DoneReason      string            `json:"done_reason,omitempty"`
+	PromptEvalCount int               `json:"prompt_eval_count,omitempty"`
+	EvalCount       int               `json:"eval_count,omitempty"`
+	Error           string            `json:"error,omitempty"`
+}
+
+type OllamaTagsResponse struct {
+	Models []struct {
+		Name  string `json:"name"`
+		Model string `json:"model"`
+	} `json:"models"`
+}
+
+type OllamaPullRequest struct {
+	Model  string `json:"model"`
+	Stream bool   `json:"stream"`
+}
+
+type OllamaPullResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
 }
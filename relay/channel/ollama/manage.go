@@ -0,0 +1,91 @@
+package ollama
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/service"
+	"strings"
+)
+
+func doOllamaRequest(method string, url string, key string, body any) ([]byte, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := common.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	resp, err := service.GetHttpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var errResp OllamaPullResponse
+		if common.Unmarshal(responseBody, &errResp) == nil && errResp.Error != "" {
+			return nil, fmt.Errorf("ollama error: %s", errResp.Error)
+		}
+		return nil, fmt.Errorf("status code: %d", resp.StatusCode)
+	}
+	return responseBody, nil
+}
+
+// FetchOllamaModels 通过 /api/tags 获取 Ollama 已下载的模型列表
+func FetchOllamaModels(baseURL string, key string) ([]string, error) {
+	body, err := doOllamaRequest(http.MethodGet, strings.TrimSuffix(baseURL, "/")+"/api/tags", key, nil)
+	if err != nil {
+		return nil, err
+	}
+	var tags OllamaTagsResponse
+	if err = common.Unmarshal(body, &tags); err != nil {
+		return nil, err
+	}
+	models := make([]string, 0, len(tags.Models))
+	for _, model := range tags.Models {
+		name := model.Name
+		if name == "" {
+			name = model.Model
+		}
+		models = append(models, name)
+	}
+	return models, nil
+}
+
+// PullOllamaModel 通过 /api/pull 让 Ollama 下载模型，下载完成后返回
+func PullOllamaModel(baseURL string, key string, modelName string) (*OllamaPullResponse, error) {
+	if modelName == "" {
+		return nil, errors.New("model is required")
+	}
+	body, err := doOllamaRequest(http.MethodPost, strings.TrimSuffix(baseURL, "/")+"/api/pull", key, &OllamaPullRequest{
+		Model:  modelName,
+		Stream: false,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var pullResponse OllamaPullResponse
+	if err = common.Unmarshal(body, &pullResponse); err != nil {
+		return nil, err
+	}
+	if pullResponse.Error != "" {
+		return nil, fmt.Errorf("ollama error: %s", pullResponse.Error)
+	}
+	return &pullResponse, nil
+}
@@ -0,0 +1,310 @@
+package ollama
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/types"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestOpenAI2OllamaChat 将 OpenAI 对话请求转换为 Ollama 原生 /api/chat 请求
+func requestOpenAI2OllamaChat(request dto.GeneralOpenAIRequest) (*OllamaChatRequest, error) {
+	messages := make([]OllamaChatMessage, 0, len(request.Messages))
+	// tool 消息只携带 tool_call_id，Ollama 需要函数名
+	toolNames := make(map[string]string)
+	for _, message := range request.Messages {
+		ollamaMessage := OllamaChatMessage{
+			Role: message.Role,
+		}
+		if message.Role == "developer" {
+			ollamaMessage.Role = "system"
+		}
+		if message.IsStringContent() {
+			ollamaMessage.Content = message.StringContent()
+		} else {
+			var text strings.Builder
+			for _, mediaMessage := range message.ParseContent() {
+				switch mediaMessage.Type {
+				case dto.ContentTypeText:
+					text.WriteString(mediaMessage.Text)
+				case dto.ContentTypeImageURL:
+					image, err := imageOpenAI2Ollama(mediaMessage.GetImageMedia().Url)
+					if err != nil {
+						return nil, err
+					}
+					ollamaMessage.Images = append(ollamaMessage.Images, image)
+				}
+			}
+			ollamaMessage.Content = text.String()
+		}
+		for _, toolCall := range message.ParseToolCalls() {
+			toolNames[toolCall.ID] = toolCall.Function.Name
+			arguments := json.RawMessage(toolCall.Function.Arguments)
+			if !json.Valid(arguments) {
+				arguments = json.RawMessage("{}")
+			}
+			ollamaMessage.ToolCalls = append(ollamaMessage.ToolCalls, OllamaToolCall{
+				Function: OllamaToolCallFunction{
+					Name:      toolCall.Function.Name,
+					Arguments: arguments,
+				},
+			})
+		}
+		if message.Role == "tool" {
+			ollamaMessage.ToolName = toolNames[message.ToolCallId]
+		}
+		messages = append(messages, ollamaMessage)
+	}
+
+	options := &Options{
+		Seed:             int(request.Seed),
+		Temperature:      request.Temperature,
+		TopK:             request.TopK,
+		TopP:             request.TopP,
+		FrequencyPenalty: request.FrequencyPenalty,
+		PresencePenalty:  request.PresencePenalty,
+		NumPredict:       int(request.MaxTokens),
+		Stop:             parseStop(request.Stop),
+	}
+	if request.MaxCompletionTokens > 0 {
+		options.NumPredict = int(request.MaxCompletionTokens)
+	}
+
+	ollamaRequest := &OllamaChatRequest{
+		Model:     request.Model,
+		Messages:  messages,
+		Tools:     request.Tools,
+		Options:   options,
+		Stream:    request.Stream,
+		KeepAlive: request.KeepAlive,
+	}
+	if request.ResponseFormat != nil {
+		switch request.ResponseFormat.Type {
+		case "json_object":
+			ollamaRequest.Format = "json"
+		case "json_schema":
+			if request.ResponseFormat.JsonSchema != nil && request.ResponseFormat.JsonSchema.Schema != nil {
+				ollamaRequest.Format = request.ResponseFormat.JsonSchema.Schema
+			} else {
+				ollamaRequest.Format = "json"
+			}
+		}
+	}
+	return ollamaRequest, nil
+}
+
+// imageOpenAI2Ollama Ollama 只接受不带 data: 前缀的 base64 图片
+func imageOpenAI2Ollama(imageUrl string) (string, error) {
+	if strings.HasPrefix(imageUrl, "http") {
+		fileData, err := service.GetFileBase64FromUrl(imageUrl)
+		if err != nil {
+			return "", fmt.Errorf("get file base64 from url failed: %s", err.Error())
+		}
+		return fileData.Base64Data, nil
+	}
+	if idx := strings.Index(imageUrl, ","); idx != -1 {
+		return imageUrl[idx+1:], nil
+	}
+	return imageUrl, nil
+}
+
+func parseStop(stop any) []string {
+	switch v := stop.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []any:
+		stops := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				stops = append(stops, s)
+			}
+		}
+		return stops
+	}
+	return nil
+}
+
+func toolCallsOllama2OpenAI(toolCalls []OllamaToolCall) []dto.ToolCallResponse {
+	openaiToolCalls := make([]dto.ToolCallResponse, 0, len(toolCalls))
+	for _, toolCall := range toolCalls {
+		arguments := string(toolCall.Function.Arguments)
+		if arguments == "" || arguments == "null" {
+			arguments = "{}"
+		}
+		openaiToolCalls = append(openaiToolCalls, dto.ToolCallResponse{
+			ID:   fmt.Sprintf("call_%s", common.GetUUID()),
+			Type: "function",
+			Function: dto.FunctionResponse{
+				Name:      toolCall.Function.Name,
+				Arguments: arguments,
+			},
+		})
+	}
+	return openaiToolCalls
+}
+
+func stopReasonOllama2OpenAI(reason string, hasToolCalls bool) string {
+	if hasToolCalls {
+		return "tool_calls"
+	}
+	switch reason {
+	case "length":
+		return "length"
+	default:
+		return "stop"
+	}
+}
+
+func usageOllama2OpenAI(response *OllamaChatResponse) *dto.Usage {
+	return &dto.Usage{
+		PromptTokens:     response.PromptEvalCount,
+		CompletionTokens: response.EvalCount,
+		TotalTokens:      response.PromptEvalCount + response.EvalCount,
+	}
+}
+
+func ollamaChatHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	common.CloseResponseBodyGracefully(resp)
+	var ollamaResponse OllamaChatResponse
+	if err = common.Unmarshal(responseBody, &ollamaResponse); err != nil {
+		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	if ollamaResponse.Error != "" {
+		return nil, types.NewError(fmt.Errorf("ollama error: %s", ollamaResponse.Error), types.ErrorCodeBadResponseBody)
+	}
+
+	message := dto.Message{
+		Role:             "assistant",
+		ReasoningContent: ollamaResponse.Message.Thinking,
+	}
+	message.SetStringContent(ollamaResponse.Message.Content)
+	toolCalls := toolCallsOllama2OpenAI(ollamaResponse.Message.ToolCalls)
+	if len(toolCalls) > 0 {
+		message.SetToolCalls(toolCalls)
+	}
+	usage := usageOllama2OpenAI(&ollamaResponse)
+	if usage.TotalTokens == 0 {
+		usage = service.ResponseText2Usage(ollamaResponse.Message.Content, info.UpstreamModelName, info.PromptTokens)
+	}
+	fullResponse := dto.OpenAITextResponse{
+		Id:      helper.GetResponseID(c),
+		Object:  "chat.completion",
+		Created: common.GetTimestamp(),
+		Model:   info.UpstreamModelName,
+		Choices: []dto.OpenAITextResponseChoice{{
+			Index:        0,
+			Message:      message,
+			FinishReason: stopReasonOllama2OpenAI(ollamaResponse.DoneReason, len(toolCalls) > 0),
+		}},
+		Usage: *usage,
+	}
+	jsonResponse, err := common.Marshal(fullResponse)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	common.IOCopyBytesGracefully(c, resp, jsonResponse)
+	return usage, nil
+}
+
+// ollamaChatStreamHandler 将 Ollama 的 NDJSON 流转换为 OpenAI SSE 流
+func ollamaChatStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	defer common.CloseResponseBodyGracefully(resp)
+
+	helper.SetEventStreamHeaders(c)
+	responseId := helper.GetResponseID(c)
+	createdTime := common.GetTimestamp()
+	model := info.UpstreamModelName
+	newChunk := func() *dto.ChatCompletionsStreamResponse {
+		return &dto.ChatCompletionsStreamResponse{
+			Id:      responseId,
+			Object:  "chat.completion.chunk",
+			Created: createdTime,
+			Model:   model,
+			Choices: []dto.ChatCompletionsStreamResponseChoice{{Index: 0}},
+		}
+	}
+
+	var usage *dto.Usage
+	var responseText strings.Builder
+	finishReason := "stop"
+	toolIndex := 0
+	isFirst := true
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var ollamaResponse OllamaChatResponse
+		if err := common.Unmarshal([]byte(line), &ollamaResponse); err != nil {
+			common.SysError("error unmarshalling ollama stream response: " + err.Error())
+			continue
+		}
+		if ollamaResponse.Error != "" {
+			return nil, types.NewError(fmt.Errorf("ollama error: %s", ollamaResponse.Error), types.ErrorCodeBadResponse)
+		}
+		info.SetFirstResponseTime()
+
+		chunk := newChunk()
+		delta := &chunk.Choices[0].Delta
+		if isFirst {
+			isFirst = false
+			delta.Role = "assistant"
+		}
+		if ollamaResponse.Message.Content != "" || delta.Role != "" {
+			responseText.WriteString(ollamaResponse.Message.Content)
+			delta.SetContentString(ollamaResponse.Message.Content)
+		}
+		if ollamaResponse.Message.Thinking != "" {
+			responseText.WriteString(ollamaResponse.Message.Thinking)
+			delta.SetReasoningContent(ollamaResponse.Message.Thinking)
+		}
+		// Ollama 的工具调用总是完整地出现在单行中
+		for _, toolCall := range toolCallsOllama2OpenAI(ollamaResponse.Message.ToolCalls) {
+			responseText.WriteString(toolCall.Function.Arguments)
+			toolCall.SetIndex(toolIndex)
+			toolIndex++
+			delta.ToolCalls = append(delta.ToolCalls, toolCall)
+		}
+		if delta.Content != nil || delta.ReasoningContent != nil || len(delta.ToolCalls) > 0 {
+			_ = helper.ObjectData(c, chunk)
+		}
+
+		if ollamaResponse.Done {
+			finishReason = stopReasonOllama2OpenAI(ollamaResponse.DoneReason, toolIndex > 0)
+			usage = usageOllama2OpenAI(&ollamaResponse)
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, io.EOF) {
+		common.SysError("error reading ollama stream: " + err.Error())
+	}
+
+	if usage == nil || usage.TotalTokens == 0 {
+		usage = service.ResponseText2Usage(responseText.String(), model, info.PromptTokens)
+	}
+	_ = helper.ObjectData(c, helper.GenerateStopResponse(responseId, createdTime, model, finishReason))
+	if info.ShouldIncludeUsage {
+		_ = helper.ObjectData(c, helper.GenerateFinalUsageResponse(responseId, createdTime, model, *usage))
+	}
+	helper.Done(c)
+	return usage, nil
+}
@@ -98,16 +98,22 @@ func ollamaEmbeddingHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *h
 	if ollamaEmbeddingResponse.Error != "" {
 		return nil, types.NewError(fmt.Errorf("ollama error: %s", ollamaEmbeddingResponse.Error), types.ErrorCodeBadResponseBody)
 	}
-	flattenedEmbeddings := flattenEmbeddings(ollamaEmbeddingResponse.Embedding)
-	data := make([]dto.OpenAIEmbeddingResponseItem, 0, 1)
-	data = append(data, dto.OpenAIEmbeddingResponseItem{
-		Embedding: flattenedEmbeddings,
-		Object:    "embedding",
-	})
+	data := make([]dto.OpenAIEmbeddingResponseItem, 0, len(ollamaEmbeddingResponse.Embedding))
+	for i, embedding := range ollamaEmbeddingResponse.Embedding {
+		data = append(data, dto.OpenAIEmbeddingResponseItem{
+			Embedding: embedding,
+			Object:    "embedding",
+			Index:     i,
+		})
+	}
+	promptTokens := info.PromptTokens
+	if ollamaEmbeddingResponse.PromptEvalCount > 0 {
+		promptTokens = ollamaEmbeddingResponse.PromptEvalCount
+	}
 	usage := &dto.Usage{
-		TotalTokens:      info.PromptTokens,
+		TotalTokens:      promptTokens,
 		CompletionTokens: 0,
-		PromptTokens:     info.PromptTokens,
+		PromptTokens:     promptTokens,
 	}
 	embeddingResponse := &dto.OpenAIEmbeddingResponse{
 		Object: "list",
@@ -122,11 +128,3 @@ func ollamaEmbeddingHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *h
 	common.IOCopyBytesGracefully(c, resp, doResponseBody)
 	return usage, nil
 }
-
-func flattenEmbeddings(embeddings [][]float64) []float64 {
-	flattened := []float64{}
-	for _, row := range embeddings {
-		flattened = append(flattened, row...)
-	}
-	return flattened
-}
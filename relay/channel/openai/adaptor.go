@@ -80,12 +80,19 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 	}
 	switch info.ChannelType {
 	case constant.ChannelTypeAzure:
-		apiVersion := info.ApiVersion
+		deployment, apiVersion := resolveAzureDeployment(info)
+		requestURL := strings.Split(info.RequestURLPath, "?")[0]
+		// Azure AI Foundry serverless 端点的路径与 OpenAI 一致
+		if info.ChannelSetting.Azure != nil && info.ChannelSetting.Azure.Serverless {
+			if apiVersion == "" {
+				apiVersion = azureServerlessApiVersion
+			}
+			return fmt.Sprintf("%s%s?api-version=%s", info.BaseUrl, strings.TrimPrefix(requestURL, "/v1"), apiVersion), nil
+		}
 		if apiVersion == "" {
 			apiVersion = constant.AzureDefaultAPIVersion
 		}
 		// https://learn.microsoft.com/en-us/azure/cognitive-services/openai/chatgpt-quickstart?pivots=rest-api&tabs=command-line#rest-api
-		requestURL := strings.Split(info.RequestURLPath, "?")[0]
 		requestURL = fmt.Sprintf("%s?api-version=%s", requestURL, apiVersion)
 		task := strings.TrimPrefix(requestURL, "/v1/")
 
@@ -95,15 +102,10 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 			return relaycommon.GetFullRequestURL(info.BaseUrl, requestURL, info.ChannelType), nil
 		}
 
-		model_ := info.UpstreamModelName
-		// 2025年5月10日后创建的渠道不移除.
-		if info.ChannelCreateTime < constant.AzureNoRemoveDotTime {
-			model_ = strings.Replace(model_, ".", "", -1)
-		}
 		// https://github.com/songquanpeng/one-api/issues/67
-		requestURL = fmt.Sprintf("/openai/deployments/%s/%s", model_, task)
+		requestURL = fmt.Sprintf("/openai/deployments/%s/%s", deployment, task)
 		if info.RelayMode == relayconstant.RelayModeRealtime {
-			requestURL = fmt.Sprintf("/openai/realtime?deployment=%s&api-version=%s", model_, apiVersion)
+			requestURL = fmt.Sprintf("/openai/realtime?deployment=%s&api-version=%s", deployment, apiVersion)
 		}
 		return relaycommon.GetFullRequestURL(info.BaseUrl, requestURL, info.ChannelType), nil
 	case constant.ChannelTypeMiniMax:
@@ -120,8 +122,7 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 func (a *Adaptor) SetupRequestHeader(c *gin.Context, header *http.Header, info *relaycommon.RelayInfo) error {
 	channel.SetupApiRequestHeader(info, c, header)
 	if info.ChannelType == constant.ChannelTypeAzure {
-		header.Set("api-key", info.ApiKey)
-		return nil
+		return setupAzureRequestHeader(info, header)
 	}
 	if info.ChannelType == constant.ChannelTypeOpenAI && "" != info.Organization {
 		header.Set("OpenAI-Organization", info.Organization)
@@ -191,6 +192,12 @@ func (a *Adaptor) ConvertOpenAIRequest(c *gin.Context, info *relaycommon.RelayIn
 			}
 		}
 	}
+	// Azure AI Foundry 的模型推理端点按 body 中的 model 选择部署
+	if info.ChannelType == constant.ChannelTypeAzure {
+		if deployment, ok := lookupAzureDeployment(info, request.Model); ok {
+			request.Model = deployment.Deployment
+		}
+	}
 
 	return request, nil
 }
@@ -407,6 +414,12 @@ func (a *Adaptor) ConvertOpenAIResponsesRequest(c *gin.Context, info *relaycommo
 		request.Reasoning.Effort = "medium"
 		request.Model = strings.TrimSuffix(request.Model, "-medium")
 	}
+	// Azure responses API 的 model 为部署名
+	if info.ChannelType == constant.ChannelTypeAzure {
+		if deployment, ok := lookupAzureDeployment(info, request.Model); ok {
+			request.Model = deployment.Deployment
+		}
+	}
 	return request, nil
 }
 
@@ -427,7 +440,7 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycom
 	case relayconstant.RelayModeRealtime:
 		err, usage = OpenaiRealtimeHandler(c, info)
 	case relayconstant.RelayModeAudioSpeech:
-		usage = OpenaiTTSHandler(c, resp, info)
+		usage = OpenaiTTSHandler(c, resp, info, a.ResponseFormat)
 	case relayconstant.RelayModeAudioTranslation:
 		fallthrough
 	case relayconstant.RelayModeAudioTranscription:
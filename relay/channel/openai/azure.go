@@ -0,0 +1,300 @@
+package openai
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+	"strings"
+	"time"
+
+	"github.com/bytedance/gopkg/cache/asynccache"
+)
+
+const (
+	azureCognitiveServicesScope = "https://cognitiveservices.azure.com/.default"
+	azureManagementScope        = "https://management.azure.com/.default"
+	azureManagementApiVersion   = "2023-05-01"
+	azureServerlessApiVersion   = "2024-05-01-preview"
+)
+
+var azureTokenCache = asynccache.NewAsyncCache(asynccache.Options{
+	RefreshDuration: time.Minute * 35,
+	EnableExpire:    true,
+	ExpireDuration:  time.Minute * 30,
+	Fetcher: func(key string) (interface{}, error) {
+		return nil, errors.New("not found")
+	},
+})
+
+func getAzureHttpClient(proxy string) (*http.Client, error) {
+	if proxy != "" {
+		return service.NewProxyHttpClient(proxy)
+	}
+	return service.GetHttpClient(), nil
+}
+
+// getAzureEntraToken 使用客户端凭据获取 Entra ID 访问令牌，key 格式为 client_id:client_secret
+func getAzureEntraToken(tenantId string, key string, scope string, proxy string) (string, error) {
+	clientId, clientSecret, ok := strings.Cut(strings.TrimSpace(key), ":")
+	if tenantId == "" || !ok || clientId == "" || clientSecret == "" {
+		return "", errors.New("entra id auth requires tenant_id and a key in client_id:client_secret format")
+	}
+	cacheKey := fmt.Sprintf("azure-token-%s-%s-%s", tenantId, clientId, scope)
+	if val, err := azureTokenCache.Get(cacheKey); err == nil {
+		return val.(string), nil
+	}
+
+	client, err := getAzureHttpClient(proxy)
+	if err != nil {
+		return "", fmt.Errorf("new proxy http client failed: %w", err)
+	}
+	data := url.Values{}
+	data.Set("grant_type", "client_credentials")
+	data.Set("client_id", clientId)
+	data.Set("client_secret", clientSecret)
+	data.Set("scope", scope)
+	resp, err := client.PostForm(fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0/token", url.PathEscape(tenantId)), data)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if err = common.Unmarshal(body, &result); err != nil {
+		return "", err
+	}
+	if result.AccessToken == "" {
+		return "", fmt.Errorf("failed to get entra id access token: %s %s", result.Error, result.ErrorDescription)
+	}
+	azureTokenCache.SetDefault(cacheKey, result.AccessToken)
+	return result.AccessToken, nil
+}
+
+// setupAzureRequestHeader 按渠道设置使用 api-key 或 Entra ID 令牌鉴权
+func setupAzureRequestHeader(info *relaycommon.RelayInfo, header *http.Header) error {
+	setting := info.ChannelSetting.Azure
+	if setting != nil && setting.AuthType == dto.AzureAuthTypeEntraId {
+		token, err := getAzureEntraToken(setting.TenantId, info.ApiKey, azureCognitiveServicesScope, info.ChannelSetting.Proxy)
+		if err != nil {
+			return err
+		}
+		header.Set("Authorization", "Bearer "+token)
+		return nil
+	}
+	header.Set("api-key", info.ApiKey)
+	// serverless 端点的部分模型只接受 Bearer 鉴权
+	if setting != nil && setting.Serverless {
+		header.Set("Authorization", "Bearer "+info.ApiKey)
+	}
+	return nil
+}
+
+// lookupAzureDeployment 在渠道设置的部署映射中查找模型对应的部署
+func lookupAzureDeployment(info *relaycommon.RelayInfo, modelName string) (dto.AzureDeployment, bool) {
+	if info.ChannelSetting.Azure == nil {
+		return dto.AzureDeployment{}, false
+	}
+	deployment, ok := info.ChannelSetting.Azure.Deployments[modelName]
+	return deployment, ok && deployment.Deployment != ""
+}
+
+// resolveAzureDeployment 返回请求使用的部署名与 api-version，未配置映射时部署名即模型名
+func resolveAzureDeployment(info *relaycommon.RelayInfo) (string, string) {
+	if deployment, ok := lookupAzureDeployment(info, info.UpstreamModelName); ok {
+		if deployment.ApiVersion != "" {
+			return deployment.Deployment, deployment.ApiVersion
+		}
+		return deployment.Deployment, info.ApiVersion
+	}
+	deployment := info.UpstreamModelName
+	// 2025年5月10日后创建的渠道不移除.
+	if info.ChannelCreateTime < constant.AzureNoRemoveDotTime {
+		deployment = strings.Replace(deployment, ".", "", -1)
+	}
+	return deployment, info.ApiVersion
+}
+
+func doAzureRequest(client *http.Client, requestURL string, header http.Header) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = header
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code %d: %s", resp.StatusCode, string(body))
+	}
+	return body, nil
+}
+
+// azureAccountName 从 https://{account}.openai.azure.com 形式的 base url 中获取资源名
+func azureAccountName(baseURL string) string {
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return ""
+	}
+	account, _, _ := strings.Cut(parsed.Hostname(), ".")
+	return account
+}
+
+// azureModelName Azure 的基础模型名去掉了版本中的点，如 gpt-35-turbo
+func azureModelName(model string) string {
+	return strings.Replace(model, "gpt-35", "gpt-3.5", 1)
+}
+
+// FetchAzureDeployments 列出 Azure 渠道的部署：serverless 端点读取 /info，
+// 配置了订阅与资源组时使用管理 API，否则按渠道的 api-version 调用数据面接口，
+// 新版 api-version 已不再提供数据面列出部署的接口，推荐使用管理 API
+func FetchAzureDeployments(baseURL string, key string, apiVersion string, channelSetting dto.ChannelSettings) ([]dto.AzureDeployment, error) {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	key = strings.TrimSpace(strings.Split(key, "\n")[0])
+	setting := channelSetting.Azure
+	if setting == nil {
+		setting = &dto.AzureSetting{}
+	}
+	client, err := getAzureHttpClient(channelSetting.Proxy)
+	if err != nil {
+		return nil, fmt.Errorf("new proxy http client failed: %w", err)
+	}
+
+	header := http.Header{}
+	if setting.AuthType == dto.AzureAuthTypeEntraId {
+		scope := azureCognitiveServicesScope
+		if setting.SubscriptionId != "" && setting.ResourceGroup != "" && !setting.Serverless {
+			scope = azureManagementScope
+		}
+		token, err := getAzureEntraToken(setting.TenantId, key, scope, channelSetting.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		header.Set("Authorization", "Bearer "+token)
+	} else {
+		header.Set("api-key", key)
+		header.Set("Authorization", "Bearer "+key)
+	}
+
+	switch {
+	case setting.Serverless:
+		body, err := doAzureRequest(client, fmt.Sprintf("%s/info?api-version=%s", baseURL, azureServerlessApiVersion), header)
+		if err != nil {
+			return nil, err
+		}
+		var info struct {
+			ModelName string `json:"model_name"`
+		}
+		if err = common.Unmarshal(body, &info); err != nil {
+			return nil, err
+		}
+		if info.ModelName == "" {
+			return nil, errors.New("serverless endpoint returned no model")
+		}
+		return []dto.AzureDeployment{{Deployment: info.ModelName, Model: info.ModelName}}, nil
+	case setting.SubscriptionId != "" && setting.ResourceGroup != "":
+		if setting.AuthType != dto.AzureAuthTypeEntraId {
+			return nil, errors.New("listing deployments through the management api requires entra id auth")
+		}
+		account := setting.AccountName
+		if account == "" {
+			account = azureAccountName(baseURL)
+		}
+		requestURL := fmt.Sprintf("https://management.azure.com/subscriptions/%s/resourceGroups/%s/providers/Microsoft.CognitiveServices/accounts/%s/deployments?api-version=%s",
+			url.PathEscape(setting.SubscriptionId), url.PathEscape(setting.ResourceGroup), url.PathEscape(account), azureManagementApiVersion)
+		body, err := doAzureRequest(client, requestURL, header)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Value []struct {
+				Name       string `json:"name"`
+				Properties struct {
+					Model struct {
+						Name    string `json:"name"`
+						Version string `json:"version"`
+					} `json:"model"`
+					ProvisioningState string `json:"provisioningState"`
+				} `json:"properties"`
+			} `json:"value"`
+		}
+		if err = common.Unmarshal(body, &result); err != nil {
+			return nil, err
+		}
+		deployments := make([]dto.AzureDeployment, 0, len(result.Value))
+		for _, item := range result.Value {
+			if item.Properties.ProvisioningState != "" && item.Properties.ProvisioningState != "Succeeded" {
+				continue
+			}
+			deployments = append(deployments, dto.AzureDeployment{
+				Deployment:   item.Name,
+				Model:        item.Properties.Model.Name,
+				ModelVersion: item.Properties.Model.Version,
+			})
+		}
+		return deployments, nil
+	default:
+		if apiVersion == "" {
+			return nil, errors.New("listing deployments requires the channel api version, or subscription_id and resource_group for the management api")
+		}
+		body, err := doAzureRequest(client, fmt.Sprintf("%s/openai/deployments?api-version=%s", baseURL, url.QueryEscape(apiVersion)), header)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Data []struct {
+				Id     string `json:"id"`
+				Model  string `json:"model"`
+				Status string `json:"status"`
+			} `json:"data"`
+		}
+		if err = common.Unmarshal(body, &result); err != nil {
+			return nil, err
+		}
+		deployments := make([]dto.AzureDeployment, 0, len(result.Data))
+		for _, item := range result.Data {
+			if item.Status != "" && item.Status != "succeeded" {
+				continue
+			}
+			deployments = append(deployments, dto.AzureDeployment{Deployment: item.Id, Model: item.Model})
+		}
+		return deployments, nil
+	}
+}
+
+// AzureDeploymentMapping 以基础模型名作为模型名映射到部署；基础模型名与其他部署的部署名相同，
+// 或同一基础模型有多个部署时，该部署使用部署名，保证每个部署都能被请求到
+func AzureDeploymentMapping(deployments []dto.AzureDeployment) map[string]dto.AzureDeployment {
+	names := make(map[string]bool, len(deployments))
+	for _, deployment := range deployments {
+		names[deployment.Deployment] = true
+	}
+	mapping := make(map[string]dto.AzureDeployment, len(deployments))
+	for _, deployment := range deployments {
+		modelName := azureModelName(deployment.Model)
+		if _, exists := mapping[modelName]; modelName == "" || exists || (names[modelName] && modelName != deployment.Deployment) {
+			modelName = deployment.Deployment
+		}
+		mapping[modelName] = deployment
+	}
+	return mapping
+}
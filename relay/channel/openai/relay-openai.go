@@ -2,6 +2,7 @@ package openai
 
 import (
 	"bytes"
+	"encoding/base64"
 	"fmt"
 	"io"
 	"math"
@@ -240,7 +241,7 @@ func OpenaiHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Respo
 	return &simpleResponse.Usage, nil
 }
 
-func OpenaiTTSHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) *dto.Usage {
+func OpenaiTTSHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo, responseFormat string) *dto.Usage {
 	// the status code has been judged before, if there is a body reading failure,
 	// it should be regarded as a non-recoverable error, so it should not return err for external retry.
 	// Analogous to nginx's load balancing, it will only retry if it can't be requested or
@@ -251,6 +252,30 @@ func OpenaiTTSHandler(c *gin.Context, resp *http.Response, info *relaycommon.Rel
 	usage := &dto.Usage{}
 	usage.PromptTokens = info.PromptTokens
 	usage.TotalTokens = info.PromptTokens
+	if info.IsStream {
+		// 流式输出边转发边收集音频，结束后按实际输出的音频时长计费，无法统计时长的格式只按输入文本计费
+		audioFormat := ttsOutputAudioFormat(responseFormat)
+		var audio *bytes.Buffer
+		if audioFormat != "" {
+			audio = &bytes.Buffer{}
+		}
+		if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+			openaiTTSSSEStream(c, resp, info, audio)
+		} else {
+			openaiTTSAudioStream(c, resp, audio)
+		}
+		if audio != nil {
+			audioTokens, err := service.CountAudioTokenOutput(base64.StdEncoding.EncodeToString(audio.Bytes()), audioFormat)
+			if err != nil {
+				common.LogError(c, "count audio output tokens failed: "+err.Error())
+			} else {
+				usage.CompletionTokens = audioTokens
+				usage.CompletionTokenDetails.AudioTokens = audioTokens
+				usage.TotalTokens = usage.PromptTokens + audioTokens
+			}
+		}
+		return usage
+	}
 	for k, v := range resp.Header {
 		c.Writer.Header().Set(k, v[0])
 	}
@@ -263,6 +288,71 @@ func OpenaiTTSHandler(c *gin.Context, resp *http.Response, info *relaycommon.Rel
 	return usage
 }
 
+// ttsOutputAudioFormat 返回可以统计时长的输出格式，未指定时上游默认输出 mp3，其他格式返回空
+func ttsOutputAudioFormat(responseFormat string) string {
+	switch responseFormat {
+	case "", service.AudioFormatMp3:
+		return service.AudioFormatMp3
+	case service.AudioFormatWav:
+		return service.AudioFormatWav
+	case service.AudioFormatPcm:
+		return "pcm16"
+	}
+	return ""
+}
+
+// openaiTTSSSEStream 转发 stream_format=sse 的语音事件，audio 不为空时收集 speech.audio.delta 中的音频
+func openaiTTSSSEStream(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo, audio *bytes.Buffer) {
+	helper.StreamScannerHandler(c, resp, info, func(data string) bool {
+		if audio != nil {
+			var event struct {
+				Type  string `json:"type"`
+				Audio string `json:"audio"`
+			}
+			if err := common.UnmarshalJsonStr(data, &event); err == nil && event.Type == "speech.audio.delta" {
+				if chunk, err := base64.StdEncoding.DecodeString(event.Audio); err == nil {
+					audio.Write(chunk)
+				}
+			}
+		}
+		if err := helper.StringData(c, data); err != nil {
+			common.LogError(c, err.Error())
+			return false
+		}
+		return true
+	})
+}
+
+// openaiTTSAudioStream 上游分块返回音频时逐块转发给客户端，audio 不为空时同时收集音频
+func openaiTTSAudioStream(c *gin.Context, resp *http.Response, audio *bytes.Buffer) {
+	for k, v := range resp.Header {
+		c.Writer.Header().Set(k, v[0])
+	}
+	c.Writer.Header().Del("Content-Length")
+	c.Writer.WriteHeader(resp.StatusCode)
+	c.Writer.WriteHeaderNow()
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if audio != nil {
+				audio.Write(buf[:n])
+			}
+			if _, writeErr := c.Writer.Write(buf[:n]); writeErr != nil {
+				common.LogError(c, writeErr.Error())
+				return
+			}
+			c.Writer.Flush()
+		}
+		if err != nil {
+			if err != io.EOF {
+				common.LogError(c, err.Error())
+			}
+			return
+		}
+	}
+}
+
 func OpenaiSTTHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo, responseFormat string) (*types.NewAPIError, *dto.Usage) {
 	defer common.CloseResponseBodyGracefully(resp)
 
@@ -271,12 +361,23 @@ func OpenaiSTTHandler(c *gin.Context, resp *http.Response, info *relaycommon.Rel
 	if err != nil {
 		return types.NewError(err, types.ErrorCodeCountTokenFailed), nil
 	}
-	responseBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return types.NewError(err, types.ErrorCodeReadResponseBodyFailed), nil
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		// stream=true 时逐条转发 transcript.text.delta / transcript.text.done 事件
+		helper.StreamScannerHandler(c, resp, info, func(data string) bool {
+			if err := helper.StringData(c, data); err != nil {
+				common.LogError(c, err.Error())
+				return false
+			}
+			return true
+		})
+	} else {
+		responseBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return types.NewError(err, types.ErrorCodeReadResponseBodyFailed), nil
+		}
+		// 写入新的 response body
+		common.IOCopyBytesGracefully(c, resp, responseBody)
 	}
-	// 写入新的 response body
-	common.IOCopyBytesGracefully(c, resp, responseBody)
 
 	usage := &dto.Usage{}
 	usage.PromptTokens = audioTokens
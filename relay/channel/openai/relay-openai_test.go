@@ -0,0 +1,45 @@
+package openai
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	relaycommon "one-api/relay/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOpenaiTTSHandlerStreamBilling(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// 6 秒 24kHz 16 位 pcm
+	pcm := make([]byte, 6*24000*2)
+	cases := []struct {
+		name           string
+		responseFormat string
+		wantTokens     int
+	}{
+		{"pcm by duration", "pcm", 83},
+		{"unmeasurable format by input only", "opus", 0},
+	}
+	for _, c := range cases {
+		recorder := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(recorder)
+		ctx.Request = httptest.NewRequest(http.MethodPost, "/v1/audio/speech", nil)
+		resp := &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"audio/pcm"}},
+			Body:       io.NopCloser(bytes.NewReader(pcm)),
+		}
+		info := &relaycommon.RelayInfo{IsStream: true, PromptTokens: 10}
+		usage := OpenaiTTSHandler(ctx, resp, info, c.responseFormat)
+		if usage.CompletionTokens != c.wantTokens || usage.TotalTokens != 10+c.wantTokens {
+			t.Errorf("%s: got completion %d total %d, want %d", c.name, usage.CompletionTokens, usage.TotalTokens, c.wantTokens)
+		}
+		if recorder.Body.Len() != len(pcm) {
+			t.Errorf("%s: forwarded %d bytes, want %d", c.name, recorder.Body.Len(), len(pcm))
+		}
+	}
+}
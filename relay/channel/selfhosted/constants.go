@@ -0,0 +1,6 @@
+package selfhosted
+
+// 自部署服务的模型由探测结果决定，这里不预置模型
+var ModelList = []string{}
+
+var ChannelName = "selfhosted"
@@ -0,0 +1,250 @@
+package selfhosted
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/service"
+	"strings"
+	"time"
+)
+
+const (
+	ServerVllm     = "vllm"
+	ServerTgi      = "tgi"
+	ServerLlamaCpp = "llama.cpp"
+	ServerUnknown  = "unknown"
+
+	// 1x1 的透明 PNG，用于探测是否支持图片输入
+	probeImageDataUrl = "data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNk+M9QDwADhgGAWjR9awAAAABJRU5ErkJggg=="
+)
+
+var (
+	// 保存渠道时同步探测，限制整次探测的总时长，单个请求的超时更短，避免一个接口无响应耗尽全部时间
+	probeTotalTimeout   = 30 * time.Second
+	probeRequestTimeout = 10 * time.Second
+)
+
+type prober struct {
+	ctx     context.Context
+	client  *http.Client
+	baseURL string
+	key     string
+	model   string
+}
+
+// do 发送探测请求，返回状态码为 200 时的响应体
+func (p *prober) do(method string, path string, body any) ([]byte, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := common.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	ctx, cancel := context.WithTimeout(p.ctx, probeRequestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, p.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.key != "" {
+		req.Header.Set("Authorization", "Bearer "+p.key)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s: status code %d", method, path, resp.StatusCode)
+	}
+	return data, nil
+}
+
+// chat 发送一个最多生成 1 个 token 的对话请求，上游接受即认为支持 extra 中的字段
+func (p *prober) chat(message map[string]any, extra map[string]any) bool {
+	request := map[string]any{
+		"model":      p.model,
+		"messages":   []any{message},
+		"max_tokens": 1,
+	}
+	for k, v := range extra {
+		request[k] = v
+	}
+	_, err := p.do(http.MethodPost, "/v1/chat/completions", request)
+	return err == nil
+}
+
+// ProbeCapabilities 通过渠道代理探测自部署 OpenAI 兼容服务支持的能力，modelName 为空时使用上游返回的第一个模型，
+// 超过总时长时返回错误，不保存不完整的探测结果
+func ProbeCapabilities(baseURL string, key string, proxy string, modelName string) (*dto.ChannelCapabilities, error) {
+	if strings.TrimSuffix(baseURL, "/") == "" {
+		return nil, errors.New("base url is required")
+	}
+	client := service.GetHttpClient()
+	if proxy != "" {
+		var err error
+		client, err = service.NewProxyHttpClient(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("new proxy http client failed: %w", err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), probeTotalTimeout)
+	defer cancel()
+	p := &prober{ctx: ctx, client: client, baseURL: strings.TrimSuffix(baseURL, "/"), key: key, model: modelName}
+	capabilities, err := p.probe()
+	if err == nil && ctx.Err() != nil {
+		err = fmt.Errorf("probe timed out after %s", probeTotalTimeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return capabilities, nil
+}
+
+func (p *prober) probe() (*dto.ChannelCapabilities, error) {
+	body, err := p.do(http.MethodGet, "/v1/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	var models struct {
+		Data []struct {
+			ID          string `json:"id"`
+			OwnedBy     string `json:"owned_by"`
+			MaxModelLen int    `json:"max_model_len"`
+		} `json:"data"`
+	}
+	if err = common.Unmarshal(body, &models); err != nil {
+		return nil, fmt.Errorf("invalid /v1/models response: %s", err.Error())
+	}
+	capabilities := &dto.ChannelCapabilities{
+		Server:   ServerUnknown,
+		ProbedAt: common.GetTimestamp(),
+	}
+	for _, m := range models.Data {
+		capabilities.Models = append(capabilities.Models, m.ID)
+		if m.OwnedBy == "vllm" {
+			capabilities.Server = ServerVllm
+		}
+		if m.ID == p.model || (p.model == "" && capabilities.MaxContext == 0) {
+			capabilities.MaxContext = m.MaxModelLen
+		}
+	}
+	if p.model == "" {
+		if len(capabilities.Models) == 0 {
+			return nil, errors.New("upstream returned no models")
+		}
+		p.model = capabilities.Models[0]
+	}
+
+	vision := -1 // -1 表示服务端未声明，需要发请求探测
+	if capabilities.Server == ServerUnknown {
+		capabilities.Server, vision = p.detectServer(capabilities)
+	}
+
+	textMessage := map[string]any{"role": "user", "content": "hi"}
+	capabilities.Tools = p.chat(textMessage, map[string]any{
+		"tools": []any{map[string]any{
+			"type": "function",
+			"function": map[string]any{
+				"name":       "probe",
+				"parameters": map[string]any{"type": "object", "properties": map[string]any{}},
+			},
+		}},
+		"tool_choice": "auto",
+	})
+	capabilities.StreamOptions = p.chat(textMessage, map[string]any{
+		"stream":         true,
+		"stream_options": map[string]any{"include_usage": true},
+	})
+	if vision >= 0 {
+		capabilities.Vision = vision == 1
+	} else {
+		capabilities.Vision = p.chat(map[string]any{
+			"role": "user",
+			"content": []any{
+				map[string]any{"type": "text", "text": "hi"},
+				map[string]any{"type": "image_url", "image_url": map[string]any{"url": probeImageDataUrl}},
+			},
+		}, nil)
+	}
+
+	schema := map[string]any{"type": "object", "properties": map[string]any{"ok": map[string]any{"type": "boolean"}}}
+	switch {
+	case p.chat(textMessage, map[string]any{"response_format": map[string]any{
+		"type":        "json_schema",
+		"json_schema": map[string]any{"name": "probe", "schema": schema},
+	}}):
+		capabilities.GuidedDecoding = dto.GuidedDecodingJsonSchema
+	case capabilities.Server == ServerTgi && p.chat(textMessage, map[string]any{"response_format": map[string]any{"type": "json", "value": schema}}):
+		capabilities.GuidedDecoding = dto.GuidedDecodingTgi
+	case capabilities.Server == ServerVllm && p.chat(textMessage, map[string]any{"guided_json": schema}):
+		capabilities.GuidedDecoding = dto.GuidedDecodingGuidedJson
+	default:
+		capabilities.GuidedDecoding = dto.GuidedDecodingNone
+	}
+	return capabilities, nil
+}
+
+// detectServer 通过各服务特有的接口识别服务类型，同时读取上下文长度与是否支持图片
+func (p *prober) detectServer(capabilities *dto.ChannelCapabilities) (string, int) {
+	if body, err := p.do(http.MethodGet, "/info", nil); err == nil {
+		var info struct {
+			ModelId        string `json:"model_id"`
+			MaxInputTokens int    `json:"max_input_tokens"`
+			MaxTotalTokens int    `json:"max_total_tokens"`
+		}
+		if common.Unmarshal(body, &info) == nil && info.ModelId != "" {
+			if info.MaxTotalTokens > 0 {
+				capabilities.MaxContext = info.MaxTotalTokens
+			} else if info.MaxInputTokens > 0 {
+				capabilities.MaxContext = info.MaxInputTokens
+			}
+			return ServerTgi, -1
+		}
+	}
+	if body, err := p.do(http.MethodGet, "/props", nil); err == nil {
+		var props struct {
+			DefaultGenerationSettings *struct {
+				NCtx int `json:"n_ctx"`
+			} `json:"default_generation_settings"`
+			Modalities *struct {
+				Vision bool `json:"vision"`
+			} `json:"modalities"`
+		}
+		if common.Unmarshal(body, &props) == nil && props.DefaultGenerationSettings != nil {
+			if props.DefaultGenerationSettings.NCtx > 0 {
+				capabilities.MaxContext = props.DefaultGenerationSettings.NCtx
+			}
+			vision := -1
+			if props.Modalities != nil {
+				vision = 0
+				if props.Modalities.Vision {
+					vision = 1
+				}
+			}
+			return ServerLlamaCpp, vision
+		}
+	}
+	if body, err := p.do(http.MethodGet, "/version", nil); err == nil {
+		var version struct {
+			Version string `json:"version"`
+		}
+		if common.Unmarshal(body, &version) == nil && version.Version != "" {
+			return ServerVllm, -1
+		}
+	}
+	return ServerUnknown, -1
+}
@@ -0,0 +1,88 @@
+package selfhosted
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"one-api/dto"
+	"one-api/service"
+)
+
+// newVllmServer 模拟一个支持工具调用与 json_schema、不支持图片输入的 vLLM 服务
+func newVllmServer(chatDelay time.Duration, hosts *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hosts != nil {
+			*hosts = append(*hosts, r.Host)
+		}
+		switch r.URL.Path {
+		case "/v1/models":
+			_, _ = w.Write([]byte(`{"data":[{"id":"qwen","owned_by":"vllm","max_model_len":32768}]}`))
+		case "/v1/chat/completions":
+			time.Sleep(chatDelay)
+			var body strings.Builder
+			buf := make([]byte, 4096)
+			n, _ := r.Body.Read(buf)
+			body.Write(buf[:n])
+			if strings.Contains(body.String(), "image_url") {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte(`{"choices":[]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestProbeCapabilities(t *testing.T) {
+	service.InitHttpClient()
+	server := newVllmServer(0, nil)
+	defer server.Close()
+
+	capabilities, err := ProbeCapabilities(server.URL+"/", "", "", "")
+	if err != nil {
+		t.Fatalf("probe failed: %s", err)
+	}
+	if capabilities.Server != ServerVllm || capabilities.MaxContext != 32768 || len(capabilities.Models) != 1 {
+		t.Errorf("unexpected server info: %+v", capabilities)
+	}
+	if !capabilities.Tools || !capabilities.StreamOptions || capabilities.Vision || capabilities.GuidedDecoding != dto.GuidedDecodingJsonSchema {
+		t.Errorf("unexpected capabilities: %+v", capabilities)
+	}
+}
+
+func TestProbeCapabilitiesUsesProxy(t *testing.T) {
+	service.InitHttpClient()
+	var hosts []string
+	// http 代理收到的是完整地址的请求，这里直接按上游处理
+	proxy := newVllmServer(0, &hosts)
+	defer proxy.Close()
+
+	if _, err := ProbeCapabilities("http://selfhosted.invalid", "", proxy.URL, "qwen"); err != nil {
+		t.Fatalf("probe through proxy failed: %s", err)
+	}
+	if len(hosts) == 0 || hosts[0] != "selfhosted.invalid" {
+		t.Errorf("requests did not go through the proxy: %v", hosts)
+	}
+}
+
+func TestProbeCapabilitiesTotalTimeout(t *testing.T) {
+	service.InitHttpClient()
+	oldTotal, oldRequest := probeTotalTimeout, probeRequestTimeout
+	defer func() { probeTotalTimeout, probeRequestTimeout = oldTotal, oldRequest }()
+	probeTotalTimeout, probeRequestTimeout = 300*time.Millisecond, 200*time.Millisecond
+
+	server := newVllmServer(150*time.Millisecond, nil)
+	defer server.Close()
+
+	start := time.Now()
+	if _, err := ProbeCapabilities(server.URL, "", "", "qwen"); err == nil {
+		t.Fatal("expected probe to fail after the total timeout")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("probe took %s, total timeout not enforced", elapsed)
+	}
+}
@@ -0,0 +1,131 @@
+package selfhosted
+
+import (
+	"fmt"
+	"one-api/dto"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CapabilityAdjustedHeader 响应头，值为因上游不支持而被移除或改写的请求字段
+const CapabilityAdjustedHeader = "X-Capability-Adjusted"
+
+// applyCapabilities 按渠道能力移除或改写上游不支持的字段，未探测到能力时原样转发
+func applyCapabilities(c *gin.Context, capabilities *dto.ChannelCapabilities, request *dto.GeneralOpenAIRequest) (any, error) {
+	// 自部署模型的对话模板通常不认识 developer 角色
+	for i := range request.Messages {
+		if request.Messages[i].Role == "developer" {
+			request.Messages[i].Role = "system"
+		}
+	}
+	if capabilities == nil {
+		return request, nil
+	}
+
+	var adjusted []string
+	if !capabilities.StreamOptions && request.StreamOptions != nil {
+		request.StreamOptions = nil
+		adjusted = append(adjusted, "stream_options")
+	}
+	if !capabilities.Tools && (len(request.Tools) > 0 || len(request.Functions) > 0 || hasToolMessages(request.Messages)) {
+		stripTools(request)
+		adjusted = append(adjusted, "tools")
+	}
+	if !capabilities.Vision && stripImages(request) {
+		adjusted = append(adjusted, "image_url")
+	}
+
+	var extraFields map[string]any
+	if request.ResponseFormat != nil && (request.ResponseFormat.Type == "json_object" || request.ResponseFormat.Type == "json_schema") {
+		schema := any(map[string]any{"type": "object"})
+		if request.ResponseFormat.JsonSchema != nil && request.ResponseFormat.JsonSchema.Schema != nil {
+			schema = request.ResponseFormat.JsonSchema.Schema
+		}
+		switch capabilities.GuidedDecoding {
+		case dto.GuidedDecodingJsonSchema:
+		case dto.GuidedDecodingGuidedJson:
+			request.ResponseFormat = nil
+			extraFields = map[string]any{"guided_json": schema}
+			adjusted = append(adjusted, "response_format")
+		case dto.GuidedDecodingTgi:
+			request.ResponseFormat = nil
+			extraFields = map[string]any{"response_format": map[string]any{"type": "json", "value": schema}}
+			adjusted = append(adjusted, "response_format")
+		default:
+			request.ResponseFormat = nil
+			adjusted = append(adjusted, "response_format")
+		}
+	}
+
+	if len(adjusted) > 0 {
+		c.Header(CapabilityAdjustedHeader, strings.Join(adjusted, ","))
+	}
+	if extraFields == nil {
+		return request, nil
+	}
+	requestMap := request.ToMap()
+	for k, v := range extraFields {
+		requestMap[k] = v
+	}
+	return requestMap, nil
+}
+
+func hasToolMessages(messages []dto.Message) bool {
+	for _, message := range messages {
+		if message.Role == "tool" || len(message.ParseToolCalls()) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// stripTools 移除工具定义，并把历史中的工具调用与结果改写为普通文本，避免上游拒绝
+func stripTools(request *dto.GeneralOpenAIRequest) {
+	request.Tools = nil
+	request.ToolChoice = nil
+	request.ParallelTooCalls = nil
+	request.Functions = nil
+	for i, message := range request.Messages {
+		toolCalls := message.ParseToolCalls()
+		switch {
+		case message.Role == "tool":
+			request.Messages[i] = dto.Message{Role: "user"}
+			request.Messages[i].SetStringContent(fmt.Sprintf("Tool result (%s):\n%s", message.ToolCallId, message.StringContent()))
+		case len(toolCalls) > 0:
+			var content strings.Builder
+			content.WriteString(message.StringContent())
+			for _, toolCall := range toolCalls {
+				if content.Len() > 0 {
+					content.WriteString("\n")
+				}
+				content.WriteString(fmt.Sprintf("Call tool %s (%s) with arguments: %s", toolCall.Function.Name, toolCall.ID, toolCall.Function.Arguments))
+			}
+			request.Messages[i] = dto.Message{Role: message.Role}
+			request.Messages[i].SetStringContent(content.String())
+		}
+	}
+}
+
+// stripImages 移除消息中的图片，返回是否有图片被移除
+func stripImages(request *dto.GeneralOpenAIRequest) bool {
+	stripped := false
+	for i, message := range request.Messages {
+		if message.IsStringContent() {
+			continue
+		}
+		contents := message.ParseContent()
+		kept := make([]dto.MediaContent, 0, len(contents))
+		for _, content := range contents {
+			if content.Type == dto.ContentTypeImageURL {
+				continue
+			}
+			kept = append(kept, content)
+		}
+		if len(kept) != len(contents) {
+			stripped = true
+			request.Messages[i].SetMediaContent(kept)
+		}
+	}
+	return stripped
+}
@@ -92,18 +92,59 @@ func (a *TaskAdaptor) ValidateRequestAndSetAction(c *gin.Context, info *relaycom
 	action := constant.TaskActionGenerate
 	info.Action = action
 
-	req := relaycommon.TaskSubmitReq{}
+	req := dto.VideoRequest{}
 	if err := common.UnmarshalBodyReusable(c, &req); err != nil {
 		taskErr = service.TaskErrorWrapperLocal(err, "invalid_request", http.StatusBadRequest)
 		return
 	}
-	if strings.TrimSpace(req.Prompt) == "" {
-		taskErr = service.TaskErrorWrapperLocal(fmt.Errorf("prompt is required"), "invalid_request", http.StatusBadRequest)
+	if err := normalizeVideoRequest(&req); err != nil {
+		taskErr = service.TaskErrorWrapperLocal(err, "invalid_request", http.StatusBadRequest)
 		return
 	}
 
 	// Store into context for later usage
-	c.Set("task_request", req)
+	c.Set("task_request", &req)
+	return nil
+}
+
+var supportedAspectRatios = []string{"16:9", "4:3", "1:1", "3:4", "9:16", "21:9"}
+
+// normalizeVideoRequest 校验即梦支持的参数组合：固定 5 秒 720p，不支持负向提示词、尾帧与生成模式
+func normalizeVideoRequest(req *dto.VideoRequest) error {
+	if strings.TrimSpace(req.Prompt) == "" {
+		return fmt.Errorf("prompt is required")
+	}
+	if req.NegativePrompt != "" {
+		return fmt.Errorf("negative_prompt is not supported")
+	}
+	if req.ImageTail != "" {
+		return fmt.Errorf("image_tail is not supported")
+	}
+	if req.Mode != "" {
+		return fmt.Errorf("mode is not supported")
+	}
+	if req.CfgScale != nil {
+		return fmt.Errorf("cfg_scale is not supported")
+	}
+	if req.N > 1 {
+		return fmt.Errorf("n must be 1")
+	}
+	if req.Duration != 0 && req.Duration != 5 {
+		return fmt.Errorf("duration must be 5")
+	}
+	req.Duration = 5
+	if req.Resolution != "" && req.Resolution != "720p" {
+		return fmt.Errorf("resolution must be 720p")
+	}
+	req.Resolution = "720p"
+	aspectRatio, err := req.GetAspectRatio(supportedAspectRatios)
+	if err != nil {
+		return err
+	}
+	req.AspectRatio = aspectRatio
+	if req.AspectRatio == "" {
+		req.AspectRatio = "16:9"
+	}
 	return nil
 }
 
@@ -125,9 +166,9 @@ func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.TaskRel
 	if !exists {
 		return nil, fmt.Errorf("request not found in context")
 	}
-	req := v.(relaycommon.TaskSubmitReq)
+	req := v.(*dto.VideoRequest)
 
-	body, err := a.convertToRequestPayload(&req)
+	body, err := a.convertToRequestPayload(req)
 	if err != nil {
 		return nil, errors.Wrap(err, "convert request payload failed")
 	}
@@ -325,16 +366,21 @@ func hmacSHA256(key []byte, data []byte) []byte {
 	return h.Sum(nil)
 }
 
-func (a *TaskAdaptor) convertToRequestPayload(req *relaycommon.TaskSubmitReq) (*requestPayload, error) {
+func (a *TaskAdaptor) convertToRequestPayload(req *dto.VideoRequest) (*requestPayload, error) {
 	r := requestPayload{
 		ReqKey:      "jimeng_vgfm_i2v_l20",
 		Prompt:      req.Prompt,
-		AspectRatio: "16:9", // Default aspect ratio
-		Seed:        -1,     // Default to random
+		AspectRatio: req.AspectRatio,
+		Seed:        -1, // Default to random
+	}
+	if req.Seed != 0 {
+		r.Seed = int64(req.Seed)
 	}
 
 	// Handle one-of image_urls or binary_data_base64
-	if req.Image != "" {
+	if req.Image == "" {
+		r.ReqKey = "jimeng_vgfm_t2v_l20"
+	} else {
 		if strings.HasPrefix(req.Image, "http") {
 			r.ImageUrls = []string{req.Image}
 		} else {
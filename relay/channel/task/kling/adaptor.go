@@ -8,6 +8,7 @@ import (
 	"io"
 	"net/http"
 	"one-api/model"
+	"strconv"
 	"strings"
 	"time"
 
@@ -27,26 +28,35 @@ import (
 // Request / Response structures
 // ============================
 
-type SubmitReq struct {
-	Prompt   string                 `json:"prompt"`
-	Model    string                 `json:"model,omitempty"`
-	Mode     string                 `json:"mode,omitempty"`
-	Image    string                 `json:"image,omitempty"`
-	Size     string                 `json:"size,omitempty"`
-	Duration int                    `json:"duration,omitempty"`
-	Metadata map[string]interface{} `json:"metadata,omitempty"`
-}
-
 type requestPayload struct {
-	Prompt      string  `json:"prompt,omitempty"`
-	Image       string  `json:"image,omitempty"`
-	Mode        string  `json:"mode,omitempty"`
-	Duration    string  `json:"duration,omitempty"`
-	AspectRatio string  `json:"aspect_ratio,omitempty"`
-	ModelName   string  `json:"model_name,omitempty"`
-	CfgScale    float64 `json:"cfg_scale,omitempty"`
+	Prompt         string  `json:"prompt,omitempty"`
+	NegativePrompt string  `json:"negative_prompt,omitempty"`
+	Image          string  `json:"image,omitempty"`
+	ImageTail      string  `json:"image_tail,omitempty"`
+	Mode           string  `json:"mode,omitempty"`
+	Duration       string  `json:"duration,omitempty"`
+	AspectRatio    string  `json:"aspect_ratio,omitempty"`
+	ModelName      string  `json:"model_name,omitempty"`
+	CfgScale       float64 `json:"cfg_scale,omitempty"`
+	CallbackUrl    string  `json:"callback_url,omitempty"`
 }
 
+var (
+	supportedAspectRatios = []string{"16:9", "9:16", "1:1"}
+	// 可灵通过 mode 区分分辨率：std 为 720p，pro 为 1080p
+	modeResolutions = map[string]string{
+		"std": "720p",
+		"pro": "1080p",
+	}
+	// metadata 中允许透传的字段
+	metadataAllowedFields = map[string]bool{
+		"negative_prompt": true,
+		"image_tail":      true,
+		"aspect_ratio":    true,
+		"cfg_scale":       true,
+	}
+)
+
 type responsePayload struct {
 	Code      int    `json:"code"`
 	Message   string `json:"message"`
@@ -92,22 +102,65 @@ func (a *TaskAdaptor) Init(info *relaycommon.TaskRelayInfo) {
 
 // ValidateRequestAndSetAction parses body, validates fields and sets default action.
 func (a *TaskAdaptor) ValidateRequestAndSetAction(c *gin.Context, info *relaycommon.TaskRelayInfo) (taskErr *dto.TaskError) {
-	// Accept only POST /v1/video/generations as "generate" action.
-	action := constant.TaskActionGenerate
-	info.Action = action
-
-	var req SubmitReq
+	var req dto.VideoRequest
 	if err := common.UnmarshalBodyReusable(c, &req); err != nil {
 		taskErr = service.TaskErrorWrapperLocal(err, "invalid_request", http.StatusBadRequest)
 		return
 	}
-	if strings.TrimSpace(req.Prompt) == "" {
-		taskErr = service.TaskErrorWrapperLocal(fmt.Errorf("prompt is required"), "invalid_request", http.StatusBadRequest)
+	if err := normalizeVideoRequest(&req); err != nil {
+		taskErr = service.TaskErrorWrapperLocal(err, "invalid_request", http.StatusBadRequest)
 		return
 	}
+	// 有图片输入时为图生视频
+	info.Action = lo.Ternary(req.Image != "", constant.TaskActionGenerate, constant.TaskActionTextGenerate)
 
 	// Store into context for later usage
-	c.Set("task_request", req)
+	c.Set("task_request", &req)
+	return nil
+}
+
+// normalizeVideoRequest 校验可灵支持的参数组合，并补全默认的模式、时长、宽高比与分辨率
+func normalizeVideoRequest(req *dto.VideoRequest) error {
+	if strings.TrimSpace(req.Prompt) == "" && req.Image == "" {
+		return fmt.Errorf("prompt is required")
+	}
+	if req.ImageTail != "" && req.Image == "" {
+		return fmt.Errorf("image_tail requires image")
+	}
+	if req.N > 1 {
+		return fmt.Errorf("n must be 1")
+	}
+	if req.Mode != "" {
+		if _, ok := modeResolutions[req.Mode]; !ok {
+			return fmt.Errorf("mode must be one of std, pro")
+		}
+	}
+	if req.Resolution != "" {
+		mode, ok := lo.FindKey(modeResolutions, req.Resolution)
+		if !ok {
+			return fmt.Errorf("resolution must be one of 720p, 1080p")
+		}
+		if req.Mode != "" && req.Mode != mode {
+			return fmt.Errorf("resolution %s is not supported in %s mode", req.Resolution, req.Mode)
+		}
+		req.Mode = mode
+	}
+	req.Mode = defaultString(req.Mode, "std")
+	req.Resolution = modeResolutions[req.Mode]
+	if req.Duration == 0 {
+		req.Duration = 5
+	}
+	if req.Duration != 5 && req.Duration != 10 {
+		return fmt.Errorf("duration must be 5 or 10")
+	}
+	aspectRatio, err := req.GetAspectRatio(supportedAspectRatios)
+	if err != nil {
+		return err
+	}
+	req.AspectRatio = defaultString(aspectRatio, "16:9")
+	if req.CfgScale != nil && (*req.CfgScale < 0 || *req.CfgScale > 1) {
+		return fmt.Errorf("cfg_scale must be between 0 and 1")
+	}
 	return nil
 }
 
@@ -137,12 +190,13 @@ func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.TaskRel
 	if !exists {
 		return nil, fmt.Errorf("request not found in context")
 	}
-	req := v.(SubmitReq)
+	req := v.(*dto.VideoRequest)
 
-	body, err := a.convertToRequestPayload(&req)
+	body, err := a.convertToRequestPayload(req)
 	if err != nil {
 		return nil, err
 	}
+	body.CallbackUrl = service.GetTaskCallbackUrl(c, string(constant.TaskPlatformKling), info.ChannelId)
 	data, err := json.Marshal(body)
 	if err != nil {
 		return nil, err
@@ -231,20 +285,31 @@ func (a *TaskAdaptor) GetChannelName() string {
 // helpers
 // ============================
 
-func (a *TaskAdaptor) convertToRequestPayload(req *SubmitReq) (*requestPayload, error) {
+func (a *TaskAdaptor) convertToRequestPayload(req *dto.VideoRequest) (*requestPayload, error) {
 	r := requestPayload{
-		Prompt:      req.Prompt,
-		Image:       req.Image,
-		Mode:        defaultString(req.Mode, "std"),
-		Duration:    fmt.Sprintf("%d", defaultInt(req.Duration, 5)),
-		AspectRatio: a.getAspectRatio(req.Size),
-		ModelName:   req.Model,
-		CfgScale:    0.5,
+		Prompt:         req.Prompt,
+		NegativePrompt: req.NegativePrompt,
+		Image:          req.Image,
+		ImageTail:      req.ImageTail,
+		Mode:           req.Mode,
+		Duration:       fmt.Sprintf("%d", int(req.Duration)),
+		AspectRatio:    req.AspectRatio,
+		ModelName:      req.Model,
+		CfgScale:       0.5,
+	}
+	if req.CfgScale != nil {
+		r.CfgScale = *req.CfgScale
 	}
 	if r.ModelName == "" {
 		r.ModelName = "kling-v1"
 	}
-	metadata := req.Metadata
+	// metadata 中只接受不影响计费的平台专有参数，模型、模式与时长在计费后不可再覆盖
+	metadata := make(map[string]any)
+	for key, value := range req.Metadata {
+		if metadataAllowedFields[key] {
+			metadata[key] = value
+		}
+	}
 	medaBytes, err := json.Marshal(metadata)
 	if err != nil {
 		return nil, errors.Wrap(err, "metadata marshal metadata failed")
@@ -256,19 +321,6 @@ func (a *TaskAdaptor) convertToRequestPayload(req *SubmitReq) (*requestPayload,
 	return &r, nil
 }
 
-func (a *TaskAdaptor) getAspectRatio(size string) string {
-	switch size {
-	case "1024x1024", "512x512":
-		return "1:1"
-	case "1280x720", "1920x1080":
-		return "16:9"
-	case "720x1280", "1080x1920":
-		return "9:16"
-	default:
-		return "1:1"
-	}
-}
-
 func defaultString(s, def string) string {
 	if strings.TrimSpace(s) == "" {
 		return def
@@ -276,13 +328,6 @@ func defaultString(s, def string) string {
 	return s
 }
 
-func defaultInt(v int, def int) int {
-	if v == 0 {
-		return def
-	}
-	return v
-}
-
 // ============================
 // JWT helpers
 // ============================
@@ -341,6 +386,7 @@ func (a *TaskAdaptor) ParseTaskResult(respBody []byte) (*relaycommon.TaskInfo, e
 	if videos := resPayload.Data.TaskResult.Videos; len(videos) > 0 {
 		video := videos[0]
 		taskInfo.Url = video.Url
+		taskInfo.Duration, _ = strconv.ParseFloat(video.Duration, 64)
 	}
 	return taskInfo, nil
 }
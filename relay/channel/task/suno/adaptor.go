@@ -31,21 +31,31 @@ func (a *TaskAdaptor) Init(info *relaycommon.TaskRelayInfo) {
 }
 
 func (a *TaskAdaptor) ValidateRequestAndSetAction(c *gin.Context, info *relaycommon.TaskRelayInfo) (taskErr *dto.TaskError) {
-	action := strings.ToUpper(c.Param("action"))
-
+	var action string
 	var sunoRequest *dto.SunoSubmitReq
-	err := common.UnmarshalBodyReusable(c, &sunoRequest)
-	if err != nil {
-		taskErr = service.TaskErrorWrapperLocal(err, "invalid_request", http.StatusBadRequest)
-		return
+	if strings.HasPrefix(c.Request.URL.Path, "/v1/audio/music") {
+		var musicRequest dto.MusicRequest
+		err := common.UnmarshalBodyReusable(c, &musicRequest)
+		if err != nil {
+			taskErr = service.TaskErrorWrapperLocal(err, "invalid_request", http.StatusBadRequest)
+			return
+		}
+		action, sunoRequest = convertMusicRequest(&musicRequest)
+	} else {
+		action = strings.ToUpper(c.Param("action"))
+		err := common.UnmarshalBodyReusable(c, &sunoRequest)
+		if err != nil {
+			taskErr = service.TaskErrorWrapperLocal(err, "invalid_request", http.StatusBadRequest)
+			return
+		}
 	}
-	err = actionValidate(c, sunoRequest, action)
+	err := actionValidate(c, sunoRequest, action)
 	if err != nil {
 		taskErr = service.TaskErrorWrapperLocal(err, "invalid_request", http.StatusBadRequest)
 		return
 	}
 
-	if sunoRequest.ContinueClipId != "" {
+	if sunoRequest.ContinueClipId != "" || sunoRequest.CoverClipId != "" || sunoRequest.ClipId != "" {
 		if sunoRequest.TaskID == "" {
 			taskErr = service.TaskErrorWrapperLocal(fmt.Errorf("task id is empty"), "invalid_request", http.StatusBadRequest)
 			return
@@ -60,7 +70,12 @@ func (a *TaskAdaptor) ValidateRequestAndSetAction(c *gin.Context, info *relaycom
 
 func (a *TaskAdaptor) BuildRequestURL(info *relaycommon.TaskRelayInfo) (string, error) {
 	baseURL := info.BaseUrl
-	fullRequestURL := fmt.Sprintf("%s%s", baseURL, "/suno/submit/"+info.Action)
+	action := info.Action
+	if action != constant.SunoActionLyrics {
+		// 续写、翻唱、分轨均通过上游的 music 接口提交
+		action = constant.SunoActionMusic
+	}
+	fullRequestURL := fmt.Sprintf("%s%s", baseURL, "/suno/submit/"+action)
 	return fullRequestURL, nil
 }
 
@@ -79,6 +94,9 @@ func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.TaskRel
 			return nil, err
 		}
 	}
+	if req, ok := sunoRequest.(*dto.SunoSubmitReq); ok {
+		req.NotifyHook = service.GetTaskCallbackUrl(c, string(constant.TaskPlatformSuno), info.ChannelId)
+	}
 	data, err := json.Marshal(sunoRequest)
 	if err != nil {
 		return nil, err
@@ -161,16 +179,61 @@ func (a *TaskAdaptor) FetchTask(baseUrl, key string, body map[string]any) (*http
 func actionValidate(c *gin.Context, sunoRequest *dto.SunoSubmitReq, action string) (err error) {
 	switch action {
 	case constant.SunoActionMusic:
-		if sunoRequest.Mv == "" {
-			sunoRequest.Mv = "chirp-v3-0"
-		}
 	case constant.SunoActionLyrics:
 		if sunoRequest.Prompt == "" {
 			err = fmt.Errorf("prompt_empty")
 			return
 		}
+	case constant.SunoActionExtend:
+		if sunoRequest.ContinueClipId == "" {
+			err = fmt.Errorf("continue_clip_id_empty")
+			return
+		}
+		sunoRequest.Task = "extend"
+	case constant.SunoActionCover:
+		if sunoRequest.CoverClipId == "" {
+			err = fmt.Errorf("cover_clip_id_empty")
+			return
+		}
+		sunoRequest.Task = "cover"
+	case constant.SunoActionStems:
+		if sunoRequest.ClipId == "" {
+			err = fmt.Errorf("clip_id_empty")
+			return
+		}
+		sunoRequest.Task = "gen_stem"
 	default:
 		err = fmt.Errorf("invalid_action")
 	}
+	if action != constant.SunoActionLyrics && sunoRequest.Mv == "" {
+		sunoRequest.Mv = "chirp-v3-0"
+	}
 	return
 }
+
+// convertMusicRequest 将统一音乐请求转换为 suno 请求
+func convertMusicRequest(req *dto.MusicRequest) (string, *dto.SunoSubmitReq) {
+	action := strings.ToUpper(req.Action)
+	if action == "" {
+		action = constant.SunoActionMusic
+	}
+	sunoRequest := &dto.SunoSubmitReq{
+		GptDescriptionPrompt: req.Description,
+		Prompt:               req.Prompt,
+		Mv:                   req.Version,
+		Title:                req.Title,
+		Tags:                 req.Tags,
+		TaskID:               req.TaskId,
+		MakeInstrumental:     req.Instrumental,
+	}
+	switch action {
+	case constant.SunoActionExtend:
+		sunoRequest.ContinueClipId = req.ClipId
+		sunoRequest.ContinueAt = req.ContinueAt
+	case constant.SunoActionCover:
+		sunoRequest.CoverClipId = req.ClipId
+	case constant.SunoActionStems:
+		sunoRequest.ClipId = req.ClipId
+	}
+	return action, sunoRequest
+}
@@ -21,11 +21,14 @@ import (
 )
 
 const (
-	RequestModeClaude = 1
-	RequestModeGemini = 2
-	RequestModeLlama  = 3
+	RequestModeClaude    = 1
+	RequestModeGemini    = 2
+	RequestModeLlama     = 3 // 合作方模型，通过 OpenAI 兼容接口调用
+	RequestModeEmbedding = 4
+	RequestModeImagen    = 5
 )
 
+// claudeModelMap 版本号不符合 claude-xxx@日期 规则的模型，其他模型可在渠道设置的 vertex_models 中配置
 var claudeModelMap = map[string]string{
 	"claude-3-sonnet-20240229":   "claude-3-sonnet@20240229",
 	"claude-3-opus-20240229":     "claude-3-opus@20240229",
@@ -42,14 +45,11 @@ const anthropicVersion = "vertex-2023-10-16"
 type Adaptor struct {
 	RequestMode        int
 	AccountCredentials Credentials
+	Model              vertexModel
 }
 
 func (a *Adaptor) ConvertClaudeRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.ClaudeRequest) (any, error) {
-	if v, ok := claudeModelMap[info.UpstreamModelName]; ok {
-		c.Set("request_model", v)
-	} else {
-		c.Set("request_model", request.Model)
-	}
+	c.Set("request_model", a.Model.Model)
 	vertexClaudeReq := copyRequest(request, anthropicVersion)
 	return vertexClaudeReq, nil
 }
@@ -60,16 +60,24 @@ func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInf
 }
 
 func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (any, error) {
-	//TODO implement me
-	return nil, errors.New("not implemented")
+	if a.RequestMode != RequestModeImagen {
+		return nil, errors.New("not supported model for image generation")
+	}
+	return gemini.ImageRequestOpenAI2Imagen(request), nil
 }
 
 func (a *Adaptor) Init(info *relaycommon.RelayInfo) {
-	if strings.HasPrefix(info.UpstreamModelName, "claude") {
+	a.Model = resolveVertexModel(info.UpstreamModelName, info.ChannelSetting)
+	switch a.Model.Api {
+	case VertexApiClaude:
 		a.RequestMode = RequestModeClaude
-	} else if strings.HasPrefix(info.UpstreamModelName, "gemini") {
+	case VertexApiGemini:
 		a.RequestMode = RequestModeGemini
-	} else if strings.Contains(info.UpstreamModelName, "llama") {
+	case VertexApiEmbedding:
+		a.RequestMode = RequestModeEmbedding
+	case VertexApiImagen:
+		a.RequestMode = RequestModeImagen
+	default:
 		a.RequestMode = RequestModeLlama
 	}
 }
@@ -82,16 +90,20 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 	region := GetModelRegion(info.ApiVersion, info.OriginModelName)
 	a.AccountCredentials = *adc
 	suffix := ""
-	if a.RequestMode == RequestModeGemini {
+	switch a.RequestMode {
+	case RequestModeGemini:
+		model := a.Model.Model
 		if model_setting.GetGeminiSettings().ThinkingAdapterEnabled {
 			// 新增逻辑：处理 -thinking-<budget> 格式
-			if strings.Contains(info.UpstreamModelName, "-thinking-") {
-				parts := strings.Split(info.UpstreamModelName, "-thinking-")
-				info.UpstreamModelName = parts[0]
-			} else if strings.HasSuffix(info.UpstreamModelName, "-thinking") { // 旧的适配
-				info.UpstreamModelName = strings.TrimSuffix(info.UpstreamModelName, "-thinking")
-			} else if strings.HasSuffix(info.UpstreamModelName, "-nothinking") {
-				info.UpstreamModelName = strings.TrimSuffix(info.UpstreamModelName, "-nothinking")
+			if strings.Contains(model, "-thinking-") {
+				model = strings.Split(model, "-thinking-")[0]
+			} else if strings.HasSuffix(model, "-thinking") { // 旧的适配
+				model = strings.TrimSuffix(model, "-thinking")
+			} else if strings.HasSuffix(model, "-nothinking") {
+				model = strings.TrimSuffix(model, "-nothinking")
+			}
+			if model != a.Model.Model && info.UpstreamModelName == a.Model.Model {
+				info.UpstreamModelName = model
 			}
 		}
 
@@ -100,57 +112,18 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 		} else {
 			suffix = "generateContent"
 		}
-		if region == "global" {
-			return fmt.Sprintf(
-				"https://aiplatform.googleapis.com/v1/projects/%s/locations/global/publishers/google/models/%s:%s",
-				adc.ProjectID,
-				info.UpstreamModelName,
-				suffix,
-			), nil
-		} else {
-			return fmt.Sprintf(
-				"https://%s-aiplatform.googleapis.com/v1/projects/%s/locations/%s/publishers/google/models/%s:%s",
-				region,
-				adc.ProjectID,
-				region,
-				info.UpstreamModelName,
-				suffix,
-			), nil
-		}
-	} else if a.RequestMode == RequestModeClaude {
+		return fmt.Sprintf("%s/publishers/%s/models/%s:%s", vertexLocationURL("v1", adc.ProjectID, region), a.Model.Publisher, model, suffix), nil
+	case RequestModeClaude:
 		if info.IsStream {
 			suffix = "streamRawPredict?alt=sse"
 		} else {
 			suffix = "rawPredict"
 		}
-		model := info.UpstreamModelName
-		if v, ok := claudeModelMap[info.UpstreamModelName]; ok {
-			model = v
-		}
-		if region == "global" {
-			return fmt.Sprintf(
-				"https://aiplatform.googleapis.com/v1/projects/%s/locations/global/publishers/anthropic/models/%s:%s",
-				adc.ProjectID,
-				model,
-				suffix,
-			), nil
-		} else {
-			return fmt.Sprintf(
-				"https://%s-aiplatform.googleapis.com/v1/projects/%s/locations/%s/publishers/anthropic/models/%s:%s",
-				region,
-				adc.ProjectID,
-				region,
-				model,
-				suffix,
-			), nil
-		}
-	} else if a.RequestMode == RequestModeLlama {
-		return fmt.Sprintf(
-			"https://%s-aiplatform.googleapis.com/v1beta1/projects/%s/locations/%s/endpoints/openapi/chat/completions",
-			region,
-			adc.ProjectID,
-			region,
-		), nil
+		return fmt.Sprintf("%s/publishers/%s/models/%s:%s", vertexLocationURL("v1", adc.ProjectID, region), a.Model.Publisher, a.Model.Model, suffix), nil
+	case RequestModeEmbedding, RequestModeImagen:
+		return fmt.Sprintf("%s/publishers/%s/models/%s:predict", vertexLocationURL("v1", adc.ProjectID, region), a.Model.Publisher, a.Model.Model), nil
+	case RequestModeLlama:
+		return vertexLocationURL("v1beta1", adc.ProjectID, region) + "/endpoints/openapi/chat/completions", nil
 	}
 	return "", errors.New("unsupported request mode")
 }
@@ -186,6 +159,8 @@ func (a *Adaptor) ConvertOpenAIRequest(c *gin.Context, info *relaycommon.RelayIn
 		c.Set("request_model", request.Model)
 		return geminiRequest, nil
 	} else if a.RequestMode == RequestModeLlama {
+		// OpenAI 兼容接口的模型名需带发布方前缀
+		request.Model = a.Model.Publisher + "/" + a.Model.Model
 		return request, nil
 	}
 	return nil, errors.New("unsupported request mode")
@@ -196,8 +171,23 @@ func (a *Adaptor) ConvertRerankRequest(c *gin.Context, relayMode int, request dt
 }
 
 func (a *Adaptor) ConvertEmbeddingRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.EmbeddingRequest) (any, error) {
-	//TODO implement me
-	return nil, errors.New("not implemented")
+	if a.RequestMode != RequestModeEmbedding {
+		return nil, errors.New("not supported model for embedding")
+	}
+	inputs := request.ParseInput()
+	if len(inputs) == 0 {
+		return nil, errors.New("input is empty")
+	}
+	vertexRequest := VertexEmbeddingRequest{
+		Instances: make([]VertexEmbeddingInstance, 0, len(inputs)),
+	}
+	for _, input := range inputs {
+		vertexRequest.Instances = append(vertexRequest.Instances, VertexEmbeddingInstance{Content: input})
+	}
+	if request.Dimensions > 0 {
+		vertexRequest.Parameters = &VertexEmbeddingParameters{OutputDimensionality: request.Dimensions}
+	}
+	return vertexRequest, nil
 }
 
 func (a *Adaptor) ConvertOpenAIResponsesRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.OpenAIResponsesRequest) (any, error) {
@@ -225,6 +215,10 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycom
 		}
 	} else {
 		switch a.RequestMode {
+		case RequestModeEmbedding:
+			usage, err = vertexEmbeddingHandler(c, info, resp)
+		case RequestModeImagen:
+			usage, err = gemini.GeminiImageHandler(c, info, resp)
 		case RequestModeClaude:
 			err, usage = claude.ClaudeHandler(c, resp, claude.RequestModeMessage, info)
 		case RequestModeGemini:
@@ -1,6 +1,34 @@
 package vertex
 
-import "one-api/common"
+import (
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	"one-api/types"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	VertexApiGemini    = "gemini"
+	VertexApiClaude    = "claude"
+	VertexApiOpenAPI   = "openapi"
+	VertexApiEmbedding = "embedding"
+	VertexApiImagen    = "imagen"
+)
+
+// vertexModel 请求模型在 Vertex 模型花园中对应的发布方、模型 id 与调用方式
+type vertexModel struct {
+	Publisher string
+	Model     string
+	Api       string
+}
+
+var claudeVersionRegex = regexp.MustCompile(`^(claude-.+)-(\d{8})$`)
 
 func GetModelRegion(other string, localModelName string) string {
 	// if other is json string
@@ -17,3 +45,115 @@ func GetModelRegion(other string, localModelName string) string {
 	}
 	return other
 }
+
+// resolveVertexModel 优先使用渠道设置中的模型映射，未配置时按模型名推断
+func resolveVertexModel(modelName string, setting dto.ChannelSettings) vertexModel {
+	if mapped, ok := setting.VertexModels[modelName]; ok {
+		model := vertexModel{
+			Publisher: mapped.Publisher,
+			Model:     mapped.Model,
+			Api:       mapped.Api,
+		}
+		if model.Model == "" {
+			model.Model = modelName
+		}
+		if model.Publisher == "" {
+			model.Publisher = inferVertexModel(model.Model).Publisher
+		}
+		if model.Api == "" {
+			switch model.Publisher {
+			case "google":
+				model.Api = inferVertexModel(model.Model).Api
+			case "anthropic":
+				model.Api = VertexApiClaude
+			default:
+				model.Api = VertexApiOpenAPI
+			}
+		}
+		return model
+	}
+	return inferVertexModel(modelName)
+}
+
+func inferVertexModel(modelName string) vertexModel {
+	switch {
+	case strings.HasPrefix(modelName, "claude"):
+		model := modelName
+		if v, ok := claudeModelMap[modelName]; ok {
+			model = v
+		} else if matches := claudeVersionRegex.FindStringSubmatch(modelName); matches != nil {
+			// claude-xxx-20250101 -> claude-xxx@20250101
+			model = matches[1] + "@" + matches[2]
+		}
+		return vertexModel{Publisher: "anthropic", Model: model, Api: VertexApiClaude}
+	case strings.HasPrefix(modelName, "imagen"):
+		return vertexModel{Publisher: "google", Model: modelName, Api: VertexApiImagen}
+	case strings.HasPrefix(modelName, "text-embedding"), strings.HasPrefix(modelName, "text-multilingual-embedding"),
+		strings.HasPrefix(modelName, "gemini-embedding"), strings.HasPrefix(modelName, "embedding"):
+		return vertexModel{Publisher: "google", Model: modelName, Api: VertexApiEmbedding}
+	case strings.HasPrefix(modelName, "gemini"):
+		return vertexModel{Publisher: "google", Model: modelName, Api: VertexApiGemini}
+	}
+	// 合作方模型通过 OpenAI 兼容接口调用，模型名形如 meta/llama-3.3-70b-instruct-maas
+	if publisher, model, ok := strings.Cut(modelName, "/"); ok {
+		return vertexModel{Publisher: publisher, Model: model, Api: VertexApiOpenAPI}
+	}
+	publisher := "google"
+	if strings.Contains(modelName, "llama") {
+		publisher = "meta"
+	} else if strings.Contains(modelName, "mistral") || strings.Contains(modelName, "codestral") {
+		publisher = "mistralai"
+	}
+	return vertexModel{Publisher: publisher, Model: modelName, Api: VertexApiOpenAPI}
+}
+
+// vertexLocationURL 获取项目在指定区域下的接口前缀
+func vertexLocationURL(version string, projectID string, region string) string {
+	if region == "global" {
+		return "https://aiplatform.googleapis.com/" + version + "/projects/" + projectID + "/locations/global"
+	}
+	return "https://" + region + "-aiplatform.googleapis.com/" + version + "/projects/" + projectID + "/locations/" + region
+}
+
+func vertexEmbeddingHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	defer common.CloseResponseBodyGracefully(resp)
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	var vertexResponse VertexEmbeddingResponse
+	if err := common.Unmarshal(responseBody, &vertexResponse); err != nil {
+		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+
+	openAIResponse := dto.OpenAIEmbeddingResponse{
+		Object: "list",
+		Data:   make([]dto.OpenAIEmbeddingResponseItem, 0, len(vertexResponse.Predictions)),
+		Model:  info.UpstreamModelName,
+	}
+	promptTokens := 0
+	for i, prediction := range vertexResponse.Predictions {
+		openAIResponse.Data = append(openAIResponse.Data, dto.OpenAIEmbeddingResponseItem{
+			Object:    "embedding",
+			Embedding: prediction.Embeddings.Values,
+			Index:     i,
+		})
+		promptTokens += int(prediction.Embeddings.Statistics.TokenCount)
+	}
+	if promptTokens == 0 {
+		promptTokens = info.PromptTokens
+	}
+	usage := &dto.Usage{
+		PromptTokens: promptTokens,
+		TotalTokens:  promptTokens,
+	}
+	openAIResponse.Usage = *usage
+
+	jsonResponse, err := common.Marshal(openAIResponse)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	common.IOCopyBytesGracefully(c, resp, jsonResponse)
+	return usage, nil
+}
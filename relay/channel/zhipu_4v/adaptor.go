@@ -30,8 +30,17 @@ func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInf
 }
 
 func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (any, error) {
-	//TODO implement me
-	return nil, errors.New("not implemented")
+	if info.RelayMode != relayconstant.RelayModeImagesGenerations {
+		return nil, errors.New("not implemented")
+	}
+	// cogview 每次只生成一张图片，n 由上层拆分请求实现
+	return ZhipuV4ImageRequest{
+		Model:   request.Model,
+		Prompt:  request.Prompt,
+		Size:    request.Size,
+		Quality: request.Quality,
+		UserId:  request.User,
+	}, nil
 }
 
 func (a *Adaptor) Init(info *relaycommon.RelayInfo) {
@@ -42,6 +51,8 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 	switch info.RelayMode {
 	case relayconstant.RelayModeEmbeddings:
 		return fmt.Sprintf("%s/embeddings", baseUrl), nil
+	case relayconstant.RelayModeImagesGenerations:
+		return fmt.Sprintf("%s/images/generations", baseUrl), nil
 	default:
 		return fmt.Sprintf("%s/chat/completions", baseUrl), nil
 	}
@@ -82,6 +93,9 @@ func (a *Adaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, request
 }
 
 func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (usage any, err *types.NewAPIError) {
+	if info.RelayMode == relayconstant.RelayModeImagesGenerations {
+		return openai.OpenaiHandlerWithUsage(c, info, resp)
+	}
 	if info.IsStream {
 		usage, err = openai.OaiStreamHandler(c, info, resp)
 	} else {
@@ -53,6 +53,14 @@ type ZhipuV4StreamResponse struct {
 	Usage   dto.Usage                                 `json:"usage"`
 }
 
+type ZhipuV4ImageRequest struct {
+	Model   string `json:"model"`
+	Prompt  string `json:"prompt"`
+	Size    string `json:"size,omitempty"`
+	Quality string `json:"quality,omitempty"`
+	UserId  string `json:"user_id,omitempty"`
+}
+
 type tokenData struct {
 	Token      string
 	ExpiryTime time.Time
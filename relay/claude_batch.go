@@ -0,0 +1,472 @@
+package relay
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/relay/channel/claude"
+	relaycommon "one-api/relay/common"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/setting"
+	"one-api/setting/model_setting"
+	"one-api/setting/ratio_setting"
+	"one-api/types"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const claudeBatchesPath = "/v1/messages/batches"
+
+// claudeBatchQuota 按模型价格或倍率计算单个请求的额度，未乘分组倍率与批处理折扣
+func claudeBatchQuota(modelName string, usage dto.ClaudeUsage) float64 {
+	if modelPrice, ok := ratio_setting.GetModelPrice(modelName, false); ok {
+		return modelPrice * common.QuotaPerUnit
+	}
+	modelRatio, _, _ := ratio_setting.GetModelRatio(modelName)
+	cacheRatio, _ := ratio_setting.GetCacheRatio(modelName)
+	cacheCreationRatio, _ := ratio_setting.GetCreateCacheRatio(modelName)
+	quota := float64(usage.InputTokens)
+	quota += float64(usage.CacheReadInputTokens) * cacheRatio
+	quota += float64(usage.CacheCreationInputTokens) * cacheCreationRatio
+	quota += float64(usage.OutputTokens) * ratio_setting.GetCompletionRatio(modelName)
+	return quota * modelRatio
+}
+
+// ClaudeBatchSubmitHelper 创建 Message Batch，按输入与 max_tokens 预扣批处理折扣后的额度，批处理结束后按实际用量结算
+func ClaudeBatchSubmitHelper(c *gin.Context) *types.NewAPIError {
+	relayInfo := relaycommon.GenRelayInfoClaude(c)
+	if relayInfo.ChannelType != constant.ChannelTypeAnthropic {
+		return types.NewErrorWithStatusCode(errors.New("message batches are only supported by anthropic channels"), types.ErrorCodeInvalidRequest, http.StatusBadRequest)
+	}
+	var batchRequest dto.ClaudeBatchRequest
+	if err := common.UnmarshalBodyReusable(c, &batchRequest); err != nil {
+		return types.NewError(err, types.ErrorCodeInvalidRequest)
+	}
+	if len(batchRequest.Requests) == 0 {
+		return types.NewError(errors.New("field requests is required"), types.ErrorCodeInvalidRequest)
+	}
+
+	// 逐个请求估算额度并按渠道模型映射改写模型名
+	var tokenModelLimit map[string]bool
+	if common.GetContextKeyBool(c, constant.ContextKeyTokenModelLimitEnabled) {
+		tokenModelLimit, _ = common.GetContextKeyType[map[string]bool](c, constant.ContextKeyTokenModelLimit)
+	}
+	modelMapping := make(map[string]string)
+	estimatedQuota := 0.0
+	for i, item := range batchRequest.Requests {
+		var textRequest dto.ClaudeRequest
+		if err := common.Unmarshal(item.Params, &textRequest); err != nil {
+			return types.NewError(fmt.Errorf("requests[%d].params is invalid: %s", i, err.Error()), types.ErrorCodeInvalidRequest)
+		}
+		if textRequest.Model == "" {
+			return types.NewError(fmt.Errorf("requests[%d].params.model is required", i), types.ErrorCodeInvalidRequest)
+		}
+		if tokenModelLimit != nil && !tokenModelLimit[textRequest.Model] {
+			return types.NewErrorWithStatusCode(errors.New("该令牌无权访问模型 "+textRequest.Model), types.ErrorCodeAccessDenied, http.StatusForbidden)
+		}
+		if !helper.ContainPriceOrRatio(textRequest.Model) && !relayInfo.UserSetting.AcceptUnsetRatioModel {
+			return types.NewError(fmt.Errorf("模型 %s 倍率或价格未配置，请联系管理员设置或开始自用模式；Model %s ratio or price not set, please set or start self-use mode", textRequest.Model, textRequest.Model), types.ErrorCodeModelPriceError)
+		}
+		itemInfo := *relayInfo
+		itemInfo.OriginModelName = textRequest.Model
+		itemInfo.UpstreamModelName = textRequest.Model
+		itemInfo.IsModelMapped = false
+		if err := helper.ModelMappedHelper(c, &itemInfo, nil); err != nil {
+			return types.NewError(err, types.ErrorCodeChannelModelMappedError)
+		}
+		promptTokens, err := service.CountTokenClaudeRequest(textRequest, itemInfo.UpstreamModelName)
+		if err != nil {
+			return types.NewError(err, types.ErrorCodeCountTokenFailed)
+		}
+		maxTokens := int(textRequest.MaxTokens)
+		if maxTokens == 0 {
+			maxTokens = model_setting.GetClaudeSettings().GetDefaultMaxTokens(textRequest.Model)
+		}
+		estimatedQuota += claudeBatchQuota(textRequest.Model, dto.ClaudeUsage{InputTokens: promptTokens, OutputTokens: maxTokens})
+
+		modelMapping[itemInfo.UpstreamModelName] = textRequest.Model
+		if itemInfo.IsModelMapped {
+			var params map[string]json.RawMessage
+			if err := common.Unmarshal(item.Params, &params); err != nil {
+				return types.NewError(err, types.ErrorCodeInvalidRequest)
+			}
+			params["model"], _ = common.Marshal(itemInfo.UpstreamModelName)
+			if batchRequest.Requests[i].Params, err = common.Marshal(params); err != nil {
+				return types.NewError(err, types.ErrorCodeJsonMarshalFailed)
+			}
+		}
+	}
+
+	groupRatio := helper.HandleGroupRatio(c, relayInfo).GroupRatio
+	batchDiscount := model_setting.GetClaudeSettings().BatchDiscount
+	quota := int(estimatedQuota * groupRatio * batchDiscount)
+	jsonData, err := common.Marshal(&batchRequest)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeJsonMarshalFailed)
+	}
+	// 与普通请求一样先校验并预扣用户与令牌额度，提交失败时退还
+	preConsumedQuota, userQuota, newAPIError := preConsumeQuota(c, quota, relayInfo)
+	if newAPIError != nil {
+		return newAPIError
+	}
+	defer func() {
+		if newAPIError != nil {
+			returnPreConsumedQuota(c, relayInfo, userQuota, preConsumedQuota)
+		}
+	}()
+	request := &claude.NativeApiRequest{
+		BaseURL:     relayInfo.BaseUrl,
+		Key:         relayInfo.ApiKey,
+		Proxy:       relayInfo.ChannelSetting.Proxy,
+		Method:      http.MethodPost,
+		Path:        claudeBatchesPath,
+		Body:        bytes.NewReader(jsonData),
+		ContentType: "application/json",
+	}
+	resp, err := request.Do(c)
+	if err != nil {
+		newAPIError = types.NewOpenAIError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)
+		return newAPIError
+	}
+	if resp.StatusCode != http.StatusOK {
+		newAPIError = service.RelayErrorHandler(resp, false)
+		return newAPIError
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		newAPIError = types.NewError(err, types.ErrorCodeReadResponseBodyFailed)
+		return newAPIError
+	}
+
+	task := &model.Task{
+		Platform:   constant.TaskPlatformClaudeBatch,
+		UserId:     relayInfo.UserId,
+		ChannelId:  relayInfo.ChannelId,
+		Action:     "batch",
+		Status:     model.TaskStatusSubmitted,
+		Progress:   "0%",
+		SubmitTime: time.Now().Unix(),
+		Quota:      quota,
+		Properties: model.Properties{
+			Group:         relayInfo.UsingGroup,
+			TokenId:       relayInfo.TokenId,
+			GroupRatio:    groupRatio,
+			BatchDiscount: batchDiscount,
+			ModelMapping:  modelMapping,
+			KeyIndex:      common.GetContextKeyInt(c, constant.ContextKeyChannelMultiKeyIndex),
+		},
+	}
+	batch, err := applyClaudeBatch(task, body)
+	if err != nil {
+		newAPIError = types.NewError(err, types.ErrorCodeBadResponseBody)
+		return newAPIError
+	}
+	task.TaskID = batch.Id
+	if err = task.Insert(); err != nil {
+		newAPIError = types.NewError(err, types.ErrorCodeUpdateDataError)
+		return newAPIError
+	}
+	service.ResetTaskPoll(string(constant.TaskPlatformClaudeBatch))
+
+	if quota != 0 {
+		if err = service.PostConsumeQuota(relayInfo, quota-preConsumedQuota, 0, true); err != nil {
+			common.SysError("error consuming token remain quota: " + err.Error())
+		}
+		logContent := fmt.Sprintf("Claude 批处理 %s，%d 个请求，预扣输入与最大输出额度，批处理折扣 %.2f，分组倍率 %.2f，结束后按实际用量结算",
+			batch.Id, len(batchRequest.Requests), batchDiscount, groupRatio)
+		model.RecordConsumeLog(c, relayInfo.UserId, model.RecordConsumeLogParams{
+			ChannelId: relayInfo.ChannelId,
+			ModelName: relayInfo.OriginModelName,
+			TokenName: c.GetString("token_name"),
+			Quota:     quota,
+			Content:   logContent,
+			TokenId:   relayInfo.TokenId,
+			UserQuota: userQuota,
+			Group:     relayInfo.UsingGroup,
+			Other: map[string]interface{}{
+				"batch_id":       batch.Id,
+				"batch_discount": batchDiscount,
+				"group_ratio":    groupRatio,
+			},
+		})
+		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
+		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
+	}
+	c.Data(http.StatusOK, "application/json", task.Data)
+	return nil
+}
+
+// applyClaudeBatch 根据上游返回的批处理对象更新任务状态与进度，results_url 改写为网关地址
+func applyClaudeBatch(task *model.Task, body []byte) (*dto.ClaudeBatch, error) {
+	var batch dto.ClaudeBatch
+	if err := common.Unmarshal(body, &batch); err != nil {
+		return nil, err
+	}
+	if batch.Id == "" {
+		return nil, fmt.Errorf("invalid batch response: %s", string(body))
+	}
+	if batch.ResultsUrl != nil {
+		var data map[string]json.RawMessage
+		if err := common.Unmarshal(body, &data); err == nil {
+			data["results_url"], _ = common.Marshal(fmt.Sprintf("%s%s/%s/results", setting.ServerAddress, claudeBatchesPath, batch.Id))
+			if rewritten, err := common.Marshal(data); err == nil {
+				body = rewritten
+			}
+		}
+	}
+	task.Data = body
+
+	counts := batch.RequestCounts
+	done := counts.Succeeded + counts.Errored + counts.Canceled + counts.Expired
+	switch batch.ProcessingStatus {
+	case dto.ClaudeBatchStatusEnded:
+		task.Status = model.TaskStatusSuccess
+		task.Progress = "100%"
+		if task.FinishTime == 0 {
+			task.FinishTime = time.Now().Unix()
+		}
+	default:
+		task.Status = model.TaskStatusInProgress
+		if task.StartTime == 0 {
+			task.StartTime = time.Now().Unix()
+		}
+		if total := done + counts.Processing; total > 0 {
+			task.Progress = fmt.Sprintf("%d%%", done*100/total)
+		}
+	}
+	return &batch, nil
+}
+
+// UpdateClaudeBatchTask 使用上游返回的批处理对象更新任务，批处理结束时按实际用量结算，c 为空时用于后台轮询
+func UpdateClaudeBatchTask(ctx context.Context, c *gin.Context, channel *model.Channel, task *model.Task, body []byte) error {
+	oldStatus := task.Status
+	oldFinishTime := task.FinishTime
+	if _, err := applyClaudeBatch(task, body); err != nil {
+		return err
+	}
+	// 所有写入都以读取时的状态为条件，其他请求已更新状态时丢弃本次过期的写入，避免覆盖已结算的任务
+	if oldStatus == model.TaskStatusSuccess || task.Status != model.TaskStatusSuccess {
+		_, err := task.UpdateWithStatus(oldStatus)
+		return err
+	}
+	// 结果读取失败时保持原状态，下次轮询重新结算
+	quota, succeeded, err := claudeBatchResultQuota(c, channel, task)
+	if err != nil {
+		common.LogError(ctx, "fail to read claude batch results: "+err.Error())
+		task.Status = oldStatus
+		task.FinishTime = oldFinishTime
+		_, err = task.UpdateWithStatus(oldStatus)
+		return err
+	}
+	// 用户查询与后台轮询可能同时看到批处理结束，只有状态更新成功的一方结算
+	oldQuota := task.Quota
+	task.Quota = quota
+	updated, err := task.UpdateWithStatus(oldStatus)
+	if err != nil || !updated {
+		task.Quota = oldQuota
+		return err
+	}
+	delta := quota - oldQuota
+	logContent := fmt.Sprintf("Claude 批处理 %s 已结束，成功 %d 个请求，按实际用量结算 %s（调整 %s）", task.TaskID, succeeded, common.LogQuota(quota), common.LogQuota(delta))
+	other := map[string]interface{}{
+		"batch_id":  task.TaskID,
+		"succeeded": succeeded,
+	}
+	if err = service.AdjustTaskQuota(ctx, task, delta, logContent, other); err != nil {
+		common.LogError(ctx, "fail to settle claude batch quota: "+err.Error())
+	}
+	return nil
+}
+
+// FetchClaudeBatch 查询上游批处理并更新任务
+func FetchClaudeBatch(ctx context.Context, c *gin.Context, channel *model.Channel, task *model.Task) ([]byte, error) {
+	request := claude.NewNativeApiRequest(channel, task.Properties.KeyIndex, http.MethodGet, claudeBatchesPath+"/"+task.TaskID)
+	resp, err := request.Do(c)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code %d: %s", resp.StatusCode, string(body))
+	}
+	if err = UpdateClaudeBatchTask(ctx, c, channel, task, body); err != nil {
+		return nil, err
+	}
+	return task.Data, nil
+}
+
+// claudeBatchResultQuota 读取批处理结果，返回成功请求按实际用量计算的额度与成功请求数
+func claudeBatchResultQuota(c *gin.Context, channel *model.Channel, task *model.Task) (int, int, error) {
+	request := claude.NewNativeApiRequest(channel, task.Properties.KeyIndex, http.MethodGet, claudeBatchesPath+"/"+task.TaskID+"/results")
+	resp, err := request.Do(c)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, 0, fmt.Errorf("status code %d", resp.StatusCode)
+	}
+
+	actualQuota := 0.0
+	succeeded := 0
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var result dto.ClaudeBatchResult
+			if common.Unmarshal(line, &result) == nil && result.Result.Message != nil && result.Result.Message.Usage != nil {
+				modelName := result.Result.Message.Model
+				if originModel, ok := task.Properties.ModelMapping[modelName]; ok {
+					modelName = originModel
+				}
+				actualQuota += claudeBatchQuota(modelName, *result.Result.Message.Usage)
+				succeeded++
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				return 0, 0, err
+			}
+			break
+		}
+	}
+	return int(actualQuota * task.Properties.GroupRatio * task.Properties.BatchDiscount), succeeded, nil
+}
+
+// getUserClaudeBatch 获取当前用户的批处理任务及其所属渠道
+func getUserClaudeBatch(c *gin.Context) (*model.Task, *model.Channel, *types.NewAPIError) {
+	task, exist, err := model.GetByTaskId(c.GetInt("id"), c.Param("id"))
+	if err != nil {
+		return nil, nil, types.NewError(err, types.ErrorCodeQueryDataError)
+	}
+	if !exist || task.Platform != constant.TaskPlatformClaudeBatch {
+		return nil, nil, types.NewErrorWithStatusCode(errors.New("batch not found"), types.ErrorCodeInvalidRequest, http.StatusNotFound)
+	}
+	channel, err := model.GetChannelById(task.ChannelId, true)
+	if err != nil {
+		return nil, nil, types.NewError(err, types.ErrorCodeGetChannelFailed)
+	}
+	return task, channel, nil
+}
+
+// ClaudeBatchFetchHelper 查询批处理
+func ClaudeBatchFetchHelper(c *gin.Context) *types.NewAPIError {
+	task, channel, apiErr := getUserClaudeBatch(c)
+	if apiErr != nil {
+		return apiErr
+	}
+	body, err := FetchClaudeBatch(c, c, channel, task)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeBadResponse)
+	}
+	c.Data(http.StatusOK, "application/json", body)
+	return nil
+}
+
+// ClaudeBatchCancelHelper 取消批处理，已完成的请求仍按用量结算
+func ClaudeBatchCancelHelper(c *gin.Context) *types.NewAPIError {
+	task, channel, apiErr := getUserClaudeBatch(c)
+	if apiErr != nil {
+		return apiErr
+	}
+	request := claude.NewNativeApiRequest(channel, task.Properties.KeyIndex, http.MethodPost, claudeBatchesPath+"/"+task.TaskID+"/cancel")
+	resp, err := request.Do(c)
+	if err != nil {
+		return types.NewOpenAIError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return service.RelayErrorHandler(resp, false)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeReadResponseBodyFailed)
+	}
+	if err = UpdateClaudeBatchTask(c, c, channel, task, body); err != nil {
+		return types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	c.Data(http.StatusOK, "application/json", task.Data)
+	return nil
+}
+
+// ClaudeBatchResultsHelper 透传批处理结果文件
+func ClaudeBatchResultsHelper(c *gin.Context) *types.NewAPIError {
+	task, channel, apiErr := getUserClaudeBatch(c)
+	if apiErr != nil {
+		return apiErr
+	}
+	request := claude.NewNativeApiRequest(channel, task.Properties.KeyIndex, http.MethodGet, claudeBatchesPath+"/"+task.TaskID+"/results")
+	resp, err := request.Do(c)
+	if err != nil {
+		return types.NewOpenAIError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return service.RelayErrorHandler(resp, false)
+	}
+	defer resp.Body.Close()
+	c.DataFromReader(http.StatusOK, resp.ContentLength, resp.Header.Get("Content-Type"), resp.Body, nil)
+	return nil
+}
+
+// ClaudeBatchDeleteHelper 删除上游批处理，本地任务保留用于对账
+func ClaudeBatchDeleteHelper(c *gin.Context) *types.NewAPIError {
+	task, channel, apiErr := getUserClaudeBatch(c)
+	if apiErr != nil {
+		return apiErr
+	}
+	request := claude.NewNativeApiRequest(channel, task.Properties.KeyIndex, http.MethodDelete, claudeBatchesPath+"/"+task.TaskID)
+	resp, err := request.Do(c)
+	if err != nil {
+		return types.NewOpenAIError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return service.RelayErrorHandler(resp, false)
+	}
+	defer resp.Body.Close()
+	c.DataFromReader(http.StatusOK, resp.ContentLength, resp.Header.Get("Content-Type"), resp.Body, nil)
+	return nil
+}
+
+// ClaudeBatchListHelper 列出当前用户的批处理，数据来自本地任务记录
+func ClaudeBatchListHelper(c *gin.Context) *types.NewAPIError {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 1000 {
+		limit = 20
+	}
+	tasks := model.TaskGetAllUserTask(c.GetInt("id"), 0, limit+1, model.SyncTaskQueryParams{
+		Platform: constant.TaskPlatformClaudeBatch,
+	})
+	response := dto.ClaudeListResponse{
+		Data:    make([]json.RawMessage, 0, len(tasks)),
+		HasMore: len(tasks) > limit,
+	}
+	if response.HasMore {
+		tasks = tasks[:limit]
+	}
+	for _, task := range tasks {
+		response.Data = append(response.Data, task.Data)
+	}
+	if len(tasks) > 0 {
+		response.FirstId = &tasks[0].TaskID
+		response.LastId = &tasks[len(tasks)-1].TaskID
+	}
+	c.JSON(http.StatusOK, response)
+	return nil
+}
@@ -0,0 +1,156 @@
+package relay
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/service"
+	"one-api/setting"
+	"one-api/setting/ratio_setting"
+)
+
+func setupClaudeBatchRatios(t *testing.T) {
+	t.Helper()
+	if err := ratio_setting.UpdateModelRatioByJSONString(`{"batch-test-model":2}`); err != nil {
+		t.Fatalf("update model ratio failed: %s", err)
+	}
+	if err := ratio_setting.UpdateCompletionRatioByJSONString(`{"batch-test-model":4}`); err != nil {
+		t.Fatalf("update completion ratio failed: %s", err)
+	}
+	if err := ratio_setting.UpdateCacheRatioByJSONString(`{"batch-test-model":0.1}`); err != nil {
+		t.Fatalf("update cache ratio failed: %s", err)
+	}
+	if err := ratio_setting.UpdateModelPriceByJSONString(`{"batch-test-price":0.01}`); err != nil {
+		t.Fatalf("update model price failed: %s", err)
+	}
+}
+
+func TestClaudeBatchQuota(t *testing.T) {
+	setupClaudeBatchRatios(t)
+	cases := []struct {
+		name  string
+		model string
+		usage dto.ClaudeUsage
+		want  float64
+	}{
+		{"input and output", "batch-test-model", dto.ClaudeUsage{InputTokens: 100, OutputTokens: 10}, (100 + 10*4) * 2},
+		{"cache read and creation", "batch-test-model", dto.ClaudeUsage{InputTokens: 100, CacheReadInputTokens: 1000, CacheCreationInputTokens: 40}, (100 + 1000*0.1 + 40*1.25) * 2},
+		{"fixed price", "batch-test-price", dto.ClaudeUsage{InputTokens: 100, OutputTokens: 10}, 0.01 * common.QuotaPerUnit},
+	}
+	for _, c := range cases {
+		if got := claudeBatchQuota(c.model, c.usage); got != c.want {
+			t.Errorf("%s: claudeBatchQuota = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestApplyClaudeBatch(t *testing.T) {
+	cases := []struct {
+		name         string
+		body         string
+		wantErr      bool
+		wantStatus   model.TaskStatus
+		wantProgress string
+		wantFinished bool
+	}{
+		{
+			name:         "in progress",
+			body:         `{"id":"msgbatch_1","processing_status":"in_progress","request_counts":{"processing":3,"succeeded":1}}`,
+			wantStatus:   model.TaskStatusInProgress,
+			wantProgress: "25%",
+		},
+		{
+			name:         "canceling",
+			body:         `{"id":"msgbatch_1","processing_status":"canceling","request_counts":{"canceled":1,"processing":1}}`,
+			wantStatus:   model.TaskStatusInProgress,
+			wantProgress: "50%",
+		},
+		{
+			name:         "ended",
+			body:         `{"id":"msgbatch_1","processing_status":"ended","request_counts":{"succeeded":2,"errored":1},"results_url":"https://api.anthropic.com/v1/messages/batches/msgbatch_1/results"}`,
+			wantStatus:   model.TaskStatusSuccess,
+			wantProgress: "100%",
+			wantFinished: true,
+		},
+		{
+			name:    "missing id",
+			body:    `{"processing_status":"ended"}`,
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		task := &model.Task{Status: model.TaskStatusSubmitted, Progress: "0%"}
+		batch, err := applyClaudeBatch(task, []byte(c.body))
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected error", c.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", c.name, err)
+			continue
+		}
+		if task.Status != c.wantStatus || task.Progress != c.wantProgress {
+			t.Errorf("%s: got status %s progress %s, want %s %s", c.name, task.Status, task.Progress, c.wantStatus, c.wantProgress)
+		}
+		if (task.FinishTime != 0) != c.wantFinished {
+			t.Errorf("%s: finish time = %d", c.name, task.FinishTime)
+		}
+		if batch.ResultsUrl != nil {
+			wantUrl := setting.ServerAddress + claudeBatchesPath + "/msgbatch_1/results"
+			if !strings.Contains(string(task.Data), wantUrl) || strings.Contains(string(task.Data), "api.anthropic.com") {
+				t.Errorf("%s: results_url not rewritten: %s", c.name, task.Data)
+			}
+		}
+	}
+}
+
+func TestClaudeBatchResultQuota(t *testing.T) {
+	setupClaudeBatchRatios(t)
+	service.InitHttpClient()
+	results := strings.Join([]string{
+		`{"custom_id":"a","result":{"type":"succeeded","message":{"model":"upstream-model","usage":{"input_tokens":100,"output_tokens":10}}}}`,
+		`{"custom_id":"b","result":{"type":"succeeded","message":{"model":"batch-test-model","usage":{"input_tokens":50}}}}`,
+		`{"custom_id":"c","result":{"type":"errored","error":{"type":"invalid_request_error"}}}`,
+		``,
+	}, "\n")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != claudeBatchesPath+"/msgbatch_1/results" || r.Header.Get("x-api-key") != "sk-test" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(results))
+	}))
+	defer server.Close()
+
+	baseURL := server.URL
+	channel := &model.Channel{Key: "sk-test", BaseURL: &baseURL}
+	task := &model.Task{
+		TaskID: "msgbatch_1",
+		Properties: model.Properties{
+			GroupRatio:    2,
+			BatchDiscount: 0.5,
+			ModelMapping:  map[string]string{"upstream-model": "batch-test-model"},
+		},
+	}
+	quota, succeeded, err := claudeBatchResultQuota(nil, channel, task)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// (100+10*4)*2 + 50*2 = 380，再乘分组倍率与批处理折扣
+	if quota != 380 || succeeded != 2 {
+		t.Errorf("got quota %d succeeded %d, want 380 2", quota, succeeded)
+	}
+
+	// 结果无法读取时返回错误，由调用方保持原状态等待下次轮询
+	task.TaskID = "msgbatch_missing"
+	if _, _, err = claudeBatchResultQuota(nil, channel, task); err == nil {
+		t.Error("expected error when results cannot be read")
+	}
+}
@@ -0,0 +1,147 @@
+package relay
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/relay/channel/claude"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+	"one-api/types"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	claudeFilesPath = "/v1/files"
+	claudeFilesBeta = "files-api-2025-04-14"
+)
+
+// ClaudeFileUploadHelper 将文件原样上传到 Anthropic 渠道并记录文件归属
+func ClaudeFileUploadHelper(c *gin.Context) *types.NewAPIError {
+	relayInfo := relaycommon.GenRelayInfoClaude(c)
+	if relayInfo.ChannelType != constant.ChannelTypeAnthropic {
+		return types.NewErrorWithStatusCode(errors.New("files api is only supported by anthropic channels"), types.ErrorCodeInvalidRequest, http.StatusBadRequest)
+	}
+	request := &claude.NativeApiRequest{
+		BaseURL:     relayInfo.BaseUrl,
+		Key:         relayInfo.ApiKey,
+		Proxy:       relayInfo.ChannelSetting.Proxy,
+		Method:      http.MethodPost,
+		Path:        claudeFilesPath,
+		Body:        c.Request.Body,
+		ContentType: c.Request.Header.Get("Content-Type"),
+		Beta:        claudeFilesBeta,
+	}
+	resp, err := request.Do(c)
+	if err != nil {
+		return types.NewOpenAIError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return service.RelayErrorHandler(resp, false)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeReadResponseBodyFailed)
+	}
+	var file dto.ClaudeFile
+	if err = common.Unmarshal(body, &file); err != nil || file.Id == "" {
+		return types.NewError(errors.New("invalid file response: "+string(body)), types.ErrorCodeBadResponseBody)
+	}
+	upstreamFile := &model.UpstreamFile{
+		UserId:    relayInfo.UserId,
+		TokenId:   relayInfo.TokenId,
+		ChannelId: relayInfo.ChannelId,
+		KeyIndex:  common.GetContextKeyInt(c, constant.ContextKeyChannelMultiKeyIndex),
+		Platform:  model.UpstreamFilePlatformClaude,
+		FileId:    file.Id,
+		Filename:  file.Filename,
+		MimeType:  file.MimeType,
+		Size:      file.SizeBytes,
+		Data:      string(body),
+		CreatedAt: time.Now().Unix(),
+	}
+	if err = upstreamFile.Insert(); err != nil {
+		return types.NewError(err, types.ErrorCodeUpdateDataError)
+	}
+	c.Data(http.StatusOK, "application/json", body)
+	return nil
+}
+
+// ClaudeFileListHelper 列出当前用户上传的文件，数据来自本地记录
+func ClaudeFileListHelper(c *gin.Context) *types.NewAPIError {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 1000 {
+		limit = 20
+	}
+	files, err := model.GetUserUpstreamFiles(c.GetInt("id"), model.UpstreamFilePlatformClaude, limit+1)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeQueryDataError)
+	}
+	response := dto.ClaudeListResponse{
+		Data:    make([]json.RawMessage, 0, len(files)),
+		HasMore: len(files) > limit,
+	}
+	if response.HasMore {
+		files = files[:limit]
+	}
+	for _, file := range files {
+		response.Data = append(response.Data, json.RawMessage(file.Data))
+	}
+	if len(files) > 0 {
+		response.FirstId = &files[0].FileId
+		response.LastId = &files[len(files)-1].FileId
+	}
+	c.JSON(http.StatusOK, response)
+	return nil
+}
+
+// getUserClaudeFile 获取当前用户上传的文件及其所属渠道
+func getUserClaudeFile(c *gin.Context) (*model.UpstreamFile, *model.Channel, *types.NewAPIError) {
+	file, err := model.GetUserUpstreamFile(c.GetInt("id"), model.UpstreamFilePlatformClaude, c.Param("id"))
+	if err != nil {
+		return nil, nil, types.NewErrorWithStatusCode(errors.New("file not found"), types.ErrorCodeInvalidRequest, http.StatusNotFound)
+	}
+	channel, err := model.GetChannelById(file.ChannelId, true)
+	if err != nil {
+		return nil, nil, types.NewError(err, types.ErrorCodeGetChannelFailed)
+	}
+	return file, channel, nil
+}
+
+// ClaudeFilePassthroughHelper 校验文件归属后透传文件信息、文件内容与删除请求
+func ClaudeFilePassthroughHelper(c *gin.Context, path string) *types.NewAPIError {
+	file, channel, apiErr := getUserClaudeFile(c)
+	if apiErr != nil {
+		return apiErr
+	}
+	request := claude.NewNativeApiRequest(channel, file.KeyIndex, c.Request.Method, claudeFilesPath+"/"+file.FileId+path)
+	request.Beta = claudeFilesBeta
+	resp, err := request.Do(c)
+	if err != nil {
+		return types.NewOpenAIError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return service.RelayErrorHandler(resp, false)
+	}
+	defer resp.Body.Close()
+	if c.Request.Method == http.MethodDelete {
+		if err = file.Delete(); err != nil {
+			common.LogError(c, "fail to delete upstream file record: "+err.Error())
+		}
+	}
+	extraHeaders := map[string]string{}
+	if disposition := resp.Header.Get("Content-Disposition"); disposition != "" {
+		extraHeaders["Content-Disposition"] = disposition
+	}
+	c.DataFromReader(http.StatusOK, resp.ContentLength, resp.Header.Get("Content-Type"), resp.Body, extraHeaders)
+	return nil
+}
@@ -0,0 +1,81 @@
+package relay
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/relay/channel/claude"
+	relaycommon "one-api/relay/common"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClaudeCountTokensHelper /v1/messages/count_tokens，Anthropic 渠道使用上游计数，其余渠道或上游失败时本地估算
+func ClaudeCountTokensHelper(c *gin.Context) *types.NewAPIError {
+	relayInfo := relaycommon.GenRelayInfoClaude(c)
+
+	textRequest, err := getAndValidateClaudeRequest(c)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeInvalidRequest)
+	}
+	err = helper.ModelMappedHelper(c, relayInfo, textRequest)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeChannelModelMappedError)
+	}
+
+	if relayInfo.ChannelType == constant.ChannelTypeAnthropic {
+		body, err := countClaudeTokensUpstream(c, relayInfo, textRequest)
+		if err == nil {
+			c.Data(http.StatusOK, "application/json", body)
+			return nil
+		}
+		common.LogWarn(c, "count tokens from upstream failed, fallback to local: "+err.Error())
+	}
+
+	inputTokens, err := service.CountTokenClaudeRequest(*textRequest, relayInfo.UpstreamModelName)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeCountTokenFailed)
+	}
+	c.JSON(http.StatusOK, dto.ClaudeCountTokensResponse{InputTokens: inputTokens})
+	return nil
+}
+
+func countClaudeTokensUpstream(c *gin.Context, info *relaycommon.RelayInfo, textRequest *dto.ClaudeRequest) ([]byte, error) {
+	// count_tokens 不接受这些字段
+	countRequest := *textRequest
+	countRequest.MaxTokens = 0
+	countRequest.Stream = false
+	jsonData, err := common.Marshal(&countRequest)
+	if err != nil {
+		return nil, err
+	}
+	request := &claude.NativeApiRequest{
+		BaseURL:     info.BaseUrl,
+		Key:         info.ApiKey,
+		Proxy:       info.ChannelSetting.Proxy,
+		Method:      http.MethodPost,
+		Path:        "/v1/messages/count_tokens",
+		Body:        bytes.NewReader(jsonData),
+		ContentType: "application/json",
+	}
+	resp, err := request.Do(c)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code %d: %s", resp.StatusCode, string(body))
+	}
+	return body, nil
+}
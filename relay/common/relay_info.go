@@ -5,6 +5,7 @@ import (
 	"one-api/constant"
 	"one-api/dto"
 	relayconstant "one-api/relay/constant"
+	"one-api/setting/operation_setting"
 	"strings"
 	"time"
 
@@ -105,6 +106,11 @@ type RelayInfo struct {
 	RelayFormat          string
 	SendResponseCount    int
 	ChannelCreateTime    int64
+	ResponseCacheHit     bool
+	ResponseCacheMiss    bool
+	SemanticCacheState   string
+	SemanticCacheScore   float64
+	TimePricing          *operation_setting.TimePricingMatch // 命中的分时计价规则
 	ThinkingContentInfo
 	*ClaudeConvertInfo
 	*RerankerInfo
@@ -282,6 +288,10 @@ func GenRelayInfo(c *gin.Context) *RelayInfo {
 	if ok {
 		info.ChannelSetting = channelSetting
 	}
+	// 自部署渠道是否支持 stream_options 取决于探测到的能力
+	if info.ChannelType == constant.ChannelTypeSelfHosted {
+		info.SupportStreamOptions = info.ChannelSetting.Capabilities == nil || info.ChannelSetting.Capabilities.StreamOptions
+	}
 	userSetting, ok := common.GetContextKeyType[dto.UserSetting](c, constant.ContextKeyUserSetting)
 	if ok {
 		info.UserSetting = userSetting
@@ -324,16 +334,6 @@ func GenTaskRelayInfo(c *gin.Context) *TaskRelayInfo {
 	return info
 }
 
-type TaskSubmitReq struct {
-	Prompt   string                 `json:"prompt"`
-	Model    string                 `json:"model,omitempty"`
-	Mode     string                 `json:"mode,omitempty"`
-	Image    string                 `json:"image,omitempty"`
-	Size     string                 `json:"size,omitempty"`
-	Duration int                    `json:"duration,omitempty"`
-	Metadata map[string]interface{} `json:"metadata,omitempty"`
-}
-
 type TaskInfo struct {
 	Code     int    `json:"code"`
 	TaskID   string `json:"task_id"`
@@ -341,4 +341,7 @@ type TaskInfo struct {
 	Reason   string `json:"reason,omitempty"`
 	Url      string `json:"url,omitempty"`
 	Progress string `json:"progress,omitempty"`
+
+	CoverUrl string  `json:"cover_url,omitempty"`
+	Duration float64 `json:"duration,omitempty"`
 }
@@ -0,0 +1,318 @@
+package relay
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/setting/model_setting"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// CompletionsEmulatedHeader 响应头，标记本次请求由 chat 接口模拟，值为被模拟的字段
+	CompletionsEmulatedHeader = "X-Completions-Emulated"
+
+	completionsSystemPrompt = "You are a text completion engine. Continue the text provided by the user. " +
+		"Output only the continuation, without repeating the given text or adding any explanation."
+	completionsSuffixSystemPrompt = "You are a text completion engine. The user provides the beginning of a text, " +
+		"and the text must end with the following suffix:\n%s\n" +
+		"Output only the text that goes between the beginning and the suffix, without repeating either of them."
+	editsSystemPrompt = "You are a text editor. Apply the following instruction to the text provided by the user " +
+		"and output only the edited text, without any explanation.\nInstruction: %s"
+)
+
+// 原生支持 /v1/completions 的渠道，其余渠道的 completions 请求需要转换为 chat 请求
+var nativeCompletionsApiTypes = map[int]bool{
+	constant.APITypeOpenAI:      true,
+	constant.APITypeOpenRouter:  true,
+	constant.APITypeXinference:  true,
+	constant.APITypeSiliconFlow: true,
+	constant.APITypeDeepSeek:    true,
+	constant.APITypeCloudflare:  true,
+	constant.APITypeAli:         true,
+	constant.APITypeSelfHosted:  true,
+}
+
+type completionsEmulation struct {
+	relayMode      int
+	echo           string
+	emulatedFields []string
+}
+
+// shouldEmulateCompletions /v1/edits 已被上游下线，总是通过 chat 接口模拟
+func shouldEmulateCompletions(info *relaycommon.RelayInfo) bool {
+	// 透传请求体时无法改写请求，也就不能转换响应
+	if model_setting.GetGlobalSettings().PassThroughRequestEnabled {
+		return false
+	}
+	switch info.RelayMode {
+	case relayconstant.RelayModeEdits:
+		return true
+	case relayconstant.RelayModeCompletions:
+		return info.ChannelSetting.CompletionsEmulation || !nativeCompletionsApiTypes[info.ApiType]
+	}
+	return false
+}
+
+// convertCompletionsToChat 将 completions / edits 请求原地转换为 chat 请求
+func convertCompletionsToChat(info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) (*completionsEmulation, error) {
+	emulation := &completionsEmulation{relayMode: info.RelayMode}
+	var systemPrompt, userPrompt string
+	if info.RelayMode == relayconstant.RelayModeEdits {
+		if request.Stream {
+			return nil, errors.New("stream is not supported for edits")
+		}
+		input, _ := request.Input.(string)
+		systemPrompt = fmt.Sprintf(editsSystemPrompt, request.Instruction)
+		userPrompt = input
+		request.Input = nil
+		request.Instruction = ""
+		emulation.emulatedFields = append(emulation.emulatedFields, "instruction")
+	} else {
+		prompt, ok := request.Prompt.(string)
+		if !ok {
+			// 批量 prompt 与 token 数组无法映射到单次 chat 请求
+			prompts, isArray := request.Prompt.([]any)
+			if !isArray || len(prompts) != 1 {
+				return nil, errors.New("only a single string prompt is supported by this channel")
+			}
+			if prompt, ok = prompts[0].(string); !ok {
+				return nil, errors.New("only a single string prompt is supported by this channel")
+			}
+		}
+		systemPrompt = completionsSystemPrompt
+		if suffix, ok := request.Suffix.(string); ok && suffix != "" {
+			systemPrompt = fmt.Sprintf(completionsSuffixSystemPrompt, suffix)
+			emulation.emulatedFields = append(emulation.emulatedFields, "suffix")
+		}
+		if request.Echo {
+			emulation.echo = prompt
+			emulation.emulatedFields = append(emulation.emulatedFields, "echo")
+		}
+		if topLogprobs, ok := request.LogProbs.(float64); ok {
+			request.LogProbs = true
+			request.TopLogProbs = int(topLogprobs)
+			emulation.emulatedFields = append(emulation.emulatedFields, "logprobs")
+		}
+		userPrompt = prompt
+		request.Prompt = nil
+		request.Suffix = nil
+		request.Echo = false
+	}
+	request.Messages = []dto.Message{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: userPrompt},
+	}
+	info.RelayMode = relayconstant.RelayModeChatCompletions
+	info.RequestURLPath = "/v1/chat/completions"
+	return emulation, nil
+}
+
+// completionsEmulationWriter 将上游返回的 chat 响应转换为 text_completion / edit 格式后写给客户端
+type completionsEmulationWriter struct {
+	gin.ResponseWriter
+	emulation  *completionsEmulation
+	isStream   bool
+	statusCode int
+	buffer     bytes.Buffer
+	echoed     map[int]bool
+}
+
+func startCompletionsEmulation(c *gin.Context, info *relaycommon.RelayInfo, emulation *completionsEmulation) *completionsEmulationWriter {
+	c.Header(CompletionsEmulatedHeader, strings.Join(append([]string{"true"}, emulation.emulatedFields...), ","))
+	writer := &completionsEmulationWriter{
+		ResponseWriter: c.Writer,
+		emulation:      emulation,
+		isStream:       info.IsStream,
+		statusCode:     http.StatusOK,
+		echoed:         make(map[int]bool),
+	}
+	c.Writer = writer
+	return writer
+}
+
+func (w *completionsEmulationWriter) WriteHeader(code int) {
+	if w.isStream {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.statusCode = code
+}
+
+func (w *completionsEmulationWriter) WriteHeaderNow() {
+	if w.isStream {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *completionsEmulationWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Write 流式响应按 SSE 事件边界转换，非流式响应缓存到 finish 时统一转换
+func (w *completionsEmulationWriter) Write(data []byte) (int, error) {
+	w.buffer.Write(data)
+	if !w.isStream {
+		return len(data), nil
+	}
+	for {
+		content := w.buffer.Bytes()
+		end := bytes.Index(content, []byte("\n\n"))
+		if end < 0 {
+			break
+		}
+		event := string(content[:end])
+		w.buffer.Next(end + 2)
+		if err := w.writeEvent(event); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *completionsEmulationWriter) writeEvent(event string) error {
+	data, isData := strings.CutPrefix(event, "data: ")
+	if !isData || data == "[DONE]" {
+		_, err := w.ResponseWriter.WriteString(event + "\n\n")
+		return err
+	}
+	var chunk emulatedChatResponse
+	if err := common.UnmarshalJsonStr(data, &chunk); err != nil {
+		_, err = w.ResponseWriter.WriteString(event + "\n\n")
+		return err
+	}
+	converted, err := common.Marshal(w.convert(&chunk))
+	if err != nil {
+		return err
+	}
+	_, err = w.ResponseWriter.WriteString("data: " + string(converted) + "\n\n")
+	return err
+}
+
+// finish 写出缓存的非流式响应，必须在 DoResponse 成功后调用
+func (w *completionsEmulationWriter) finish(c *gin.Context) {
+	c.Writer = w.ResponseWriter
+	if w.isStream {
+		if w.buffer.Len() > 0 {
+			_, _ = w.ResponseWriter.Write(w.buffer.Bytes())
+		}
+		return
+	}
+	body := w.buffer.Bytes()
+	var response emulatedChatResponse
+	if err := common.Unmarshal(body, &response); err == nil {
+		if converted, err := common.Marshal(w.convert(&response)); err == nil {
+			body = converted
+		}
+	}
+	w.ResponseWriter.Header().Set("Content-Length", fmt.Sprintf("%d", len(body)))
+	w.ResponseWriter.WriteHeader(w.statusCode)
+	_, _ = w.ResponseWriter.Write(body)
+}
+
+type emulatedChatMessage struct {
+	Content any `json:"content"`
+}
+
+type emulatedChatLogprob struct {
+	Token       string                `json:"token"`
+	Logprob     float64               `json:"logprob"`
+	TopLogprobs []emulatedChatLogprob `json:"top_logprobs,omitempty"`
+}
+
+type emulatedChatChoice struct {
+	Index        int                  `json:"index"`
+	Message      *emulatedChatMessage `json:"message,omitempty"`
+	Delta        *emulatedChatMessage `json:"delta,omitempty"`
+	FinishReason *string              `json:"finish_reason"`
+	Logprobs     *struct {
+		Content []emulatedChatLogprob `json:"content"`
+	} `json:"logprobs"`
+}
+
+// emulatedChatResponse 同时兼容 chat.completion 与 chat.completion.chunk
+type emulatedChatResponse struct {
+	Id      string               `json:"id"`
+	Created any                  `json:"created"`
+	Model   string               `json:"model"`
+	Choices []emulatedChatChoice `json:"choices"`
+	Usage   *dto.Usage           `json:"usage,omitempty"`
+}
+
+func (w *completionsEmulationWriter) convert(chat *emulatedChatResponse) *dto.CompletionsResponse {
+	created, ok := chat.Created.(float64)
+	if !ok {
+		created = float64(common.GetTimestamp())
+	}
+	response := &dto.CompletionsResponse{
+		Id:      strings.Replace(chat.Id, "chatcmpl", "cmpl", 1),
+		Object:  "text_completion",
+		Created: int64(created),
+		Model:   chat.Model,
+		Choices: make([]dto.CompletionsChoice, 0, len(chat.Choices)),
+		Usage:   chat.Usage,
+	}
+	if w.emulation.relayMode == relayconstant.RelayModeEdits {
+		response.Object = "edit"
+		response.Id = ""
+		response.Model = ""
+	}
+	for _, choice := range chat.Choices {
+		message := choice.Message
+		if message == nil {
+			message = choice.Delta
+		}
+		var text string
+		if message != nil {
+			text, _ = message.Content.(string)
+		}
+		if w.emulation.echo != "" && !w.echoed[choice.Index] {
+			text = w.emulation.echo + text
+			w.echoed[choice.Index] = true
+		}
+		completionChoice := dto.CompletionsChoice{
+			Text:         text,
+			Index:        choice.Index,
+			FinishReason: choice.FinishReason,
+		}
+		if choice.Logprobs != nil && len(choice.Logprobs.Content) > 0 {
+			completionChoice.Logprobs = convertChatLogprobs(choice.Logprobs.Content)
+		}
+		response.Choices = append(response.Choices, completionChoice)
+	}
+	return response
+}
+
+// convertChatLogprobs 将 chat 格式的 logprobs.content 转换为 completions 格式
+func convertChatLogprobs(content []emulatedChatLogprob) map[string]any {
+	tokens := make([]string, 0, len(content))
+	tokenLogprobs := make([]float64, 0, len(content))
+	topLogprobs := make([]map[string]float64, 0, len(content))
+	textOffset := make([]int, 0, len(content))
+	offset := 0
+	for _, item := range content {
+		tokens = append(tokens, item.Token)
+		tokenLogprobs = append(tokenLogprobs, item.Logprob)
+		textOffset = append(textOffset, offset)
+		offset += len(item.Token)
+		top := make(map[string]float64, len(item.TopLogprobs))
+		for _, topItem := range item.TopLogprobs {
+			top[topItem.Token] = topItem.Logprob
+		}
+		topLogprobs = append(topLogprobs, top)
+	}
+	return map[string]any{
+		"tokens":         tokens,
+		"token_logprobs": tokenLogprobs,
+		"top_logprobs":   topLogprobs,
+		"text_offset":    textOffset,
+	}
+}
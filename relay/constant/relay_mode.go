@@ -46,6 +46,9 @@ const (
 	RelayModeJimengFetchByID
 	RelayModeJimengSubmit
 
+	RelayModeMusicFetchByID
+	RelayModeMusicSubmit
+
 	RelayModeRerank
 
 	RelayModeResponses
@@ -165,3 +168,13 @@ func Path2RelayJimeng(method, path string) int {
 	}
 	return relayMode
 }
+
+func Path2RelayMusic(method, path string) int {
+	relayMode := RelayModeUnknown
+	if method == http.MethodPost && strings.HasSuffix(path, "/audio/music") {
+		relayMode = RelayModeMusicSubmit
+	} else if method == http.MethodGet && strings.Contains(path, "/audio/music/") {
+		relayMode = RelayModeMusicFetchByID
+	}
+	return relayMode
+}
@@ -72,6 +72,17 @@ func EmbeddingHelper(c *gin.Context) (newAPIError *types.NewAPIError) {
 		}
 	}()
 
+	cacheKey := getResponseCacheKey(c, relayInfo, embeddingRequest)
+	if cacheKey != "" {
+		if entry, ok := service.GetResponseCache(cacheKey); ok {
+			relayInfo.ResponseCacheHit = true
+			replayResponseCache(c, relayInfo, entry)
+			postConsumeQuota(c, relayInfo, &entry.Usage, preConsumedQuota, userQuota, priceData, "")
+			return nil
+		}
+		relayInfo.ResponseCacheMiss = true
+	}
+
 	adaptor := GetAdaptor(relayInfo.ApiType)
 	if adaptor == nil {
 		return types.NewError(fmt.Errorf("invalid api type: %d", relayInfo.ApiType), types.ErrorCodeInvalidApiType)
@@ -105,12 +116,24 @@ func EmbeddingHelper(c *gin.Context) (newAPIError *types.NewAPIError) {
 		}
 	}
 
+	var captureWriter *responseCaptureWriter
+	if cacheKey != "" {
+		captureWriter = startResponseCapture(c)
+	}
 	usage, newAPIError := adaptor.DoResponse(c, httpResp, relayInfo)
+	if captureWriter != nil {
+		c.Writer = captureWriter.ResponseWriter
+	}
 	if newAPIError != nil {
 		// reset status code 重置状态码
 		service.ResetStatusCode(newAPIError, statusCodeMappingStr)
 		return newAPIError
 	}
+	if captureWriter != nil {
+		if entry := buildResponseCacheEntry(relayInfo, captureWriter, usage.(*dto.Usage)); entry != nil {
+			service.SetResponseCache(cacheKey, entry)
+		}
+	}
 	postConsumeQuota(c, relayInfo, usage.(*dto.Usage), preConsumedQuota, userQuota, priceData, "")
 	return nil
 }
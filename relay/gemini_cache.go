@@ -0,0 +1,395 @@
+package relay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	"one-api/relay/channel/gemini"
+	relaycommon "one-api/relay/common"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/setting/model_setting"
+	"one-api/setting/ratio_setting"
+	"one-api/types"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const geminiCachedContentsPath = "/v1beta/cachedContents"
+
+type geminiCachedContent struct {
+	Name          string `json:"name"`
+	Model         string `json:"model"`
+	ExpireTime    string `json:"expireTime"`
+	UsageMetadata struct {
+		TotalTokenCount int `json:"totalTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+// parseGeminiTime 解析 Gemini 返回的 RFC3339 时间，失败时返回 0
+func parseGeminiTime(value string) int64 {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return 0
+	}
+	return t.Unix()
+}
+
+// geminiNativeQuery 透传客户端的查询参数，去掉用于网关鉴权的 key
+func geminiNativeQuery(c *gin.Context) string {
+	query := c.Request.URL.Query()
+	query.Del("key")
+	if len(query) == 0 {
+		return ""
+	}
+	return "?" + query.Encode()
+}
+
+func newGeminiNativeApiRequest(info *relaycommon.RelayInfo, method string, path string) *gemini.NativeApiRequest {
+	return &gemini.NativeApiRequest{
+		BaseURL: info.BaseUrl,
+		Key:     info.ApiKey,
+		Proxy:   info.ChannelSetting.Proxy,
+		Method:  method,
+		Path:    path,
+		Header:  http.Header{},
+	}
+}
+
+// GeminiCacheCreateHelper 创建上下文缓存，按缓存 token 数计输入费用，并按过期时间预收存储费用
+func GeminiCacheCreateHelper(c *gin.Context) *types.NewAPIError {
+	relayInfo := relaycommon.GenRelayInfoGemini(c)
+	if relayInfo.ChannelType != constant.ChannelTypeGemini {
+		return types.NewErrorWithStatusCode(errors.New("cached contents are only supported by gemini channels"), types.ErrorCodeInvalidRequest, http.StatusBadRequest)
+	}
+	var request map[string]json.RawMessage
+	if err := common.UnmarshalBodyReusable(c, &request); err != nil {
+		return types.NewError(err, types.ErrorCodeInvalidRequest)
+	}
+	if relayInfo.OriginModelName == "" {
+		return types.NewError(errors.New("field model is required"), types.ErrorCodeInvalidRequest)
+	}
+	if !helper.ContainPriceOrRatio(relayInfo.OriginModelName) && !relayInfo.UserSetting.AcceptUnsetRatioModel {
+		return types.NewError(fmt.Errorf("模型 %s 倍率或价格未配置，请联系管理员设置或开始自用模式；Model %s ratio or price not set, please set or start self-use mode", relayInfo.OriginModelName, relayInfo.OriginModelName), types.ErrorCodeModelPriceError)
+	}
+	if err := helper.ModelMappedHelper(c, relayInfo, nil); err != nil {
+		return types.NewError(err, types.ErrorCodeChannelModelMappedError)
+	}
+	request["model"], _ = common.Marshal("models/" + relayInfo.UpstreamModelName)
+	jsonData, err := common.Marshal(request)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeJsonMarshalFailed)
+	}
+
+	// 按请求内容估算缓存 token 数预扣额度，创建成功后按实际 token 数与存储时长结算
+	var geminiRequest gemini.GeminiChatRequest
+	if err = common.Unmarshal(jsonData, &geminiRequest); err != nil {
+		return types.NewError(err, types.ErrorCodeInvalidRequest)
+	}
+	priceData, err := helper.ModelPriceHelper(c, relayInfo, getGeminiInputTokens(&geminiRequest, relayInfo), 0)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeModelPriceError)
+	}
+	preConsumedQuota, userQuota, newAPIError := preConsumeQuota(c, priceData.ShouldPreConsumedQuota, relayInfo)
+	if newAPIError != nil {
+		return newAPIError
+	}
+	defer func() {
+		if newAPIError != nil {
+			returnPreConsumedQuota(c, relayInfo, userQuota, preConsumedQuota)
+		}
+	}()
+
+	nativeRequest := newGeminiNativeApiRequest(relayInfo, http.MethodPost, geminiCachedContentsPath)
+	nativeRequest.Body = bytes.NewReader(jsonData)
+	nativeRequest.Header.Set("Content-Type", "application/json")
+	resp, err := nativeRequest.Do(c)
+	if err != nil {
+		newAPIError = types.NewOpenAIError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)
+		return newAPIError
+	}
+	if resp.StatusCode != http.StatusOK {
+		newAPIError = service.RelayErrorHandler(resp, false)
+		return newAPIError
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		newAPIError = types.NewError(err, types.ErrorCodeReadResponseBodyFailed)
+		return newAPIError
+	}
+	var cache geminiCachedContent
+	if err = common.Unmarshal(body, &cache); err != nil || cache.Name == "" {
+		newAPIError = types.NewError(errors.New("invalid cached content response: "+string(body)), types.ErrorCodeBadResponseBody)
+		return newAPIError
+	}
+
+	tokens := cache.UsageMetadata.TotalTokenCount
+	// 分组倍率已包含分时倍率，模型倍率与存储价格同样按创建时命中的分时倍率计算
+	groupRatio := priceData.GroupRatioInfo.GroupRatio
+	timeModelMultiplier := 1.0
+	if relayInfo.TimePricing != nil {
+		timeModelMultiplier = relayInfo.TimePricing.ModelMultiplier
+	}
+	modelRatio, _, _ := ratio_setting.GetModelRatio(relayInfo.OriginModelName)
+	modelRatio *= timeModelMultiplier
+	storagePrice := model_setting.GetGeminiCacheStoragePrice(relayInfo.OriginModelName) * timeModelMultiplier
+	storageQuotaPerHour := float64(tokens) / 1000000 * storagePrice * common.QuotaPerUnit * groupRatio
+	now := time.Now().Unix()
+	expiresAt := parseGeminiTime(cache.ExpireTime)
+	hours := 0.0
+	if expiresAt > now {
+		hours = float64(expiresAt-now) / 3600
+	}
+	quota := int(float64(tokens)*modelRatio*groupRatio + storageQuotaPerHour*hours)
+
+	cacheRecord := &model.UpstreamFile{
+		UserId:              relayInfo.UserId,
+		TokenId:             relayInfo.TokenId,
+		ChannelId:           relayInfo.ChannelId,
+		KeyIndex:            common.GetContextKeyInt(c, constant.ContextKeyChannelMultiKeyIndex),
+		Platform:            model.UpstreamFilePlatformGeminiCache,
+		FileId:              cache.Name,
+		Size:                int64(tokens),
+		Data:                string(body),
+		CreatedAt:           now,
+		ExpiresAt:           expiresAt,
+		ModelName:           relayInfo.OriginModelName,
+		StorageQuotaPerHour: storageQuotaPerHour,
+	}
+	if err = cacheRecord.Insert(); err != nil {
+		newAPIError = types.NewError(err, types.ErrorCodeUpdateDataError)
+		return newAPIError
+	}
+
+	if quota != preConsumedQuota {
+		if err = service.PostConsumeQuota(relayInfo, quota-preConsumedQuota, 0, true); err != nil {
+			common.SysError("error consuming token remain quota: " + err.Error())
+		}
+	}
+	if quota != 0 {
+		logContent := fmt.Sprintf("Gemini 上下文缓存 %s，%d tokens，模型倍率 %.2f，存储 %.2f 小时（每百万 token 每小时 $%.2f），分组倍率 %.2f",
+			cache.Name, tokens, modelRatio, hours, storagePrice, groupRatio)
+		other := map[string]interface{}{
+			"cached_content": cache.Name,
+			"model_ratio":    modelRatio,
+			"group_ratio":    groupRatio,
+			"storage_price":  storagePrice,
+			"storage_hours":  hours,
+		}
+		if relayInfo.TimePricing != nil {
+			other["time_pricing"] = relayInfo.TimePricing
+		}
+		model.RecordConsumeLog(c, relayInfo.UserId, model.RecordConsumeLogParams{
+			ChannelId:    relayInfo.ChannelId,
+			PromptTokens: tokens,
+			ModelName:    relayInfo.OriginModelName,
+			TokenName:    c.GetString("token_name"),
+			Quota:        quota,
+			Content:      logContent,
+			TokenId:      relayInfo.TokenId,
+			UserQuota:    userQuota,
+			Group:        relayInfo.UsingGroup,
+			Other:        other,
+		})
+		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
+		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
+	}
+	c.Data(http.StatusOK, "application/json", body)
+	return nil
+}
+
+// geminiCacheStorageQuota 计算缓存过期时间调整为 expiresAt 时的存储费用，已过期的时长不退还
+func geminiCacheStorageQuota(cache *model.UpstreamFile, expiresAt int64) (int, float64) {
+	now := time.Now().Unix()
+	from := max(cache.ExpiresAt, now)
+	to := max(expiresAt, now)
+	hours := float64(to-from) / 3600
+	return int(cache.StorageQuotaPerHour * hours), hours
+}
+
+// geminiCacheRequestedExpiry 解析更新请求中的 ttl 或 expireTime，未指定时返回 0
+func geminiCacheRequestedExpiry(body []byte) int64 {
+	var update struct {
+		Ttl        string `json:"ttl"`
+		ExpireTime string `json:"expireTime"`
+	}
+	if common.Unmarshal(body, &update) != nil {
+		return 0
+	}
+	if update.ExpireTime != "" {
+		return parseGeminiTime(update.ExpireTime)
+	}
+	if ttl, err := time.ParseDuration(update.Ttl); err == nil {
+		return time.Now().Add(ttl).Unix()
+	}
+	return 0
+}
+
+// checkGeminiCacheStorageQuota 延长缓存前校验创建缓存的用户与令牌额度是否足够支付存储费用
+func checkGeminiCacheStorageQuota(cache *model.UpstreamFile, quota int) *types.NewAPIError {
+	userQuota, err := model.GetUserQuota(cache.UserId, false)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeQueryDataError)
+	}
+	if userQuota < quota {
+		return types.NewErrorWithStatusCode(fmt.Errorf("user quota is not enough, need %s", common.LogQuota(quota)), types.ErrorCodeInsufficientUserQuota, http.StatusForbidden)
+	}
+	if cache.TokenId > 0 {
+		token, err := model.GetTokenById(cache.TokenId)
+		if err == nil && !token.UnlimitedQuota && token.RemainQuota < quota {
+			return types.NewErrorWithStatusCode(fmt.Errorf("token quota is not enough, need %s", common.LogQuota(quota)), types.ErrorCodePreConsumeTokenQuotaFailed, http.StatusForbidden)
+		}
+	}
+	return nil
+}
+
+// settleGeminiCacheStorage 缓存过期时间变化时按存储时长多退少补，同时调整用户与创建缓存的令牌额度
+func settleGeminiCacheStorage(ctx context.Context, cache *model.UpstreamFile, expiresAt int64) {
+	quota, hours := geminiCacheStorageQuota(cache, expiresAt)
+	if quota == 0 {
+		return
+	}
+	logContent := fmt.Sprintf("Gemini 上下文缓存 %s 存储时长调整 %.2f 小时，结算 %s", cache.FileId, hours, common.LogQuota(quota))
+	err := service.AdjustBackgroundQuota(ctx, cache.UserId, cache.TokenId, quota, model.RecordConsumeLogParams{
+		ChannelId: cache.ChannelId,
+		ModelName: cache.ModelName,
+		Content:   logContent,
+		Other: map[string]interface{}{
+			"cached_content": cache.FileId,
+			"storage_hours":  hours,
+		},
+	})
+	if err != nil {
+		common.LogError(ctx, "fail to settle gemini cache storage quota: "+err.Error())
+	}
+}
+
+// getUserGeminiResource 获取当前用户的上下文缓存或文件及其所属渠道
+func getUserGeminiResource(c *gin.Context, platform string, name string) (*model.UpstreamFile, *model.Channel, *types.NewAPIError) {
+	file, err := model.GetUserUpstreamFile(c.GetInt("id"), platform, name)
+	if err != nil {
+		return nil, nil, types.NewErrorWithStatusCode(errors.New(name+" not found"), types.ErrorCodeInvalidRequest, http.StatusNotFound)
+	}
+	channel, err := model.GetChannelById(file.ChannelId, true)
+	if err != nil {
+		return nil, nil, types.NewError(err, types.ErrorCodeGetChannelFailed)
+	}
+	return file, channel, nil
+}
+
+// GeminiCachePassthroughHelper 校验缓存归属后透传查询、更新与删除请求，更新过期时间或删除时结算存储费用
+func GeminiCachePassthroughHelper(c *gin.Context) *types.NewAPIError {
+	cache, channel, apiErr := getUserGeminiResource(c, model.UpstreamFilePlatformGeminiCache, "cachedContents/"+c.Param("id"))
+	if apiErr != nil {
+		return apiErr
+	}
+	nativeRequest := gemini.NewNativeApiRequest(channel, cache.KeyIndex, c.Request.Method, "/v1beta/"+cache.FileId+geminiNativeQuery(c))
+	if c.Request.Method == http.MethodPatch {
+		requestBody, err := common.GetRequestBody(c)
+		if err != nil {
+			return types.NewError(err, types.ErrorCodeReadRequestBodyFailed)
+		}
+		if expiresAt := geminiCacheRequestedExpiry(requestBody); expiresAt != 0 {
+			if quota, _ := geminiCacheStorageQuota(cache, expiresAt); quota > 0 {
+				if apiErr = checkGeminiCacheStorageQuota(cache, quota); apiErr != nil {
+					return apiErr
+				}
+			}
+		}
+		nativeRequest.Body = bytes.NewReader(requestBody)
+		nativeRequest.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := nativeRequest.Do(c)
+	if err != nil {
+		return types.NewOpenAIError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return service.RelayErrorHandler(resp, false)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeReadResponseBodyFailed)
+	}
+	switch c.Request.Method {
+	case http.MethodPatch:
+		var updated geminiCachedContent
+		if common.Unmarshal(body, &updated) == nil {
+			if expiresAt := parseGeminiTime(updated.ExpireTime); expiresAt != 0 {
+				settleGeminiCacheStorage(c, cache, expiresAt)
+				cache.ExpiresAt = expiresAt
+			}
+			cache.Data = string(body)
+			if err = cache.Update(); err != nil {
+				common.LogError(c, "fail to update gemini cache record: "+err.Error())
+			}
+		}
+	case http.MethodDelete:
+		settleGeminiCacheStorage(c, cache, time.Now().Unix())
+		if err = cache.Delete(); err != nil {
+			common.LogError(c, "fail to delete gemini cache record: "+err.Error())
+		}
+	}
+	c.Data(http.StatusOK, "application/json", body)
+	return nil
+}
+
+// GeminiResourceListHelper 列出当前用户未过期的上下文缓存或文件，数据来自本地记录
+func GeminiResourceListHelper(c *gin.Context, platform string, field string) *types.NewAPIError {
+	pageSize, _ := strconv.Atoi(c.Query("pageSize"))
+	if pageSize <= 0 || pageSize > 1000 {
+		pageSize = 100
+	}
+	files, err := model.GetUserUpstreamFiles(c.GetInt("id"), platform, pageSize)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeQueryDataError)
+	}
+	items := make([]json.RawMessage, 0, len(files))
+	for _, file := range files {
+		items = append(items, json.RawMessage(file.Data))
+	}
+	c.JSON(http.StatusOK, gin.H{field: items})
+	return nil
+}
+
+// CleanupTokenGeminiResources 删除令牌创建的上下文缓存与文件，未使用的缓存存储时长退还给用户
+func CleanupTokenGeminiResources(ctx context.Context, userId int, tokenIds []int) {
+	files, err := model.GetTokensUpstreamFiles(userId, tokenIds, []string{model.UpstreamFilePlatformGeminiCache, model.UpstreamFilePlatformGeminiFile})
+	if err != nil {
+		common.LogError(ctx, "fail to get token gemini resources: "+err.Error())
+		return
+	}
+	for _, file := range files {
+		channel, err := model.CacheGetChannel(file.ChannelId)
+		if err != nil {
+			common.LogError(ctx, fmt.Sprintf("fail to get channel #%d for %s: %s", file.ChannelId, file.FileId, err.Error()))
+			continue
+		}
+		resp, err := gemini.NewNativeApiRequest(channel, file.KeyIndex, http.MethodDelete, "/v1beta/"+file.FileId).Do(ctx)
+		if err != nil {
+			common.LogError(ctx, fmt.Sprintf("fail to delete %s: %s", file.FileId, err.Error()))
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+			common.LogError(ctx, fmt.Sprintf("fail to delete %s, status code %d", file.FileId, resp.StatusCode))
+			continue
+		}
+		if file.Platform == model.UpstreamFilePlatformGeminiCache {
+			settleGeminiCacheStorage(ctx, file, time.Now().Unix())
+		}
+		if err = file.Delete(); err != nil {
+			common.LogError(ctx, "fail to delete gemini resource record: "+err.Error())
+		}
+	}
+}
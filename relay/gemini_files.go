@@ -0,0 +1,162 @@
+package relay
+
+import (
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/model"
+	"one-api/relay/channel/gemini"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+	"one-api/setting"
+	"one-api/types"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	geminiUploadFilesPath = "/upload/v1beta/files"
+	geminiUploadUrlHeader = "X-Goog-Upload-URL"
+)
+
+type geminiFile struct {
+	Name           string `json:"name"`
+	DisplayName    string `json:"displayName"`
+	MimeType       string `json:"mimeType"`
+	SizeBytes      string `json:"sizeBytes"`
+	ExpirationTime string `json:"expirationTime"`
+}
+
+func signGeminiUpload(uploadId string, channelId int, keyIndex int, userId int) string {
+	return common.GenerateHMAC(fmt.Sprintf("gemini_upload:%s:%d:%d:%d", uploadId, channelId, keyIndex, userId))
+}
+
+// GeminiFileUploadHelper 透传 Files API 上传请求。可续传上传返回的上传地址改写为网关地址，
+// 其中带有渠道、密钥位置与签名，后续分片直接使用同一渠道与密钥；上传完成后记录文件归属
+func GeminiFileUploadHelper(c *gin.Context) *types.NewAPIError {
+	userId := c.GetInt("id")
+	var nativeRequest *gemini.NativeApiRequest
+	var channelId int
+	var keyIndex int
+	var tokenId int
+	if uploadId := c.Query("upload_id"); uploadId == "" {
+		relayInfo := relaycommon.GenRelayInfoGemini(c)
+		if relayInfo.ChannelType != constant.ChannelTypeGemini {
+			return types.NewErrorWithStatusCode(errors.New("files api is only supported by gemini channels"), types.ErrorCodeInvalidRequest, http.StatusBadRequest)
+		}
+		channelId = relayInfo.ChannelId
+		keyIndex = common.GetContextKeyInt(c, constant.ContextKeyChannelMultiKeyIndex)
+		tokenId = relayInfo.TokenId
+		nativeRequest = newGeminiNativeApiRequest(relayInfo, http.MethodPost, geminiUploadFilesPath+geminiNativeQuery(c))
+	} else {
+		channelId, _ = strconv.Atoi(c.Query("channel_id"))
+		keyIndex, _ = strconv.Atoi(c.Query("key_index"))
+		if !hmac.Equal([]byte(c.Query("signature")), []byte(signGeminiUpload(uploadId, channelId, keyIndex, userId))) {
+			return types.NewErrorWithStatusCode(errors.New("invalid upload signature"), types.ErrorCodeAccessDenied, http.StatusForbidden)
+		}
+		channel, err := model.GetChannelById(channelId, true)
+		if err != nil {
+			return types.NewError(err, types.ErrorCodeGetChannelFailed)
+		}
+		tokenId = common.GetContextKeyInt(c, constant.ContextKeyTokenId)
+		query := url.Values{}
+		query.Set("upload_id", uploadId)
+		query.Set("upload_protocol", "resumable")
+		nativeRequest = gemini.NewNativeApiRequest(channel, keyIndex, c.Request.Method, geminiUploadFilesPath+"?"+query.Encode())
+	}
+	for key, values := range c.Request.Header {
+		if strings.HasPrefix(strings.ToLower(key), "x-goog-upload-") {
+			nativeRequest.Header[key] = values
+		}
+	}
+	if contentType := c.Request.Header.Get("Content-Type"); contentType != "" {
+		nativeRequest.Header.Set("Content-Type", contentType)
+	}
+	nativeRequest.Body = c.Request.Body
+	nativeRequest.ContentLength = c.Request.ContentLength
+
+	resp, err := nativeRequest.Do(c)
+	if err != nil {
+		return types.NewOpenAIError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return service.RelayErrorHandler(resp, false)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeReadResponseBodyFailed)
+	}
+	for key, values := range resp.Header {
+		if strings.HasPrefix(strings.ToLower(key), "x-goog-upload-") {
+			c.Writer.Header()[key] = values
+		}
+	}
+	if uploadUrl := resp.Header.Get(geminiUploadUrlHeader); uploadUrl != "" {
+		parsed, err := url.Parse(uploadUrl)
+		if err != nil {
+			return types.NewError(err, types.ErrorCodeBadResponse)
+		}
+		uploadId := parsed.Query().Get("upload_id")
+		c.Header(geminiUploadUrlHeader, fmt.Sprintf("%s%s?upload_id=%s&channel_id=%d&key_index=%d&signature=%s",
+			setting.ServerAddress, geminiUploadFilesPath, url.QueryEscape(uploadId), channelId, keyIndex, signGeminiUpload(uploadId, channelId, keyIndex, userId)))
+	}
+
+	var uploaded struct {
+		File *geminiFile `json:"file"`
+	}
+	if len(body) > 0 && common.Unmarshal(body, &uploaded) == nil && uploaded.File != nil && uploaded.File.Name != "" {
+		fileData, _ := common.Marshal(uploaded.File)
+		size, _ := strconv.ParseInt(uploaded.File.SizeBytes, 10, 64)
+		file := &model.UpstreamFile{
+			UserId:    userId,
+			TokenId:   tokenId,
+			ChannelId: channelId,
+			KeyIndex:  keyIndex,
+			Platform:  model.UpstreamFilePlatformGeminiFile,
+			FileId:    uploaded.File.Name,
+			Filename:  uploaded.File.DisplayName,
+			MimeType:  uploaded.File.MimeType,
+			Size:      size,
+			Data:      string(fileData),
+			CreatedAt: time.Now().Unix(),
+			ExpiresAt: parseGeminiTime(uploaded.File.ExpirationTime),
+		}
+		if err = file.Insert(); err != nil {
+			return types.NewError(err, types.ErrorCodeUpdateDataError)
+		}
+	}
+	c.Data(http.StatusOK, resp.Header.Get("Content-Type"), body)
+	return nil
+}
+
+// GeminiFilePassthroughHelper 校验文件归属后透传查询与删除请求
+func GeminiFilePassthroughHelper(c *gin.Context) *types.NewAPIError {
+	file, channel, apiErr := getUserGeminiResource(c, model.UpstreamFilePlatformGeminiFile, "files/"+c.Param("id"))
+	if apiErr != nil {
+		return apiErr
+	}
+	resp, err := gemini.NewNativeApiRequest(channel, file.KeyIndex, c.Request.Method, "/v1beta/"+file.FileId).Do(c)
+	if err != nil {
+		return types.NewOpenAIError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return service.RelayErrorHandler(resp, false)
+	}
+	defer resp.Body.Close()
+	if c.Request.Method == http.MethodDelete {
+		if err = file.Delete(); err != nil {
+			common.LogError(c, "fail to delete gemini file record: "+err.Error())
+		}
+	}
+	c.DataFromReader(http.StatusOK, resp.ContentLength, resp.Header.Get("Content-Type"), resp.Body, nil)
+	return nil
+}
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"one-api/common"
 	relaycommon "one-api/relay/common"
+	"one-api/setting/operation_setting"
 	"one-api/setting/ratio_setting"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -59,13 +61,28 @@ func HandleGroupRatio(ctx *gin.Context, relayInfo *relaycommon.RelayInfo) GroupR
 		groupRatioInfo.GroupRatio = ratio_setting.GetGroupRatio(relayInfo.UsingGroup)
 	}
 
+	// 分时计价，模型系数由调用方应用到模型倍率或价格上
+	relayInfo.TimePricing = operation_setting.GetTimePricingSetting().Match(relayInfo.UsingGroup, relayInfo.OriginModelName, time.Now())
+	if relayInfo.TimePricing != nil {
+		groupRatioInfo.GroupRatio *= relayInfo.TimePricing.GroupMultiplier
+	}
+
 	return groupRatioInfo
 }
 
+func getTimeModelMultiplier(info *relaycommon.RelayInfo) float64 {
+	if info.TimePricing == nil {
+		return 1
+	}
+	return info.TimePricing.ModelMultiplier
+}
+
 func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens int, maxTokens int) (PriceData, error) {
 	modelPrice, usePrice := ratio_setting.GetModelPrice(info.OriginModelName, false)
 
 	groupRatioInfo := HandleGroupRatio(c, info)
+	timeModelMultiplier := getTimeModelMultiplier(info)
+	modelPrice *= timeModelMultiplier
 
 	var preConsumedQuota int
 	var modelRatio float64
@@ -90,6 +107,7 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 				return PriceData{}, fmt.Errorf("模型 %s 倍率或价格未配置，请联系管理员设置或开始自用模式；Model %s ratio or price not set, please set or start self-use mode", matchName, matchName)
 			}
 		}
+		modelRatio *= timeModelMultiplier
 		completionRatio = ratio_setting.GetCompletionRatio(info.OriginModelName)
 		cacheRatio, _ = ratio_setting.GetCacheRatio(info.OriginModelName)
 		cacheCreationRatio, _ = ratio_setting.GetCreateCacheRatio(info.OriginModelName)
@@ -123,6 +141,7 @@ type PerCallPriceData struct {
 	ModelPrice     float64
 	Quota          int
 	GroupRatioInfo GroupRatioInfo
+	TimePricing    *operation_setting.TimePricingMatch
 }
 
 // ModelPriceHelperPerCall 按次计费的 PriceHelper (MJ、Task)
@@ -139,11 +158,13 @@ func ModelPriceHelperPerCall(c *gin.Context, info *relaycommon.RelayInfo) PerCal
 			modelPrice = defaultPrice
 		}
 	}
+	modelPrice *= getTimeModelMultiplier(info)
 	quota := int(modelPrice * common.QuotaPerUnit * groupRatioInfo.GroupRatio)
 	priceData := PerCallPriceData{
 		ModelPrice:     modelPrice,
 		Quota:          quota,
 		GroupRatioInfo: groupRatioInfo,
+		TimePricing:    info.TimePricing,
 	}
 	return priceData
 }
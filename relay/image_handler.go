@@ -11,11 +11,13 @@ import (
 	"one-api/constant"
 	"one-api/dto"
 	"one-api/model"
+	"one-api/relay/channel"
 	relaycommon "one-api/relay/common"
 	relayconstant "one-api/relay/constant"
 	"one-api/relay/helper"
 	"one-api/service"
 	"one-api/setting"
+	"one-api/setting/ratio_setting"
 	"one-api/types"
 	"strings"
 
@@ -122,12 +124,18 @@ func ImageHelper(c *gin.Context) (newAPIError *types.NewAPIError) {
 		return types.NewError(err, types.ErrorCodeChannelModelMappedError)
 	}
 
+	normalization, err := normalizeImageRequest(relayInfo, imageRequest)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeInvalidRequest)
+	}
+
 	priceData, err := helper.ModelPriceHelper(c, relayInfo, len(imageRequest.Prompt), 0)
 	if err != nil {
 		return types.NewError(err, types.ErrorCodeModelPriceError)
 	}
 	var preConsumedQuota int
 	var quota int
+	var imagePrice float64
 	var userQuota int
 	if !priceData.UsePrice {
 		// modelRatio 16 = modelPrice $0.04
@@ -144,31 +152,15 @@ func ImageHelper(c *gin.Context) (newAPIError *types.NewAPIError) {
 		}()
 
 	} else {
-		sizeRatio := 1.0
-		qualityRatio := 1.0
-
-		if strings.HasPrefix(imageRequest.Model, "dall-e") {
-			// Size
-			if imageRequest.Size == "256x256" {
-				sizeRatio = 0.4
-			} else if imageRequest.Size == "512x512" {
-				sizeRatio = 0.45
-			} else if imageRequest.Size == "1024x1024" {
-				sizeRatio = 1
-			} else if imageRequest.Size == "1024x1792" || imageRequest.Size == "1792x1024" {
-				sizeRatio = 2
-			}
-
-			if imageRequest.Model == "dall-e-3" && imageRequest.Quality == "hd" {
-				qualityRatio = 2.0
-				if imageRequest.Size == "1024x1792" || imageRequest.Size == "1792x1024" {
-					qualityRatio = 1.5
-				}
-			}
+		// 按尺寸与品质计算单张图片倍率
+		imageRatio := ratio_setting.GetImageSizeRatio(relayInfo.OriginModelName, imageRequest.Size, imageRequest.Quality)
+		if relayInfo.UpstreamModelName != relayInfo.OriginModelName && imageRatio == 1 {
+			imageRatio = ratio_setting.GetImageSizeRatio(relayInfo.UpstreamModelName, imageRequest.Size, imageRequest.Quality)
 		}
 
 		// reset model price
-		priceData.ModelPrice *= sizeRatio * qualityRatio * float64(imageRequest.N)
+		imagePrice = priceData.ModelPrice * imageRatio
+		priceData.ModelPrice = imagePrice * float64(imageRequest.N)
 		quota = int(priceData.ModelPrice * priceData.GroupRatioInfo.GroupRatio * common.QuotaPerUnit)
 		userQuota, err = model.GetUserQuota(relayInfo.UserId, false)
 		if err != nil {
@@ -185,18 +177,85 @@ func ImageHelper(c *gin.Context) (newAPIError *types.NewAPIError) {
 	}
 	adaptor.Init(relayInfo)
 
+	statusCodeMappingStr := c.GetString("status_code_mapping")
+
+	// 上游不支持的 n 通过多次请求模拟，响应合并后统一转换为客户端期望的格式
+	usage := &dto.Usage{}
+	writers := make([]*imageResponseWriter, 0, len(normalization.calls))
+	generated := 0
+	var callErr *types.NewAPIError
+	for _, n := range normalization.calls {
+		callRequest := *imageRequest
+		callRequest.N = n
+		callUsage, writer, newAPIError := doImageRequest(c, adaptor, relayInfo, callRequest)
+		if newAPIError != nil {
+			// reset status code 重置状态码
+			service.ResetStatusCode(newAPIError, statusCodeMappingStr)
+			if generated == 0 {
+				return newAPIError
+			}
+			// 已生成的图片上游已计费，返回部分结果并只按已生成的数量计费
+			common.LogError(c, fmt.Sprintf("image request failed after %d images generated: %s", generated, newAPIError.Error()))
+			callErr = newAPIError
+			break
+		}
+		generated += n
+		if callUsage != nil {
+			usage.PromptTokens += callUsage.PromptTokens
+			usage.CompletionTokens += callUsage.CompletionTokens
+			usage.TotalTokens += callUsage.TotalTokens
+			usage.PromptTokensDetails.TextTokens += callUsage.PromptTokensDetails.TextTokens
+			usage.PromptTokensDetails.ImageTokens += callUsage.PromptTokensDetails.ImageTokens
+		}
+		if writer != nil {
+			writers = append(writers, writer)
+		}
+	}
+	if len(writers) > 0 {
+		if err := writeNormalizedImageResponse(c, normalization, writers); err != nil {
+			return types.NewError(err, types.ErrorCodeBadResponseBody)
+		}
+	}
+
+	if callErr != nil && priceData.UsePrice {
+		priceData.ModelPrice = imagePrice * float64(generated)
+	}
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = generated
+	}
+	if usage.PromptTokens == 0 {
+		usage.PromptTokens = generated
+	}
+	quality := "standard"
+	if imageRequest.Quality == "hd" {
+		quality = "hd"
+	}
+
+	logContent := fmt.Sprintf("大小 %s, 品质 %s", imageRequest.Size, quality)
+	if len(normalization.calls) > 1 {
+		logContent += fmt.Sprintf(", 拆分为 %d 次请求", len(normalization.calls))
+	}
+	if callErr != nil {
+		logContent += fmt.Sprintf(", 部分请求失败, 实际生成 %d/%d 张", generated, imageRequest.N)
+	}
+	postConsumeQuota(c, relayInfo, usage, preConsumedQuota, userQuota, priceData, logContent)
+	return nil
+}
+
+// doImageRequest 完成一次上游图片请求，非流式响应写入返回的 writer 而不是客户端连接
+func doImageRequest(c *gin.Context, adaptor channel.Adaptor, relayInfo *relaycommon.RelayInfo, imageRequest dto.ImageRequest) (*dto.Usage, *imageResponseWriter, *types.NewAPIError) {
 	var requestBody io.Reader
 
-	convertedRequest, err := adaptor.ConvertImageRequest(c, relayInfo, *imageRequest)
+	convertedRequest, err := adaptor.ConvertImageRequest(c, relayInfo, imageRequest)
 	if err != nil {
-		return types.NewError(err, types.ErrorCodeConvertRequestFailed)
+		return nil, nil, types.NewError(err, types.ErrorCodeConvertRequestFailed)
 	}
 	if relayInfo.RelayMode == relayconstant.RelayModeImagesEdits {
 		requestBody = convertedRequest.(io.Reader)
 	} else {
 		jsonData, err := json.Marshal(convertedRequest)
 		if err != nil {
-			return types.NewError(err, types.ErrorCodeConvertRequestFailed)
+			return nil, nil, types.NewError(err, types.ErrorCodeConvertRequestFailed)
 		}
 		requestBody = bytes.NewBuffer(jsonData)
 	}
@@ -205,43 +264,30 @@ func ImageHelper(c *gin.Context) (newAPIError *types.NewAPIError) {
 		println(fmt.Sprintf("image request body: %s", requestBody))
 	}
 
-	statusCodeMappingStr := c.GetString("status_code_mapping")
-
 	resp, err := adaptor.DoRequest(c, relayInfo, requestBody)
 	if err != nil {
-		return types.NewOpenAIError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)
+		return nil, nil, types.NewOpenAIError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)
 	}
 	var httpResp *http.Response
 	if resp != nil {
 		httpResp = resp.(*http.Response)
 		relayInfo.IsStream = relayInfo.IsStream || strings.HasPrefix(httpResp.Header.Get("Content-Type"), "text/event-stream")
 		if httpResp.StatusCode != http.StatusOK {
-			newAPIError = service.RelayErrorHandler(httpResp, false)
-			// reset status code 重置状态码
-			service.ResetStatusCode(newAPIError, statusCodeMappingStr)
-			return newAPIError
+			return nil, nil, service.RelayErrorHandler(httpResp, false)
 		}
 	}
 
-	usage, newAPIError := adaptor.DoResponse(c, httpResp, relayInfo)
-	if newAPIError != nil {
-		// reset status code 重置状态码
-		service.ResetStatusCode(newAPIError, statusCodeMappingStr)
-		return newAPIError
-	}
-
-	if usage.(*dto.Usage).TotalTokens == 0 {
-		usage.(*dto.Usage).TotalTokens = imageRequest.N
+	var writer *imageResponseWriter
+	if !relayInfo.IsStream {
+		writer = startImageResponseCapture(c)
 	}
-	if usage.(*dto.Usage).PromptTokens == 0 {
-		usage.(*dto.Usage).PromptTokens = imageRequest.N
+	usage, newAPIError := adaptor.DoResponse(c, httpResp, relayInfo)
+	if writer != nil {
+		c.Writer = writer.ResponseWriter
 	}
-	quality := "standard"
-	if imageRequest.Quality == "hd" {
-		quality = "hd"
+	if newAPIError != nil {
+		return nil, nil, newAPIError
 	}
-
-	logContent := fmt.Sprintf("大小 %s, 品质 %s", imageRequest.Size, quality)
-	postConsumeQuota(c, relayInfo, usage.(*dto.Usage), preConsumedQuota, userQuota, priceData, logContent)
-	return nil
+	callUsage, _ := usage.(*dto.Usage)
+	return callUsage, writer, nil
 }
@@ -0,0 +1,328 @@
+package relay
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	validImageQualities       = []string{"standard", "hd", "low", "medium", "high", "auto"}
+	validImageStyles          = []string{"vivid", "natural"}
+	validImageResponseFormats = []string{"url", "b64_json"}
+)
+
+// imageCapability 描述上游图片接口支持的参数范围
+type imageCapability struct {
+	sizes           []string // 支持的尺寸，第一个为默认尺寸，为空表示不限制
+	minSide         int      // 未列出尺寸时的边长范围，0 表示不限制
+	maxSide         int
+	maxN            int      // 单次请求最多生成的图片数量
+	qualities       []string // 为空表示上游不支持 quality 参数
+	styles          []string // 为空表示上游不支持 style 参数
+	responseFormats []string // 上游可返回的格式，为空表示透传客户端的 response_format
+}
+
+// getImageCapability 返回 nil 表示不调整请求参数，只统一响应格式
+func getImageCapability(info *relaycommon.RelayInfo) *imageCapability {
+	switch info.ApiType {
+	case constant.APITypeOpenAI:
+		capability := &imageCapability{
+			maxN:      10,
+			qualities: validImageQualities,
+			styles:    validImageStyles,
+		}
+		if info.UpstreamModelName == "dall-e-3" {
+			capability.maxN = 1
+		}
+		return capability
+	case constant.APITypeGemini:
+		return &imageCapability{
+			sizes:           []string{"1024x1024", "1024x1792", "1792x1024", "896x1280", "1280x896"},
+			maxN:            4,
+			responseFormats: []string{"b64_json"},
+		}
+	case constant.APITypeJimeng:
+		return &imageCapability{
+			minSide:         256,
+			maxSide:         768,
+			maxN:            1,
+			responseFormats: []string{"url", "b64_json"},
+		}
+	case constant.APITypeAli:
+		return &imageCapability{
+			sizes:           []string{"1024x1024", "720x1280", "1280x720"},
+			maxN:            4,
+			responseFormats: []string{"url"},
+		}
+	case constant.APITypeZhipuV4:
+		return &imageCapability{
+			sizes:           []string{"1024x1024", "768x1344", "864x1152", "1344x768", "1152x864", "1440x720", "720x1440"},
+			maxN:            1,
+			qualities:       []string{"standard", "hd"},
+			responseFormats: []string{"url"},
+		}
+	}
+	return nil
+}
+
+// imageNormalization 记录图片请求的归一化结果
+type imageNormalization struct {
+	responseFormat string // 客户端期望的返回格式
+	calls          []int  // 每次上游请求生成的图片数量，多于一次表示通过多次请求模拟 n
+}
+
+// normalizeImageRequest 校验图片请求参数，并按上游能力原地调整尺寸、品质、风格与返回格式
+func normalizeImageRequest(info *relaycommon.RelayInfo, request *dto.ImageRequest) (*imageNormalization, error) {
+	if request.Quality != "" && !common.StringsContains(validImageQualities, request.Quality) {
+		return nil, fmt.Errorf("quality must be one of %s", strings.Join(validImageQualities, ", "))
+	}
+	if request.Style != "" && !common.StringsContains(validImageStyles, request.Style) {
+		return nil, fmt.Errorf("style must be one of %s", strings.Join(validImageStyles, ", "))
+	}
+	if request.ResponseFormat != "" && !common.StringsContains(validImageResponseFormats, request.ResponseFormat) {
+		return nil, fmt.Errorf("response_format must be one of %s", strings.Join(validImageResponseFormats, ", "))
+	}
+	normalization := &imageNormalization{
+		responseFormat: request.ResponseFormat,
+		calls:          []int{request.N},
+	}
+	if normalization.responseFormat == "" {
+		// gpt-image-1 只返回 base64，其余模型默认返回 url
+		normalization.responseFormat = "url"
+		if strings.HasPrefix(info.UpstreamModelName, "gpt-image-1") {
+			normalization.responseFormat = "b64_json"
+		}
+	}
+	capability := getImageCapability(info)
+	// 编辑请求以 multipart 原样转发，只统一响应格式
+	if capability == nil || info.RelayMode == relayconstant.RelayModeImagesEdits {
+		return normalization, nil
+	}
+
+	size, err := mapImageSize(request.Size, capability)
+	if err != nil {
+		return nil, err
+	}
+	request.Size = size
+	if !common.StringsContains(capability.qualities, request.Quality) {
+		// 高品质请求映射为上游的 hd
+		if request.Quality == "high" && common.StringsContains(capability.qualities, "hd") {
+			request.Quality = "hd"
+		} else {
+			request.Quality = ""
+		}
+	}
+	if !common.StringsContains(capability.styles, request.Style) {
+		request.Style = ""
+	}
+	if len(capability.responseFormats) > 0 {
+		request.ResponseFormat = capability.responseFormats[0]
+		if common.StringsContains(capability.responseFormats, normalization.responseFormat) {
+			request.ResponseFormat = normalization.responseFormat
+		}
+	}
+
+	if capability.maxN > 0 && request.N > capability.maxN {
+		normalization.calls = normalization.calls[:0]
+		for remain := request.N; remain > 0; remain -= capability.maxN {
+			normalization.calls = append(normalization.calls, min(remain, capability.maxN))
+		}
+	}
+	return normalization, nil
+}
+
+func parseImageSize(size string) (int, int, bool) {
+	widthStr, heightStr, ok := strings.Cut(size, "x")
+	if !ok {
+		return 0, 0, false
+	}
+	width, err := strconv.Atoi(widthStr)
+	if err != nil || width <= 0 {
+		return 0, 0, false
+	}
+	height, err := strconv.Atoi(heightStr)
+	if err != nil || height <= 0 {
+		return 0, 0, false
+	}
+	return width, height, true
+}
+
+// mapImageSize 将尺寸映射为上游支持的尺寸：列出尺寸时取宽高比最接近的一项，否则按边长范围等比缩放
+func mapImageSize(size string, capability *imageCapability) (string, error) {
+	if size == "" || size == "auto" {
+		if len(capability.sizes) > 0 {
+			return capability.sizes[0], nil
+		}
+		return size, nil
+	}
+	width, height, ok := parseImageSize(size)
+	if !ok {
+		return "", errors.New("size must be in the format of {width}x{height}")
+	}
+	if len(capability.sizes) > 0 {
+		if common.StringsContains(capability.sizes, size) {
+			return size, nil
+		}
+		best := capability.sizes[0]
+		bestScore := math.MaxFloat64
+		for _, candidate := range capability.sizes {
+			candidateWidth, candidateHeight, _ := parseImageSize(candidate)
+			ratioDiff := math.Abs(math.Log(float64(width)/float64(height)) - math.Log(float64(candidateWidth)/float64(candidateHeight)))
+			areaDiff := math.Abs(math.Log(float64(width*height) / float64(candidateWidth*candidateHeight)))
+			// 宽高比优先，面积其次
+			score := ratioDiff*10 + areaDiff
+			if score < bestScore {
+				best = candidate
+				bestScore = score
+			}
+		}
+		return best, nil
+	}
+	scale := 1.0
+	if capability.maxSide > 0 && max(width, height) > capability.maxSide {
+		scale = float64(capability.maxSide) / float64(max(width, height))
+	} else if capability.minSide > 0 && min(width, height) < capability.minSide {
+		scale = float64(capability.minSide) / float64(min(width, height))
+	}
+	width = clampImageSide(int(math.Round(float64(width)*scale)), capability)
+	height = clampImageSide(int(math.Round(float64(height)*scale)), capability)
+	return fmt.Sprintf("%dx%d", width, height), nil
+}
+
+func clampImageSide(side int, capability *imageCapability) int {
+	if capability.minSide > 0 && side < capability.minSide {
+		return capability.minSide
+	}
+	if capability.maxSide > 0 && side > capability.maxSide {
+		return capability.maxSide
+	}
+	return side
+}
+
+// imageResponseWriter 缓存上游返回的图片响应，全部请求完成后统一转换再写给客户端
+type imageResponseWriter struct {
+	gin.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func startImageResponseCapture(c *gin.Context) *imageResponseWriter {
+	writer := &imageResponseWriter{
+		ResponseWriter: c.Writer,
+		statusCode:     http.StatusOK,
+	}
+	c.Writer = writer
+	return writer
+}
+
+func (w *imageResponseWriter) WriteHeader(code int) {
+	w.statusCode = code
+}
+
+func (w *imageResponseWriter) WriteHeaderNow() {
+}
+
+func (w *imageResponseWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *imageResponseWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+// writeNormalizedImageResponse 合并各次请求返回的图片，按客户端期望的格式写出
+func writeNormalizedImageResponse(c *gin.Context, normalization *imageNormalization, writers []*imageResponseWriter) error {
+	merged := dto.ImageResponse{
+		Created: common.GetTimestamp(),
+		Data:    make([]dto.ImageData, 0),
+	}
+	for i, writer := range writers {
+		var response dto.ImageResponse
+		if err := common.Unmarshal(writer.body.Bytes(), &response); err != nil {
+			if len(writers) > 1 {
+				return fmt.Errorf("parse image response failed: %w", err)
+			}
+			// 无法识别的响应原样返回
+			writeImageResponseBody(c, writer.statusCode, writer.body.Bytes())
+			return nil
+		}
+		if i == 0 && response.Created != 0 {
+			merged.Created = response.Created
+		}
+		merged.Data = append(merged.Data, response.Data...)
+	}
+	for i := range merged.Data {
+		if err := convertImageData(c, &merged.Data[i], normalization.responseFormat); err != nil {
+			return err
+		}
+	}
+	body, err := common.Marshal(merged)
+	if err != nil {
+		return err
+	}
+	c.Writer.Header().Set("Content-Type", "application/json")
+	writeImageResponseBody(c, http.StatusOK, body)
+	return nil
+}
+
+func writeImageResponseBody(c *gin.Context, statusCode int, body []byte) {
+	c.Writer.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	c.Writer.WriteHeader(statusCode)
+	_, _ = c.Writer.Write(body)
+}
+
+// convertImageData 在 url 与 b64_json 之间转换，只保留客户端期望的字段
+func convertImageData(c *gin.Context, data *dto.ImageData, responseFormat string) error {
+	switch responseFormat {
+	case "b64_json":
+		if data.B64Json == "" && data.Url != "" {
+			_, b64, err := service.GetImageFromUrl(data.Url)
+			if err != nil {
+				return fmt.Errorf("get image data failed: %w", err)
+			}
+			data.B64Json = b64
+		}
+		data.Url = ""
+	default:
+		if operation_setting.GetMediaStorageSetting().IsEnabledFor("image") {
+			storeImageData(c, data)
+		}
+		// 上游只返回 base64 且未开启媒体存储时无法提供可访问的链接，保留 b64_json 返回
+		if data.Url != "" {
+			data.B64Json = ""
+		}
+	}
+	return nil
+}
+
+// storeImageData 将图片保存到网关并替换为签名链接，失败时保留上游返回的内容
+func storeImageData(c *gin.Context, data *dto.ImageData) {
+	userId := common.GetContextKeyInt(c, constant.ContextKeyUserId)
+	var ref string
+	var err error
+	if data.B64Json != "" {
+		ref, err = service.StoreMediaBase64(c, userId, "image", data.B64Json)
+	} else {
+		ref, err = service.StoreMediaFromUrl(c, userId, "image", data.Url)
+	}
+	if err != nil {
+		common.LogError(c, "store image failed: "+err.Error())
+		return
+	}
+	data.Url = service.ResolveMediaUrl(ref)
+	data.B64Json = ""
+}
@@ -0,0 +1,85 @@
+package relay
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/middleware"
+	"one-api/model"
+	"one-api/types"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 内部子请求不继承的上下文键，这些值与客户端原始请求或其选中的渠道绑定
+var internalContextSkipKeys = map[string]bool{
+	common.KeyRequestBody:      true,
+	"prompt_tokens":            true,
+	"use_channel":              true,
+	"event_stream_headers_set": true,
+	"auto_group":               true,
+	"api_version":              true,
+	"region":                   true,
+	"plugin":                   true,
+	"bot_id":                   true,
+	string(constant.ContextKeyTokenSpecificChannelId):   true,
+	string(constant.ContextKeyChannelIsMultiKey):        true,
+	string(constant.ContextKeyChannelMultiKeyIndex):     true,
+	string(constant.ContextKeyChannelOrganization):      true,
+	string(constant.ContextKeyChannelStatusCodeMapping): true,
+	string(constant.ContextKeyChannelParamOverride):     true,
+	string(constant.ContextKeyChannelSlot):              true,
+	string(constant.ContextKeyChannelPinnedId):          true,
+	string(constant.ContextKeyChannelPinnedKeyIndex):    true,
+	"chat_completion_web_search_context_size":           true,
+	"claude_web_search_requests":                        true,
+}
+
+// newInternalContext 以客户端请求的用户与令牌身份创建一个网关内部子请求，响应写入 recorder 而不是客户端连接
+func newInternalContext(c *gin.Context, path string, contentType string, body []byte) (*gin.Context, *httptest.ResponseRecorder, error) {
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	req, err := http.NewRequestWithContext(c.Request.Context(), http.MethodPost, path, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	ctx.Request = req
+	for key, value := range c.Keys {
+		if internalContextSkipKeys[key] {
+			continue
+		}
+		ctx.Set(key, value)
+	}
+	common.SetContextKey(ctx, constant.ContextKeyRequestStartTime, time.Now())
+	return ctx, recorder, nil
+}
+
+// setupInternalChannel 为内部子请求在指定分组中选择可用渠道，子请求结束后调用方需通过 model.ReleaseChannelSlot 释放占用的并发位
+func setupInternalChannel(ctx *gin.Context, group string, modelName string) *types.NewAPIError {
+	channel, _, err := model.CacheGetRandomSatisfiedChannel(ctx, group, modelName, 0)
+	if err != nil {
+		return types.NewError(fmt.Errorf("获取分组 %s 下模型 %s 的可用渠道失败: %s", group, modelName, err.Error()), types.ErrorCodeGetChannelFailed)
+	}
+	if channel == nil {
+		return types.NewError(errors.New("channel not found"), types.ErrorCodeGetChannelFailed)
+	}
+	return middleware.SetupContextForSelectedChannel(ctx, channel, modelName)
+}
+
+// checkTokenModelLimit 检查客户端令牌是否允许访问内部子请求使用的模型
+func checkTokenModelLimit(c *gin.Context, modelName string) *types.NewAPIError {
+	if !common.GetContextKeyBool(c, constant.ContextKeyTokenModelLimitEnabled) {
+		return nil
+	}
+	tokenModelLimit, _ := common.GetContextKeyType[map[string]bool](c, constant.ContextKeyTokenModelLimit)
+	if !tokenModelLimit[modelName] {
+		return types.NewErrorWithStatusCode(errors.New("该令牌无权访问模型 "+modelName), types.ErrorCodeAccessDenied, http.StatusForbidden)
+	}
+	return nil
+}
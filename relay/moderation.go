@@ -0,0 +1,159 @@
+package relay
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/model"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"one-api/types"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultModerationChatPrompt = `You are a content moderation classifier. Rate the user's message for each of the following categories with a score between 0 and 1, where 1 means the content clearly belongs to the category:
+%s
+Reply with a single JSON object that maps every category name to its score and nothing else.`
+
+// moderationBackend 由网关自行完成审核的后端，每个输入返回一个审核结果
+type moderationBackend interface {
+	Moderate(c *gin.Context, group string, inputs []string) ([]dto.ModerationResult, *types.NewAPIError)
+}
+
+var moderationBackends = map[string]moderationBackend{
+	operation_setting.ModerationBackendChat:  chatModerationBackend{},
+	operation_setting.ModerationBackendLocal: localModerationBackend{},
+}
+
+// ModerationHelper 由网关按配置的后端完成审核，openai 后端不经过这里而是转发到渠道
+func ModerationHelper(c *gin.Context) *types.NewAPIError {
+	backendName := operation_setting.GetModerationSetting().Backend
+	backend, ok := moderationBackends[backendName]
+	if !ok {
+		return types.NewError(fmt.Errorf("unknown moderation backend: %s", backendName), types.ErrorCodeInvalidRequest)
+	}
+	var request dto.ModerationRequest
+	if err := common.UnmarshalBodyReusable(c, &request); err != nil {
+		return types.NewError(err, types.ErrorCodeInvalidRequest)
+	}
+	inputs, err := request.ParseInput()
+	if err != nil {
+		return types.NewErrorWithStatusCode(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest)
+	}
+	if len(inputs) == 0 {
+		return types.NewError(errors.New("field input is required"), types.ErrorCodeInvalidRequest)
+	}
+	if request.Model == "" {
+		request.Model = "omni-moderation-latest"
+	}
+	results, apiErr := backend.Moderate(c, common.GetContextKeyString(c, constant.ContextKeyUsingGroup), inputs)
+	if apiErr != nil {
+		return apiErr
+	}
+	c.JSON(http.StatusOK, dto.ModerationResponse{
+		Id:      "modr-" + c.GetString(common.RequestIdKey),
+		Model:   request.Model,
+		Results: results,
+	})
+	return nil
+}
+
+// localModerationBackend 使用敏感词库匹配，命中时标记配置的类别，不计费
+type localModerationBackend struct{}
+
+func (localModerationBackend) Moderate(c *gin.Context, group string, inputs []string) ([]dto.ModerationResult, *types.NewAPIError) {
+	category := operation_setting.GetModerationSetting().LocalCategory
+	results := make([]dto.ModerationResult, 0, len(inputs))
+	for _, input := range inputs {
+		scores := map[string]float64{}
+		if hit, words := service.SensitiveWordContains(input); hit {
+			common.LogInfo(c, fmt.Sprintf("moderation sensitive words detected: %s", strings.Join(words, ", ")))
+			scores[category] = 1
+		}
+		results = append(results, dto.NewModerationResult(scores, 1))
+	}
+	return results, nil
+}
+
+// chatModerationBackend 在配置的对话模型上逐条打分，子请求按正常对话请求计费
+type chatModerationBackend struct{}
+
+func (chatModerationBackend) Moderate(c *gin.Context, group string, inputs []string) ([]dto.ModerationResult, *types.NewAPIError) {
+	moderationSetting := operation_setting.GetModerationSetting()
+	if moderationSetting.ChatModel == "" {
+		return nil, types.NewError(errors.New("moderation chat model is not configured"), types.ErrorCodeGetChannelFailed)
+	}
+	// 子请求以客户端的令牌发起，同样受令牌的模型限制
+	if apiErr := checkTokenModelLimit(c, moderationSetting.ChatModel); apiErr != nil {
+		return nil, apiErr
+	}
+	prompt := moderationSetting.ChatPrompt
+	if prompt == "" {
+		prompt = fmt.Sprintf(defaultModerationChatPrompt, strings.Join(dto.ModerationCategories, "\n"))
+	}
+	threshold := moderationSetting.FlagThreshold
+	if threshold <= 0 {
+		threshold = 0.5
+	}
+	results := make([]dto.ModerationResult, 0, len(inputs))
+	for _, input := range inputs {
+		scores, apiErr := getModerationScores(c, group, moderationSetting.ChatModel, prompt, input)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		results = append(results, dto.NewModerationResult(scores, threshold))
+	}
+	return results, nil
+}
+
+// getModerationScores 以客户端身份发起一次内部对话请求，解析模型返回的各类别得分
+func getModerationScores(c *gin.Context, group string, chatModel string, prompt string, input string) (map[string]float64, *types.NewAPIError) {
+	temperature := 0.0
+	body, err := common.Marshal(dto.GeneralOpenAIRequest{
+		Model: chatModel,
+		Messages: []dto.Message{
+			{Role: "system", Content: prompt},
+			{Role: "user", Content: input},
+		},
+		Temperature: &temperature,
+	})
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeInvalidRequest)
+	}
+	ctx, recorder, err := newInternalContext(c, "/v1/chat/completions", "application/json", body)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeInvalidRequest)
+	}
+	defer model.ReleaseChannelSlot(ctx)
+	if apiErr := setupInternalChannel(ctx, group, chatModel); apiErr != nil {
+		return nil, apiErr
+	}
+	if apiErr := TextHelper(ctx); apiErr != nil {
+		// 开启提示词敏感词检查时子请求会被拒绝，视为命中本地审核
+		if apiErr.GetErrorCode() == types.ErrorCodeSensitiveWordsDetected {
+			return map[string]float64{operation_setting.GetModerationSetting().LocalCategory: 1}, nil
+		}
+		return nil, apiErr
+	}
+	var response dto.OpenAITextResponse
+	if err := common.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	if len(response.Choices) == 0 {
+		return nil, types.NewError(errors.New("empty moderation response"), types.ErrorCodeBadResponseBody)
+	}
+	content := response.Choices[0].Message.StringContent()
+	var scores map[string]float64
+	if err := common.UnmarshalJsonStr(repairStructuredOutput(content), &scores); err != nil {
+		return nil, types.NewError(fmt.Errorf("invalid moderation scores: %s", content), types.ErrorCodeBadResponseBody)
+	}
+	for category, score := range scores {
+		scores[category] = min(max(score, 0), 1)
+	}
+	return scores, nil
+}
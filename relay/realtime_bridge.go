@@ -0,0 +1,481 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"one-api/types"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	realtimeBridgeSampleRate = 24000     // pcm16 音频的采样率，与 OpenAI Realtime 一致
+	realtimeBridgeAudioChunk = 32 * 1024 // 每个 response.audio.delta 事件携带的音频字节数
+	// 输入音频缓冲的上限，与 OpenAI Realtime 的 15 MiB 一致
+	realtimeBridgeMaxAudioBytes = 15 * 1024 * 1024
+)
+
+// realtimeBridgeSession 以 OpenAI Realtime 事件协议服务客户端，音频依次经转写、对话、语音合成渠道处理
+type realtimeBridgeSession struct {
+	c        *gin.Context
+	ws       *websocket.Conn
+	group    string
+	pipeline operation_setting.RealtimeBridgePipeline
+	session  dto.RealtimeSession
+	audio    bytes.Buffer
+	messages []dto.Message
+}
+
+// RealtimeBridgeHelper 处理桥接模式的 realtime 会话，各组件调用分别按对应模型计费
+func RealtimeBridgeHelper(c *gin.Context, ws *websocket.Conn, pipeline operation_setting.RealtimeBridgePipeline) *types.NewAPIError {
+	s := &realtimeBridgeSession{
+		c:        c,
+		ws:       ws,
+		group:    common.GetContextKeyString(c, constant.ContextKeyUsingGroup),
+		pipeline: pipeline,
+		session: dto.RealtimeSession{
+			Modalities:        []string{"text", "audio"},
+			Voice:             pipeline.Voice,
+			InputAudioFormat:  "pcm16",
+			OutputAudioFormat: "pcm16",
+			InputAudioTranscription: dto.InputAudioTranscription{
+				Model: pipeline.TranscriptionModel,
+			},
+		},
+	}
+	// 令牌的模型限制对各组件模型同样生效
+	for _, modelName := range []string{pipeline.TranscriptionModel, pipeline.ChatModel, pipeline.TtsModel} {
+		if apiErr := checkTokenModelLimit(c, modelName); apiErr != nil {
+			return apiErr
+		}
+	}
+	s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventTypeSessionCreated, Session: &s.session})
+
+	for {
+		_, message, err := ws.ReadMessage()
+		if err != nil {
+			// 客户端断开连接即结束会话
+			common.LogInfo(c, "realtime bridge session closed: "+err.Error())
+			return nil
+		}
+		event := &dto.RealtimeEvent{}
+		if err := common.Unmarshal(message, event); err != nil {
+			s.sendError(types.NewError(err, types.ErrorCodeInvalidRequest))
+			continue
+		}
+		if apiErr := s.handleEvent(event); apiErr != nil {
+			// 额度不足时结束会话，其余错误只通知客户端
+			switch apiErr.GetErrorCode() {
+			case types.ErrorCodeInsufficientUserQuota, types.ErrorCodePreConsumeTokenQuotaFailed:
+				return apiErr
+			}
+			s.sendError(apiErr)
+		}
+	}
+}
+
+func (s *realtimeBridgeSession) handleEvent(event *dto.RealtimeEvent) *types.NewAPIError {
+	switch event.Type {
+	case dto.RealtimeEventTypeSessionUpdate:
+		if event.Session != nil {
+			if apiErr := s.updateSession(event.Session); apiErr != nil {
+				return apiErr
+			}
+		}
+		s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventTypeSessionUpdated, Session: &s.session})
+	case dto.RealtimeEventInputAudioBufferAppend:
+		data, err := base64.StdEncoding.DecodeString(event.Audio)
+		if err != nil {
+			return types.NewError(err, types.ErrorCodeInvalidRequest)
+		}
+		if s.audio.Len()+len(data) > realtimeBridgeMaxAudioBytes {
+			return types.NewError(fmt.Errorf("input audio buffer exceeds %d bytes, commit or clear it first", realtimeBridgeMaxAudioBytes), types.ErrorCodeInvalidRequest)
+		}
+		s.audio.Write(data)
+	case dto.RealtimeEventInputAudioBufferClear:
+		s.audio.Reset()
+		s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventInputAudioBufferCleared})
+	case dto.RealtimeEventInputAudioBufferCommit:
+		return s.commitAudio()
+	case dto.RealtimeEventTypeConversationCreate:
+		if event.Item == nil {
+			return types.NewError(errors.New("item is required"), types.ErrorCodeInvalidRequest)
+		}
+		s.addItem(event.Item)
+		s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventConversationItemCreated, Item: event.Item})
+	case dto.RealtimeEventTypeResponseCreate:
+		return s.createResponse()
+	default:
+		return types.NewError(fmt.Errorf("event type %s is not supported in bridge mode", event.Type), types.ErrorCodeInvalidRequest)
+	}
+	return nil
+}
+
+// updateSession 合并客户端的会话配置，桥接模式没有服务端语音检测，turn_detection 不为空时提交音频后自动生成回复
+func (s *realtimeBridgeSession) updateSession(session *dto.RealtimeSession) *types.NewAPIError {
+	for _, format := range []string{session.InputAudioFormat, session.OutputAudioFormat} {
+		if format != "" && format != "pcm16" {
+			return types.NewError(fmt.Errorf("audio format %s is not supported in bridge mode, only pcm16 is supported", format), types.ErrorCodeInvalidRequest)
+		}
+	}
+	if len(session.Modalities) > 0 {
+		s.session.Modalities = session.Modalities
+	}
+	if session.Instructions != "" {
+		s.session.Instructions = session.Instructions
+	}
+	if session.Voice != "" {
+		s.session.Voice = session.Voice
+	}
+	if session.Temperature > 0 {
+		s.session.Temperature = session.Temperature
+	}
+	s.session.TurnDetection = session.TurnDetection
+	return nil
+}
+
+func (s *realtimeBridgeSession) addItem(item *dto.RealtimeItem) {
+	if item.Type != "" && item.Type != "message" {
+		return
+	}
+	var texts []string
+	for _, content := range item.Content {
+		if content.Text != "" {
+			texts = append(texts, content.Text)
+		} else if content.Transcript != "" {
+			texts = append(texts, content.Transcript)
+		}
+	}
+	if len(texts) == 0 {
+		return
+	}
+	role := item.Role
+	if role == "" {
+		role = "user"
+	}
+	message := dto.Message{Role: role}
+	message.SetStringContent(strings.Join(texts, "\n"))
+	s.messages = append(s.messages, message)
+}
+
+func (s *realtimeBridgeSession) commitAudio() *types.NewAPIError {
+	if s.audio.Len() == 0 {
+		return types.NewError(errors.New("input audio buffer is empty"), types.ErrorCodeInvalidRequest)
+	}
+	wav := service.EncodeWav(s.audio.Bytes(), realtimeBridgeSampleRate)
+	s.audio.Reset()
+	itemId := "item_" + common.GetRandomString(16)
+	s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventInputAudioBufferCommitted, ItemId: itemId})
+
+	transcript, _, apiErr := s.transcribe(wav)
+	if apiErr != nil {
+		return apiErr
+	}
+	item := &dto.RealtimeItem{
+		Id:      itemId,
+		Type:    "message",
+		Status:  "completed",
+		Role:    "user",
+		Content: []dto.RealtimeContent{{Type: "input_audio", Transcript: transcript}},
+	}
+	s.addItem(item)
+	s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventConversationItemCreated, Item: item})
+	s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventInputAudioTranscriptionCompleted, ItemId: itemId, Transcript: transcript})
+
+	if s.session.TurnDetection != nil {
+		return s.createResponse()
+	}
+	return nil
+}
+
+func (s *realtimeBridgeSession) createResponse() *types.NewAPIError {
+	responseId := "resp_" + common.GetRandomString(16)
+	itemId := "item_" + common.GetRandomString(16)
+	s.send(&dto.RealtimeEvent{
+		Type:     dto.RealtimeEventResponseCreated,
+		Response: &dto.RealtimeResponse{Id: responseId, Status: "in_progress"},
+	})
+
+	usage := &dto.RealtimeUsage{}
+	text, chatUsage, apiErr := s.chat()
+	if apiErr != nil {
+		return apiErr
+	}
+	addRealtimeUsage(usage, chatUsage)
+	message := dto.Message{Role: "assistant"}
+	message.SetStringContent(text)
+	s.messages = append(s.messages, message)
+
+	content := dto.RealtimeContent{Type: "text", Text: text}
+	if s.wantsAudio() {
+		audio, ttsUsage, apiErr := s.speech(text)
+		if apiErr != nil {
+			return apiErr
+		}
+		addRealtimeUsage(usage, ttsUsage)
+		s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseAudioTranscriptionDelta, ResponseId: responseId, ItemId: itemId, Delta: text})
+		for start := 0; start < len(audio); start += realtimeBridgeAudioChunk {
+			end := min(start+realtimeBridgeAudioChunk, len(audio))
+			s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseAudioDelta, ResponseId: responseId, ItemId: itemId, Delta: base64.StdEncoding.EncodeToString(audio[start:end])})
+		}
+		s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseAudioDone, ResponseId: responseId, ItemId: itemId})
+		s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseAudioTranscriptionDone, ResponseId: responseId, ItemId: itemId, Transcript: text})
+		content = dto.RealtimeContent{Type: "audio", Transcript: text}
+	} else {
+		s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseTextDelta, ResponseId: responseId, ItemId: itemId, Delta: text})
+		s.send(&dto.RealtimeEvent{Type: dto.RealtimeEventResponseTextDone, ResponseId: responseId, ItemId: itemId, Text: text})
+	}
+
+	s.send(&dto.RealtimeEvent{
+		Type: dto.RealtimeEventTypeResponseDone,
+		Response: &dto.RealtimeResponse{
+			Id:     responseId,
+			Status: "completed",
+			Output: []dto.RealtimeItem{{
+				Id:      itemId,
+				Type:    "message",
+				Status:  "completed",
+				Role:    "assistant",
+				Content: []dto.RealtimeContent{content},
+			}},
+			Usage: usage,
+		},
+	})
+	return nil
+}
+
+func (s *realtimeBridgeSession) wantsAudio() bool {
+	for _, modality := range s.session.Modalities {
+		if modality == "audio" {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *realtimeBridgeSession) transcribe(wav []byte) (string, *dto.RealtimeUsage, *types.NewAPIError) {
+	modelName := s.pipeline.TranscriptionModel
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	_ = writer.WriteField("model", modelName)
+	_ = writer.WriteField("response_format", "json")
+	part, err := writer.CreateFormFile("file", "audio.wav")
+	if err != nil {
+		return "", nil, types.NewError(err, types.ErrorCodeConvertRequestFailed)
+	}
+	_, _ = part.Write(wav)
+	_ = writer.Close()
+
+	respBody, usage, apiErr := s.call("转写", "/v1/audio/transcriptions", writer.FormDataContentType(), body.Bytes(), &dto.AudioRequest{
+		Model:          modelName,
+		ResponseFormat: "json",
+	})
+	if apiErr != nil {
+		return "", nil, apiErr
+	}
+	var audioResponse dto.AudioResponse
+	if err := common.Unmarshal(respBody, &audioResponse); err != nil {
+		return "", nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	return audioResponse.Text, usage, nil
+}
+
+func (s *realtimeBridgeSession) chat() (string, *dto.RealtimeUsage, *types.NewAPIError) {
+	messages := make([]dto.Message, 0, len(s.messages)+1)
+	if s.session.Instructions != "" {
+		systemMessage := dto.Message{Role: "system"}
+		systemMessage.SetStringContent(s.session.Instructions)
+		messages = append(messages, systemMessage)
+	}
+	messages = append(messages, s.messages...)
+	request := &dto.GeneralOpenAIRequest{
+		Model:    s.pipeline.ChatModel,
+		Messages: messages,
+	}
+	if s.session.Temperature > 0 {
+		temperature := s.session.Temperature
+		request.Temperature = &temperature
+	}
+	body, err := common.Marshal(request)
+	if err != nil {
+		return "", nil, types.NewError(err, types.ErrorCodeJsonMarshalFailed)
+	}
+	respBody, usage, apiErr := s.call("对话", "/v1/chat/completions", "application/json", body, request)
+	if apiErr != nil {
+		return "", nil, apiErr
+	}
+	var textResponse dto.OpenAITextResponse
+	if err := common.Unmarshal(respBody, &textResponse); err != nil {
+		return "", nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	if len(textResponse.Choices) == 0 {
+		return "", nil, types.NewError(errors.New("empty chat response"), types.ErrorCodeBadResponseBody)
+	}
+	return textResponse.Choices[0].Message.StringContent(), usage, nil
+}
+
+func (s *realtimeBridgeSession) speech(text string) ([]byte, *dto.RealtimeUsage, *types.NewAPIError) {
+	request := &dto.AudioRequest{
+		Model:          s.pipeline.TtsModel,
+		Input:          text,
+		Voice:          s.session.Voice,
+		ResponseFormat: "pcm",
+	}
+	body, err := common.Marshal(request)
+	if err != nil {
+		return nil, nil, types.NewError(err, types.ErrorCodeJsonMarshalFailed)
+	}
+	return s.call("语音合成", "/v1/audio/speech", "application/json", body, request)
+}
+
+// call 以客户端身份在组件模型的渠道上发起一次内部请求，按 realtime 接口的方式计费，返回上游响应体
+func (s *realtimeBridgeSession) call(component string, path string, contentType string, body []byte, request any) ([]byte, *dto.RealtimeUsage, *types.NewAPIError) {
+	ctx, recorder, err := newInternalContext(s.c, path, contentType, body)
+	if err != nil {
+		return nil, nil, types.NewError(err, types.ErrorCodeInvalidRequest)
+	}
+	ctx.Set(common.KeyRequestBody, body)
+	defer model.ReleaseChannelSlot(ctx)
+
+	var modelName string
+	var info *relaycommon.RelayInfo
+	switch r := request.(type) {
+	case *dto.GeneralOpenAIRequest:
+		modelName = r.Model
+	case *dto.AudioRequest:
+		modelName = r.Model
+	}
+	if apiErr := setupInternalChannel(ctx, s.group, modelName); apiErr != nil {
+		return nil, nil, apiErr
+	}
+	if audioRequest, ok := request.(*dto.AudioRequest); ok {
+		info = relaycommon.GenRelayInfoOpenAIAudio(ctx)
+		if info.RelayMode == relayconstant.RelayModeAudioSpeech {
+			info.PromptTokens = service.CountTTSToken(audioRequest.Input, audioRequest.Model)
+		} else if err := ctx.Request.ParseMultipartForm(32 << 20); err != nil {
+			return nil, nil, types.NewError(err, types.ErrorCodeInvalidRequest)
+		}
+	} else {
+		info = relaycommon.GenRelayInfo(ctx)
+	}
+
+	err = helper.ModelMappedHelper(ctx, info, request)
+	if err != nil {
+		return nil, nil, types.NewError(err, types.ErrorCodeChannelModelMappedError)
+	}
+	priceData, err := helper.ModelPriceHelper(ctx, info, 0, 0)
+	if err != nil {
+		return nil, nil, types.NewError(err, types.ErrorCodeModelPriceError)
+	}
+	preConsumedQuota, userQuota, newAPIError := preConsumeQuota(ctx, priceData.ShouldPreConsumedQuota, info)
+	if newAPIError != nil {
+		return nil, nil, newAPIError
+	}
+	defer func() {
+		if newAPIError != nil {
+			returnPreConsumedQuota(ctx, info, userQuota, preConsumedQuota)
+		}
+	}()
+
+	adaptor := GetAdaptor(info.ApiType)
+	if adaptor == nil {
+		newAPIError = types.NewError(fmt.Errorf("invalid api type: %d", info.ApiType), types.ErrorCodeInvalidApiType)
+		return nil, nil, newAPIError
+	}
+	adaptor.Init(info)
+	var requestBody io.Reader
+	switch r := request.(type) {
+	case *dto.GeneralOpenAIRequest:
+		convertedRequest, err := adaptor.ConvertOpenAIRequest(ctx, info, r)
+		if err != nil {
+			newAPIError = types.NewError(err, types.ErrorCodeConvertRequestFailed)
+			return nil, nil, newAPIError
+		}
+		jsonData, err := common.Marshal(convertedRequest)
+		if err != nil {
+			newAPIError = types.NewError(err, types.ErrorCodeConvertRequestFailed)
+			return nil, nil, newAPIError
+		}
+		requestBody = bytes.NewReader(jsonData)
+	case *dto.AudioRequest:
+		requestBody, err = adaptor.ConvertAudioRequest(ctx, info, *r)
+		if err != nil {
+			newAPIError = types.NewError(err, types.ErrorCodeConvertRequestFailed)
+			return nil, nil, newAPIError
+		}
+	}
+
+	resp, err := adaptor.DoRequest(ctx, info, requestBody)
+	if err != nil {
+		newAPIError = types.NewError(err, types.ErrorCodeDoRequestFailed)
+		return nil, nil, newAPIError
+	}
+	var httpResp *http.Response
+	if resp != nil {
+		httpResp = resp.(*http.Response)
+		if httpResp.StatusCode != http.StatusOK {
+			newAPIError = service.RelayErrorHandler(httpResp, false)
+			service.ResetStatusCode(newAPIError, ctx.GetString("status_code_mapping"))
+			return nil, nil, newAPIError
+		}
+	}
+	usage, newAPIError := adaptor.DoResponse(ctx, httpResp, info)
+	if newAPIError != nil {
+		return nil, nil, newAPIError
+	}
+
+	textUsage, _ := usage.(*dto.Usage)
+	if textUsage == nil {
+		textUsage = &dto.Usage{}
+	}
+	realtimeUsage := &dto.RealtimeUsage{
+		TotalTokens:  textUsage.PromptTokens + textUsage.CompletionTokens,
+		InputTokens:  textUsage.PromptTokens,
+		OutputTokens: textUsage.CompletionTokens,
+	}
+	realtimeUsage.InputTokenDetails.TextTokens = textUsage.PromptTokens
+	realtimeUsage.OutputTokenDetails.TextTokens = textUsage.CompletionTokens
+	// 每个组件调用与普通接口一样结算，预扣额度在此多退少补
+	postConsumeQuota(ctx, info, textUsage, preConsumedQuota, userQuota, priceData, "实时语音桥接："+component)
+	return recorder.Body.Bytes(), realtimeUsage, nil
+}
+
+func (s *realtimeBridgeSession) send(event *dto.RealtimeEvent) {
+	event.EventId = "event_" + common.GetRandomString(16)
+	if err := helper.WssObject(s.c, s.ws, event); err != nil {
+		common.LogError(s.c, "realtime bridge send event failed: "+err.Error())
+	}
+}
+
+func (s *realtimeBridgeSession) sendError(apiErr *types.NewAPIError) {
+	helper.WssError(s.c, s.ws, apiErr.ToOpenAIError())
+}
+
+func addRealtimeUsage(total *dto.RealtimeUsage, usage *dto.RealtimeUsage) {
+	if usage == nil {
+		return
+	}
+	total.TotalTokens += usage.TotalTokens
+	total.InputTokens += usage.InputTokens
+	total.OutputTokens += usage.OutputTokens
+	total.InputTokenDetails.TextTokens += usage.InputTokenDetails.TextTokens
+	total.InputTokenDetails.AudioTokens += usage.InputTokenDetails.AudioTokens
+	total.OutputTokenDetails.TextTokens += usage.OutputTokenDetails.TextTokens
+	total.OutputTokenDetails.AudioTokens += usage.OutputTokenDetails.AudioTokens
+}
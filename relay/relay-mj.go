@@ -32,6 +32,10 @@ func RelayMidjourneyImage(c *gin.Context) {
 		})
 		return
 	}
+	if strings.HasPrefix(midjourneyTask.ImageUrl, service.MediaRefPrefix) {
+		c.Redirect(http.StatusFound, service.ResolveMediaUrl(midjourneyTask.ImageUrl))
+		return
+	}
 	var httpClient *http.Client
 	if channel, err := model.CacheGetChannel(midjourneyTask.ChannelId); err == nil {
 		proxy := channel.GetSetting().Proxy
@@ -121,6 +125,11 @@ func RelayMidjourneyNotify(c *gin.Context) *dto.MidjourneyResponse {
 	return nil
 }
 
+// MidjourneyModel2Dto 转换为返回给客户端的任务格式，用于任务完成通知
+func MidjourneyModel2Dto(originTask *model.Midjourney) dto.MidjourneyDto {
+	return coverMidjourneyTaskDto(nil, originTask)
+}
+
 func coverMidjourneyTaskDto(c *gin.Context, originTask *model.Midjourney) (midjourneyTask dto.MidjourneyDto) {
 	midjourneyTask.MjId = originTask.MjId
 	midjourneyTask.Progress = originTask.Progress
@@ -130,7 +139,10 @@ func coverMidjourneyTaskDto(c *gin.Context, originTask *model.Midjourney) (midjo
 	midjourneyTask.StartTime = originTask.StartTime
 	midjourneyTask.FinishTime = originTask.FinishTime
 	midjourneyTask.ImageUrl = ""
-	if originTask.ImageUrl != "" && setting.MjForwardUrlEnabled {
+	if strings.HasPrefix(originTask.ImageUrl, service.MediaRefPrefix) {
+		// 已保存到网关的图片直接返回签名链接
+		midjourneyTask.ImageUrl = service.ResolveMediaUrl(originTask.ImageUrl)
+	} else if originTask.ImageUrl != "" && setting.MjForwardUrlEnabled {
 		midjourneyTask.ImageUrl = setting.ServerAddress + "/mj/image/" + originTask.MjId
 		if originTask.Status != "SUCCESS" {
 			midjourneyTask.ImageUrl += "?rand=" + strconv.FormatInt(time.Now().UnixNano(), 10)
@@ -185,6 +197,10 @@ func RelaySwapFace(c *gin.Context) *dto.MidjourneyResponse {
 	if swapFaceRequest.SourceBase64 == "" || swapFaceRequest.TargetBase64 == "" {
 		return service.MidjourneyErrorWrapper(constant.MjRequestError, "sour_base64_and_target_base64_is_required")
 	}
+	callbackUrl := getTaskCallbackUrl(c)
+	if err := service.ValidateTaskWebhookUrl(c.GetInt("id"), callbackUrl); err != nil {
+		return service.MidjourneyErrorWrapper(constant.MjRequestError, "invalid_callback_url")
+	}
 	modelName := service.CoverActionToModelName(constant.MjActionSwapFace)
 
 	priceData := helper.ModelPriceHelperPerCall(c, relayInfo)
@@ -254,11 +270,14 @@ func RelaySwapFace(c *gin.Context) *dto.MidjourneyResponse {
 		FailReason:  "",
 		ChannelId:   c.GetInt("channel_id"),
 		Quota:       priceData.Quota,
+		CallbackUrl: callbackUrl,
 	}
+	midjourneyTask.CallbackNonce = service.GetTaskCallbackNonce(c)
 	err = midjourneyTask.Insert()
 	if err != nil {
 		return service.MidjourneyErrorWrapper(constant.MjRequestError, "insert_midjourney_task_failed")
 	}
+	service.ResetTaskPoll(constant.TaskPlatformMidjourney)
 	c.Writer.WriteHeader(mjResp.StatusCode)
 	respBody, err := json.Marshal(midjResponse)
 	if err != nil {
@@ -383,6 +402,10 @@ func RelayMidjourneySubmit(c *gin.Context, relayMode int) *dto.MidjourneyRespons
 	if err != nil {
 		return service.MidjourneyErrorWrapper(constant.MjRequestError, "bind_request_body_failed")
 	}
+	callbackUrl := getTaskCallbackUrl(c)
+	if err := service.ValidateTaskWebhookUrl(c.GetInt("id"), callbackUrl); err != nil {
+		return service.MidjourneyErrorWrapper(constant.MjRequestError, "invalid_callback_url")
+	}
 
 	if relayMode == relayconstant.RelayModeMidjourneyAction { // midjourney plus，需要从customId中获取任务信息
 		mjErr := service.CoverPlusActionToNormalAction(&midjRequest)
@@ -567,6 +590,7 @@ func RelayMidjourneySubmit(c *gin.Context, relayMode int) *dto.MidjourneyRespons
 		FailReason:  "",
 		ChannelId:   c.GetInt("channel_id"),
 		Quota:       priceData.Quota,
+		CallbackUrl: callbackUrl,
 	}
 	if midjResponse.Code == 3 {
 		//无实例账号自动禁用渠道（No available account instance）
@@ -611,6 +635,7 @@ func RelayMidjourneySubmit(c *gin.Context, relayMode int) *dto.MidjourneyRespons
 		midjourneyTask.Progress = "100%"
 		midjourneyTask.Status = "SUCCESS"
 	}
+	midjourneyTask.CallbackNonce = service.GetTaskCallbackNonce(c)
 	err = midjourneyTask.Insert()
 	if err != nil {
 		return &dto.MidjourneyResponse{
@@ -618,6 +643,7 @@ func RelayMidjourneySubmit(c *gin.Context, relayMode int) *dto.MidjourneyRespons
 			Description: "insert_midjourney_task_failed",
 		}
 	}
+	service.ResetTaskPoll(constant.TaskPlatformMidjourney)
 
 	if midjResponse.Code == 22 { //22-排队中，说明任务已存在
 		//修改返回值
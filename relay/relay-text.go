@@ -12,6 +12,7 @@ import (
 	"one-api/constant"
 	"one-api/dto"
 	"one-api/model"
+	"one-api/relay/channel"
 	relaycommon "one-api/relay/common"
 	relayconstant "one-api/relay/constant"
 	"one-api/relay/helper"
@@ -142,6 +143,28 @@ func TextHelper(c *gin.Context) (newAPIError *types.NewAPIError) {
 			returnPreConsumedQuota(c, relayInfo, userQuota, preConsumedQuota)
 		}
 	}()
+
+	// 确定性请求优先读取响应缓存
+	cacheKey := getResponseCacheKey(c, relayInfo, textRequest)
+	if cacheKey != "" {
+		if entry, ok := service.GetResponseCache(cacheKey); ok {
+			relayInfo.ResponseCacheHit = true
+			replayResponseCache(c, relayInfo, entry)
+			postConsumeQuota(c, relayInfo, &entry.Usage, preConsumedQuota, userQuota, priceData, "")
+			return nil
+		}
+		relayInfo.ResponseCacheMiss = true
+	}
+	semanticCache := newSemanticCacheLookup(c, relayInfo, textRequest)
+	if semanticCache != nil {
+		if entry, ok := semanticCache.lookup(c, relayInfo); ok {
+			relayInfo.ResponseCacheHit = true
+			replayResponseCache(c, relayInfo, entry)
+			postConsumeQuota(c, relayInfo, &entry.Usage, preConsumedQuota, userQuota, priceData, "")
+			return nil
+		}
+	}
+
 	includeUsage := false
 	// 判断用户是否需要返回使用情况
 	if textRequest.StreamOptions != nil && textRequest.StreamOptions.IncludeUsage {
@@ -164,6 +187,31 @@ func TextHelper(c *gin.Context) (newAPIError *types.NewAPIError) {
 		relayInfo.ShouldIncludeUsage = true
 	}
 
+	// 上游只支持 chat 接口时，completions / edits 请求转换为 chat 请求
+	var emulation *completionsEmulation
+	if shouldEmulateCompletions(relayInfo) {
+		emulation, err = convertCompletionsToChat(relayInfo, textRequest)
+		if err != nil {
+			newApiErr = types.NewError(err, types.ErrorCodeInvalidRequest)
+			return newApiErr
+		}
+	}
+	// 渠道开启工具调用模拟时，工具定义改为注入提示词
+	var toolEmulation *toolCallEmulation
+	if shouldEmulateToolCalls(relayInfo, textRequest) {
+		toolEmulation, err = convertToolsToPrompt(textRequest)
+		if err != nil {
+			newApiErr = types.NewError(err, types.ErrorCodeInvalidRequest)
+			return newApiErr
+		}
+	}
+	// response_format 为 json_schema 时按配置模拟并校验响应
+	output, err := newStructuredOutput(c, relayInfo, textRequest)
+	if err != nil {
+		newApiErr = types.NewError(err, types.ErrorCodeInvalidRequest)
+		return newApiErr
+	}
+
 	adaptor := GetAdaptor(relayInfo.ApiType)
 	if adaptor == nil {
 		return types.NewError(fmt.Errorf("invalid api type: %d", relayInfo.ApiType), types.ErrorCodeInvalidApiType)
@@ -178,32 +226,10 @@ func TextHelper(c *gin.Context) (newAPIError *types.NewAPIError) {
 		}
 		requestBody = bytes.NewBuffer(body)
 	} else {
-		convertedRequest, err := adaptor.ConvertOpenAIRequest(c, relayInfo, textRequest)
-		if err != nil {
-			return types.NewError(err, types.ErrorCodeConvertRequestFailed)
-		}
-		jsonData, err := json.Marshal(convertedRequest)
-		if err != nil {
-			return types.NewError(err, types.ErrorCodeConvertRequestFailed)
-		}
-
-		// apply param override
-		if len(relayInfo.ParamOverride) > 0 {
-			reqMap := make(map[string]interface{})
-			_ = common.Unmarshal(jsonData, &reqMap)
-			for key, value := range relayInfo.ParamOverride {
-				reqMap[key] = value
-			}
-			jsonData, err = common.Marshal(reqMap)
-			if err != nil {
-				return types.NewError(err, types.ErrorCodeChannelParamOverrideInvalid)
-			}
-		}
-
-		if common.DebugEnabled {
-			println("requestBody: ", string(jsonData))
+		requestBody, newApiErr = convertTextRequestBody(c, relayInfo, adaptor, textRequest)
+		if newApiErr != nil {
+			return newApiErr
 		}
-		requestBody = bytes.NewBuffer(jsonData)
 	}
 
 	var httpResp *http.Response
@@ -226,12 +252,67 @@ func TextHelper(c *gin.Context) (newAPIError *types.NewAPIError) {
 		}
 	}
 
+	// 缓存捕获写在最外层，保存的是经过各类模拟转换后客户端实际收到的响应
+	var captureWriter *responseCaptureWriter
+	if cacheKey != "" || semanticCache != nil {
+		captureWriter = startResponseCapture(c)
+	}
+	var emulationWriter *completionsEmulationWriter
+	if emulation != nil {
+		emulationWriter = startCompletionsEmulation(c, relayInfo, emulation)
+	}
+	var outputWriter *structuredOutputWriter
+	if output != nil && !relayInfo.IsStream {
+		outputWriter = startStructuredOutputValidation(c, output, toolEmulation)
+	}
+	var toolEmulationWriter *toolCallEmulationWriter
+	if toolEmulation != nil {
+		toolEmulationWriter = startToolCallEmulation(c, relayInfo, toolEmulation)
+	}
 	usage, newApiErr := adaptor.DoResponse(c, httpResp, relayInfo)
+	if toolEmulationWriter != nil {
+		if newApiErr == nil {
+			toolEmulationWriter.finish(c)
+		} else {
+			c.Writer = toolEmulationWriter.ResponseWriter
+		}
+	}
+	if outputWriter != nil {
+		if newApiErr == nil {
+			var retryUsage *dto.Usage
+			retryUsage, newApiErr = outputWriter.finish(c, relayInfo, adaptor, textRequest)
+			if retryUsage != nil {
+				usage = addUsage(usage.(*dto.Usage), retryUsage)
+			}
+		} else {
+			c.Writer = outputWriter.ResponseWriter
+		}
+	}
+	if emulationWriter != nil {
+		if newApiErr == nil {
+			emulationWriter.finish(c)
+		} else {
+			c.Writer = emulationWriter.ResponseWriter
+		}
+	}
+	if captureWriter != nil {
+		c.Writer = captureWriter.ResponseWriter
+	}
 	if newApiErr != nil {
 		// reset status code 重置状态码
 		service.ResetStatusCode(newApiErr, statusCodeMappingStr)
 		return newApiErr
 	}
+	if captureWriter != nil {
+		if entry := buildResponseCacheEntry(relayInfo, captureWriter, usage.(*dto.Usage)); entry != nil {
+			if cacheKey != "" {
+				service.SetResponseCache(cacheKey, entry)
+			}
+			if semanticCache != nil {
+				semanticCache.save(entry)
+			}
+		}
+	}
 
 	if strings.HasPrefix(relayInfo.OriginModelName, "gpt-4o-audio") {
 		service.PostAudioConsumeQuota(c, relayInfo, usage.(*dto.Usage), preConsumedQuota, userQuota, priceData, "")
@@ -241,6 +322,36 @@ func TextHelper(c *gin.Context) (newAPIError *types.NewAPIError) {
 	return nil
 }
 
+// convertTextRequestBody 将请求转换为上游格式并应用参数覆盖
+func convertTextRequestBody(c *gin.Context, relayInfo *relaycommon.RelayInfo, adaptor channel.Adaptor, textRequest *dto.GeneralOpenAIRequest) (io.Reader, *types.NewAPIError) {
+	convertedRequest, err := adaptor.ConvertOpenAIRequest(c, relayInfo, textRequest)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeConvertRequestFailed)
+	}
+	jsonData, err := json.Marshal(convertedRequest)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeConvertRequestFailed)
+	}
+
+	// apply param override
+	if len(relayInfo.ParamOverride) > 0 {
+		reqMap := make(map[string]interface{})
+		_ = common.Unmarshal(jsonData, &reqMap)
+		for key, value := range relayInfo.ParamOverride {
+			reqMap[key] = value
+		}
+		jsonData, err = common.Marshal(reqMap)
+		if err != nil {
+			return nil, types.NewError(err, types.ErrorCodeChannelParamOverrideInvalid)
+		}
+	}
+
+	if common.DebugEnabled {
+		println("requestBody: ", string(jsonData))
+	}
+	return bytes.NewBuffer(jsonData), nil
+}
+
 func getPromptTokens(textRequest *dto.GeneralOpenAIRequest, info *relaycommon.RelayInfo) (int, error) {
 	var promptTokens int
 	var err error
@@ -249,6 +360,8 @@ func getPromptTokens(textRequest *dto.GeneralOpenAIRequest, info *relaycommon.Re
 		promptTokens, err = service.CountTokenChatRequest(info, *textRequest)
 	case relayconstant.RelayModeCompletions:
 		promptTokens = service.CountTokenInput(textRequest.Prompt, textRequest.Model)
+	case relayconstant.RelayModeEdits:
+		promptTokens = service.CountTokenInput(textRequest.Instruction, textRequest.Model) + service.CountTokenInput(textRequest.Input, textRequest.Model)
 	case relayconstant.RelayModeModerations:
 		promptTokens = service.CountTokenInput(textRequest.Input, textRequest.Model)
 	case relayconstant.RelayModeEmbeddings:
@@ -475,6 +588,14 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 	// 添加 audio input 独立计费
 	quotaCalculateDecimal = quotaCalculateDecimal.Add(audioInputQuota)
 
+	// 响应缓存命中按配置的倍率计费
+	var responseCacheBillingRatio float64
+	if relayInfo.ResponseCacheHit {
+		responseCacheBillingRatio = operation_setting.GetResponseCacheSetting().GetBillingRatio()
+		quotaCalculateDecimal = quotaCalculateDecimal.Mul(decimal.NewFromFloat(responseCacheBillingRatio))
+		extraContent += fmt.Sprintf("响应缓存命中，计费倍率 %.2f", responseCacheBillingRatio)
+	}
+
 	quota := int(quotaCalculateDecimal.Round(0).IntPart())
 	totalTokens := promptTokens + completionTokens
 
@@ -495,7 +616,9 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 			"tokenId %d, model %s， pre-consumed quota %d", relayInfo.UserId, relayInfo.ChannelId, relayInfo.TokenId, modelName, preConsumedQuota))
 	} else {
 		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
-		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
+		if !relayInfo.ResponseCacheHit {
+			model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
+		}
 	}
 
 	quotaDelta := quota - preConsumedQuota
@@ -548,6 +671,19 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 			other["file_search_price"] = fileSearchPrice
 		}
 	}
+	if relayInfo.ResponseCacheHit {
+		other["response_cache_hit"] = true
+		other["response_cache_billing_ratio"] = responseCacheBillingRatio
+	}
+	if relayInfo.ResponseCacheMiss {
+		other["response_cache_miss"] = true
+	}
+	if relayInfo.SemanticCacheState != "" {
+		other["semantic_cache"] = relayInfo.SemanticCacheState
+		if relayInfo.SemanticCacheState == SemanticCacheStateHit {
+			other["semantic_cache_score"] = relayInfo.SemanticCacheScore
+		}
+	}
 	if !audioInputQuota.IsZero() {
 		other["audio_input_seperate_price"] = true
 		other["audio_input_token_count"] = audioTokens
@@ -14,7 +14,9 @@ import (
 	relaycommon "one-api/relay/common"
 	relayconstant "one-api/relay/constant"
 	"one-api/service"
+	"one-api/setting/operation_setting"
 	"one-api/setting/ratio_setting"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -36,6 +38,10 @@ func RelayTaskSubmit(c *gin.Context, relayMode int) (taskErr *dto.TaskError) {
 	if taskErr != nil {
 		return
 	}
+	callbackUrl := getTaskCallbackUrl(c)
+	if err := service.ValidateTaskWebhookUrl(c.GetInt("id"), callbackUrl); err != nil {
+		return service.TaskErrorWrapperLocal(err, "invalid_callback_url", http.StatusBadRequest)
+	}
 
 	modelName := relayInfo.OriginModelName
 	if modelName == "" {
@@ -51,6 +57,22 @@ func RelayTaskSubmit(c *gin.Context, relayMode int) (taskErr *dto.TaskError) {
 		}
 	}
 
+	// 视频按时长与分辨率计费
+	var videoRequest *dto.VideoRequest
+	if v, ok := c.Get("task_request"); ok {
+		videoRequest, _ = v.(*dto.VideoRequest)
+	}
+	basePrice := modelPrice
+	if videoRequest != nil {
+		modelPrice = operation_setting.GetVideoPricingSetting().GetVideoPrice(modelName, modelPrice, videoRequest.Duration, videoRequest.Resolution)
+	}
+
+	// 音乐按返回的歌曲数计费，提交时按预计歌曲数预扣
+	clips := operation_setting.GetMusicPricingSetting().GetExpectedClips(relayInfo.Action)
+	if clips > 0 {
+		modelPrice = basePrice * float64(clips)
+	}
+
 	// 预扣
 	groupRatio := ratio_setting.GetGroupRatio(relayInfo.UsingGroup)
 	var ratio float64
@@ -60,12 +82,23 @@ func RelayTaskSubmit(c *gin.Context, relayMode int) (taskErr *dto.TaskError) {
 	} else {
 		ratio = modelPrice * groupRatio
 	}
+	// 分时计价
+	timePricing := operation_setting.GetTimePricingSetting().Match(relayInfo.UsingGroup, modelName, time.Now())
+	if timePricing != nil {
+		ratio *= timePricing.GroupMultiplier * timePricing.ModelMultiplier
+	}
 	userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
 	if err != nil {
 		taskErr = service.TaskErrorWrapper(err, "get_user_quota_failed", http.StatusInternalServerError)
 		return
 	}
 	quota := int(ratio * common.QuotaPerUnit)
+	clipQuota := 0
+	if clips > 0 {
+		// 先算单首额度再乘以歌曲数，结算时按单首额度多退少补不会有取整误差
+		clipQuota = int(ratio / float64(clips) * common.QuotaPerUnit)
+		quota = clipQuota * clips
+	}
 	if userQuota-quota < 0 {
 		taskErr = service.TaskErrorWrapperLocal(errors.New("user quota is not enough"), "quota_not_enough", http.StatusForbidden)
 		return
@@ -135,10 +168,23 @@ func RelayTaskSubmit(c *gin.Context, relayMode int) (taskErr *dto.TaskError) {
 				logContent := fmt.Sprintf("模型固定价格 %.2f，分组倍率 %.2f，操作 %s", modelPrice, gRatio, relayInfo.Action)
 				other := make(map[string]interface{})
 				other["model_price"] = modelPrice
+				if videoRequest != nil {
+					logContent += fmt.Sprintf("，视频时长 %g 秒，分辨率 %s（基础价格 %.2f）", videoRequest.Duration, videoRequest.Resolution, basePrice)
+					other["video_duration"] = videoRequest.Duration
+					other["video_resolution"] = videoRequest.Resolution
+				}
+				if clips > 0 {
+					logContent += fmt.Sprintf("，按歌曲计费，预扣 %d 首（单首价格 %.2f）", clips, basePrice)
+					other["music_clips"] = clips
+				}
 				other["group_ratio"] = groupRatio
 				if hasUserGroupRatio {
 					other["user_group_ratio"] = userGroupRatio
 				}
+				if timePricing != nil {
+					logContent += fmt.Sprintf("，分时计价 %s（分组系数 %.2f，模型系数 %.2f）", timePricing.Rule, timePricing.GroupMultiplier, timePricing.ModelMultiplier)
+					other["time_pricing"] = timePricing
+				}
 				model.RecordConsumeLog(c, relayInfo.UserId, model.RecordConsumeLogParams{
 					ChannelId: relayInfo.ChannelId,
 					ModelName: modelName,
@@ -167,11 +213,28 @@ func RelayTaskSubmit(c *gin.Context, relayMode int) (taskErr *dto.TaskError) {
 	task.Quota = quota
 	task.Data = taskData
 	task.Action = relayInfo.Action
+	task.CallbackUrl = callbackUrl
+	task.CallbackNonce = service.GetTaskCallbackNonce(c)
+	task.Properties.Model = modelName
+	task.Properties.Group = relayInfo.UsingGroup
+	task.Properties.ClipQuota = clipQuota
+	if operation_setting.GetTaskFailureSetting().RetryEnabled && relayInfo.OriginTaskID == "" {
+		// 记录原始请求，上游临时错误时可重新提交到其他渠道；续写类任务依赖原渠道，不重新提交
+		if requestBody, err := common.GetRequestBody(c); err == nil {
+			task.Properties.Request = string(requestBody)
+			task.Properties.RequestPath = c.Request.URL.Path
+			task.Properties.Action = c.Param("action")
+			if task.Properties.Action == "" {
+				task.Properties.Action = c.GetString("action")
+			}
+		}
+	}
 	err = task.Insert()
 	if err != nil {
 		taskErr = service.TaskErrorWrapper(err, "insert_task_failed", http.StatusInternalServerError)
 		return
 	}
+	service.ResetTaskPoll(string(platform))
 	return nil
 }
 
@@ -179,6 +242,7 @@ var fetchRespBuilders = map[int]func(c *gin.Context) (respBody []byte, taskResp
 	relayconstant.RelayModeSunoFetchByID:  sunoFetchByIDRespBodyBuilder,
 	relayconstant.RelayModeSunoFetch:      sunoFetchRespBodyBuilder,
 	relayconstant.RelayModeKlingFetchByID: videoFetchByIDRespBodyBuilder,
+	relayconstant.RelayModeMusicFetchByID: videoFetchByIDRespBodyBuilder,
 }
 
 func RelayTaskFetch(c *gin.Context, relayMode int) (taskResp *dto.TaskError) {
@@ -274,16 +338,36 @@ func videoFetchByIDRespBodyBuilder(c *gin.Context) (respBody []byte, taskResp *d
 	return
 }
 
+// getTaskCallbackUrl 获取任务完成通知地址，请求中的 callback_url 优先于令牌设置
+func getTaskCallbackUrl(c *gin.Context) string {
+	var req struct {
+		CallbackUrl string `json:"callback_url"`
+	}
+	_ = common.UnmarshalBodyReusable(c, &req)
+	if req.CallbackUrl != "" {
+		return req.CallbackUrl
+	}
+	return common.GetContextKeyString(c, constant.ContextKeyTokenCallbackUrl)
+}
+
 func TaskModel2Dto(task *model.Task) *dto.TaskDto {
-	return &dto.TaskDto{
-		TaskID:     task.TaskID,
+	taskDto := &dto.TaskDto{
+		TaskID:     task.GetClientTaskID(),
 		Action:     task.Action,
 		Status:     string(task.Status),
-		FailReason: task.FailReason,
+		FailReason: service.ResolveMediaUrl(task.FailReason),
 		SubmitTime: task.SubmitTime,
 		StartTime:  task.StartTime,
 		FinishTime: task.FinishTime,
 		Progress:   task.Progress,
-		Data:       task.Data,
+		Data:       service.ResolveMediaRefs(task.Data),
+	}
+	if result := task.Properties.VideoResult; result != nil {
+		taskDto.Result = &dto.VideoTaskResult{
+			Url:      service.ResolveMediaUrl(result.Url),
+			CoverUrl: service.ResolveMediaUrl(result.CoverUrl),
+			Duration: result.Duration,
+		}
 	}
+	return taskDto
 }
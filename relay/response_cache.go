@@ -0,0 +1,145 @@
+package relay
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/relay/helper"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// responseCaptureWriter 在写给客户端的同时保留一份响应内容，用于写入缓存
+type responseCaptureWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseCaptureWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseCaptureWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// getResponseCacheKey 返回空字符串表示本次请求不走缓存
+func getResponseCacheKey(c *gin.Context, info *relaycommon.RelayInfo, request any) string {
+	cacheSetting := operation_setting.GetResponseCacheSetting()
+	if !cacheSetting.Enabled || info.IsPlayground {
+		return ""
+	}
+	if service.IsResponseCacheOptOut(c.Request.Header.Get(service.ResponseCacheHeader)) {
+		return ""
+	}
+	switch req := request.(type) {
+	case *dto.GeneralOpenAIRequest:
+		if !cacheSetting.ChatEnabled || info.RelayMode != relayconstant.RelayModeChatCompletions {
+			return ""
+		}
+		// 只缓存确定性的请求
+		if req.Temperature == nil || *req.Temperature != 0 || req.N > 1 {
+			return ""
+		}
+	case *dto.EmbeddingRequest:
+		if !cacheSetting.EmbeddingEnabled {
+			return ""
+		}
+	default:
+		return ""
+	}
+	key, err := service.GenerateResponseCacheKey(info.RelayMode, info.UpstreamModelName, info.UsingGroup, getResponseCacheVariant(info, request), request)
+	if err != nil {
+		common.LogError(c, "generate response cache key failed: "+err.Error())
+		return ""
+	}
+	return key
+}
+
+// getResponseCacheVariant 返回渠道对请求启用的模拟方式，模拟与原生渠道的响应分开缓存
+func getResponseCacheVariant(info *relaycommon.RelayInfo, request any) string {
+	textRequest, ok := request.(*dto.GeneralOpenAIRequest)
+	if !ok {
+		return ""
+	}
+	var variants []string
+	if shouldEmulateToolCalls(info, textRequest) {
+		variants = append(variants, "tool_call_emulation")
+	}
+	if info.ChannelSetting.StructuredOutputEmulation && textRequest.ResponseFormat != nil && textRequest.ResponseFormat.Type == "json_schema" {
+		variants = append(variants, "structured_output_emulation")
+	}
+	return strings.Join(variants, ",")
+}
+
+func startResponseCapture(c *gin.Context) *responseCaptureWriter {
+	writer := &responseCaptureWriter{ResponseWriter: c.Writer}
+	c.Writer = writer
+	return writer
+}
+
+// buildResponseCacheEntry 将捕获的响应转换为缓存条目，流式响应只保留 data 事件的内容，返回 nil 表示不可缓存
+func buildResponseCacheEntry(info *relaycommon.RelayInfo, writer *responseCaptureWriter, usage *dto.Usage) *service.ResponseCacheEntry {
+	if writer.Status() != http.StatusOK || usage == nil || usage.PromptTokens+usage.CompletionTokens == 0 {
+		return nil
+	}
+	entry := &service.ResponseCacheEntry{
+		IsStream:    info.IsStream,
+		ContentType: writer.Header().Get("Content-Type"),
+		Usage:       *usage,
+		CreatedAt:   common.GetTimestamp(),
+	}
+	if info.IsStream {
+		scanner := bufio.NewScanner(bytes.NewReader(writer.body.Bytes()))
+		scanner.Buffer(make([]byte, helper.InitialScannerBufferSize), helper.MaxScannerBufferSize)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if !strings.HasPrefix(line, "data:") {
+				continue
+			}
+			data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			if data == "" || data == "[DONE]" {
+				continue
+			}
+			entry.Chunks = append(entry.Chunks, data)
+		}
+		if len(entry.Chunks) == 0 {
+			return nil
+		}
+	} else {
+		entry.Body = writer.body.String()
+	}
+	return entry
+}
+
+// replayResponseCache 将缓存的响应返回给客户端，流式响应通过与上游相同的 SSE 写入方式重放
+func replayResponseCache(c *gin.Context, info *relaycommon.RelayInfo, entry *service.ResponseCacheEntry) {
+	c.Header(service.ResponseCacheHeader, "HIT")
+	info.SetFirstResponseTime()
+	if entry.IsStream {
+		info.IsStream = true
+		helper.SetEventStreamHeaders(c)
+		for _, chunk := range entry.Chunks {
+			if err := helper.StringData(c, chunk); err != nil {
+				common.LogError(c, "replay response cache failed: "+err.Error())
+				return
+			}
+		}
+		helper.Done(c)
+		return
+	}
+	contentType := entry.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	c.Data(http.StatusOK, contentType, []byte(entry.Body))
+}
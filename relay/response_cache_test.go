@@ -0,0 +1,79 @@
+package relay
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetResponseCacheKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cacheSetting := operation_setting.GetResponseCacheSetting()
+	oldSetting := *cacheSetting
+	defer func() { *cacheSetting = oldSetting }()
+	cacheSetting.Enabled = true
+	cacheSetting.ChatEnabled = true
+	cacheSetting.EmbeddingEnabled = true
+
+	zero, warm := 0.0, 0.7
+	chatInfo := func() *relaycommon.RelayInfo {
+		return &relaycommon.RelayInfo{RelayMode: relayconstant.RelayModeChatCompletions, UpstreamModelName: "gpt-4o", UsingGroup: "default"}
+	}
+	cases := []struct {
+		name      string
+		info      *relaycommon.RelayInfo
+		request   any
+		header    string
+		wantCache bool
+	}{
+		{"deterministic chat", chatInfo(), &dto.GeneralOpenAIRequest{Temperature: &zero}, "", true},
+		{"temperature unset", chatInfo(), &dto.GeneralOpenAIRequest{}, "", false},
+		{"non-zero temperature", chatInfo(), &dto.GeneralOpenAIRequest{Temperature: &warm}, "", false},
+		{"multiple choices", chatInfo(), &dto.GeneralOpenAIRequest{Temperature: &zero, N: 2}, "", false},
+		{"client opt-out", chatInfo(), &dto.GeneralOpenAIRequest{Temperature: &zero}, "no-cache", false},
+		{"playground", &relaycommon.RelayInfo{RelayMode: relayconstant.RelayModeChatCompletions, IsPlayground: true}, &dto.GeneralOpenAIRequest{Temperature: &zero}, "", false},
+		{"embedding", &relaycommon.RelayInfo{RelayMode: relayconstant.RelayModeEmbeddings}, &dto.EmbeddingRequest{Input: "hello"}, "", true},
+		{"unsupported request", chatInfo(), &dto.AudioRequest{}, "", false},
+	}
+	for _, c := range cases {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		if c.header != "" {
+			ctx.Request.Header.Set(service.ResponseCacheHeader, c.header)
+		}
+		if got := getResponseCacheKey(ctx, c.info, c.request) != ""; got != c.wantCache {
+			t.Errorf("%s: cacheable = %v, want %v", c.name, got, c.wantCache)
+		}
+	}
+
+	cacheSetting.Enabled = false
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	if getResponseCacheKey(ctx, chatInfo(), &dto.GeneralOpenAIRequest{Temperature: &zero}) != "" {
+		t.Error("disabled cache should not produce a key")
+	}
+}
+
+func TestBuildResponseCacheEntryStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	writer := startResponseCapture(ctx)
+	_, _ = ctx.Writer.WriteString("data: {\"a\":1}\n\n: keep-alive\n\ndata: {\"b\":2}\n\ndata: [DONE]\n\n")
+	usage := &dto.Usage{PromptTokens: 3, CompletionTokens: 2}
+
+	entry := buildResponseCacheEntry(&relaycommon.RelayInfo{IsStream: true}, writer, usage)
+	if entry == nil || len(entry.Chunks) != 2 || entry.Chunks[0] != `{"a":1}` || entry.Chunks[1] != `{"b":2}` {
+		t.Fatalf("unexpected entry: %+v", entry)
+	}
+	if entry := buildResponseCacheEntry(&relaycommon.RelayInfo{IsStream: true}, writer, &dto.Usage{}); entry != nil {
+		t.Error("response without usage should not be cached")
+	}
+}
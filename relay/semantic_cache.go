@@ -0,0 +1,168 @@
+package relay
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	SemanticCacheStateHit  = "hit"
+	SemanticCacheStateMiss = "miss"
+
+	semanticCacheKeyPrefix = "semantic_cache:"
+)
+
+// semanticCacheLookup 记录一次请求的语义缓存检索信息，未命中时用于回写缓存
+type semanticCacheLookup struct {
+	namespace string
+	prompt    string
+	vector    []float64
+}
+
+// getSemanticCachePrompt 取最后一条 user 消息作为语义缓存的检索文本，
+// 其之前的系统提示词、历史对话以及 response_format、输出长度、采样参数与工具定义的摘要作为上下文，上下文不同的请求不共享缓存
+func getSemanticCachePrompt(request *dto.GeneralOpenAIRequest) (string, string) {
+	for i := len(request.Messages) - 1; i >= 0; i-- {
+		if request.Messages[i].Role != "user" {
+			continue
+		}
+		context, err := common.Marshal(struct {
+			Messages            []dto.Message         `json:"messages"`
+			ResponseFormat      *dto.ResponseFormat   `json:"response_format,omitempty"`
+			MaxTokens           uint                  `json:"max_tokens,omitempty"`
+			MaxCompletionTokens uint                  `json:"max_completion_tokens,omitempty"`
+			Temperature         *float64              `json:"temperature,omitempty"`
+			TopP                float64               `json:"top_p,omitempty"`
+			Tools               []dto.ToolCallRequest `json:"tools,omitempty"`
+			ToolChoice          any                   `json:"tool_choice,omitempty"`
+		}{
+			Messages:            request.Messages[:i],
+			ResponseFormat:      request.ResponseFormat,
+			MaxTokens:           request.MaxTokens,
+			MaxCompletionTokens: request.MaxCompletionTokens,
+			Temperature:         request.Temperature,
+			TopP:                request.TopP,
+			Tools:               request.Tools,
+			ToolChoice:          request.ToolChoice,
+		})
+		if err != nil {
+			return "", ""
+		}
+		return request.Messages[i].StringContent(), hex.EncodeToString(common.Sha256Raw(context))
+	}
+	return "", ""
+}
+
+// newSemanticCacheLookup 返回 nil 表示本次请求不走语义缓存
+func newSemanticCacheLookup(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) *semanticCacheLookup {
+	cacheSetting := operation_setting.GetSemanticCacheSetting()
+	if info.RelayMode != relayconstant.RelayModeChatCompletions || info.IsPlayground {
+		return nil
+	}
+	if !cacheSetting.IsEnabledFor(info.UsingGroup, info.OriginModelName) {
+		return nil
+	}
+	if service.IsResponseCacheOptOut(c.Request.Header.Get(service.ResponseCacheHeader)) {
+		return nil
+	}
+	// 工具调用与多结果请求的返回与上下文强相关，不做语义复用
+	if request.N > 1 || len(request.Tools) > 0 || len(request.Functions) > 0 {
+		return nil
+	}
+	prompt, context := getSemanticCachePrompt(request)
+	if variant := getResponseCacheVariant(info, request); variant != "" {
+		context += "|" + variant
+	}
+	if prompt == "" {
+		return nil
+	}
+	if cacheSetting.MaxPromptLength > 0 && len(prompt) > cacheSetting.MaxPromptLength {
+		return nil
+	}
+	return &semanticCacheLookup{
+		namespace: service.SemanticCacheNamespace(info.UsingGroup, info.UpstreamModelName, request.Stream, context),
+		prompt:    prompt,
+	}
+}
+
+// lookup 对检索文本做 embedding 并在索引中查找足够相似的历史请求
+func (s *semanticCacheLookup) lookup(c *gin.Context, info *relaycommon.RelayInfo) (*service.ResponseCacheEntry, bool) {
+	vector, err := getPromptEmbedding(c, info, s.prompt)
+	if err != nil {
+		// 无法计算向量时（包括令牌无权使用 embedding 模型）跳过语义缓存
+		common.LogError(c, "semantic cache embedding failed: "+err.Error())
+		return nil, false
+	}
+	info.SemanticCacheState = SemanticCacheStateMiss
+	s.vector = vector
+	index := service.GetSemanticIndex()
+	threshold := operation_setting.GetSemanticCacheSetting().GetThreshold(info.OriginModelName)
+	key, score, ok := index.Search(s.namespace, vector, threshold)
+	if !ok {
+		return nil, false
+	}
+	entry, ok := service.GetResponseCache(key)
+	if !ok {
+		// 响应已过期，同步清理索引
+		index.Remove(s.namespace, key)
+		return nil, false
+	}
+	common.LogInfo(c, fmt.Sprintf("semantic cache hit, score %.4f", score))
+	info.SemanticCacheState = SemanticCacheStateHit
+	info.SemanticCacheScore = score
+	return entry, true
+}
+
+func (s *semanticCacheLookup) save(entry *service.ResponseCacheEntry) {
+	if len(s.vector) == 0 || entry == nil {
+		return
+	}
+	hash := common.Sha256Raw([]byte(s.namespace + "|" + s.prompt))
+	key := semanticCacheKeyPrefix + hex.EncodeToString(hash)
+	service.SetResponseCache(key, entry)
+	service.GetSemanticIndex().Add(s.namespace, key, s.vector)
+}
+
+// getPromptEmbedding 通过配置的 embedding 模型在现有渠道上计算文本向量，按正常 embedding 请求计费，令牌限制了可用模型时同样生效
+func getPromptEmbedding(c *gin.Context, info *relaycommon.RelayInfo, text string) ([]float64, error) {
+	embeddingModel := operation_setting.GetSemanticCacheSetting().EmbeddingModel
+	if apiErr := checkTokenModelLimit(c, embeddingModel); apiErr != nil {
+		return nil, apiErr
+	}
+	body, err := common.Marshal(dto.EmbeddingRequest{
+		Model: embeddingModel,
+		Input: text,
+	})
+	if err != nil {
+		return nil, err
+	}
+	ctx, recorder, err := newInternalContext(c, "/v1/embeddings", "application/json", body)
+	if err != nil {
+		return nil, err
+	}
+	defer model.ReleaseChannelSlot(ctx)
+	if apiErr := setupInternalChannel(ctx, info.UsingGroup, embeddingModel); apiErr != nil {
+		return nil, apiErr
+	}
+	if apiErr := EmbeddingHelper(ctx); apiErr != nil {
+		return nil, apiErr
+	}
+	var embeddingResponse dto.OpenAIEmbeddingResponse
+	if err := common.Unmarshal(recorder.Body.Bytes(), &embeddingResponse); err != nil {
+		return nil, err
+	}
+	if len(embeddingResponse.Data) == 0 || len(embeddingResponse.Data[0].Embedding) == 0 {
+		return nil, errors.New("empty embedding response")
+	}
+	return embeddingResponse.Data[0].Embedding, nil
+}
@@ -0,0 +1,60 @@
+package relay
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"one-api/common"
+	"one-api/constant"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetSemanticCachePromptContext(t *testing.T) {
+	temperature := 0.2
+	newRequest := func() *dto.GeneralOpenAIRequest {
+		return &dto.GeneralOpenAIRequest{
+			Messages: []dto.Message{
+				{Role: "system", Content: "you are helpful"},
+				{Role: "user", Content: "hello"},
+			},
+		}
+	}
+	prompt, base := getSemanticCachePrompt(newRequest())
+	if prompt != "hello" || base == "" {
+		t.Fatalf("got prompt %q context %q", prompt, base)
+	}
+	cases := []struct {
+		name   string
+		modify func(r *dto.GeneralOpenAIRequest)
+	}{
+		{"max_tokens", func(r *dto.GeneralOpenAIRequest) { r.MaxTokens = 16 }},
+		{"max_completion_tokens", func(r *dto.GeneralOpenAIRequest) { r.MaxCompletionTokens = 16 }},
+		{"temperature", func(r *dto.GeneralOpenAIRequest) { r.Temperature = &temperature }},
+		{"tools", func(r *dto.GeneralOpenAIRequest) {
+			r.Tools = []dto.ToolCallRequest{{Type: "function", Function: dto.FunctionRequest{Name: "get_weather"}}}
+		}},
+		{"system prompt", func(r *dto.GeneralOpenAIRequest) { r.Messages[0].Content = "answer briefly" }},
+	}
+	for _, c := range cases {
+		request := newRequest()
+		c.modify(request)
+		if _, context := getSemanticCachePrompt(request); context == base {
+			t.Errorf("%s: context should differ", c.name)
+		}
+	}
+}
+
+func TestGetPromptEmbeddingRespectsTokenModelLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	common.SetContextKey(c, constant.ContextKeyTokenModelLimitEnabled, true)
+	common.SetContextKey(c, constant.ContextKeyTokenModelLimit, map[string]bool{"gpt-4o": true})
+	if _, err := getPromptEmbedding(c, &relaycommon.RelayInfo{}, "hello"); err == nil {
+		t.Fatal("expected embedding to be rejected by token model limit")
+	}
+}
@@ -0,0 +1,286 @@
+package relay
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/relay/channel"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/service"
+	"one-api/setting/operation_setting"
+	"one-api/types"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+const (
+	// StructuredOutputEmulatedHeader 响应头，标记本次请求的 json_schema 由提示词模拟
+	StructuredOutputEmulatedHeader = "X-Structured-Output-Emulated"
+
+	structuredOutputSystemPrompt = "Respond only with a single JSON value that conforms to the following JSON Schema. " +
+		"Do not wrap it in a markdown code block and do not add any explanation.\nJSON Schema:\n%s"
+	structuredOutputRetryPrompt = "Your previous reply does not conform to the JSON Schema:\n%s\n" +
+		"Reply again with only the corrected JSON value."
+	structuredOutputSchemaUrl = "response_format.json"
+)
+
+// structuredOutput response_format 为 json_schema 时的响应校验
+type structuredOutput struct {
+	schema *jsonschema.Schema
+}
+
+// structuredOutputError 响应不符合 JSON Schema
+type structuredOutputError struct {
+	content string
+	reasons []string
+}
+
+func (e *structuredOutputError) Error() string {
+	return "response does not conform to the json schema: " + strings.Join(e.reasons, "; ")
+}
+
+// newStructuredOutput 渠道模拟结构化输出或开启了校验时编译 JSON Schema，模拟时将 schema 注入系统提示词
+func newStructuredOutput(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) (*structuredOutput, error) {
+	if info.RelayMode != relayconstant.RelayModeChatCompletions || request.ResponseFormat == nil ||
+		request.ResponseFormat.Type != "json_schema" || request.ResponseFormat.JsonSchema == nil || request.ResponseFormat.JsonSchema.Schema == nil {
+		return nil, nil
+	}
+	emulated := info.ChannelSetting.StructuredOutputEmulation
+	if !emulated && !operation_setting.GetStructuredOutputSetting().ValidationEnabled {
+		return nil, nil
+	}
+	// 流式响应边生成边返回，网关无法在返回前校验；模拟的渠道只能靠网关保证严格 schema，因此不支持流式，
+	// 原生支持的渠道流式时由上游保证，网关跳过校验
+	if request.Stream && emulated && request.ResponseFormat.JsonSchema.Strict == true {
+		return nil, errors.New("stream is not supported for strict json_schema response_format on this channel")
+	}
+	schemaJson, err := common.Marshal(request.ResponseFormat.JsonSchema.Schema)
+	if err != nil {
+		return nil, err
+	}
+	document, err := jsonschema.UnmarshalJSON(bytes.NewReader(schemaJson))
+	if err != nil {
+		return nil, fmt.Errorf("invalid json schema: %s", err.Error())
+	}
+	compiler := jsonschema.NewCompiler()
+	if err = compiler.AddResource(structuredOutputSchemaUrl, document); err != nil {
+		return nil, fmt.Errorf("invalid json schema: %s", err.Error())
+	}
+	schema, err := compiler.Compile(structuredOutputSchemaUrl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid json schema: %s", err.Error())
+	}
+
+	if emulated {
+		systemPrompt := fmt.Sprintf(structuredOutputSystemPrompt, string(schemaJson))
+		if len(request.Messages) > 0 && request.Messages[0].Role == "system" && request.Messages[0].IsStringContent() {
+			request.Messages[0].SetStringContent(request.Messages[0].StringContent() + "\n\n" + systemPrompt)
+		} else {
+			request.Messages = append([]dto.Message{{Role: "system", Content: systemPrompt}}, request.Messages...)
+		}
+		request.ResponseFormat = nil
+		c.Header(StructuredOutputEmulatedHeader, "true")
+	}
+	return &structuredOutput{schema: schema}, nil
+}
+
+// validate 校验文本是否为符合 schema 的 JSON
+func (s *structuredOutput) validate(content string) *structuredOutputError {
+	instance, err := jsonschema.UnmarshalJSON(strings.NewReader(content))
+	if err != nil {
+		return &structuredOutputError{content: content, reasons: []string{"invalid json: " + err.Error()}}
+	}
+	err = s.schema.Validate(instance)
+	if err == nil {
+		return nil
+	}
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return &structuredOutputError{content: content, reasons: []string{err.Error()}}
+	}
+	reasons := make([]string, 0)
+	for _, line := range strings.Split(validationErr.Error(), "\n") {
+		if reason, ok := strings.CutPrefix(strings.TrimSpace(line), "- "); ok {
+			reasons = append(reasons, reason)
+		}
+	}
+	if len(reasons) == 0 {
+		reasons = append(reasons, validationErr.Error())
+	}
+	return &structuredOutputError{content: content, reasons: reasons}
+}
+
+// repairStructuredOutput 去除 markdown 代码块与 JSON 前后的多余文本
+func repairStructuredOutput(content string) string {
+	repaired := strings.TrimSpace(content)
+	if strings.HasPrefix(repaired, "```") {
+		if idx := strings.Index(repaired, "\n"); idx >= 0 {
+			repaired = repaired[idx+1:]
+		}
+		repaired = strings.TrimSuffix(strings.TrimSpace(repaired), "```")
+	}
+	start := strings.IndexAny(repaired, "{[")
+	end := strings.LastIndexAny(repaired, "}]")
+	if start >= 0 && end > start {
+		repaired = repaired[start : end+1]
+	}
+	return strings.TrimSpace(repaired)
+}
+
+// check 校验非流式响应中的每个 choice，返回修复后的响应体
+func (s *structuredOutput) check(body []byte, repair bool) ([]byte, *structuredOutputError) {
+	var response dto.OpenAITextResponse
+	if err := common.Unmarshal(body, &response); err != nil || len(response.Choices) == 0 {
+		return body, nil
+	}
+	changed := false
+	for i := range response.Choices {
+		message := &response.Choices[i].Message
+		if !message.IsStringContent() {
+			continue
+		}
+		content := message.StringContent()
+		if content == "" && len(message.ParseToolCalls()) > 0 {
+			continue
+		}
+		validationErr := s.validate(content)
+		if validationErr == nil {
+			continue
+		}
+		if repair {
+			if repaired := repairStructuredOutput(content); repaired != content && s.validate(repaired) == nil {
+				message.SetStringContent(repaired)
+				changed = true
+				continue
+			}
+		}
+		return body, validationErr
+	}
+	if changed {
+		if repaired, err := common.Marshal(&response); err == nil {
+			return repaired, nil
+		}
+	}
+	return body, nil
+}
+
+// structuredOutputWriter 缓存非流式响应，校验通过后再写给客户端
+type structuredOutputWriter struct {
+	gin.ResponseWriter
+	output        *structuredOutput
+	toolEmulation *toolCallEmulation // 同时模拟工具调用时，重试的响应同样需要转换
+	statusCode    int
+	buffer        bytes.Buffer
+}
+
+func startStructuredOutputValidation(c *gin.Context, output *structuredOutput, toolEmulation *toolCallEmulation) *structuredOutputWriter {
+	writer := &structuredOutputWriter{
+		ResponseWriter: c.Writer,
+		output:         output,
+		toolEmulation:  toolEmulation,
+		statusCode:     http.StatusOK,
+	}
+	c.Writer = writer
+	return writer
+}
+
+func (w *structuredOutputWriter) WriteHeader(code int) {
+	w.statusCode = code
+}
+
+func (w *structuredOutputWriter) WriteHeaderNow() {
+}
+
+func (w *structuredOutputWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *structuredOutputWriter) Write(data []byte) (int, error) {
+	return w.buffer.Write(data)
+}
+
+// finish 校验缓存的响应，按配置修复或重试一次，返回重试产生的额外用量，必须在 DoResponse 成功后调用
+func (w *structuredOutputWriter) finish(c *gin.Context, info *relaycommon.RelayInfo, adaptor channel.Adaptor, request *dto.GeneralOpenAIRequest) (*dto.Usage, *types.NewAPIError) {
+	defer func() {
+		c.Writer = w.ResponseWriter
+	}()
+	setting := operation_setting.GetStructuredOutputSetting()
+	body, validationErr := w.output.check(w.buffer.Bytes(), setting.RepairEnabled)
+	var retryUsage *dto.Usage
+	if validationErr != nil && setting.RetryEnabled {
+		var apiErr *types.NewAPIError
+		retryUsage, apiErr = w.retry(c, info, adaptor, request, validationErr)
+		if apiErr != nil {
+			common.LogError(c, "structured output retry failed: "+apiErr.Error())
+		} else {
+			body, validationErr = w.output.check(w.buffer.Bytes(), setting.RepairEnabled)
+		}
+	}
+	if validationErr != nil {
+		return retryUsage, types.NewErrorWithStatusCode(validationErr, types.ErrorCodeStructuredOutputInvalid, http.StatusBadGateway)
+	}
+	w.ResponseWriter.Header().Set("Content-Length", fmt.Sprintf("%d", len(body)))
+	w.ResponseWriter.WriteHeader(w.statusCode)
+	_, _ = w.ResponseWriter.Write(body)
+	return retryUsage, nil
+}
+
+// retry 将不符合的回复与错误信息追加到对话中重新请求一次，响应写入缓存
+func (w *structuredOutputWriter) retry(c *gin.Context, info *relaycommon.RelayInfo, adaptor channel.Adaptor, request *dto.GeneralOpenAIRequest, validationErr *structuredOutputError) (*dto.Usage, *types.NewAPIError) {
+	retryRequest := *request
+	retryRequest.Messages = append(append([]dto.Message{}, request.Messages...),
+		dto.Message{Role: "assistant", Content: validationErr.content},
+		dto.Message{Role: "user", Content: fmt.Sprintf(structuredOutputRetryPrompt, strings.Join(validationErr.reasons, "\n"))},
+	)
+	requestBody, apiErr := convertTextRequestBody(c, info, adaptor, &retryRequest)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	resp, err := adaptor.DoRequest(c, info, requestBody)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeDoRequestFailed)
+	}
+	httpResp, _ := resp.(*http.Response)
+	if httpResp != nil && httpResp.StatusCode != http.StatusOK {
+		return nil, service.RelayErrorHandler(httpResp, false)
+	}
+	w.buffer.Reset()
+	w.statusCode = http.StatusOK
+	// 首次响应的工具调用模拟已经结束并恢复了 c.Writer，重试时重新安装，保证写入缓存的响应经过相同的转换
+	var toolEmulationWriter *toolCallEmulationWriter
+	if w.toolEmulation != nil {
+		toolEmulationWriter = startToolCallEmulation(c, info, w.toolEmulation)
+	}
+	usage, apiErr := adaptor.DoResponse(c, httpResp, info)
+	if toolEmulationWriter != nil {
+		if apiErr == nil {
+			toolEmulationWriter.finish(c)
+		} else {
+			c.Writer = toolEmulationWriter.ResponseWriter
+		}
+	}
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	retryUsage, _ := usage.(*dto.Usage)
+	return retryUsage, nil
+}
+
+// addUsage 累加重试请求的用量，用于计费
+func addUsage(usage *dto.Usage, extra *dto.Usage) *dto.Usage {
+	if usage == nil {
+		return extra
+	}
+	usage.PromptTokens += extra.PromptTokens
+	usage.CompletionTokens += extra.CompletionTokens
+	usage.TotalTokens += extra.TotalTokens
+	usage.PromptTokensDetails.CachedTokens += extra.PromptTokensDetails.CachedTokens
+	usage.CompletionTokenDetails.ReasoningTokens += extra.CompletionTokenDetails.ReasoningTokens
+	return usage
+}
@@ -0,0 +1,179 @@
+package relay
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"one-api/common"
+	"one-api/dto"
+	"one-api/relay/channel"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/setting/operation_setting"
+	"one-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestStructuredOutput(t *testing.T, c *gin.Context, info *relaycommon.RelayInfo) (*structuredOutput, *dto.GeneralOpenAIRequest) {
+	t.Helper()
+	request := &dto.GeneralOpenAIRequest{
+		Messages: []dto.Message{{Role: "user", Content: "weather?"}},
+		ResponseFormat: &dto.ResponseFormat{
+			Type: "json_schema",
+			JsonSchema: &dto.FormatJsonSchema{
+				Name: "weather",
+				Schema: map[string]any{
+					"type":                 "object",
+					"properties":           map[string]any{"city": map[string]any{"type": "string"}},
+					"required":             []string{"city"},
+					"additionalProperties": false,
+				},
+			},
+		},
+	}
+	output, err := newStructuredOutput(c, info, request)
+	if err != nil || output == nil {
+		t.Fatalf("new structured output failed: %v", err)
+	}
+	return output, request
+}
+
+func textResponseBody(contents ...string) []byte {
+	response := dto.OpenAITextResponse{Object: "chat.completion"}
+	for i, content := range contents {
+		choice := dto.OpenAITextResponseChoice{Index: i, FinishReason: "stop"}
+		choice.Message.Role = "assistant"
+		choice.Message.SetStringContent(content)
+		response.Choices = append(response.Choices, choice)
+	}
+	body, _ := common.Marshal(&response)
+	return body
+}
+
+func TestStructuredOutputCheck(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	info := &relaycommon.RelayInfo{RelayMode: relayconstant.RelayModeChatCompletions}
+	info.ChannelSetting.StructuredOutputEmulation = true
+	output, _ := newTestStructuredOutput(t, c, info)
+
+	cases := []struct {
+		name        string
+		contents    []string
+		repair      bool
+		wantErr     bool
+		wantContent string
+	}{
+		{"valid", []string{`{"city":"Paris"}`}, false, false, `{"city":"Paris"}`},
+		{"fenced without repair", []string{"```json\n{\"city\":\"Paris\"}\n```"}, false, true, ""},
+		{"fenced with repair", []string{"```json\n{\"city\":\"Paris\"}\n```"}, true, false, `{"city":"Paris"}`},
+		{"surrounding text with repair", []string{`Here you go: {"city":"Paris"} hope it helps`}, true, false, `{"city":"Paris"}`},
+		{"wrong type", []string{`{"city":1}`}, true, true, ""},
+		{"missing required field", []string{`{}`}, true, true, ""},
+		{"second choice invalid", []string{`{"city":"Paris"}`, `not json`}, true, true, ""},
+	}
+	for _, tc := range cases {
+		body, validationErr := output.check(textResponseBody(tc.contents...), tc.repair)
+		if (validationErr != nil) != tc.wantErr {
+			t.Errorf("%s: error = %v, want error %v", tc.name, validationErr, tc.wantErr)
+			continue
+		}
+		if tc.wantErr {
+			continue
+		}
+		var response dto.OpenAITextResponse
+		if err := common.Unmarshal(body, &response); err != nil {
+			t.Fatalf("%s: invalid body: %s", tc.name, err)
+		}
+		if got := response.Choices[0].Message.StringContent(); got != tc.wantContent {
+			t.Errorf("%s: content = %q, want %q", tc.name, got, tc.wantContent)
+		}
+	}
+}
+
+// fakeRetryAdaptor 重试时返回固定的上游响应
+type fakeRetryAdaptor struct {
+	channel.Adaptor
+	body     []byte
+	requests int
+}
+
+func (a *fakeRetryAdaptor) ConvertOpenAIRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) (any, error) {
+	return request, nil
+}
+
+func (a *fakeRetryAdaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, requestBody io.Reader) (any, error) {
+	a.requests++
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(a.body))}, nil
+}
+
+func (a *fakeRetryAdaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (any, *types.NewAPIError) {
+	body, _ := io.ReadAll(resp.Body)
+	c.Writer.WriteHeader(http.StatusOK)
+	_, _ = c.Writer.Write(body)
+	return &dto.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}, nil
+}
+
+func TestStructuredOutputRetry(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	outputSetting := operation_setting.GetStructuredOutputSetting()
+	oldSetting := *outputSetting
+	defer func() { *outputSetting = oldSetting }()
+	outputSetting.RepairEnabled = true
+	outputSetting.RetryEnabled = true
+
+	cases := []struct {
+		name          string
+		toolEmulation bool
+		retryContent  string
+		wantErr       bool
+		wantInBody    string
+	}{
+		{"retry fixes reply", false, `{"city":"Paris"}`, false, `"content":"{\"city\":\"Paris\"}"`},
+		{"retry still invalid", false, `still not json`, true, ""},
+		// 重试的响应同样经过工具调用模拟的转换，工具调用不参与 schema 校验
+		{"retry with tool emulation", true, "<tool_calls>[{\"name\": \"get_weather\", \"arguments\": {\"city\": \"Paris\"}}]</tool_calls>", false, `"finish_reason":"tool_calls"`},
+	}
+	for _, tc := range cases {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		info := &relaycommon.RelayInfo{RelayMode: relayconstant.RelayModeChatCompletions}
+		info.ChannelSetting.StructuredOutputEmulation = true
+		output, request := newTestStructuredOutput(t, c, info)
+		var emulation *toolCallEmulation
+		if tc.toolEmulation {
+			emulation = newTestToolCallEmulation()
+		}
+		adaptor := &fakeRetryAdaptor{body: textResponseBody(tc.retryContent)}
+
+		outputWriter := startStructuredOutputValidation(c, output, emulation)
+		var toolWriter *toolCallEmulationWriter
+		if emulation != nil {
+			toolWriter = startToolCallEmulation(c, info, emulation)
+		}
+		_, _ = c.Writer.Write(textResponseBody("I cannot answer in JSON"))
+		if toolWriter != nil {
+			toolWriter.finish(c)
+		}
+		retryUsage, apiErr := outputWriter.finish(c, info, adaptor, request)
+
+		if adaptor.requests != 1 || retryUsage == nil || retryUsage.TotalTokens != 15 {
+			t.Errorf("%s: requests %d usage %+v, want one retry with usage", tc.name, adaptor.requests, retryUsage)
+		}
+		if (apiErr != nil) != tc.wantErr {
+			t.Errorf("%s: error = %v, want error %v", tc.name, apiErr, tc.wantErr)
+			continue
+		}
+		if c.Writer != outputWriter.ResponseWriter {
+			t.Errorf("%s: writer not restored", tc.name)
+		}
+		if !tc.wantErr && !strings.Contains(recorder.Body.String(), tc.wantInBody) {
+			t.Errorf("%s: body %s does not contain %s", tc.name, recorder.Body.String(), tc.wantInBody)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package relay
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/middleware"
+	"one-api/model"
+	relaycommon "one-api/relay/common"
+	"one-api/service"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 选择其他渠道时的最多尝试次数
+const resubmitChannelAttempts = 5
+
+// ResubmitTask 以提交时记录的原始请求将失败任务重新提交到同平台的其他渠道，成功后原任务改为跟踪新的上游任务，额度不重复扣除
+func ResubmitTask(ctx context.Context, task *model.Task) error {
+	properties := task.Properties
+	if properties.Request == "" || properties.RequestPath == "" {
+		return errors.New("original request is not recorded")
+	}
+	adaptor := GetTaskAdaptor(task.Platform)
+	if adaptor == nil {
+		return fmt.Errorf("invalid api platform: %s", task.Platform)
+	}
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, properties.RequestPath, bytes.NewReader([]byte(properties.Request)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.Request = req
+	if properties.Action != "" {
+		c.Params = gin.Params{{Key: "action", Value: properties.Action}}
+		c.Set("action", properties.Action)
+	}
+	common.SetContextKey(c, constant.ContextKeyUserId, task.UserId)
+	common.SetContextKey(c, constant.ContextKeyUsingGroup, properties.Group)
+	common.SetContextKey(c, constant.ContextKeyRequestStartTime, time.Now())
+	c.Set("platform", string(task.Platform))
+	defer model.ReleaseChannelSlot(c)
+
+	channel, err := getResubmitChannel(c, properties.Group, properties.Model, task.ChannelId)
+	if err != nil {
+		return err
+	}
+	if apiErr := middleware.SetupContextForSelectedChannel(c, channel, properties.Model); apiErr != nil {
+		return apiErr
+	}
+
+	info := relaycommon.GenTaskRelayInfo(c)
+	adaptor.Init(info)
+	if taskErr := adaptor.ValidateRequestAndSetAction(c, info); taskErr != nil {
+		return fmt.Errorf("validate request failed: %s", taskErr.Message)
+	}
+	requestBody, err := adaptor.BuildRequestBody(c, info)
+	if err != nil {
+		return err
+	}
+	resp, err := adaptor.DoRequest(c, info, requestBody)
+	if err != nil {
+		return err
+	}
+	if resp != nil && resp.StatusCode != http.StatusOK {
+		responseBody, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		return fmt.Errorf("upstream returned status %d: %s", resp.StatusCode, string(responseBody))
+	}
+	taskID, taskData, taskErr := adaptor.DoResponse(c, resp, info)
+	if taskErr != nil {
+		return fmt.Errorf("submit task failed: %s", taskErr.Message)
+	}
+
+	if task.ClientTaskID == "" {
+		task.ClientTaskID = task.TaskID
+	}
+	task.TaskID = taskID
+	task.ChannelId = channel.Id
+	task.CallbackNonce = service.GetTaskCallbackNonce(c)
+	task.Status = model.TaskStatusSubmitted
+	task.Progress = "10%"
+	task.FailReason = ""
+	task.StartTime = 0
+	task.FinishTime = 0
+	task.Data = taskData
+	task.Properties.RetryCount++
+	// 失败状态已由处理方写入，重新提交只接续该状态
+	updated, err := task.UpdateWithStatus(model.TaskStatusFailure)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return errors.New("task status changed during resubmission")
+	}
+	return nil
+}
+
+func getResubmitChannel(c *gin.Context, group string, modelName string, excludeChannelId int) (*model.Channel, error) {
+	for i := 0; i < resubmitChannelAttempts; i++ {
+		// 优先在同优先级中随机选择，之后逐步放宽到低优先级渠道
+		channel, _, err := model.CacheGetRandomSatisfiedChannel(c, group, modelName, i)
+		if err != nil {
+			return nil, err
+		}
+		if channel != nil && channel.Id != excludeChannelId {
+			return channel, nil
+		}
+	}
+	return nil, fmt.Errorf("no other channel available for model %s", modelName)
+}
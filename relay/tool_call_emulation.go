@@ -0,0 +1,490 @@
+package relay
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/dto"
+	relaycommon "one-api/relay/common"
+	relayconstant "one-api/relay/constant"
+	"one-api/setting/model_setting"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// ToolCallsEmulatedHeader 响应头，标记本次请求的工具调用由提示词模拟
+	ToolCallsEmulatedHeader = "X-Tool-Calls-Emulated"
+
+	toolCallsStartTag = "<tool_calls>"
+	toolCallsEndTag   = "</tool_calls>"
+
+	toolCallsSystemPrompt = "You have access to the following tools, described as JSON Schema:\n%s\n\n" +
+		"To call tools, reply with a single block in exactly this format and nothing after it:\n" +
+		toolCallsStartTag + "\n[{\"name\": \"<tool name>\", \"arguments\": {<arguments as a JSON object>}}]\n" + toolCallsEndTag + "\n" +
+		"The block must contain a valid JSON array. Results of tool calls are sent back to you in " +
+		"<tool_result> blocks; use them to answer the user.\n%s"
+	toolChoiceAutoPrompt      = "Call tools only when they are needed; otherwise answer the user directly without the block."
+	toolChoiceRequiredPrompt  = "You must call at least one tool in this reply."
+	toolChoiceFunctionPrompt  = "You must call the tool %q in this reply."
+	toolChoiceSingleCallTail  = " Call at most one tool at a time."
+	toolResultMessageTemplate = "<tool_result name=%q id=%q>\n%s\n</tool_result>"
+)
+
+// toolCallEmulation 工具调用模拟的上下文
+type toolCallEmulation struct {
+	toolNames map[string]bool
+}
+
+// shouldEmulateToolCalls 渠道开启工具调用模拟且请求包含工具或工具调用历史时，改用提示词模拟
+func shouldEmulateToolCalls(info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) bool {
+	if !info.ChannelSetting.ToolCallEmulation || info.RelayMode != relayconstant.RelayModeChatCompletions {
+		return false
+	}
+	// 透传请求体时无法改写请求
+	if model_setting.GetGlobalSettings().PassThroughRequestEnabled {
+		return false
+	}
+	if len(request.Tools) > 0 {
+		return true
+	}
+	for _, message := range request.Messages {
+		if message.Role == "tool" || len(message.ParseToolCalls()) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// convertToolsToPrompt 将工具定义注入系统提示词，并把历史中的工具调用与结果改写为文本
+func convertToolsToPrompt(request *dto.GeneralOpenAIRequest) (*toolCallEmulation, error) {
+	emulation := &toolCallEmulation{toolNames: make(map[string]bool)}
+	tools := request.Tools
+	choicePrompt := toolChoiceAutoPrompt
+	switch choice := request.ToolChoice.(type) {
+	case string:
+		switch choice {
+		case "none":
+			tools = nil
+		case "required":
+			choicePrompt = toolChoiceRequiredPrompt
+		}
+	case map[string]any:
+		if function, ok := choice["function"].(map[string]any); ok {
+			name, _ := function["name"].(string)
+			forced := make([]dto.ToolCallRequest, 0, 1)
+			for _, tool := range tools {
+				if tool.Function.Name == name {
+					forced = append(forced, tool)
+				}
+			}
+			if len(forced) == 0 {
+				return nil, fmt.Errorf("tool_choice function %q is not in tools", name)
+			}
+			tools = forced
+			choicePrompt = fmt.Sprintf(toolChoiceFunctionPrompt, name)
+		}
+	}
+	if request.ParallelTooCalls != nil && !*request.ParallelTooCalls {
+		choicePrompt += toolChoiceSingleCallTail
+	}
+
+	messages := make([]dto.Message, 0, len(request.Messages)+1)
+	original := request.Messages
+	if len(tools) > 0 {
+		definitions := make([]dto.FunctionRequest, 0, len(tools))
+		for _, tool := range tools {
+			emulation.toolNames[tool.Function.Name] = true
+			definitions = append(definitions, tool.Function)
+		}
+		definitionsJson, err := common.Marshal(definitions)
+		if err != nil {
+			return nil, err
+		}
+		systemPrompt := fmt.Sprintf(toolCallsSystemPrompt, string(definitionsJson), choicePrompt)
+		// 部分模型只接受一条系统消息，与已有的系统提示词合并
+		if len(original) > 0 && original[0].Role == "system" && original[0].IsStringContent() {
+			systemPrompt = original[0].StringContent() + "\n\n" + systemPrompt
+			original = original[1:]
+		}
+		messages = append(messages, dto.Message{
+			Role:    "system",
+			Content: systemPrompt,
+		})
+	}
+
+	toolNames := make(map[string]string)
+	for _, message := range original {
+		toolCalls := message.ParseToolCalls()
+		switch {
+		case message.Role == "tool":
+			rewritten := dto.Message{Role: "user"}
+			rewritten.SetStringContent(fmt.Sprintf(toolResultMessageTemplate, toolNames[message.ToolCallId], message.ToolCallId, message.StringContent()))
+			messages = append(messages, rewritten)
+		case len(toolCalls) > 0:
+			calls := make([]map[string]any, 0, len(toolCalls))
+			for _, toolCall := range toolCalls {
+				toolNames[toolCall.ID] = toolCall.Function.Name
+				var arguments any = map[string]any{}
+				if toolCall.Function.Arguments != "" {
+					if err := common.UnmarshalJsonStr(toolCall.Function.Arguments, &arguments); err != nil {
+						arguments = toolCall.Function.Arguments
+					}
+				}
+				calls = append(calls, map[string]any{"name": toolCall.Function.Name, "arguments": arguments})
+			}
+			callsJson, err := common.Marshal(calls)
+			if err != nil {
+				return nil, err
+			}
+			content := strings.TrimSpace(message.StringContent())
+			if content != "" {
+				content += "\n"
+			}
+			rewritten := dto.Message{Role: message.Role}
+			rewritten.SetStringContent(content + toolCallsStartTag + "\n" + string(callsJson) + "\n" + toolCallsEndTag)
+			messages = append(messages, rewritten)
+		default:
+			messages = append(messages, message)
+		}
+	}
+
+	request.Messages = messages
+	request.Tools = nil
+	request.ToolChoice = nil
+	request.ParallelTooCalls = nil
+	return emulation, nil
+}
+
+type emulatedToolCall struct {
+	Name      string `json:"name"`
+	Arguments any    `json:"arguments"`
+}
+
+// parseEmulatedToolCalls 从模型输出中解析工具调用，返回工具调用块之前的文本
+func (e *toolCallEmulation) parseEmulatedToolCalls(content string) (string, []dto.ToolCallResponse) {
+	text, block := content, ""
+	if idx := strings.Index(content, toolCallsStartTag); idx >= 0 {
+		text = content[:idx]
+		block = content[idx+len(toolCallsStartTag):]
+		if end := strings.Index(block, toolCallsEndTag); end >= 0 {
+			block = block[:end]
+		}
+	} else {
+		// 部分模型会省略标签直接输出 JSON
+		trimmed := strings.TrimSpace(content)
+		if !strings.HasPrefix(trimmed, "[") && !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "```") {
+			return content, nil
+		}
+		text, block = "", trimmed
+	}
+	block = strings.TrimSpace(block)
+	block = strings.TrimPrefix(block, "```json")
+	block = strings.TrimPrefix(block, "```")
+	block = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(block), "```"))
+
+	var calls []emulatedToolCall
+	if err := common.UnmarshalJsonStr(block, &calls); err != nil {
+		var call emulatedToolCall
+		if err = common.UnmarshalJsonStr(block, &call); err != nil {
+			return content, nil
+		}
+		calls = []emulatedToolCall{call}
+	}
+	toolCalls := make([]dto.ToolCallResponse, 0, len(calls))
+	for _, call := range calls {
+		if !e.toolNames[call.Name] {
+			continue
+		}
+		arguments, ok := call.Arguments.(string)
+		if !ok {
+			if call.Arguments == nil {
+				call.Arguments = map[string]any{}
+			}
+			argumentsJson, err := common.Marshal(call.Arguments)
+			if err != nil {
+				continue
+			}
+			arguments = string(argumentsJson)
+		}
+		toolCalls = append(toolCalls, dto.ToolCallResponse{
+			ID:   fmt.Sprintf("call_%s", common.GetUUID()),
+			Type: "function",
+			Function: dto.FunctionResponse{
+				Name:      call.Name,
+				Arguments: arguments,
+			},
+		})
+	}
+	if len(toolCalls) == 0 {
+		return content, nil
+	}
+	return strings.TrimSpace(text), toolCalls
+}
+
+// toolCallStreamState 流式响应中单个 choice 的解析状态
+type toolCallStreamState struct {
+	pending string // 可能是工具调用块开头、暂未发送的文本
+	started bool   // 是否已经发送过非空白文本
+	inBlock bool
+	block   strings.Builder
+}
+
+// feed 处理一段增量文本，返回可以立即发送给客户端的部分
+func (s *toolCallStreamState) feed(content string) string {
+	if s.inBlock {
+		s.block.WriteString(content)
+		return ""
+	}
+	text := s.pending + content
+	if !s.started {
+		// 与非流式解析一致，开头省略标签直接输出的 JSON 也按工具调用块缓存，解析失败时再原样发送
+		trimmed := strings.TrimLeftFunc(text, unicode.IsSpace)
+		switch {
+		case strings.HasPrefix(trimmed, "[") || strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "```"):
+			s.inBlock = true
+			s.pending = ""
+			s.block.WriteString(text)
+			return ""
+		case strings.HasPrefix("```", trimmed):
+			s.pending = text
+			return ""
+		}
+		s.started = true
+	}
+	if idx := strings.Index(text, toolCallsStartTag); idx >= 0 {
+		s.inBlock = true
+		s.pending = ""
+		s.block.WriteString(toolCallsStartTag + text[idx+len(toolCallsStartTag):])
+		return text[:idx]
+	}
+	keep := 0
+	for k := len(toolCallsStartTag) - 1; k > 0; k-- {
+		if strings.HasSuffix(text, toolCallsStartTag[:k]) {
+			keep = k
+			break
+		}
+	}
+	s.pending = text[len(text)-keep:]
+	return text[:len(text)-keep]
+}
+
+// toolCallEmulationWriter 从上游返回的文本中解析出 tool_calls 后写给客户端
+type toolCallEmulationWriter struct {
+	gin.ResponseWriter
+	emulation  *toolCallEmulation
+	isStream   bool
+	statusCode int
+	buffer     bytes.Buffer
+	states     map[int]*toolCallStreamState
+	lastChunk  *dto.ChatCompletionsStreamResponse
+}
+
+func startToolCallEmulation(c *gin.Context, info *relaycommon.RelayInfo, emulation *toolCallEmulation) *toolCallEmulationWriter {
+	c.Header(ToolCallsEmulatedHeader, "true")
+	writer := &toolCallEmulationWriter{
+		ResponseWriter: c.Writer,
+		emulation:      emulation,
+		isStream:       info.IsStream,
+		statusCode:     http.StatusOK,
+		states:         make(map[int]*toolCallStreamState),
+	}
+	c.Writer = writer
+	return writer
+}
+
+func (w *toolCallEmulationWriter) WriteHeader(code int) {
+	if w.isStream {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.statusCode = code
+}
+
+func (w *toolCallEmulationWriter) WriteHeaderNow() {
+	if w.isStream {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *toolCallEmulationWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Write 流式响应按 SSE 事件边界转换，非流式响应缓存到 finish 时统一转换
+func (w *toolCallEmulationWriter) Write(data []byte) (int, error) {
+	w.buffer.Write(data)
+	if !w.isStream {
+		return len(data), nil
+	}
+	for {
+		content := w.buffer.Bytes()
+		end := bytes.Index(content, []byte("\n\n"))
+		if end < 0 {
+			break
+		}
+		event := string(content[:end])
+		w.buffer.Next(end + 2)
+		if err := w.writeEvent(event); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *toolCallEmulationWriter) state(index int) *toolCallStreamState {
+	state, ok := w.states[index]
+	if !ok {
+		state = &toolCallStreamState{}
+		w.states[index] = state
+	}
+	return state
+}
+
+func (w *toolCallEmulationWriter) writeChunk(chunk *dto.ChatCompletionsStreamResponse) error {
+	data, err := common.Marshal(chunk)
+	if err != nil {
+		return err
+	}
+	_, err = w.ResponseWriter.WriteString("data: " + string(data) + "\n\n")
+	return err
+}
+
+func (w *toolCallEmulationWriter) writeEvent(event string) error {
+	data, isData := strings.CutPrefix(event, "data: ")
+	if !isData {
+		_, err := w.ResponseWriter.WriteString(event + "\n\n")
+		return err
+	}
+	if data == "[DONE]" {
+		// 上游未发送 finish_reason 时，在结束前补发缓存的内容
+		if err := w.flushStates(); err != nil {
+			return err
+		}
+		_, err := w.ResponseWriter.WriteString(event + "\n\n")
+		return err
+	}
+	var chunk dto.ChatCompletionsStreamResponse
+	if err := common.UnmarshalJsonStr(data, &chunk); err != nil {
+		_, err = w.ResponseWriter.WriteString(event + "\n\n")
+		return err
+	}
+	w.lastChunk = &chunk
+	for i := range chunk.Choices {
+		choice := &chunk.Choices[i]
+		state := w.state(choice.Index)
+		if choice.Delta.Content != nil {
+			choice.Delta.SetContentString(state.feed(*choice.Delta.Content))
+		}
+		if choice.FinishReason != nil {
+			text, toolCalls := w.finishState(choice.Index)
+			if text != "" {
+				choice.Delta.SetContentString(choice.Delta.GetContentString() + text)
+			}
+			if len(toolCalls) > 0 {
+				choice.Delta.ToolCalls = toolCalls
+				finishReason := "tool_calls"
+				choice.FinishReason = &finishReason
+			}
+		}
+	}
+	return w.writeChunk(&chunk)
+}
+
+// finishState 结束 choice 的解析，返回仍需发送的文本与解析出的工具调用
+func (w *toolCallEmulationWriter) finishState(index int) (string, []dto.ToolCallResponse) {
+	state, ok := w.states[index]
+	if !ok {
+		return "", nil
+	}
+	delete(w.states, index)
+	if !state.inBlock {
+		return state.pending, nil
+	}
+	block := state.block.String()
+	text, toolCalls := w.emulation.parseEmulatedToolCalls(block)
+	if len(toolCalls) == 0 {
+		// 解析失败时把原文发给客户端
+		return text, nil
+	}
+	for i := range toolCalls {
+		toolCalls[i].SetIndex(i)
+	}
+	return text, toolCalls
+}
+
+func (w *toolCallEmulationWriter) flushStates() error {
+	for index := range w.states {
+		text, toolCalls := w.finishState(index)
+		if text == "" && len(toolCalls) == 0 {
+			continue
+		}
+		chunk := &dto.ChatCompletionsStreamResponse{
+			Object:  "chat.completion.chunk",
+			Created: common.GetTimestamp(),
+			Choices: []dto.ChatCompletionsStreamResponseChoice{{Index: index}},
+		}
+		if w.lastChunk != nil {
+			chunk.Id = w.lastChunk.Id
+			chunk.Created = w.lastChunk.Created
+			chunk.Model = w.lastChunk.Model
+		}
+		if text != "" {
+			chunk.Choices[0].Delta.SetContentString(text)
+		}
+		if len(toolCalls) > 0 {
+			chunk.Choices[0].Delta.ToolCalls = toolCalls
+			finishReason := "tool_calls"
+			chunk.Choices[0].FinishReason = &finishReason
+		}
+		if err := w.writeChunk(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// finish 写出缓存的非流式响应，必须在 DoResponse 成功后调用
+func (w *toolCallEmulationWriter) finish(c *gin.Context) {
+	c.Writer = w.ResponseWriter
+	if w.isStream {
+		if w.buffer.Len() > 0 {
+			_, _ = w.ResponseWriter.Write(w.buffer.Bytes())
+		}
+		return
+	}
+	body := w.buffer.Bytes()
+	var response dto.OpenAITextResponse
+	if err := common.Unmarshal(body, &response); err == nil {
+		changed := false
+		for i := range response.Choices {
+			message := &response.Choices[i].Message
+			if !message.IsStringContent() {
+				continue
+			}
+			text, toolCalls := w.emulation.parseEmulatedToolCalls(message.StringContent())
+			if len(toolCalls) == 0 {
+				continue
+			}
+			if text == "" {
+				message.SetNullContent()
+			} else {
+				message.SetStringContent(text)
+			}
+			message.SetToolCalls(toolCalls)
+			response.Choices[i].FinishReason = "tool_calls"
+			changed = true
+		}
+		if changed {
+			if converted, err := common.Marshal(&response); err == nil {
+				body = converted
+			}
+		}
+	}
+	w.ResponseWriter.Header().Set("Content-Length", fmt.Sprintf("%d", len(body)))
+	w.ResponseWriter.WriteHeader(w.statusCode)
+	_, _ = w.ResponseWriter.Write(body)
+}
@@ -0,0 +1,163 @@
+package relay
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	relaycommon "one-api/relay/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestToolCallEmulation() *toolCallEmulation {
+	return &toolCallEmulation{toolNames: map[string]bool{"get_weather": true}}
+}
+
+func TestParseEmulatedToolCalls(t *testing.T) {
+	cases := []struct {
+		name      string
+		content   string
+		wantText  string
+		wantCalls []string // 工具名称:参数
+	}{
+		{
+			name:      "tagged block after text",
+			content:   "Let me check.\n<tool_calls>\n[{\"name\": \"get_weather\", \"arguments\": {\"city\": \"Paris\"}}]\n</tool_calls>",
+			wantText:  "Let me check.",
+			wantCalls: []string{`get_weather:{"city":"Paris"}`},
+		},
+		{
+			name:      "missing end tag",
+			content:   "<tool_calls>[{\"name\": \"get_weather\", \"arguments\": {}}]",
+			wantCalls: []string{`get_weather:{}`},
+		},
+		{
+			name:      "untagged single object",
+			content:   "{\"name\": \"get_weather\", \"arguments\": {\"city\": \"Oslo\"}}",
+			wantCalls: []string{`get_weather:{"city":"Oslo"}`},
+		},
+		{
+			name:      "fenced json",
+			content:   "```json\n[{\"name\": \"get_weather\", \"arguments\": \"{\\\"city\\\":\\\"Rome\\\"}\"}]\n```",
+			wantCalls: []string{`get_weather:{"city":"Rome"}`},
+		},
+		{
+			name:     "unknown tool is ignored",
+			content:  "<tool_calls>[{\"name\": \"delete_files\", \"arguments\": {}}]</tool_calls>",
+			wantText: "<tool_calls>[{\"name\": \"delete_files\", \"arguments\": {}}]</tool_calls>",
+		},
+		{
+			name:     "invalid json keeps content",
+			content:  "<tool_calls>[{\"name\": </tool_calls>",
+			wantText: "<tool_calls>[{\"name\": </tool_calls>",
+		},
+		{
+			name:     "plain answer",
+			content:  "It is sunny.",
+			wantText: "It is sunny.",
+		},
+	}
+	emulation := newTestToolCallEmulation()
+	for _, c := range cases {
+		text, toolCalls := emulation.parseEmulatedToolCalls(c.content)
+		if text != c.wantText {
+			t.Errorf("%s: text = %q, want %q", c.name, text, c.wantText)
+		}
+		if len(toolCalls) != len(c.wantCalls) {
+			t.Errorf("%s: got %d tool calls, want %d", c.name, len(toolCalls), len(c.wantCalls))
+			continue
+		}
+		for i, toolCall := range toolCalls {
+			if got := toolCall.Function.Name + ":" + toolCall.Function.Arguments; got != c.wantCalls[i] {
+				t.Errorf("%s: tool call %d = %s, want %s", c.name, i, got, c.wantCalls[i])
+			}
+			if !strings.HasPrefix(toolCall.ID, "call_") || toolCall.Type != "function" {
+				t.Errorf("%s: unexpected tool call id %q type %q", c.name, toolCall.ID, toolCall.Type)
+			}
+		}
+	}
+}
+
+func TestToolCallStreamStateFeed(t *testing.T) {
+	cases := []struct {
+		name      string
+		chunks    []string
+		wantSent  string
+		wantBlock string
+	}{
+		{
+			name:     "plain text",
+			chunks:   []string{"Hello", " world"},
+			wantSent: "Hello world",
+		},
+		{
+			name:      "tag split across chunks",
+			chunks:    []string{"Sure.", " <tool", "_ca", "lls>[{\"name\"", ": \"get_weather\"}]</tool_calls>"},
+			wantSent:  "Sure. ",
+			wantBlock: "<tool_calls>[{\"name\": \"get_weather\"}]</tool_calls>",
+		},
+		{
+			name:     "partial tag that is not a tag",
+			chunks:   []string{"a <tool", "box> b"},
+			wantSent: "a <toolbox> b",
+		},
+		{
+			name:      "untagged json at start",
+			chunks:    []string{"\n", "[{\"name\"", ": \"get_weather\"}]"},
+			wantBlock: "\n[{\"name\": \"get_weather\"}]",
+		},
+		{
+			name:      "fence split across chunks",
+			chunks:    []string{"`", "``json\n{}", "\n```"},
+			wantBlock: "```json\n{}\n```",
+		},
+		{
+			name:     "json after text is not a block",
+			chunks:   []string{"Result: ", "[1, 2]"},
+			wantSent: "Result: [1, 2]",
+		},
+	}
+	for _, c := range cases {
+		state := &toolCallStreamState{}
+		sent := ""
+		for _, chunk := range c.chunks {
+			sent += state.feed(chunk)
+		}
+		if !state.inBlock {
+			sent += state.pending
+		}
+		if sent != c.wantSent {
+			t.Errorf("%s: sent %q, want %q", c.name, sent, c.wantSent)
+		}
+		if got := state.block.String(); got != c.wantBlock {
+			t.Errorf("%s: block %q, want %q", c.name, got, c.wantBlock)
+		}
+	}
+}
+
+func TestToolCallEmulationWriterStreamUntaggedJson(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	writer := startToolCallEmulation(c, &relaycommon.RelayInfo{IsStream: true}, newTestToolCallEmulation())
+	events := []string{
+		`data: {"id":"1","choices":[{"index":0,"delta":{"content":"[{\"name\": \"get_weather\","}}]}`,
+		`data: {"id":"1","choices":[{"index":0,"delta":{"content":" \"arguments\": {\"city\": \"Paris\"}}]"}}]}`,
+		`data: {"id":"1","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+		`data: [DONE]`,
+	}
+	for _, event := range events {
+		if _, err := c.Writer.WriteString(event + "\n\n"); err != nil {
+			t.Fatalf("write event failed: %s", err)
+		}
+	}
+	writer.finish(c)
+	body := recorder.Body.String()
+	if strings.Contains(body, `get_weather\"`) {
+		t.Errorf("raw tool call json leaked to client: %s", body)
+	}
+	if !strings.Contains(body, `"tool_calls":[{"index":0`) || !strings.Contains(body, `"finish_reason":"tool_calls"`) {
+		t.Errorf("tool calls not emitted: %s", body)
+	}
+}
@@ -129,6 +129,8 @@ func SetApiRouter(router *gin.Engine) {
 			channelRoute.POST("/fix", controller.FixChannelsAbilities)
 			channelRoute.GET("/fetch_models/:id", controller.FetchUpstreamModels)
 			channelRoute.POST("/fetch_models", controller.FetchModels)
+			channelRoute.POST("/ollama/pull/:id", controller.PullOllamaModel)
+			channelRoute.POST("/azure/discover/:id", controller.DiscoverAzureDeployments)
 			channelRoute.POST("/batch/tag", controller.BatchSetChannelTag)
 			channelRoute.GET("/tag/models", controller.GetTagModels)
 			channelRoute.POST("/copy/:id", controller.CopyChannel)
@@ -186,6 +188,8 @@ func SetApiRouter(router *gin.Engine) {
 		{
 			taskRoute.GET("/self", middleware.UserAuth(), controller.GetUserTask)
 			taskRoute.GET("/", middleware.AdminAuth(), controller.GetAllTask)
+			taskRoute.GET("/webhook", middleware.AdminAuth(), controller.GetTaskWebhookDeliveries)
+			taskRoute.POST("/webhook/:id/redeliver", middleware.AdminAuth(), controller.RedeliverTaskWebhook)
 		}
 	}
 }
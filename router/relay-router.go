@@ -19,6 +19,10 @@ func SetRelayRouter(router *gin.Engine) {
 		modelsRouter.GET("", controller.ListModels)
 		modelsRouter.GET("/:model", controller.RetrieveModel)
 	}
+	// 网关保存的生成结果，通过签名校验访问
+	router.GET("/media/:key", controller.GetMedia)
+	// 上游任务完成回调，通过地址中的签名校验来源
+	router.POST("/task/callback/:platform/:channel_id/:nonce/:signature", controller.TaskCallback)
 	playgroundRouter := router.Group("/pg")
 	playgroundRouter.Use(middleware.UserAuth(), middleware.Distribute())
 	{
@@ -38,6 +42,13 @@ func SetRelayRouter(router *gin.Engine) {
 		httpRouter := relayV1Router.Group("")
 		httpRouter.Use(middleware.Distribute())
 		httpRouter.POST("/messages", controller.RelayClaude)
+		httpRouter.POST("/messages/count_tokens", controller.RelayClaudeCountTokens)
+		httpRouter.POST("/messages/batches", controller.RelayClaudeBatch)
+		httpRouter.GET("/messages/batches", controller.RelayClaudeBatch)
+		httpRouter.GET("/messages/batches/:id", controller.RelayClaudeBatch)
+		httpRouter.POST("/messages/batches/:id/cancel", controller.RelayClaudeBatch)
+		httpRouter.GET("/messages/batches/:id/results", controller.RelayClaudeBatch)
+		httpRouter.DELETE("/messages/batches/:id", controller.RelayClaudeBatch)
 		httpRouter.POST("/completions", controller.Relay)
 		httpRouter.POST("/chat/completions", controller.Relay)
 		httpRouter.POST("/edits", controller.Relay)
@@ -50,18 +61,18 @@ func SetRelayRouter(router *gin.Engine) {
 		httpRouter.POST("/audio/translations", controller.Relay)
 		httpRouter.POST("/audio/speech", controller.Relay)
 		httpRouter.POST("/responses", controller.Relay)
-		httpRouter.GET("/files", controller.RelayNotImplemented)
-		httpRouter.POST("/files", controller.RelayNotImplemented)
-		httpRouter.DELETE("/files/:id", controller.RelayNotImplemented)
-		httpRouter.GET("/files/:id", controller.RelayNotImplemented)
-		httpRouter.GET("/files/:id/content", controller.RelayNotImplemented)
+		httpRouter.GET("/files", controller.RelayClaudeFiles)
+		httpRouter.POST("/files", controller.RelayClaudeFiles)
+		httpRouter.DELETE("/files/:id", controller.RelayClaudeFiles)
+		httpRouter.GET("/files/:id", controller.RelayClaudeFiles)
+		httpRouter.GET("/files/:id/content", controller.RelayClaudeFiles)
 		httpRouter.POST("/fine-tunes", controller.RelayNotImplemented)
 		httpRouter.GET("/fine-tunes", controller.RelayNotImplemented)
 		httpRouter.GET("/fine-tunes/:id", controller.RelayNotImplemented)
 		httpRouter.POST("/fine-tunes/:id/cancel", controller.RelayNotImplemented)
 		httpRouter.GET("/fine-tunes/:id/events", controller.RelayNotImplemented)
 		httpRouter.DELETE("/models/:model", controller.RelayNotImplemented)
-		httpRouter.POST("/moderations", controller.Relay)
+		httpRouter.POST("/moderations", controller.RelayModeration)
 		httpRouter.POST("/rerank", controller.Relay)
 		httpRouter.POST("/models/*path", controller.Relay)
 	}
@@ -81,6 +92,13 @@ func SetRelayRouter(router *gin.Engine) {
 		relaySunoRouter.GET("/fetch/:id", controller.RelayTask)
 	}
 
+	relayMusicRouter := router.Group("/v1/audio/music")
+	relayMusicRouter.Use(middleware.TokenAuth(), middleware.Distribute())
+	{
+		relayMusicRouter.POST("", controller.RelayTask)
+		relayMusicRouter.GET("/:task_id", controller.RelayTask)
+	}
+
 	relayGeminiRouter := router.Group("/v1beta")
 	relayGeminiRouter.Use(middleware.TokenAuth())
 	relayGeminiRouter.Use(middleware.ModelRequestRateLimit())
@@ -88,6 +106,24 @@ func SetRelayRouter(router *gin.Engine) {
 	{
 		// Gemini API 路径格式: /v1beta/models/{model_name}:{action}
 		relayGeminiRouter.POST("/models/*path", controller.Relay)
+		relayGeminiRouter.POST("/cachedContents", controller.RelayGeminiCachedContents)
+		relayGeminiRouter.GET("/cachedContents", controller.RelayGeminiCachedContents)
+		relayGeminiRouter.GET("/cachedContents/:id", controller.RelayGeminiCachedContents)
+		relayGeminiRouter.PATCH("/cachedContents/:id", controller.RelayGeminiCachedContents)
+		relayGeminiRouter.DELETE("/cachedContents/:id", controller.RelayGeminiCachedContents)
+		relayGeminiRouter.GET("/files", controller.RelayGeminiFiles)
+		relayGeminiRouter.GET("/files/:id", controller.RelayGeminiFiles)
+		relayGeminiRouter.DELETE("/files/:id", controller.RelayGeminiFiles)
+	}
+
+	relayGeminiUploadRouter := router.Group("/upload/v1beta")
+	relayGeminiUploadRouter.Use(middleware.TokenAuth())
+	relayGeminiUploadRouter.Use(middleware.ModelRequestRateLimit())
+	relayGeminiUploadRouter.Use(middleware.Distribute())
+	{
+		// 可续传上传的后续分片同样发往该地址
+		relayGeminiUploadRouter.POST("/files", controller.RelayGeminiFiles)
+		relayGeminiUploadRouter.PUT("/files", controller.RelayGeminiFiles)
 	}
 }
 
@@ -11,11 +11,30 @@ func parseAudio(audioBase64 string, format string) (duration float64, err error)
 	if err != nil {
 		return 0, fmt.Errorf("base64 decode error: %v", err)
 	}
+	return getAudioDuration(audioData, format), nil
+}
 
+func getAudioDuration(audioData []byte, format string) float64 {
 	var samplesCount int
 	var sampleRate int
 
 	switch format {
+	case AudioFormatWav:
+		audio, _, err := DecodeWav(audioData)
+		if err != nil {
+			return 0
+		}
+		return audio.Duration()
+	case AudioFormatMp3:
+		frames, err := parseMp3Frames(audioData)
+		if err != nil {
+			return 0
+		}
+		var duration float64
+		for _, frame := range frames {
+			duration += frame.duration
+		}
+		return duration
 	case "pcm16":
 		samplesCount = len(audioData) / 2 // 16位 = 2字节每样本
 		sampleRate = 24000                // 24kHz
@@ -27,8 +46,7 @@ func parseAudio(audioBase64 string, format string) (duration float64, err error)
 		sampleRate = 8000             // 8kHz
 	}
 
-	duration = float64(samplesCount) / float64(sampleRate)
-	return duration, nil
+	return float64(samplesCount) / float64(sampleRate)
 }
 
 func DecodeBase64AudioData(audioBase64 string) (string, error) {
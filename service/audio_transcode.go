@@ -0,0 +1,400 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"one-api/setting/operation_setting"
+	"path/filepath"
+	"strings"
+)
+
+// 转写前的音频预处理：识别容器格式，将 wav、裸 pcm 转为 16 位单声道 wav，并将超过上游限制的音频切分为多段
+
+const (
+	AudioFormatWav  = "wav"
+	AudioFormatPcm  = "pcm"
+	AudioFormatMp3  = "mp3"
+	AudioFormatFlac = "flac"
+	AudioFormatOgg  = "ogg"
+	AudioFormatM4a  = "m4a"
+	AudioFormatWebm = "webm"
+)
+
+// DetectAudioFormat 按文件头识别音频容器，无法识别时按扩展名判断是否为裸 pcm
+func DetectAudioFormat(data []byte, filename string) string {
+	switch {
+	case len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WAVE":
+		return AudioFormatWav
+	case len(data) >= 4 && string(data[0:4]) == "fLaC":
+		return AudioFormatFlac
+	case len(data) >= 4 && string(data[0:4]) == "OggS":
+		return AudioFormatOgg
+	case len(data) >= 8 && string(data[4:8]) == "ftyp":
+		return AudioFormatM4a
+	case len(data) >= 4 && bytes.Equal(data[0:4], []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		return AudioFormatWebm
+	case len(data) >= 3 && string(data[0:3]) == "ID3":
+		return AudioFormatMp3
+	case len(data) >= 2 && data[0] == 0xFF && data[1]&0xE0 == 0xE0:
+		return AudioFormatMp3
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".pcm", ".raw":
+		return AudioFormatPcm
+	}
+	return ""
+}
+
+// PcmAudio 16 位单声道 pcm 音频
+type PcmAudio struct {
+	SampleRate int
+	Samples    []byte // 小端 int16
+}
+
+func (p *PcmAudio) Duration() float64 {
+	if p.SampleRate <= 0 {
+		return 0
+	}
+	return float64(len(p.Samples)/2) / float64(p.SampleRate)
+}
+
+// EncodeWav 为 16 位单声道 pcm 加上 wav 文件头
+func EncodeWav(pcm []byte, sampleRate int) []byte {
+	var buf bytes.Buffer
+	buf.Grow(44 + len(pcm))
+	buf.WriteString("RIFF")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(36+len(pcm)))
+	buf.WriteString("WAVEfmt ")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(16))
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(1)) // PCM
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(1)) // 单声道
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(sampleRate))
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(sampleRate*2))
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(2))
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(16))
+	buf.WriteString("data")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(len(pcm)))
+	buf.Write(pcm)
+	return buf.Bytes()
+}
+
+// DecodeWav 解析 wav 文件并转为 16 位单声道 pcm，converted 表示原文件不是 16 位单声道 pcm
+func DecodeWav(data []byte) (audio *PcmAudio, converted bool, err error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, false, errors.New("invalid wav file")
+	}
+	var (
+		format, channels, blockAlign, bits uint16
+		sampleRate                         uint32
+		samples                            []byte
+		hasFmt                             bool
+	)
+	for pos := 12; pos+8 <= len(data); {
+		chunkId := string(data[pos : pos+4])
+		chunkSize := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		body := data[pos+8:]
+		if chunkSize < 0 || chunkSize > len(body) {
+			// 流式写入的 wav 可能没有正确的长度，截断到文件末尾
+			chunkSize = len(body)
+		}
+		body = body[:chunkSize]
+		switch chunkId {
+		case "fmt ":
+			if len(body) < 16 {
+				return nil, false, errors.New("invalid wav fmt chunk")
+			}
+			format = binary.LittleEndian.Uint16(body[0:2])
+			channels = binary.LittleEndian.Uint16(body[2:4])
+			sampleRate = binary.LittleEndian.Uint32(body[4:8])
+			blockAlign = binary.LittleEndian.Uint16(body[12:14])
+			bits = binary.LittleEndian.Uint16(body[14:16])
+			if format == 0xFFFE && len(body) >= 26 {
+				// WAVE_FORMAT_EXTENSIBLE 的实际格式在子格式 GUID 的前两个字节
+				format = binary.LittleEndian.Uint16(body[24:26])
+			}
+			hasFmt = true
+		case "data":
+			samples = body
+		}
+		pos += 8 + chunkSize + chunkSize%2
+	}
+	if !hasFmt || samples == nil {
+		return nil, false, errors.New("wav file has no fmt or data chunk")
+	}
+	if channels == 0 || sampleRate == 0 || blockAlign == 0 {
+		return nil, false, errors.New("invalid wav format")
+	}
+	if format == 1 && bits == 16 && channels == 1 {
+		return &PcmAudio{SampleRate: int(sampleRate), Samples: samples[:len(samples)&^1]}, false, nil
+	}
+
+	readSample, err := wavSampleReader(format, bits)
+	if err != nil {
+		return nil, false, err
+	}
+	bytesPerSample := int(bits / 8)
+	frames := len(samples) / int(blockAlign)
+	out := make([]byte, frames*2)
+	for i := 0; i < frames; i++ {
+		frame := samples[i*int(blockAlign):]
+		var sum float64
+		for ch := 0; ch < int(channels); ch++ {
+			sum += readSample(frame[ch*bytesPerSample:])
+		}
+		value := math.Max(-1, math.Min(1, sum/float64(channels)))
+		binary.LittleEndian.PutUint16(out[i*2:], uint16(int16(value*math.MaxInt16)))
+	}
+	return &PcmAudio{SampleRate: int(sampleRate), Samples: out}, true, nil
+}
+
+// wavSampleReader 返回将单个采样转换为 [-1, 1] 浮点数的函数
+func wavSampleReader(format uint16, bits uint16) (func(b []byte) float64, error) {
+	switch {
+	case format == 1 && bits == 8:
+		return func(b []byte) float64 { return (float64(b[0]) - 128) / 128 }, nil
+	case format == 1 && bits == 16:
+		return func(b []byte) float64 { return float64(int16(binary.LittleEndian.Uint16(b))) / 32768 }, nil
+	case format == 1 && bits == 24:
+		return func(b []byte) float64 {
+			v := int32(b[0]) | int32(b[1])<<8 | int32(int8(b[2]))<<16
+			return float64(v) / 8388608
+		}, nil
+	case format == 1 && bits == 32:
+		return func(b []byte) float64 { return float64(int32(binary.LittleEndian.Uint32(b))) / 2147483648 }, nil
+	case format == 3 && bits == 32:
+		return func(b []byte) float64 { return float64(math.Float32frombits(binary.LittleEndian.Uint32(b))) }, nil
+	case format == 3 && bits == 64:
+		return func(b []byte) float64 { return math.Float64frombits(binary.LittleEndian.Uint64(b)) }, nil
+	}
+	return nil, fmt.Errorf("unsupported wav encoding: format %d, %d bits", format, bits)
+}
+
+type mp3Frame struct {
+	offset   int
+	size     int
+	duration float64
+}
+
+var (
+	mp3BitratesV1 = [3][15]int{
+		{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448}, // Layer I
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},    // Layer II
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},     // Layer III
+	}
+	mp3BitratesV2 = [3][15]int{
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+	}
+	mp3SampleRates = map[int][3]int{
+		3: {44100, 48000, 32000}, // MPEG-1
+		2: {22050, 24000, 16000}, // MPEG-2
+		0: {11025, 12000, 8000},  // MPEG-2.5
+	}
+)
+
+// parseMp3Frame 解析 pos 处的 mp3 帧头，无效时返回 false
+func parseMp3Frame(data []byte, pos int) (mp3Frame, bool) {
+	if pos+4 > len(data) || data[pos] != 0xFF || data[pos+1]&0xE0 != 0xE0 {
+		return mp3Frame{}, false
+	}
+	version := int(data[pos+1]>>3) & 3
+	layer := int(data[pos+1]>>1) & 3
+	bitrateIndex := int(data[pos+2] >> 4)
+	sampleRateIndex := int(data[pos+2]>>2) & 3
+	padding := int(data[pos+2]>>1) & 1
+	if version == 1 || layer == 0 || bitrateIndex == 0 || bitrateIndex == 15 || sampleRateIndex == 3 {
+		return mp3Frame{}, false
+	}
+	layerIndex := 3 - layer // Layer I = 0, II = 1, III = 2
+	bitrate := mp3BitratesV2[layerIndex][bitrateIndex] * 1000
+	if version == 3 {
+		bitrate = mp3BitratesV1[layerIndex][bitrateIndex] * 1000
+	}
+	sampleRate := mp3SampleRates[version][sampleRateIndex]
+
+	var samplesPerFrame, size int
+	switch layerIndex {
+	case 0:
+		samplesPerFrame = 384
+		size = (12*bitrate/sampleRate + padding) * 4
+	case 1:
+		samplesPerFrame = 1152
+		size = 144*bitrate/sampleRate + padding
+	default:
+		samplesPerFrame = 1152
+		if version != 3 {
+			samplesPerFrame = 576
+		}
+		size = samplesPerFrame/8*bitrate/sampleRate + padding
+	}
+	if size < 4 {
+		return mp3Frame{}, false
+	}
+	return mp3Frame{offset: pos, size: size, duration: float64(samplesPerFrame) / float64(sampleRate)}, true
+}
+
+// parseMp3Frames 跳过 ID3 标签后逐帧解析 mp3，不解码音频
+func parseMp3Frames(data []byte) ([]mp3Frame, error) {
+	pos := 0
+	if len(data) >= 10 && string(data[0:3]) == "ID3" {
+		tagSize := int(data[6]&0x7F)<<21 | int(data[7]&0x7F)<<14 | int(data[8]&0x7F)<<7 | int(data[9]&0x7F)
+		pos = 10 + tagSize
+		if data[5]&0x10 != 0 {
+			pos += 10
+		}
+	}
+	var frames []mp3Frame
+	for pos+4 <= len(data) {
+		if string(data[pos:min(pos+3, len(data))]) == "TAG" && len(data)-pos == 128 {
+			break
+		}
+		frame, ok := parseMp3Frame(data, pos)
+		if !ok || pos+frame.size > len(data) {
+			// 跳过无法识别的字节重新同步
+			pos++
+			continue
+		}
+		frames = append(frames, frame)
+		pos += frame.size
+	}
+	if len(frames) == 0 {
+		return nil, errors.New("no mp3 frames found")
+	}
+	return frames, nil
+}
+
+// AudioChunk 切分后的音频片段
+type AudioChunk struct {
+	Data     []byte
+	Filename string
+	Offset   float64 // 片段在原音频中的起始时间（秒）
+	Duration float64
+}
+
+// SplitPcmOnSilence 按最大时长切分 pcm，切分点选在每段末尾 searchSeconds 内能量最低的位置
+func SplitPcmOnSilence(audio *PcmAudio, maxSeconds float64, searchSeconds float64) []AudioChunk {
+	totalSamples := len(audio.Samples) / 2
+	maxSamples := int(maxSeconds * float64(audio.SampleRate))
+	searchSamples := min(int(searchSeconds*float64(audio.SampleRate)), maxSamples/2)
+	window := max(audio.SampleRate/50, 1) // 20ms
+
+	var chunks []AudioChunk
+	for start := 0; start < totalSamples; {
+		end := totalSamples
+		if totalSamples-start > maxSamples {
+			end = start + maxSamples
+			best := end
+			bestEnergy := math.MaxFloat64
+			for pos := end - searchSamples; pos+window <= end; pos += window {
+				var energy float64
+				for i := pos; i < pos+window; i++ {
+					sample := float64(int16(binary.LittleEndian.Uint16(audio.Samples[i*2:])))
+					energy += sample * sample
+				}
+				if energy < bestEnergy {
+					bestEnergy = energy
+					best = pos + window/2
+				}
+			}
+			end = best
+		}
+		chunks = append(chunks, AudioChunk{
+			Data:     EncodeWav(audio.Samples[start*2:end*2], audio.SampleRate),
+			Filename: fmt.Sprintf("chunk_%d.wav", len(chunks)),
+			Offset:   float64(start) / float64(audio.SampleRate),
+			Duration: float64(end-start) / float64(audio.SampleRate),
+		})
+		start = end
+	}
+	return chunks
+}
+
+// SplitMp3 在帧边界处切分 mp3，每段不超过 maxBytes 与 maxSeconds；mp3 无法在不解码的情况下判断静音
+func SplitMp3(data []byte, maxBytes int, maxSeconds float64) ([]AudioChunk, error) {
+	frames, err := parseMp3Frames(data)
+	if err != nil {
+		return nil, err
+	}
+	var chunks []AudioChunk
+	var offset float64
+	for i := 0; i < len(frames); {
+		start := frames[i].offset
+		end := start
+		var duration float64
+		for ; i < len(frames); i++ {
+			frame := frames[i]
+			if end > start && (frame.offset+frame.size-start > maxBytes || duration+frame.duration > maxSeconds) {
+				break
+			}
+			end = frame.offset + frame.size
+			duration += frame.duration
+		}
+		chunks = append(chunks, AudioChunk{
+			Data:     data[start:end],
+			Filename: fmt.Sprintf("chunk_%d.mp3", len(chunks)),
+			Offset:   offset,
+			Duration: duration,
+		})
+		offset += duration
+	}
+	return chunks, nil
+}
+
+// PreparedAudio 预处理后的待转写音频
+type PreparedAudio struct {
+	Format   string
+	Duration float64
+	Data     []byte // 转码后的完整文件，未转码时为空
+	Filename string
+	Chunks   []AudioChunk // 超过上游限制需要分段转写时不为空
+}
+
+// PrepareTranscriptionAudio 预处理待转写音频，无法识别或无需处理的格式返回 nil
+func PrepareTranscriptionAudio(data []byte, filename string, pcmSampleRate int) (*PreparedAudio, error) {
+	transcodeSetting := operation_setting.GetAudioTranscodeSetting()
+	maxBytes := transcodeSetting.MaxFileSizeMB << 20
+	format := DetectAudioFormat(data, filename)
+	prepared := &PreparedAudio{Format: format}
+	switch format {
+	case AudioFormatWav, AudioFormatPcm:
+		var audio *PcmAudio
+		converted := true
+		if format == AudioFormatPcm {
+			audio = &PcmAudio{SampleRate: pcmSampleRate, Samples: data[:len(data)&^1]}
+		} else {
+			var err error
+			audio, converted, err = DecodeWav(data)
+			if err != nil {
+				return nil, err
+			}
+		}
+		prepared.Duration = audio.Duration()
+		if len(data) > maxBytes || prepared.Duration > transcodeSetting.MaxDurationSeconds {
+			// 转码后的 wav 同样不能超过上游的文件大小限制
+			chunkSeconds := math.Min(transcodeSetting.ChunkDurationSeconds, float64(maxBytes-44)/2/float64(audio.SampleRate)*0.95)
+			prepared.Chunks = SplitPcmOnSilence(audio, chunkSeconds, transcodeSetting.SilenceSearchSeconds)
+		} else if converted {
+			prepared.Data = EncodeWav(audio.Samples, audio.SampleRate)
+			prepared.Filename = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename)) + ".wav"
+		} else {
+			return nil, nil
+		}
+	case AudioFormatMp3:
+		prepared.Duration = getAudioDuration(data, AudioFormatMp3)
+		if len(data) <= maxBytes && prepared.Duration <= transcodeSetting.MaxDurationSeconds {
+			return nil, nil
+		}
+		chunks, err := SplitMp3(data, int(float64(maxBytes)*0.95), transcodeSetting.ChunkDurationSeconds)
+		if err != nil {
+			return nil, err
+		}
+		prepared.Chunks = chunks
+	default:
+		return nil, nil
+	}
+	return prepared, nil
+}
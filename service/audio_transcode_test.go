@@ -0,0 +1,179 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// buildWav 生成指定声道数与位深的 pcm wav 文件
+func buildWav(sampleRate int, channels int, bits int, samples []byte) []byte {
+	blockAlign := channels * bits / 8
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(36+len(samples)))
+	buf.WriteString("WAVEfmt ")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(16))
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(1))
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(channels))
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(sampleRate))
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(sampleRate*blockAlign))
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(blockAlign))
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(bits))
+	buf.WriteString("data")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(len(samples)))
+	buf.Write(samples)
+	return buf.Bytes()
+}
+
+func int16Samples(values ...int16) []byte {
+	out := make([]byte, len(values)*2)
+	for i, v := range values {
+		binary.LittleEndian.PutUint16(out[i*2:], uint16(v))
+	}
+	return out
+}
+
+func TestDecodeWavMono16(t *testing.T) {
+	samples := int16Samples(1, -2, 300, -400)
+	audio, converted, err := DecodeWav(buildWav(16000, 1, 16, samples))
+	if err != nil {
+		t.Fatalf("decode wav failed: %s", err)
+	}
+	if converted {
+		t.Errorf("16 bit mono wav should not be converted")
+	}
+	if audio.SampleRate != 16000 || !bytes.Equal(audio.Samples, samples) {
+		t.Errorf("unexpected audio: rate %d, samples %v", audio.SampleRate, audio.Samples)
+	}
+}
+
+func TestDecodeWavStereoToMono(t *testing.T) {
+	// 每帧左右声道取平均
+	audio, converted, err := DecodeWav(buildWav(8000, 2, 16, int16Samples(1000, 3000, -2000, -4000)))
+	if err != nil {
+		t.Fatalf("decode wav failed: %s", err)
+	}
+	if !converted {
+		t.Errorf("stereo wav should be converted")
+	}
+	if len(audio.Samples) != 4 {
+		t.Fatalf("expected 2 mono samples, got %d bytes", len(audio.Samples))
+	}
+	for i, want := range []float64{2000, -3000} {
+		got := float64(int16(binary.LittleEndian.Uint16(audio.Samples[i*2:])))
+		if math.Abs(got-want) > 1 {
+			t.Errorf("sample %d: got %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestDecodeWavInvalid(t *testing.T) {
+	if _, _, err := DecodeWav([]byte("not a wav file")); err == nil {
+		t.Errorf("expected error for invalid wav")
+	}
+	// 缺少 data 块
+	header := buildWav(8000, 1, 16, nil)
+	if _, _, err := DecodeWav(header[:36]); err == nil {
+		t.Errorf("expected error for wav without data chunk")
+	}
+}
+
+// buildMp3 生成 count 个 MPEG-1 Layer III 128kbps 44.1kHz 的空帧，每帧 417 字节
+func buildMp3(count int) []byte {
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xFF, 0xFB, 0x90, 0x00})
+	return bytes.Repeat(frame, count)
+}
+
+func TestSplitMp3(t *testing.T) {
+	data := buildMp3(100)
+	frameDuration := 1152.0 / 44100
+	chunks, err := SplitMp3(data, 1<<20, 1)
+	if err != nil {
+		t.Fatalf("split mp3 failed: %s", err)
+	}
+	if len(chunks) != 3 {
+		t.Fatalf("expected 3 chunks, got %d", len(chunks))
+	}
+	var joined []byte
+	var total float64
+	for i, chunk := range chunks {
+		if chunk.Duration > 1 {
+			t.Errorf("chunk %d duration %v exceeds limit", i, chunk.Duration)
+		}
+		if math.Abs(chunk.Offset-total) > 1e-9 {
+			t.Errorf("chunk %d offset %v, want %v", i, chunk.Offset, total)
+		}
+		total += chunk.Duration
+		joined = append(joined, chunk.Data...)
+	}
+	if !bytes.Equal(joined, data) {
+		t.Errorf("chunks do not cover the original data")
+	}
+	if math.Abs(total-100*frameDuration) > 1e-9 {
+		t.Errorf("total duration %v, want %v", total, 100*frameDuration)
+	}
+}
+
+func TestSplitMp3ByBytes(t *testing.T) {
+	chunks, err := SplitMp3(buildMp3(25), 417*10, 3600)
+	if err != nil {
+		t.Fatalf("split mp3 failed: %s", err)
+	}
+	if len(chunks) != 3 {
+		t.Fatalf("expected 3 chunks, got %d", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if len(chunk.Data) > 417*10 || len(chunk.Data)%417 != 0 {
+			t.Errorf("chunk %d has %d bytes, not split on frame boundaries within limit", i, len(chunk.Data))
+		}
+	}
+}
+
+func TestSplitMp3Invalid(t *testing.T) {
+	if _, err := SplitMp3(make([]byte, 1024), 1<<20, 60); err == nil {
+		t.Errorf("expected error for data without mp3 frames")
+	}
+}
+
+func TestSplitPcmOnSilence(t *testing.T) {
+	const sampleRate = 1000
+	values := make([]int16, 10*sampleRate)
+	for i := range values {
+		values[i] = 10000
+	}
+	// 3.5 秒到 3.6 秒之间静音
+	for i := 3500; i < 3600; i++ {
+		values[i] = 0
+	}
+	audio := &PcmAudio{SampleRate: sampleRate, Samples: int16Samples(values...)}
+	chunks := SplitPcmOnSilence(audio, 4, 1)
+	if len(chunks) < 3 {
+		t.Fatalf("expected at least 3 chunks, got %d", len(chunks))
+	}
+	if first := chunks[0].Duration; first < 3.5 || first > 3.6 {
+		t.Errorf("first chunk should end inside the silence, got duration %v", first)
+	}
+	var total float64
+	for i, chunk := range chunks {
+		if chunk.Duration > 4 {
+			t.Errorf("chunk %d duration %v exceeds limit", i, chunk.Duration)
+		}
+		if math.Abs(chunk.Offset-total) > 1e-9 {
+			t.Errorf("chunk %d offset %v, want %v", i, chunk.Offset, total)
+		}
+		decoded, _, err := DecodeWav(chunk.Data)
+		if err != nil {
+			t.Fatalf("chunk %d is not a valid wav: %s", i, err)
+		}
+		if math.Abs(decoded.Duration()-chunk.Duration) > 1e-9 {
+			t.Errorf("chunk %d wav duration %v, want %v", i, decoded.Duration(), chunk.Duration)
+		}
+		total += chunk.Duration
+	}
+	if math.Abs(total-10) > 1e-9 {
+		t.Errorf("total duration %v, want 10", total)
+	}
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"one-api/common"
+	"one-api/model"
+)
+
+// ClaudeFileResource 返回请求中引用的 Files API 文件，用于确定文件所属的渠道与密钥，
+// 文件不属于当前用户时返回错误，未引用文件时返回 nil
+func ClaudeFileResource(userId int, body []byte) (*model.UpstreamFile, error) {
+	var request struct {
+		Messages []struct {
+			Content json.RawMessage `json:"content"`
+		} `json:"messages"`
+	}
+	if err := common.Unmarshal(body, &request); err != nil {
+		return nil, nil
+	}
+	var resource *model.UpstreamFile
+	for _, message := range request.Messages {
+		var blocks []struct {
+			Source *struct {
+				FileId string `json:"file_id"`
+			} `json:"source"`
+		}
+		if common.Unmarshal(message.Content, &blocks) != nil {
+			continue
+		}
+		for _, block := range blocks {
+			if block.Source == nil || block.Source.FileId == "" {
+				continue
+			}
+			file, err := model.GetUserUpstreamFile(userId, model.UpstreamFilePlatformClaude, block.Source.FileId)
+			if err != nil {
+				return nil, errors.New("file not found: " + block.Source.FileId)
+			}
+			if resource != nil && (resource.ChannelId != file.ChannelId || resource.KeyIndex != file.KeyIndex) {
+				return nil, errors.New("files uploaded through different channels cannot be used in the same request")
+			}
+			resource = file
+		}
+	}
+	return resource, nil
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"errors"
+	"one-api/common"
+	"one-api/model"
+	"strings"
+)
+
+// GeminiResource 返回请求中引用的上下文缓存或 Files API 文件，用于确定它们所属的渠道与密钥，
+// 缓存或文件不属于当前用户时返回错误，未引用时返回 nil
+func GeminiResource(userId int, body []byte) (*model.UpstreamFile, error) {
+	var request struct {
+		CachedContent string `json:"cachedContent"`
+		Contents      []struct {
+			Parts []struct {
+				FileData *struct {
+					FileUri string `json:"fileUri"`
+				} `json:"fileData"`
+			} `json:"parts"`
+		} `json:"contents"`
+	}
+	if err := common.Unmarshal(body, &request); err != nil {
+		return nil, nil
+	}
+	var resource *model.UpstreamFile
+	use := func(platform string, name string) error {
+		file, err := model.GetUserUpstreamFile(userId, platform, name)
+		if err != nil {
+			return errors.New("resource not found: " + name)
+		}
+		if resource != nil && (resource.ChannelId != file.ChannelId || resource.KeyIndex != file.KeyIndex) {
+			return errors.New("cached contents and files created through different channels cannot be used in the same request")
+		}
+		resource = file
+		return nil
+	}
+	if request.CachedContent != "" {
+		if err := use(model.UpstreamFilePlatformGeminiCache, request.CachedContent); err != nil {
+			return nil, err
+		}
+	}
+	for _, content := range request.Contents {
+		for _, part := range content.Parts {
+			if part.FileData == nil {
+				continue
+			}
+			// 只校验 Files API 上传的文件，其他地址（如 YouTube）原样使用
+			idx := strings.Index(part.FileData.FileUri, "/v1beta/files/")
+			if idx < 0 {
+				continue
+			}
+			if err := use(model.UpstreamFilePlatformGeminiFile, part.FileData.FileUri[idx+len("/v1beta/"):]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return resource, nil
+}
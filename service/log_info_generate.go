@@ -24,6 +24,9 @@ func GenerateTextOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, m
 	if relayInfo.ReasoningEffort != "" {
 		other["reasoning_effort"] = relayInfo.ReasoningEffort
 	}
+	if relayInfo.TimePricing != nil {
+		other["time_pricing"] = relayInfo.TimePricing
+	}
 	if relayInfo.IsModelMapped {
 		other["is_model_mapped"] = true
 		other["upstream_model_name"] = relayInfo.UpstreamModelName
@@ -79,5 +82,8 @@ func GenerateMjOtherInfo(priceData helper.PerCallPriceData) map[string]interface
 	if priceData.GroupRatioInfo.HasSpecialRatio {
 		other["user_group_ratio"] = priceData.GroupRatioInfo.GroupSpecialRatio
 	}
+	if priceData.TimePricing != nil {
+		other["time_pricing"] = priceData.TimePricing
+	}
 	return other
 }
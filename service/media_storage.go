@@ -0,0 +1,377 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting"
+	"one-api/setting/operation_setting"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// MediaRefPrefix 数据库中保存的本地存储引用，返回给客户端前替换为签名链接
+const MediaRefPrefix = "media://"
+
+var mediaRefRegexp = regexp.MustCompile(`media://[0-9a-f]{32}`)
+
+// MediaStore 生成结果的存储后端
+type MediaStore interface {
+	Put(ctx context.Context, key string, contentType string, data []byte) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+func GetMediaStore(backend string) (MediaStore, error) {
+	storageSetting := operation_setting.GetMediaStorageSetting()
+	switch backend {
+	case operation_setting.MediaStorageBackendLocal:
+		return &localMediaStore{root: storageSetting.LocalPath}, nil
+	case operation_setting.MediaStorageBackendS3:
+		if storageSetting.S3Endpoint == "" || storageSetting.S3Bucket == "" {
+			return nil, errors.New("s3 endpoint and bucket are required")
+		}
+		return &s3MediaStore{
+			endpoint:  strings.TrimSuffix(storageSetting.S3Endpoint, "/"),
+			region:    storageSetting.S3Region,
+			bucket:    storageSetting.S3Bucket,
+			pathStyle: storageSetting.S3PathStyle,
+			credentials: aws.Credentials{
+				AccessKeyID:     storageSetting.S3AccessKeyId,
+				SecretAccessKey: storageSetting.S3SecretAccessKey,
+			},
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown media storage backend: %s", backend)
+}
+
+type localMediaStore struct {
+	root string
+}
+
+func (s *localMediaStore) path(key string) string {
+	return filepath.Join(s.root, key[:2], key)
+}
+
+func (s *localMediaStore) Put(_ context.Context, key string, _ string, data []byte) error {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func (s *localMediaStore) Open(_ context.Context, key string) (io.ReadCloser, error) {
+	return os.Open(s.path(key))
+}
+
+func (s *localMediaStore) Delete(_ context.Context, key string) error {
+	err := os.Remove(s.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// s3MediaStore 兼容 S3 协议的对象存储，请求使用 SigV4 签名
+type s3MediaStore struct {
+	endpoint    string
+	region      string
+	bucket      string
+	pathStyle   bool
+	credentials aws.Credentials
+}
+
+func (s *s3MediaStore) objectUrl(key string) (string, error) {
+	endpoint, err := url.Parse(s.endpoint)
+	if err != nil {
+		return "", err
+	}
+	if s.pathStyle {
+		endpoint.Path = "/" + s.bucket + "/" + key
+	} else {
+		endpoint.Host = s.bucket + "." + endpoint.Host
+		endpoint.Path = "/" + key
+	}
+	return endpoint.String(), nil
+}
+
+func (s *s3MediaStore) do(ctx context.Context, method string, key string, contentType string, data []byte) (*http.Response, error) {
+	objectUrl, err := s.objectUrl(key)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, objectUrl, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	payloadHash := sha256.Sum256(data)
+	payloadHashHex := hex.EncodeToString(payloadHash[:])
+	req.Header.Set("X-Amz-Content-Sha256", payloadHashHex)
+	region := s.region
+	if region == "" {
+		region = "us-east-1"
+	}
+	if err := v4.NewSigner().SignHTTP(ctx, s.credentials, req, payloadHashHex, "s3", region, time.Now()); err != nil {
+		return nil, err
+	}
+	resp, err := GetHttpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 && !(method == http.MethodDelete && resp.StatusCode == http.StatusNotFound) {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("s3 %s %s failed: status %d, %s", method, key, resp.StatusCode, string(body))
+	}
+	return resp, nil
+}
+
+func (s *s3MediaStore) Put(ctx context.Context, key string, contentType string, data []byte) error {
+	resp, err := s.do(ctx, http.MethodPut, key, contentType, data)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (s *s3MediaStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *s3MediaStore) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, "", nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// StoreMediaFromUrl 下载上游生成结果并保存，返回 media:// 引用；未启用存储时返回原地址
+func StoreMediaFromUrl(ctx context.Context, userId int, kind string, sourceUrl string) (string, error) {
+	if sourceUrl == "" || strings.HasPrefix(sourceUrl, MediaRefPrefix) || !operation_setting.GetMediaStorageSetting().IsEnabledFor(kind) {
+		return sourceUrl, nil
+	}
+	resp, err := DoDownloadRequest(sourceUrl)
+	if err != nil {
+		return sourceUrl, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return sourceUrl, fmt.Errorf("download media failed: status %d", resp.StatusCode)
+	}
+	maxBytes := int64(operation_setting.GetMediaStorageSetting().MaxFileMB) * 1024 * 1024
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return sourceUrl, err
+	}
+	if int64(len(data)) > maxBytes {
+		return sourceUrl, fmt.Errorf("media size exceeds %d MB", operation_setting.GetMediaStorageSetting().MaxFileMB)
+	}
+	ref, err := StoreMediaData(ctx, userId, kind, resp.Header.Get("Content-Type"), data, sourceUrl)
+	if err != nil {
+		return sourceUrl, err
+	}
+	return ref, nil
+}
+
+// StoreMediaBase64 保存 base64 编码的生成结果，返回 media:// 引用
+func StoreMediaBase64(ctx context.Context, userId int, kind string, b64 string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		return "", err
+	}
+	return StoreMediaData(ctx, userId, kind, "", data, "")
+}
+
+// StoreMediaData 按用户分组的保存天数与存储上限保存数据，返回 media:// 引用
+func StoreMediaData(ctx context.Context, userId int, kind string, contentType string, data []byte, sourceUrl string) (string, error) {
+	storageSetting := operation_setting.GetMediaStorageSetting()
+	group, err := model.GetUserGroup(userId, false)
+	if err != nil {
+		return "", err
+	}
+	if maxBytes := storageSetting.GetMaxStorageBytes(group); maxBytes > 0 {
+		usage, err := model.GetUserMediaUsage(userId)
+		if err != nil {
+			return "", err
+		}
+		if usage+int64(len(data)) > maxBytes {
+			return "", fmt.Errorf("user %d media storage exceeds limit %d MB", userId, maxBytes/1024/1024)
+		}
+	}
+	if contentType == "" || contentType == "application/octet-stream" {
+		contentType = http.DetectContentType(data)
+	}
+	store, err := GetMediaStore(storageSetting.Backend)
+	if err != nil {
+		return "", err
+	}
+	key := common.GetUUID()
+	if err := store.Put(ctx, key, contentType, data); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	asset := &model.MediaAsset{
+		UserId:      userId,
+		Key:         key,
+		Kind:        kind,
+		Backend:     storageSetting.Backend,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		SourceUrl:   sourceUrl,
+		CreatedAt:   now.Unix(),
+	}
+	userSetting, _ := model.GetUserSetting(userId, false)
+	if days := storageSetting.GetRetentionDays(group, userSetting.MediaRetentionDays); days > 0 {
+		asset.ExpiresAt = now.AddDate(0, 0, days).Unix()
+	}
+	if err := asset.Insert(); err != nil {
+		_ = store.Delete(ctx, key)
+		return "", err
+	}
+	return MediaRefPrefix + key, nil
+}
+
+// StoreMediaInJSON 保存 JSON 对象（或对象数组）中指定字段的链接，fields 为字段名到媒体类型的映射
+func StoreMediaInJSON(ctx context.Context, userId int, data []byte, fields map[string]string) []byte {
+	var items []map[string]any
+	isArray := true
+	if err := common.Unmarshal(data, &items); err != nil {
+		var item map[string]any
+		if err := common.Unmarshal(data, &item); err != nil {
+			return data
+		}
+		items = []map[string]any{item}
+		isArray = false
+	}
+	changed := false
+	for _, item := range items {
+		for field, kind := range fields {
+			sourceUrl, ok := item[field].(string)
+			if !ok || sourceUrl == "" {
+				continue
+			}
+			ref, err := StoreMediaFromUrl(ctx, userId, kind, sourceUrl)
+			if err != nil {
+				common.LogError(ctx, fmt.Sprintf("store media %s failed: %s", sourceUrl, err.Error()))
+				continue
+			}
+			if ref != sourceUrl {
+				item[field] = ref
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return data
+	}
+	var result []byte
+	var err error
+	if isArray {
+		result, err = common.Marshal(items)
+	} else {
+		result, err = common.Marshal(items[0])
+	}
+	if err != nil {
+		return data
+	}
+	return result
+}
+
+func signMedia(key string, expires int64) string {
+	return common.GenerateHMAC("media:" + key + ":" + strconv.FormatInt(expires, 10))
+}
+
+// SignMediaUrl 生成带过期时间的访问链接
+func SignMediaUrl(key string) string {
+	expireSeconds := operation_setting.GetMediaStorageSetting().SignedUrlExpireSeconds
+	if expireSeconds <= 0 {
+		expireSeconds = 3600
+	}
+	expires := time.Now().Add(time.Duration(expireSeconds) * time.Second).Unix()
+	return fmt.Sprintf("%s/media/%s?expires=%d&signature=%s", setting.ServerAddress, key, expires, signMedia(key, expires))
+}
+
+func VerifyMediaSignature(key string, expires int64, signature string) bool {
+	if expires < time.Now().Unix() {
+		return false
+	}
+	return hmac.Equal([]byte(signMedia(key, expires)), []byte(signature))
+}
+
+// ResolveMediaUrl 将 media:// 引用替换为签名链接，其余地址原样返回
+func ResolveMediaUrl(ref string) string {
+	if key, ok := strings.CutPrefix(ref, MediaRefPrefix); ok {
+		return SignMediaUrl(key)
+	}
+	return ref
+}
+
+// ResolveMediaRefs 替换 JSON 等文本中的全部 media:// 引用
+func ResolveMediaRefs(data []byte) []byte {
+	if !bytes.Contains(data, []byte(MediaRefPrefix)) {
+		return data
+	}
+	return mediaRefRegexp.ReplaceAllFunc(data, func(ref []byte) []byte {
+		return []byte(ResolveMediaUrl(string(ref)))
+	})
+}
+
+// CleanupExpiredMedia 定时删除超过保存期限的生成结果
+func CleanupExpiredMedia(frequency time.Duration) {
+	for {
+		time.Sleep(frequency)
+		for {
+			assets, err := model.GetExpiredMediaAssets(time.Now().Unix(), 100)
+			if err != nil {
+				common.SysError("failed to get expired media assets: " + err.Error())
+				break
+			}
+			deleted := 0
+			for _, asset := range assets {
+				store, err := GetMediaStore(asset.Backend)
+				if err == nil {
+					err = store.Delete(context.Background(), asset.Key)
+				}
+				if err != nil {
+					common.SysError(fmt.Sprintf("failed to delete media %s: %s", asset.Key, err.Error()))
+					continue
+				}
+				if err := asset.Delete(); err != nil {
+					common.SysError(fmt.Sprintf("failed to delete media asset %d: %s", asset.Id, err.Error()))
+					continue
+				}
+				deleted++
+			}
+			// 整批删除失败时留到下个周期重试，避免空转
+			if len(assets) < 100 || deleted == 0 {
+				break
+			}
+		}
+	}
+}
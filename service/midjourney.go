@@ -179,6 +179,14 @@ func DoMidjourneyHttpRequest(c *gin.Context, timeout time.Duration, fullRequestU
 		if !setting.MjNotifyEnabled {
 			delete(mapResult, "notifyHook")
 		}
+		// callback_url 由网关在任务完成时通知，不转发给上游
+		delete(mapResult, "callback_url")
+		// 提交任务时由网关接收上游回调，客户端自带的回调地址优先
+		if _, ok := mapResult["notifyHook"]; !ok && mapResult != nil && strings.Contains(c.Request.URL.Path, "/submit/") {
+			if callbackUrl := GetTaskCallbackUrl(c, constant.TaskPlatformMidjourney, common.GetContextKeyInt(c, constant.ContextKeyChannelId)); callbackUrl != "" {
+				mapResult["notifyHook"] = callbackUrl
+			}
+		}
 		//req, err := http.NewRequest(c.Request.Method, fullRequestURL, requestBody)
 		// make new request with mapResult
 	}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
@@ -110,6 +111,11 @@ func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usag
 	if ok {
 		actualGroupRatio = userGroupRatio
 	}
+	// 与日志中的倍率一致，应用会话开始时命中的分时计价
+	if relayInfo.TimePricing != nil {
+		actualGroupRatio *= relayInfo.TimePricing.GroupMultiplier
+		modelRatio *= relayInfo.TimePricing.ModelMultiplier
+	}
 
 	quotaInfo := QuotaInfo{
 		InputDetails: TokenDetails{
@@ -508,3 +514,40 @@ func checkAndSendQuotaNotify(relayInfo *relaycommon.RelayInfo, quota int, preCon
 		}
 	})
 }
+
+// AdjustBackgroundQuota 在后台（无请求上下文）按差额调整用户与令牌额度并记录消费日志，delta 为负数表示退还，
+// 令牌已删除时只调整用户额度
+func AdjustBackgroundQuota(ctx context.Context, userId int, tokenId int, delta int, params model.RecordConsumeLogParams) error {
+	if delta == 0 {
+		return nil
+	}
+	var err error
+	if delta > 0 {
+		err = model.DecreaseUserQuota(userId, delta)
+	} else {
+		err = model.IncreaseUserQuota(userId, -delta, false)
+	}
+	if err != nil {
+		return err
+	}
+	if tokenId > 0 {
+		token, err := model.GetTokenById(tokenId)
+		if err != nil {
+			common.LogWarn(ctx, "get token failed: "+err.Error())
+		} else {
+			params.TokenName = token.Name
+			if delta > 0 {
+				err = model.DecreaseTokenQuota(token.Id, token.Key, delta)
+			} else {
+				err = model.IncreaseTokenQuota(token.Id, token.Key, -delta)
+			}
+			if err != nil {
+				common.LogError(ctx, "fail to adjust token quota: "+err.Error())
+			}
+		}
+	}
+	params.TokenId = tokenId
+	params.Quota = delta
+	model.RecordBackgroundConsumeLog(ctx, userId, params)
+	return nil
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"container/list"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/setting/operation_setting"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// ResponseCacheHeader 客户端可通过该请求头跳过响应缓存，命中时响应中也会带上该头
+	ResponseCacheHeader = "X-Response-Cache"
+
+	responseCacheKeyPrefix = "response_cache:"
+)
+
+type ResponseCacheEntry struct {
+	IsStream    bool      `json:"is_stream"`
+	ContentType string    `json:"content_type"`
+	Body        string    `json:"body,omitempty"`
+	Chunks      []string  `json:"chunks,omitempty"`
+	Usage       dto.Usage `json:"usage"`
+	CreatedAt   int64     `json:"created_at"`
+}
+
+// IsResponseCacheOptOut 判断客户端是否通过请求头关闭了本次请求的缓存
+func IsResponseCacheOptOut(headerValue string) bool {
+	switch strings.ToLower(strings.TrimSpace(headerValue)) {
+	case "no-cache", "no-store", "bypass", "off", "false", "0":
+		return true
+	}
+	return false
+}
+
+// GenerateResponseCacheKey 根据中继模式、上游模型、分组、渠道模拟方式以及规范化后的请求体生成缓存键
+func GenerateResponseCacheKey(relayMode int, upstreamModel string, group string, variant string, request any) (string, error) {
+	// 结构体按字段顺序序列化，map 按 key 排序，因此 json 序列化结果即为规范化后的请求
+	data, err := common.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	raw := fmt.Sprintf("%d|%s|%s|%s|", relayMode, upstreamModel, group, variant)
+	hash := common.Sha256Raw(append([]byte(raw), data...))
+	return responseCacheKeyPrefix + hex.EncodeToString(hash), nil
+}
+
+func GetResponseCache(key string) (*ResponseCacheEntry, bool) {
+	if common.RedisEnabled {
+		val, err := common.RedisGet(key)
+		if err != nil {
+			if !errors.Is(err, redis.Nil) {
+				common.SysError("get response cache failed: " + err.Error())
+			}
+			return nil, false
+		}
+		var entry ResponseCacheEntry
+		if err := common.UnmarshalJsonStr(val, &entry); err != nil {
+			return nil, false
+		}
+		return &entry, true
+	}
+	return memoryResponseCache.get(key)
+}
+
+func SetResponseCache(key string, entry *ResponseCacheEntry) {
+	cacheSetting := operation_setting.GetResponseCacheSetting()
+	data, err := common.Marshal(entry)
+	if err != nil {
+		common.SysError("marshal response cache failed: " + err.Error())
+		return
+	}
+	if cacheSetting.MaxEntryBytes > 0 && len(data) > cacheSetting.MaxEntryBytes {
+		return
+	}
+	ttl := time.Duration(cacheSetting.TTLSeconds) * time.Second
+	if common.RedisEnabled {
+		if err := common.RedisSet(key, string(data), ttl); err != nil {
+			common.SysError("set response cache failed: " + err.Error())
+		}
+		return
+	}
+	memoryResponseCache.set(key, entry, ttl, cacheSetting.MaxMemoryEntries)
+}
+
+// lruCache 未启用 Redis 时使用的有界内存缓存
+type lruCache struct {
+	mutex sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type lruItem struct {
+	key       string
+	value     *ResponseCacheEntry
+	expiresAt time.Time
+}
+
+var memoryResponseCache = &lruCache{
+	ll:    list.New(),
+	items: make(map[string]*list.Element),
+}
+
+func (l *lruCache) get(key string) (*ResponseCacheEntry, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	elem, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	item := elem.Value.(*lruItem)
+	if !item.expiresAt.IsZero() && time.Now().After(item.expiresAt) {
+		l.ll.Remove(elem)
+		delete(l.items, key)
+		return nil, false
+	}
+	l.ll.MoveToFront(elem)
+	return item.value, true
+}
+
+func (l *lruCache) set(key string, value *ResponseCacheEntry, ttl time.Duration, maxEntries int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+	if elem, ok := l.items[key]; ok {
+		l.ll.MoveToFront(elem)
+		item := elem.Value.(*lruItem)
+		item.value = value
+		item.expiresAt = expiresAt
+		return
+	}
+	l.items[key] = l.ll.PushFront(&lruItem{key: key, value: value, expiresAt: expiresAt})
+	if maxEntries <= 0 {
+		return
+	}
+	for l.ll.Len() > maxEntries {
+		oldest := l.ll.Back()
+		if oldest == nil {
+			break
+		}
+		l.ll.Remove(oldest)
+		delete(l.items, oldest.Value.(*lruItem).key)
+	}
+}
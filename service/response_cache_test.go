@@ -0,0 +1,86 @@
+package service
+
+import (
+	"container/list"
+	"fmt"
+	"testing"
+	"time"
+
+	"one-api/dto"
+)
+
+func TestGenerateResponseCacheKey(t *testing.T) {
+	temperature := 0.0
+	request := func(content string) *dto.GeneralOpenAIRequest {
+		return &dto.GeneralOpenAIRequest{
+			Model:       "gpt-4o",
+			Temperature: &temperature,
+			Messages:    []dto.Message{{Role: "user", Content: content}},
+		}
+	}
+	base, err := GenerateResponseCacheKey(1, "gpt-4o", "default", "", request("hello"))
+	if err != nil {
+		t.Fatalf("generate key failed: %s", err)
+	}
+	if again, _ := GenerateResponseCacheKey(1, "gpt-4o", "default", "", request("hello")); again != base {
+		t.Errorf("same request produced different keys: %s %s", base, again)
+	}
+	cases := []struct {
+		name          string
+		relayMode     int
+		upstreamModel string
+		group         string
+		variant       string
+		content       string
+	}{
+		{"relay mode", 2, "gpt-4o", "default", "", "hello"},
+		{"upstream model", 1, "gpt-4o-mini", "default", "", "hello"},
+		{"group", 1, "gpt-4o", "vip", "", "hello"},
+		{"emulation variant", 1, "gpt-4o", "default", "tool_call_emulation", "hello"},
+		{"request body", 1, "gpt-4o", "default", "", "hello!"},
+	}
+	for _, c := range cases {
+		key, err := GenerateResponseCacheKey(c.relayMode, c.upstreamModel, c.group, c.variant, request(c.content))
+		if err != nil {
+			t.Fatalf("%s: generate key failed: %s", c.name, err)
+		}
+		if key == base {
+			t.Errorf("%s: key should differ", c.name)
+		}
+	}
+}
+
+func TestIsResponseCacheOptOut(t *testing.T) {
+	cases := map[string]bool{
+		"":          false,
+		"HIT":       false,
+		"no-cache":  true,
+		" No-Store": true,
+		"bypass":    true,
+		"0":         true,
+	}
+	for header, want := range cases {
+		if got := IsResponseCacheOptOut(header); got != want {
+			t.Errorf("IsResponseCacheOptOut(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
+
+func TestLruCacheExpiryAndEviction(t *testing.T) {
+	cache := &lruCache{ll: list.New(), items: make(map[string]*list.Element)}
+	for i := 0; i < 3; i++ {
+		cache.set(fmt.Sprintf("key%d", i), &ResponseCacheEntry{Body: fmt.Sprint(i)}, 0, 2)
+	}
+	if _, ok := cache.get("key0"); ok {
+		t.Error("oldest entry should be evicted")
+	}
+	if entry, ok := cache.get("key2"); !ok || entry.Body != "2" {
+		t.Error("newest entry should be kept")
+	}
+
+	cache.set("expiring", &ResponseCacheEntry{}, time.Millisecond, 0)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := cache.get("expiring"); ok {
+		t.Error("expired entry should not be returned")
+	}
+}
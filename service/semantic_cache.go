@@ -0,0 +1,129 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"one-api/setting/operation_setting"
+	"sync"
+)
+
+// SemanticIndex 语义缓存的向量索引，namespace 用于隔离不同分组与模型的缓存
+type SemanticIndex interface {
+	Search(namespace string, vector []float64, threshold float64) (key string, score float64, ok bool)
+	Add(namespace string, key string, vector []float64)
+	Remove(namespace string, key string)
+}
+
+var semanticIndexFactories = map[string]func() SemanticIndex{
+	"memory": func() SemanticIndex {
+		return newMemorySemanticIndex()
+	},
+}
+
+var (
+	semanticIndex     SemanticIndex
+	semanticIndexType string
+	semanticIndexLock sync.Mutex
+)
+
+// RegisterSemanticIndex 注册自定义的向量索引实现，配置 index_type 后生效
+func RegisterSemanticIndex(name string, factory func() SemanticIndex) {
+	semanticIndexLock.Lock()
+	defer semanticIndexLock.Unlock()
+	semanticIndexFactories[name] = factory
+}
+
+func GetSemanticIndex() SemanticIndex {
+	indexType := operation_setting.GetSemanticCacheSetting().IndexType
+	semanticIndexLock.Lock()
+	defer semanticIndexLock.Unlock()
+	if semanticIndex != nil && semanticIndexType == indexType {
+		return semanticIndex
+	}
+	factory, ok := semanticIndexFactories[indexType]
+	if !ok {
+		factory = semanticIndexFactories["memory"]
+	}
+	semanticIndex = factory()
+	semanticIndexType = indexType
+	return semanticIndex
+}
+
+// SemanticCacheNamespace 只在同一命名空间内检索相似请求，context 为检索文本之前的对话上下文摘要
+func SemanticCacheNamespace(group string, upstreamModel string, isStream bool, context string) string {
+	return fmt.Sprintf("%s|%s|%t|%s", group, upstreamModel, isStream, context)
+}
+
+func CosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+type semanticVector struct {
+	key    string
+	vector []float64
+}
+
+// memorySemanticIndex 进程内暴力检索索引，每个 namespace 超出上限时淘汰最早写入的向量
+type memorySemanticIndex struct {
+	mutex   sync.RWMutex
+	vectors map[string][]semanticVector
+}
+
+func newMemorySemanticIndex() *memorySemanticIndex {
+	return &memorySemanticIndex{
+		vectors: make(map[string][]semanticVector),
+	}
+}
+
+func (m *memorySemanticIndex) Search(namespace string, vector []float64, threshold float64) (string, float64, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	bestKey := ""
+	bestScore := -1.0
+	for _, item := range m.vectors[namespace] {
+		score := CosineSimilarity(vector, item.vector)
+		if score > bestScore {
+			bestScore = score
+			bestKey = item.key
+		}
+	}
+	if bestKey == "" || bestScore < threshold {
+		return "", bestScore, false
+	}
+	return bestKey, bestScore, true
+}
+
+func (m *memorySemanticIndex) Add(namespace string, key string, vector []float64) {
+	maxEntries := operation_setting.GetSemanticCacheSetting().MaxEntries
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	items := append(m.vectors[namespace], semanticVector{key: key, vector: vector})
+	if maxEntries > 0 && len(items) > maxEntries {
+		items = items[len(items)-maxEntries:]
+	}
+	m.vectors[namespace] = items
+}
+
+func (m *memorySemanticIndex) Remove(namespace string, key string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	items := m.vectors[namespace]
+	for i, item := range items {
+		if item.key == key {
+			m.vectors[namespace] = append(items[:i], items[i+1:]...)
+			return
+		}
+	}
+}
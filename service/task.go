@@ -1,10 +1,27 @@
 package service
 
 import (
+	"context"
 	"one-api/constant"
+	"one-api/model"
 	"strings"
 )
 
 func CoverTaskActionToModelName(platform constant.TaskPlatform, action string) string {
 	return strings.ToLower(string(platform)) + "_" + strings.ToLower(action)
 }
+
+// AdjustTaskQuota 在后台按差额调整任务的用户与令牌额度并记录消费日志，delta 为负数表示退还
+func AdjustTaskQuota(ctx context.Context, task *model.Task, delta int, content string, other map[string]interface{}) error {
+	modelName := task.Properties.Model
+	if modelName == "" {
+		modelName = CoverTaskActionToModelName(task.Platform, task.Action)
+	}
+	return AdjustBackgroundQuota(ctx, task.UserId, task.Properties.TokenId, delta, model.RecordConsumeLogParams{
+		ChannelId: task.ChannelId,
+		ModelName: modelName,
+		Content:   content,
+		Group:     task.Properties.Group,
+		Other:     other,
+	})
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting/operation_setting"
+	"strings"
+)
+
+// ClassifyTaskFailure 按失败原因判断失败类型
+func ClassifyTaskFailure(reason string) string {
+	failureSetting := operation_setting.GetTaskFailureSetting()
+	lowerReason := strings.ToLower(reason)
+	for _, keyword := range failureSetting.ContentKeywords {
+		if keyword != "" && strings.Contains(lowerReason, strings.ToLower(keyword)) {
+			return operation_setting.TaskFailureClassContent
+		}
+	}
+	for _, keyword := range failureSetting.RetryableKeywords {
+		if keyword != "" && strings.Contains(lowerReason, strings.ToLower(keyword)) {
+			return operation_setting.TaskFailureClassRetryable
+		}
+	}
+	return operation_setting.TaskFailureClassOther
+}
+
+// RefundTaskQuota 按失败类型退还任务额度并记录退款日志
+func RefundTaskQuota(ctx context.Context, task *model.Task, failureClass string) {
+	if task.Quota == 0 {
+		return
+	}
+	ratio := operation_setting.GetTaskFailureSetting().GetRefundRatio(failureClass)
+	quota := int(float64(task.Quota) * ratio)
+	if quota <= 0 {
+		return
+	}
+	logContent := fmt.Sprintf("异步任务执行失败 %s（%s），退还 %s（%.0f%%）", task.GetClientTaskID(), failureClass, common.LogQuota(quota), ratio*100)
+	other := map[string]interface{}{
+		"task_id":       task.GetClientTaskID(),
+		"failure_class": failureClass,
+		"refund":        true,
+	}
+	if err := AdjustTaskQuota(ctx, task, -quota, logContent, other); err != nil {
+		common.LogError(ctx, "fail to refund task quota: "+err.Error())
+	}
+}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"testing"
+
+	"one-api/setting/operation_setting"
+)
+
+func TestClassifyTaskFailure(t *testing.T) {
+	cases := []struct {
+		reason string
+		want   string
+	}{
+		{"Prompt violates the Content Policy", operation_setting.TaskFailureClassContent},
+		{"内容审核未通过", operation_setting.TaskFailureClassContent},
+		{"Upstream Timeout", operation_setting.TaskFailureClassRetryable},
+		{"429 Too Many Requests", operation_setting.TaskFailureClassRetryable},
+		{"服务繁忙，请稍后再试", operation_setting.TaskFailureClassRetryable},
+		// 同时命中时按内容审核处理，不重新提交
+		{"moderation service timeout", operation_setting.TaskFailureClassContent},
+		{"invalid image size", operation_setting.TaskFailureClassOther},
+		{"", operation_setting.TaskFailureClassOther},
+	}
+	for _, c := range cases {
+		if got := ClassifyTaskFailure(c.reason); got != c.want {
+			t.Errorf("ClassifyTaskFailure(%q) = %s, want %s", c.reason, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"one-api/common"
+	"one-api/dto"
+	"one-api/model"
+)
+
+// CountSunoClips 统计 suno 任务结果中的歌曲数，歌词等非数组结果返回 0
+func CountSunoClips(data json.RawMessage) int {
+	var songs []dto.SunoSong
+	if err := json.Unmarshal(data, &songs); err != nil {
+		return 0
+	}
+	return len(songs)
+}
+
+// SettleTaskClipQuota 任务成功后按实际返回的歌曲数结算额度，多退少补
+func SettleTaskClipQuota(ctx context.Context, task *model.Task, clips int) {
+	clipQuota := task.Properties.ClipQuota
+	if clipQuota <= 0 || clips <= 0 {
+		return
+	}
+	actualQuota := clipQuota * clips
+	delta := actualQuota - task.Quota
+	if delta == 0 {
+		return
+	}
+	oldQuota := task.Quota
+	task.Quota = actualQuota
+	updated, err := task.UpdateQuotaWithOld(oldQuota)
+	if err != nil || !updated {
+		task.Quota = oldQuota
+		if err != nil {
+			common.LogError(ctx, "fail to update task clip quota: "+err.Error())
+		}
+		return
+	}
+	logContent := fmt.Sprintf("音乐任务 %s 实际生成 %d 首，按歌曲结算 %s（调整 %s）", task.GetClientTaskID(), clips, common.LogQuota(actualQuota), common.LogQuota(delta))
+	other := map[string]interface{}{
+		"task_id":    task.GetClientTaskID(),
+		"clips":      clips,
+		"clip_quota": clipQuota,
+	}
+	if err := AdjustTaskQuota(ctx, task, delta, logContent, other); err != nil {
+		common.LogError(ctx, "fail to settle task clip quota: "+err.Error())
+	}
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"crypto/hmac"
+	"fmt"
+	"one-api/common"
+	"one-api/constant"
+	"one-api/setting"
+	"one-api/setting/operation_setting"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 支持向上游传入回调地址的任务平台
+var taskCallbackPlatforms = map[string]bool{
+	string(constant.TaskPlatformKling): true,
+	string(constant.TaskPlatformSuno):  true,
+	constant.TaskPlatformMidjourney:    true,
+}
+
+func IsTaskCallbackEnabled(platform string) bool {
+	return operation_setting.GetTaskUpdateSetting().CallbackEnabled && taskCallbackPlatforms[platform]
+}
+
+// 上游任务 id 在提交完成前未知，每次提交生成一个随机串写入回调地址并保存在任务上，签名覆盖该随机串，
+// 回调地址只能用于更新对应的任务
+const taskCallbackNonceKey = "task_callback_nonce"
+
+func signTaskCallback(platform string, channelId int, nonce string) string {
+	return common.GenerateHMAC(fmt.Sprintf("task_callback:%s:%d:%s", platform, channelId, nonce))
+}
+
+// GetTaskCallbackUrl 生成本次提交的回调地址，未启用回调时返回空字符串
+func GetTaskCallbackUrl(c *gin.Context, platform string, channelId int) string {
+	if !IsTaskCallbackEnabled(platform) {
+		return ""
+	}
+	nonce := common.GetRandomString(32)
+	c.Set(taskCallbackNonceKey, nonce)
+	return fmt.Sprintf("%s/task/callback/%s/%d/%s/%s", setting.ServerAddress, platform, channelId, nonce, signTaskCallback(platform, channelId, nonce))
+}
+
+// GetTaskCallbackNonce 获取本次提交生成的回调随机串，需要保存到任务上
+func GetTaskCallbackNonce(c *gin.Context) string {
+	return c.GetString(taskCallbackNonceKey)
+}
+
+func VerifyTaskCallbackSignature(platform string, channelId int, nonce string, signature string) bool {
+	return nonce != "" && hmac.Equal([]byte(signTaskCallback(platform, channelId, nonce)), []byte(signature))
+}
+
+// taskPollState 记录平台的轮询进度，没有任务进度变化时逐步拉长轮询间隔
+type taskPollState struct {
+	interval time.Duration
+	nextPoll time.Time
+	cursor   int64 // 分批轮询时上一批最后一个任务的 id
+}
+
+var (
+	taskPollStates     = make(map[string]*taskPollState)
+	taskPollStatesLock sync.Mutex
+)
+
+func getTaskPollBaseInterval(platform string) time.Duration {
+	updateSetting := operation_setting.GetTaskUpdateSetting()
+	seconds := updateSetting.PollIntervalSeconds
+	if IsTaskCallbackEnabled(platform) && updateSetting.CallbackPollIntervalSeconds > seconds {
+		seconds = updateSetting.CallbackPollIntervalSeconds
+	}
+	if seconds <= 0 {
+		seconds = 15
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func getTaskPollState(platform string) *taskPollState {
+	state, ok := taskPollStates[platform]
+	if !ok {
+		state = &taskPollState{interval: getTaskPollBaseInterval(platform)}
+		taskPollStates[platform] = state
+	}
+	return state
+}
+
+// ShouldPollTasks 判断平台是否到了下一次轮询时间，返回本批次的起始游标
+func ShouldPollTasks(platform string) (bool, int64) {
+	taskPollStatesLock.Lock()
+	defer taskPollStatesLock.Unlock()
+	state := getTaskPollState(platform)
+	return !time.Now().Before(state.nextPoll), state.cursor
+}
+
+// FinishTaskPoll 记录一次轮询结果：有进度变化时恢复基础间隔，否则加倍退避
+func FinishTaskPoll(platform string, changed bool, cursor int64) {
+	updateSetting := operation_setting.GetTaskUpdateSetting()
+	taskPollStatesLock.Lock()
+	defer taskPollStatesLock.Unlock()
+	state := getTaskPollState(platform)
+	baseInterval := getTaskPollBaseInterval(platform)
+	maxInterval := time.Duration(updateSetting.MaxPollIntervalSeconds) * time.Second
+	if changed || state.interval < baseInterval {
+		state.interval = baseInterval
+	} else if state.interval < maxInterval {
+		state.interval = min(state.interval*2, maxInterval)
+	}
+	state.cursor = cursor
+	state.nextPoll = time.Now().Add(state.interval)
+}
+
+// ResetTaskPoll 有新任务提交时恢复平台的基础轮询间隔
+func ResetTaskPoll(platform string) {
+	taskPollStatesLock.Lock()
+	defer taskPollStatesLock.Unlock()
+	state := getTaskPollState(platform)
+	state.interval = getTaskPollBaseInterval(platform)
+	if next := time.Now().Add(state.interval); next.Before(state.nextPoll) {
+		state.nextPoll = next
+	}
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"one-api/constant"
+	"one-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTaskCallbackSignature(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	updateSetting := operation_setting.GetTaskUpdateSetting()
+	oldSetting := *updateSetting
+	defer func() { *updateSetting = oldSetting }()
+	updateSetting.CallbackEnabled = true
+
+	platform := string(constant.TaskPlatformSuno)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	url := GetTaskCallbackUrl(c, platform, 7)
+	// 地址格式：/task/callback/{platform}/{channel_id}/{nonce}/{signature}
+	parts := strings.Split(url[strings.Index(url, "/task/callback/")+len("/task/callback/"):], "/")
+	if len(parts) != 4 || parts[0] != platform || parts[1] != "7" {
+		t.Fatalf("unexpected callback url %s", url)
+	}
+	nonce, signature := parts[2], parts[3]
+	tampered := []byte(signature)
+	tampered[0] ^= 1
+	if GetTaskCallbackNonce(c) != nonce {
+		t.Fatalf("nonce %s not saved on context", nonce)
+	}
+
+	cases := []struct {
+		name      string
+		platform  string
+		channelId int
+		nonce     string
+		signature string
+		want      bool
+	}{
+		{"valid", platform, 7, nonce, signature, true},
+		{"other channel", platform, 8, nonce, signature, false},
+		{"other platform", string(constant.TaskPlatformKling), 7, nonce, signature, false},
+		{"other nonce", platform, 7, nonce + "x", signature, false},
+		{"tampered signature", platform, 7, nonce, string(tampered), false},
+		{"empty nonce", platform, 7, "", signTaskCallback(platform, 7, ""), false},
+	}
+	for _, tc := range cases {
+		if got := VerifyTaskCallbackSignature(tc.platform, tc.channelId, tc.nonce, tc.signature); got != tc.want {
+			t.Errorf("%s: verify = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	// 每次提交使用不同的随机串
+	other, _ := gin.CreateTestContext(httptest.NewRecorder())
+	if GetTaskCallbackUrl(other, platform, 7) == url {
+		t.Error("callback urls of two submissions should differ")
+	}
+
+	updateSetting.CallbackEnabled = false
+	if url := GetTaskCallbackUrl(c, platform, 7); url != "" {
+		t.Errorf("disabled callback returned url %s", url)
+	}
+}
+
+func TestFinishTaskPollBackoff(t *testing.T) {
+	updateSetting := operation_setting.GetTaskUpdateSetting()
+	oldSetting := *updateSetting
+	defer func() { *updateSetting = oldSetting }()
+	updateSetting.CallbackEnabled = false
+	updateSetting.PollIntervalSeconds = 10
+	updateSetting.MaxPollIntervalSeconds = 35
+
+	platform := "test_backoff"
+	wantIntervals := []struct {
+		changed bool
+		seconds int
+	}{
+		{false, 20},
+		{false, 35},
+		{false, 35},
+		{true, 10},
+		{false, 20},
+	}
+	for i, want := range wantIntervals {
+		FinishTaskPoll(platform, want.changed, int64(i))
+		taskPollStatesLock.Lock()
+		state := taskPollStates[platform]
+		interval, cursor := state.interval, state.cursor
+		taskPollStatesLock.Unlock()
+		if interval.Seconds() != float64(want.seconds) || cursor != int64(i) {
+			t.Errorf("poll %d: interval %s cursor %d, want %ds %d", i, interval, cursor, want.seconds, i)
+		}
+	}
+	// 有新任务提交时恢复基础间隔
+	ResetTaskPoll(platform)
+	taskPollStatesLock.Lock()
+	interval := taskPollStates[platform].interval
+	taskPollStatesLock.Unlock()
+	if interval.Seconds() != 10 {
+		t.Errorf("interval after reset = %s, want 10s", interval)
+	}
+}
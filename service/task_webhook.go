@@ -0,0 +1,280 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/model"
+	"one-api/setting"
+	"one-api/setting/operation_setting"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+// TaskWebhookPayload 任务完成通知的负载数据
+type TaskWebhookPayload struct {
+	Event     string `json:"event"`
+	Platform  string `json:"platform"`
+	TaskId    string `json:"task_id"`
+	Status    string `json:"status"`
+	Timestamp int64  `json:"timestamp"`
+	Data      any    `json:"data"`
+}
+
+// ValidateTaskWebhookUrl 校验任务通知地址，要求用户已设置 webhook 密钥且地址不指向内网
+func ValidateTaskWebhookUrl(userId int, rawUrl string) error {
+	if rawUrl == "" {
+		return nil
+	}
+	if err := checkTaskWebhookHost(rawUrl); err != nil {
+		return err
+	}
+	userSetting, err := model.GetUserSetting(userId, false)
+	if err != nil {
+		return err
+	}
+	if userSetting.WebhookSecret == "" {
+		return errors.New("webhook secret is required before setting callback_url")
+	}
+	return nil
+}
+
+// taskWebhookBlockedNets 除标准库可识别的回环、私有等地址外，还需拒绝的网段
+var taskWebhookBlockedNets = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),     // 本网络
+	mustParseCIDR("100.64.0.0/10"), // 运营商级 NAT
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return ipNet
+}
+
+// isBlockedWebhookIP 判断通知地址解析到的 IP 是否为回环、私有、链路本地等不允许访问的地址
+func isBlockedWebhookIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return true
+	}
+	for _, ipNet := range taskWebhookBlockedNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	taskWebhookClient     *http.Client
+	taskWebhookClientOnce sync.Once
+)
+
+// getTaskWebhookClient 投递通知专用的客户端：建立连接时校验实际连接的 IP，防止 DNS 重绑定到内网；
+// 不跟随重定向，也不使用环境变量中的代理
+func getTaskWebhookClient() *http.Client {
+	taskWebhookClientOnce.Do(func() {
+		dialer := &net.Dialer{
+			Timeout: 10 * time.Second,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || isBlockedWebhookIP(ip) {
+					return fmt.Errorf("webhook address %s is not allowed", host)
+				}
+				return nil
+			},
+		}
+		taskWebhookClient = &http.Client{
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: 10 * time.Second,
+			},
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+	})
+	return taskWebhookClient
+}
+
+// checkTaskWebhookHost 解析通知地址的主机，拒绝回环、私有、链路本地等地址
+func checkTaskWebhookHost(rawUrl string) error {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return errors.New("callback_url must be a valid http or https url")
+	}
+	host := parsed.Hostname()
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		ips, err = net.LookupIP(host)
+		if err != nil || len(ips) == 0 {
+			return errors.New("callback_url host cannot be resolved")
+		}
+	}
+	for _, ip := range ips {
+		if isBlockedWebhookIP(ip) {
+			return errors.New("callback_url must not point to a private or local address")
+		}
+	}
+	return nil
+}
+
+// EnqueueTaskWebhook 记录一次任务完成通知并立即尝试投递，失败后由 RetryTaskWebhooks 按退避重试
+func EnqueueTaskWebhook(userId int, platform string, taskId string, webhookUrl string, status string, data any) {
+	webhookSetting := operation_setting.GetTaskWebhookSetting()
+	if !webhookSetting.Enabled || webhookUrl == "" {
+		return
+	}
+	payload, err := common.Marshal(TaskWebhookPayload{
+		Event:     "task." + status,
+		Platform:  platform,
+		TaskId:    taskId,
+		Status:    status,
+		Timestamp: time.Now().Unix(),
+		Data:      data,
+	})
+	if err != nil {
+		common.SysError(fmt.Sprintf("marshal task webhook payload of %s failed: %s", taskId, err.Error()))
+		return
+	}
+	delivery := &model.TaskWebhookDelivery{
+		UserId:   userId,
+		Platform: platform,
+		TaskId:   taskId,
+		Url:      webhookUrl,
+		Payload:  string(payload),
+		Status:   model.TaskWebhookStatusPending,
+		// 立即投递失败前不会被重试任务取到
+		NextRetryAt: time.Now().Unix() + int64(webhookSetting.RetryBaseSeconds),
+	}
+	if err := delivery.Insert(); err != nil {
+		common.SysError(fmt.Sprintf("insert task webhook delivery of %s failed: %s", taskId, err.Error()))
+		return
+	}
+	gopool.Go(func() {
+		DeliverTaskWebhook(delivery)
+	})
+}
+
+// DeliverTaskWebhook 投递一次通知并记录结果，签名使用用户设置中的 webhook 密钥
+func DeliverTaskWebhook(delivery *model.TaskWebhookDelivery) error {
+	webhookSetting := operation_setting.GetTaskWebhookSetting()
+	statusCode, err := sendTaskWebhook(delivery)
+	delivery.Attempts++
+	delivery.StatusCode = statusCode
+	if err == nil {
+		delivery.Status = model.TaskWebhookStatusSuccess
+		delivery.LastError = ""
+	} else {
+		delivery.LastError = err.Error()
+		if delivery.Attempts >= webhookSetting.MaxAttempts {
+			delivery.Status = model.TaskWebhookStatusFailed
+		} else {
+			backoff := int64(webhookSetting.RetryBaseSeconds) << (delivery.Attempts - 1)
+			delivery.NextRetryAt = time.Now().Unix() + backoff
+		}
+	}
+	if updateErr := delivery.Update(); updateErr != nil {
+		common.SysError(fmt.Sprintf("update task webhook delivery #%d failed: %s", delivery.Id, updateErr.Error()))
+	}
+	return err
+}
+
+func sendTaskWebhook(delivery *model.TaskWebhookDelivery) (int, error) {
+	payload := []byte(delivery.Payload)
+	headers := map[string]string{
+		"Content-Type":          "application/json",
+		"X-Webhook-Delivery-Id": strconv.Itoa(delivery.Id),
+	}
+	// 投递时再次校验地址，实际连接的 IP 由投递客户端在建立连接时校验
+	if err := checkTaskWebhookHost(delivery.Url); err != nil {
+		return 0, err
+	}
+	userSetting, err := model.GetUserSetting(delivery.UserId, false)
+	if err != nil {
+		return 0, err
+	}
+	if userSetting.WebhookSecret == "" {
+		return 0, errors.New("webhook secret is not set, refuse to send unsigned webhook")
+	}
+	headers["X-Webhook-Signature"] = generateSignature(userSetting.WebhookSecret, payload)
+
+	var resp *http.Response
+	if setting.EnableWorker() {
+		resp, err = DoWorkerRequest(&WorkerRequest{
+			URL:     delivery.Url,
+			Key:     setting.WorkerValidKey,
+			Method:  http.MethodPost,
+			Headers: headers,
+			Body:    payload,
+		})
+	} else {
+		timeout := time.Duration(operation_setting.GetTaskWebhookSetting().TimeoutSeconds) * time.Second
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		var req *http.Request
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(payload))
+		if err != nil {
+			return 0, err
+		}
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		resp, err = getTaskWebhookClient().Do(req)
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook request failed with status code: %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// RedeliverTaskWebhook 手动重新投递，不受最多投递次数限制
+func RedeliverTaskWebhook(id int) (*model.TaskWebhookDelivery, error) {
+	delivery, err := model.GetTaskWebhookDeliveryById(id)
+	if err != nil {
+		return nil, err
+	}
+	statusCode, err := sendTaskWebhook(delivery)
+	delivery.Attempts++
+	delivery.StatusCode = statusCode
+	if err == nil {
+		delivery.Status = model.TaskWebhookStatusSuccess
+		delivery.LastError = ""
+	} else {
+		delivery.LastError = err.Error()
+	}
+	if updateErr := delivery.Update(); updateErr != nil {
+		return nil, updateErr
+	}
+	return delivery, err
+}
+
+// RetryTaskWebhooks 定时重试投递失败的通知
+func RetryTaskWebhooks(frequency time.Duration) {
+	for {
+		time.Sleep(frequency)
+		for _, delivery := range model.GetDueTaskWebhookDeliveries(time.Now().Unix(), 100) {
+			if err := DeliverTaskWebhook(delivery); err != nil {
+				common.SysLog(fmt.Sprintf("task webhook delivery #%d attempt %d failed: %s", delivery.Id, delivery.Attempts, err.Error()))
+			}
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckTaskWebhookHost(t *testing.T) {
+	cases := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://8.8.8.8/hook", true},
+		{"http://1.1.1.1:8080/hook", true},
+		{"https://[2001:4860:4860::8888]/hook", true},
+		{"ftp://8.8.8.8/hook", false},
+		{"not a url", false},
+		{"https:///hook", false},
+		{"http://127.0.0.1/hook", false},
+		{"http://10.0.0.1/hook", false},
+		{"http://172.16.0.1/hook", false},
+		{"http://192.168.1.1/hook", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"http://100.64.0.1/hook", false},
+		{"http://100.127.255.254/hook", false},
+		{"http://0.0.0.0/hook", false},
+		{"http://0.1.2.3/hook", false},
+		{"http://[::1]/hook", false},
+		{"http://[fe80::1]/hook", false},
+		{"http://[fc00::1]/hook", false},
+		{"http://[::ffff:127.0.0.1]/hook", false},
+	}
+	for _, c := range cases {
+		err := checkTaskWebhookHost(c.url)
+		if c.allowed && err != nil {
+			t.Errorf("checkTaskWebhookHost(%q) should be allowed, got %s", c.url, err)
+		}
+		if !c.allowed && err == nil {
+			t.Errorf("checkTaskWebhookHost(%q) should be rejected", c.url)
+		}
+	}
+}
+
+func TestIsBlockedWebhookIP(t *testing.T) {
+	cases := map[string]bool{
+		"100.63.255.255": false,
+		"100.64.0.0":     true,
+		"100.128.0.0":    false,
+		"0.255.255.255":  true,
+		"1.0.0.0":        false,
+		"224.0.0.1":      true,
+	}
+	for ip, blocked := range cases {
+		if got := isBlockedWebhookIP(net.ParseIP(ip)); got != blocked {
+			t.Errorf("isBlockedWebhookIP(%s) = %v, want %v", ip, got, blocked)
+		}
+	}
+}
+
+func TestTaskWebhookClientRejectsLocalConnection(t *testing.T) {
+	// 通过校验的主机名在投递时解析到本地地址（DNS 重绑定）时，建立连接前被拒绝
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	resp, err := getTaskWebhookClient().Get(server.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatalf("connection to %s should be rejected", server.URL)
+	}
+}
+
+func TestTaskWebhookClientDoesNotFollowRedirects(t *testing.T) {
+	client := getTaskWebhookClient()
+	req := httptest.NewRequest(http.MethodPost, "http://169.254.169.254/latest/meta-data", nil)
+	if err := client.CheckRedirect(req, []*http.Request{req}); err != http.ErrUseLastResponse {
+		t.Errorf("redirects should not be followed, got %v", err)
+	}
+}
@@ -17,6 +17,8 @@ type ClaudeSettings struct {
 	DefaultMaxTokens                      map[string]int                 `json:"default_max_tokens"`
 	ThinkingAdapterEnabled                bool                           `json:"thinking_adapter_enabled"`
 	ThinkingAdapterBudgetTokensPercentage float64                        `json:"thinking_adapter_budget_tokens_percentage"`
+	BatchDiscount                         float64                        `json:"batch_discount"` // Message Batches 的计费折扣
+	FilesModel                            string                         `json:"files_model"`    // 上传文件时按该模型选择渠道
 }
 
 // 默认配置
@@ -27,6 +29,8 @@ var defaultClaudeSettings = ClaudeSettings{
 		"default": 8192,
 	},
 	ThinkingAdapterBudgetTokensPercentage: 0.8,
+	BatchDiscount:                         0.5,
+	FilesModel:                            "claude-sonnet-4-20250514",
 }
 
 // 全局实例
@@ -6,11 +6,13 @@ import (
 
 // GeminiSettings 定义Gemini模型的配置
 type GeminiSettings struct {
-	SafetySettings                        map[string]string `json:"safety_settings"`
-	VersionSettings                       map[string]string `json:"version_settings"`
-	SupportedImagineModels                []string          `json:"supported_imagine_models"`
-	ThinkingAdapterEnabled                bool              `json:"thinking_adapter_enabled"`
-	ThinkingAdapterBudgetTokensPercentage float64           `json:"thinking_adapter_budget_tokens_percentage"`
+	SafetySettings                        map[string]string  `json:"safety_settings"`
+	VersionSettings                       map[string]string  `json:"version_settings"`
+	SupportedImagineModels                []string           `json:"supported_imagine_models"`
+	ThinkingAdapterEnabled                bool               `json:"thinking_adapter_enabled"`
+	ThinkingAdapterBudgetTokensPercentage float64            `json:"thinking_adapter_budget_tokens_percentage"`
+	CacheStoragePrice                     map[string]float64 `json:"cache_storage_price"` // 上下文缓存每百万 token 每小时的存储价格（美元）
+	FilesModel                            string             `json:"files_model"`         // 上传文件时按该模型选择渠道
 }
 
 // 默认配置
@@ -29,6 +31,11 @@ var defaultGeminiSettings = GeminiSettings{
 	},
 	ThinkingAdapterEnabled:                false,
 	ThinkingAdapterBudgetTokensPercentage: 0.6,
+	CacheStoragePrice: map[string]float64{
+		"default":        1.0,
+		"gemini-2.5-pro": 4.5,
+	},
+	FilesModel: "gemini-2.5-flash",
 }
 
 // 全局实例
@@ -68,3 +75,11 @@ func IsGeminiModelSupportImagine(model string) bool {
 	}
 	return false
 }
+
+// GetGeminiCacheStoragePrice 获取上下文缓存的存储价格
+func GetGeminiCacheStoragePrice(model string) float64 {
+	if value, ok := geminiSettings.CacheStoragePrice[model]; ok {
+		return value
+	}
+	return geminiSettings.CacheStoragePrice["default"]
+}
@@ -0,0 +1,34 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+// AudioTranscodeSetting 转写前的音频转码与长音频切分
+type AudioTranscodeSetting struct {
+	Enabled              bool    `json:"enabled"`
+	MaxFileSizeMB        int     `json:"max_file_size_mb"`       // 上游允许的最大文件大小
+	MaxDurationSeconds   float64 `json:"max_duration_seconds"`   // 上游允许的最大音频时长
+	ChunkDurationSeconds float64 `json:"chunk_duration_seconds"` // 切分后每段的最大时长
+	SilenceSearchSeconds float64 `json:"silence_search_seconds"` // 在每段末尾多长范围内寻找静音作为切分点
+	Concurrency          int     `json:"concurrency"`            // 分段并发转写数
+	PcmSampleRate        int     `json:"pcm_sample_rate"`        // 裸 pcm 上传未指定 sample_rate 时使用的采样率
+}
+
+// 默认配置
+var audioTranscodeSetting = AudioTranscodeSetting{
+	Enabled:              false,
+	MaxFileSizeMB:        25,
+	MaxDurationSeconds:   1400,
+	ChunkDurationSeconds: 600,
+	SilenceSearchSeconds: 10,
+	Concurrency:          4,
+	PcmSampleRate:        24000,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("audio_transcode_setting", &audioTranscodeSetting)
+}
+
+func GetAudioTranscodeSetting() *AudioTranscodeSetting {
+	return &audioTranscodeSetting
+}
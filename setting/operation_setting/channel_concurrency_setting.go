@@ -0,0 +1,26 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+// ChannelConcurrencySetting 渠道达到最大并发数时的排队策略
+type ChannelConcurrencySetting struct {
+	QueueEnabled        bool `json:"queue_enabled"`         // 同优先级渠道全部满载时排队等待，关闭时直接返回错误
+	QueueTimeoutSeconds int  `json:"queue_timeout_seconds"` // 排队等待的最长时间
+	MaxQueueLength      int  `json:"max_queue_length"`      // 同时排队的最大请求数，0 表示不限制
+}
+
+// 默认配置
+var channelConcurrencySetting = ChannelConcurrencySetting{
+	QueueEnabled:        true,
+	QueueTimeoutSeconds: 10,
+	MaxQueueLength:      1000,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("channel_concurrency_setting", &channelConcurrencySetting)
+}
+
+func GetChannelConcurrencySetting() *ChannelConcurrencySetting {
+	return &channelConcurrencySetting
+}
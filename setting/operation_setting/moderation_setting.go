@@ -0,0 +1,41 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+const (
+	ModerationBackendOpenAI = "openai" // 转发到支持 moderation 接口的渠道
+	ModerationBackendChat   = "chat"   // 使用对话模型按分类提示词打分
+	ModerationBackendLocal  = "local"  // 使用本地敏感词库匹配
+)
+
+// ModerationSetting /v1/moderations 的审核后端配置
+type ModerationSetting struct {
+	Backend       string  `json:"backend"`
+	ChatModel     string  `json:"chat_model"`     // chat 后端使用的模型，按正常对话请求计费
+	ChatPrompt    string  `json:"chat_prompt"`    // chat 后端的分类提示词，为空时使用内置提示词
+	FlagThreshold float64 `json:"flag_threshold"` // chat 后端某一类别得分达到该值时标记
+	LocalCategory string  `json:"local_category"` // local 后端命中敏感词时标记的类别
+}
+
+// 默认配置
+var moderationSetting = ModerationSetting{
+	Backend:       ModerationBackendOpenAI,
+	ChatModel:     "gpt-4o-mini",
+	ChatPrompt:    "",
+	FlagThreshold: 0.5,
+	LocalCategory: "illicit",
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("moderation_setting", &moderationSetting)
+}
+
+func GetModerationSetting() *ModerationSetting {
+	return &moderationSetting
+}
+
+// UseLocalModeration 是否由网关自行完成审核而不转发到渠道
+func (s *ModerationSetting) UseLocalModeration() bool {
+	return s.Backend == ModerationBackendChat || s.Backend == ModerationBackendLocal
+}
@@ -0,0 +1,37 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+// MusicPricingSetting 音乐生成按返回的歌曲数计费
+type MusicPricingSetting struct {
+	ClipBillingEnabled bool           `json:"clip_billing_enabled"` // 启用后模型固定价格为单首歌曲价格
+	ActionClips        map[string]int `json:"action_clips"`         // 各操作预计返回的歌曲数，用于提交时预扣，未配置的操作按次计费
+}
+
+// 默认配置
+var musicPricingSetting = MusicPricingSetting{
+	ClipBillingEnabled: false,
+	ActionClips: map[string]int{
+		"MUSIC":  2,
+		"EXTEND": 2,
+		"COVER":  2,
+		"STEMS":  2,
+	},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("music_pricing_setting", &musicPricingSetting)
+}
+
+func GetMusicPricingSetting() *MusicPricingSetting {
+	return &musicPricingSetting
+}
+
+// GetExpectedClips 返回操作预计生成的歌曲数，0 表示该操作不按歌曲计费
+func (s *MusicPricingSetting) GetExpectedClips(action string) int {
+	if !s.ClipBillingEnabled {
+		return 0
+	}
+	return s.ActionClips[action]
+}
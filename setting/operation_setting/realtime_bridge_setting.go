@@ -0,0 +1,44 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+// RealtimeBridgePipeline 实时语音桥接使用的组件模型
+type RealtimeBridgePipeline struct {
+	TranscriptionModel string `json:"transcription_model"` // 语音转写模型，如 whisper-1
+	ChatModel          string `json:"chat_model"`          // 对话模型，可以是任意渠道的 chat 模型
+	TtsModel           string `json:"tts_model"`           // 语音合成模型，需支持输出 24kHz pcm
+	Voice              string `json:"voice"`               // 客户端未指定音色时使用的默认音色
+}
+
+// RealtimeBridgeSetting 以转写、对话、语音合成渠道组合提供 /v1/realtime 接口
+type RealtimeBridgeSetting struct {
+	Enabled   bool                              `json:"enabled"`
+	Pipelines map[string]RealtimeBridgePipeline `json:"pipelines"` // 键为客户端请求的 realtime 模型名
+}
+
+// 默认配置
+var realtimeBridgeSetting = RealtimeBridgeSetting{
+	Enabled:   false,
+	Pipelines: map[string]RealtimeBridgePipeline{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("realtime_bridge_setting", &realtimeBridgeSetting)
+}
+
+func GetRealtimeBridgeSetting() *RealtimeBridgeSetting {
+	return &realtimeBridgeSetting
+}
+
+// GetPipeline 返回模型对应的桥接配置，未启用或未配置时返回 false
+func (s *RealtimeBridgeSetting) GetPipeline(modelName string) (RealtimeBridgePipeline, bool) {
+	if !s.Enabled {
+		return RealtimeBridgePipeline{}, false
+	}
+	pipeline, ok := s.Pipelines[modelName]
+	if !ok || pipeline.TranscriptionModel == "" || pipeline.ChatModel == "" || pipeline.TtsModel == "" {
+		return RealtimeBridgePipeline{}, false
+	}
+	return pipeline, true
+}
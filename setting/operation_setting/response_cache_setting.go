@@ -0,0 +1,45 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+// ResponseCacheSetting 确定性请求（embedding、temperature=0 的 chat）的响应缓存配置
+type ResponseCacheSetting struct {
+	Enabled          bool    `json:"enabled"`
+	ChatEnabled      bool    `json:"chat_enabled"`
+	EmbeddingEnabled bool    `json:"embedding_enabled"`
+	TTLSeconds       int     `json:"ttl_seconds"`
+	MaxMemoryEntries int     `json:"max_memory_entries"`
+	MaxEntryBytes    int     `json:"max_entry_bytes"`
+	BillingRatio     float64 `json:"billing_ratio"`
+}
+
+// 默认配置
+var responseCacheSetting = ResponseCacheSetting{
+	Enabled:          false,
+	ChatEnabled:      true,
+	EmbeddingEnabled: true,
+	TTLSeconds:       3600,
+	MaxMemoryEntries: 1000,
+	MaxEntryBytes:    1 << 20, // 1MB
+	BillingRatio:     0,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("response_cache_setting", &responseCacheSetting)
+}
+
+func GetResponseCacheSetting() *ResponseCacheSetting {
+	return &responseCacheSetting
+}
+
+// GetBillingRatio 缓存命中时的计费倍率，范围 [0, 1]
+func (s *ResponseCacheSetting) GetBillingRatio() float64 {
+	if s.BillingRatio < 0 {
+		return 0
+	}
+	if s.BillingRatio > 1 {
+		return 1
+	}
+	return s.BillingRatio
+}
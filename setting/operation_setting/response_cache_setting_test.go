@@ -0,0 +1,22 @@
+package operation_setting
+
+import "testing"
+
+func TestResponseCacheBillingRatio(t *testing.T) {
+	cases := []struct {
+		configured float64
+		want       float64
+	}{
+		{0, 0},
+		{0.1, 0.1},
+		{1, 1},
+		{-0.5, 0},
+		{2, 1},
+	}
+	for _, c := range cases {
+		setting := ResponseCacheSetting{BillingRatio: c.configured}
+		if got := setting.GetBillingRatio(); got != c.want {
+			t.Errorf("GetBillingRatio() with %v = %v, want %v", c.configured, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package operation_setting
+
+import (
+	"one-api/common"
+	"one-api/setting/config"
+)
+
+// SemanticCacheSetting 基于 embedding 相似度的语义缓存配置
+type SemanticCacheSetting struct {
+	Enabled             bool               `json:"enabled"`
+	EmbeddingModel      string             `json:"embedding_model"`
+	IndexType           string             `json:"index_type"`
+	SimilarityThreshold float64            `json:"similarity_threshold"`
+	ModelThresholds     map[string]float64 `json:"model_thresholds"`
+	EnabledGroups       []string           `json:"enabled_groups"`
+	EnabledModels       []string           `json:"enabled_models"`
+	MaxEntries          int                `json:"max_entries"`
+	MaxPromptLength     int                `json:"max_prompt_length"`
+}
+
+// 默认配置
+var semanticCacheSetting = SemanticCacheSetting{
+	Enabled:             false,
+	EmbeddingModel:      "text-embedding-3-small",
+	IndexType:           "memory",
+	SimilarityThreshold: 0.95,
+	ModelThresholds:     map[string]float64{},
+	EnabledGroups:       []string{},
+	EnabledModels:       []string{},
+	MaxEntries:          10000,
+	MaxPromptLength:     4096,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("semantic_cache_setting", &semanticCacheSetting)
+}
+
+func GetSemanticCacheSetting() *SemanticCacheSetting {
+	return &semanticCacheSetting
+}
+
+// IsEnabledFor 判断指定分组和模型是否开启语义缓存，列表为空表示不限制
+func (s *SemanticCacheSetting) IsEnabledFor(group string, model string) bool {
+	if !s.Enabled || s.EmbeddingModel == "" {
+		return false
+	}
+	if len(s.EnabledGroups) > 0 && !common.StringsContains(s.EnabledGroups, group) {
+		return false
+	}
+	if len(s.EnabledModels) > 0 && !common.StringsContains(s.EnabledModels, model) {
+		return false
+	}
+	return true
+}
+
+// GetThreshold 获取模型的相似度阈值，未单独配置时使用全局阈值
+func (s *SemanticCacheSetting) GetThreshold(model string) float64 {
+	if threshold, ok := s.ModelThresholds[model]; ok {
+		return threshold
+	}
+	return s.SimilarityThreshold
+}
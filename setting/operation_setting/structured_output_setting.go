@@ -0,0 +1,26 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+// StructuredOutputSetting response_format 为 json_schema 时对响应的校验策略，仅作用于非流式请求
+type StructuredOutputSetting struct {
+	ValidationEnabled bool `json:"validation_enabled"` // 按 JSON Schema 校验所有渠道的响应，模拟结构化输出的渠道总是校验
+	RepairEnabled     bool `json:"repair_enabled"`     // 校验失败时尝试修复，如去除 markdown 代码块、截取 JSON 部分
+	RetryEnabled      bool `json:"retry_enabled"`      // 修复后仍不符合时携带错误信息重试一次
+}
+
+// 默认配置
+var structuredOutputSetting = StructuredOutputSetting{
+	ValidationEnabled: false,
+	RepairEnabled:     true,
+	RetryEnabled:      false,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("structured_output_setting", &structuredOutputSetting)
+}
+
+func GetStructuredOutputSetting() *StructuredOutputSetting {
+	return &structuredOutputSetting
+}
@@ -0,0 +1,66 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+// 异步任务失败类型
+const (
+	TaskFailureClassContent   = "content"   // 内容审核未通过
+	TaskFailureClassRetryable = "retryable" // 上游临时错误，可重新提交
+	TaskFailureClassTimeout   = "timeout"   // 超过期限未完成
+	TaskFailureClassOther     = "other"
+)
+
+// TaskFailureSetting 异步任务失败处理策略：按失败类型退还额度、重新提交与超时
+type TaskFailureSetting struct {
+	RefundRatios           map[string]float64 `json:"refund_ratios"`            // 按失败类型退还的额度比例，未配置的类型全额退还
+	ContentKeywords        []string           `json:"content_keywords"`         // 失败原因包含这些关键字时视为内容审核未通过
+	RetryableKeywords      []string           `json:"retryable_keywords"`       // 失败原因包含这些关键字时视为上游临时错误
+	RetryEnabled           bool               `json:"retry_enabled"`            // 上游临时错误时重新提交到其他渠道
+	MaxRetries             int                `json:"max_retries"`              // 每个任务最多重新提交次数
+	TimeoutMinutes         int                `json:"timeout_minutes"`          // 任务提交后超过该时间未完成则判定失败，0 表示不限制
+	PlatformTimeoutMinutes map[string]int     `json:"platform_timeout_minutes"` // 按平台覆盖超时时间
+}
+
+// 默认配置
+var taskFailureSetting = TaskFailureSetting{
+	RefundRatios: map[string]float64{
+		TaskFailureClassContent:   1,
+		TaskFailureClassRetryable: 1,
+		TaskFailureClassTimeout:   1,
+		TaskFailureClassOther:     1,
+	},
+	ContentKeywords:   []string{"sensitive", "violat", "moderation", "content policy", "risk control", "敏感", "违规", "审核"},
+	RetryableKeywords: []string{"timeout", "timed out", "rate limit", "too many requests", "overload", "busy", "unavailable", "internal error", "繁忙", "超时", "限流"},
+	RetryEnabled:      false,
+	MaxRetries:        1,
+	TimeoutMinutes:    0,
+	PlatformTimeoutMinutes: map[string]int{
+		"claude_batch": 0, // Claude 批处理最长 24 小时，由上游负责过期
+	},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("task_failure_setting", &taskFailureSetting)
+}
+
+func GetTaskFailureSetting() *TaskFailureSetting {
+	return &taskFailureSetting
+}
+
+func (s *TaskFailureSetting) GetRefundRatio(class string) float64 {
+	ratio, ok := s.RefundRatios[class]
+	if !ok {
+		return 1
+	}
+	return min(max(ratio, 0), 1)
+}
+
+// GetTimeoutSeconds 获取平台任务的超时时间（秒），0 表示不限制
+func (s *TaskFailureSetting) GetTimeoutSeconds(platform string) int64 {
+	minutes := s.TimeoutMinutes
+	if platformMinutes, ok := s.PlatformTimeoutMinutes[platform]; ok {
+		minutes = platformMinutes
+	}
+	return int64(max(minutes, 0)) * 60
+}
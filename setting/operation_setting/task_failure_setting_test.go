@@ -0,0 +1,40 @@
+package operation_setting
+
+import "testing"
+
+func TestTaskFailureSettingRefundRatio(t *testing.T) {
+	setting := TaskFailureSetting{RefundRatios: map[string]float64{
+		TaskFailureClassContent:   0.3,
+		TaskFailureClassRetryable: 1.5,
+		TaskFailureClassOther:     -1,
+	}}
+	cases := map[string]float64{
+		TaskFailureClassContent:   0.3,
+		TaskFailureClassRetryable: 1,
+		TaskFailureClassOther:     0,
+		TaskFailureClassTimeout:   1, // 未配置的类型全额退还
+	}
+	for class, want := range cases {
+		if got := setting.GetRefundRatio(class); got != want {
+			t.Errorf("GetRefundRatio(%s) = %v, want %v", class, got, want)
+		}
+	}
+}
+
+func TestTaskFailureSettingTimeoutSeconds(t *testing.T) {
+	setting := TaskFailureSetting{
+		TimeoutMinutes:         30,
+		PlatformTimeoutMinutes: map[string]int{"claude_batch": 0, "kling": 120, "suno": -5},
+	}
+	cases := map[string]int64{
+		"midjourney":   1800,
+		"claude_batch": 0,
+		"kling":        7200,
+		"suno":         0,
+	}
+	for platform, want := range cases {
+		if got := setting.GetTimeoutSeconds(platform); got != want {
+			t.Errorf("GetTimeoutSeconds(%s) = %d, want %d", platform, got, want)
+		}
+	}
+}
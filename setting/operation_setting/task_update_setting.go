@@ -0,0 +1,42 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+// TaskUpdateSetting 异步任务进度更新配置：上游回调与自适应轮询
+type TaskUpdateSetting struct {
+	CallbackEnabled             bool           `json:"callback_enabled"`               // 向支持回调的上游传入网关回调地址
+	PollIntervalSeconds         int            `json:"poll_interval_seconds"`          // 基础轮询间隔
+	MaxPollIntervalSeconds      int            `json:"max_poll_interval_seconds"`      // 连续无进度变化时退避的上限
+	CallbackPollIntervalSeconds int            `json:"callback_poll_interval_seconds"` // 已启用回调的平台兜底轮询间隔
+	BatchSize                   int            `json:"batch_size"`                     // 每个平台每轮最多轮询的任务数
+	PlatformBatchSize           map[string]int `json:"platform_batch_size"`            // 按平台覆盖每轮任务数
+}
+
+// 默认配置
+var taskUpdateSetting = TaskUpdateSetting{
+	CallbackEnabled:             false,
+	PollIntervalSeconds:         15,
+	MaxPollIntervalSeconds:      120,
+	CallbackPollIntervalSeconds: 300,
+	BatchSize:                   500,
+	PlatformBatchSize:           map[string]int{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("task_update_setting", &taskUpdateSetting)
+}
+
+func GetTaskUpdateSetting() *TaskUpdateSetting {
+	return &taskUpdateSetting
+}
+
+func (s *TaskUpdateSetting) GetBatchSize(platform string) int {
+	if batchSize, ok := s.PlatformBatchSize[platform]; ok && batchSize > 0 {
+		return batchSize
+	}
+	if s.BatchSize <= 0 {
+		return 500
+	}
+	return s.BatchSize
+}
@@ -0,0 +1,28 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+// TaskWebhookSetting 异步任务完成通知配置
+type TaskWebhookSetting struct {
+	Enabled          bool `json:"enabled"`
+	MaxAttempts      int  `json:"max_attempts"`       // 最多投递次数，超过后标记为失败
+	RetryBaseSeconds int  `json:"retry_base_seconds"` // 首次重试间隔，之后每次加倍
+	TimeoutSeconds   int  `json:"timeout_seconds"`
+}
+
+// 默认配置
+var taskWebhookSetting = TaskWebhookSetting{
+	Enabled:          true,
+	MaxAttempts:      6,
+	RetryBaseSeconds: 30,
+	TimeoutSeconds:   10,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("task_webhook_setting", &taskWebhookSetting)
+}
+
+func GetTaskWebhookSetting() *TaskWebhookSetting {
+	return &taskWebhookSetting
+}
@@ -0,0 +1,82 @@
+package operation_setting
+
+import (
+	"one-api/common"
+	"one-api/setting/config"
+	"time"
+)
+
+// TimePricingRule 分时计价规则，在时段内对分组倍率与模型倍率（或固定价格）乘以对应系数
+type TimePricingRule struct {
+	Name            string   `json:"name"`
+	Cron            string   `json:"cron"`             // 时段，格式同 cron 表达式（分 时 日 月 周）
+	Timezone        string   `json:"timezone"`         // IANA 时区名，为空时使用服务器时区
+	Groups          []string `json:"groups"`           // 适用的分组，为空表示全部分组
+	Models          []string `json:"models"`           // 适用的模型，为空表示全部模型
+	GroupMultiplier float64  `json:"group_multiplier"` // 分组倍率系数，0 视为 1
+	ModelMultiplier float64  `json:"model_multiplier"` // 模型倍率与固定价格系数，0 视为 1
+}
+
+// TimePricingSetting 分时计价，按顺序使用第一条匹配的规则
+type TimePricingSetting struct {
+	Enabled bool              `json:"enabled"`
+	Rules   []TimePricingRule `json:"rules"`
+}
+
+// TimePricingMatch 请求命中的分时计价规则，记录在消费日志中
+type TimePricingMatch struct {
+	Rule            string  `json:"rule"`
+	GroupMultiplier float64 `json:"group_multiplier"`
+	ModelMultiplier float64 `json:"model_multiplier"`
+}
+
+// 默认配置
+var timePricingSetting = TimePricingSetting{
+	Enabled: false,
+	Rules:   []TimePricingRule{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("time_pricing_setting", &timePricingSetting)
+}
+
+func GetTimePricingSetting() *TimePricingSetting {
+	return &timePricingSetting
+}
+
+// Match 获取分组与模型在指定时间命中的规则，未命中时返回 nil
+func (s *TimePricingSetting) Match(group string, modelName string, now time.Time) *TimePricingMatch {
+	if !s.Enabled {
+		return nil
+	}
+	for _, rule := range s.Rules {
+		if len(rule.Groups) > 0 && !common.StringsContains(rule.Groups, group) {
+			continue
+		}
+		if len(rule.Models) > 0 && !common.StringsContains(rule.Models, modelName) {
+			continue
+		}
+		schedule, err := common.ParseSchedule(common.ScheduleWindow{Cron: rule.Cron, Timezone: rule.Timezone})
+		if err != nil {
+			common.SysError("invalid time pricing rule " + rule.Name + ": " + err.Error())
+			continue
+		}
+		if !schedule.Contains(now) {
+			continue
+		}
+		match := &TimePricingMatch{
+			Rule:            rule.Name,
+			GroupMultiplier: rule.GroupMultiplier,
+			ModelMultiplier: rule.ModelMultiplier,
+		}
+		if match.GroupMultiplier <= 0 {
+			match.GroupMultiplier = 1
+		}
+		if match.ModelMultiplier <= 0 {
+			match.ModelMultiplier = 1
+		}
+		return match
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package operation_setting
+
+import "one-api/setting/config"
+
+// VideoPricingSetting 视频生成按时长与分辨率计费
+type VideoPricingSetting struct {
+	PerSecondPrices     map[string]float64 `json:"per_second_prices"`     // 按秒计费的模型单价，未配置的模型按固定价格乘以时长倍数
+	BaseDurationSeconds int                `json:"base_duration_seconds"` // 模型固定价格对应的视频时长，0 表示不按时长缩放
+	ResolutionRatios    map[string]float64 `json:"resolution_ratios"`     // 分辨率倍率，键为 "模型:分辨率" 或 "分辨率"
+}
+
+// 默认配置
+var videoPricingSetting = VideoPricingSetting{
+	PerSecondPrices:     map[string]float64{},
+	BaseDurationSeconds: 0,
+	ResolutionRatios:    map[string]float64{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("video_pricing_setting", &videoPricingSetting)
+}
+
+func GetVideoPricingSetting() *VideoPricingSetting {
+	return &videoPricingSetting
+}
+
+// GetVideoPrice 计算一次视频生成的价格，modelPrice 为模型固定价格
+func (s *VideoPricingSetting) GetVideoPrice(modelName string, modelPrice float64, durationSeconds float64, resolution string) float64 {
+	price := modelPrice
+	if perSecond, ok := s.PerSecondPrices[modelName]; ok {
+		price = perSecond * durationSeconds
+	} else if s.BaseDurationSeconds > 0 && durationSeconds > 0 {
+		price = modelPrice * durationSeconds / float64(s.BaseDurationSeconds)
+	}
+	return price * s.GetResolutionRatio(modelName, resolution)
+}
+
+func (s *VideoPricingSetting) GetResolutionRatio(modelName string, resolution string) float64 {
+	if resolution == "" {
+		return 1
+	}
+	if ratio, ok := s.ResolutionRatios[modelName+":"+resolution]; ok {
+		return ratio
+	}
+	if ratio, ok := s.ResolutionRatios[resolution]; ok {
+		return ratio
+	}
+	return 1
+}
@@ -604,6 +604,20 @@ func ModelRatio2JSONString() string {
 var defaultImageRatio = map[string]float64{
 	"gpt-image-1": 2,
 }
+
+// defaultImageSizeRatio 按张计费的图片倍率，键为 "模型:尺寸" 或 "模型:尺寸:品质"，
+// ImageRatio 中未配置对应键时使用，避免已保存的 ImageRatio 配置覆盖掉内置的尺寸价格
+var defaultImageSizeRatio = map[string]float64{
+	"dall-e:256x256":        0.4,
+	"dall-e:512x512":        0.45,
+	"dall-e-2:256x256":      0.4,
+	"dall-e-2:512x512":      0.45,
+	"dall-e-3:1024x1792":    2,
+	"dall-e-3:1792x1024":    2,
+	"dall-e-3:1024x1024:hd": 2,
+	"dall-e-3:1024x1792:hd": 3,
+	"dall-e-3:1792x1024:hd": 3,
+}
 var imageRatioMap map[string]float64
 var imageRatioMapMutex sync.RWMutex
 
@@ -634,6 +648,23 @@ func GetImageRatio(name string) (float64, bool) {
 	return ratio, true
 }
 
+// GetImageSizeRatio 按 "模型:尺寸:品质"、"模型:尺寸" 的顺序查找按张计费的图片倍率，
+// 优先使用 ImageRatio 中的配置，其次使用内置的尺寸价格，均未配置时为 1
+func GetImageSizeRatio(name string, size string, quality string) float64 {
+	keys := []string{name + ":" + size + ":" + quality, name + ":" + size}
+	for _, key := range keys {
+		if ratio, ok := GetImageRatio(key); ok {
+			return ratio
+		}
+	}
+	for _, key := range keys {
+		if ratio, ok := defaultImageSizeRatio[key]; ok {
+			return ratio
+		}
+	}
+	return 1
+}
+
 func GetModelRatioCopy() map[string]float64 {
 	modelRatioMapMutex.RLock()
 	defer modelRatioMapMutex.RUnlock()
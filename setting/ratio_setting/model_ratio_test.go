@@ -0,0 +1,38 @@
+package ratio_setting
+
+import "testing"
+
+func TestGetImageSizeRatioWithLegacyOption(t *testing.T) {
+	// 升级前保存的 ImageRatio 配置不包含尺寸价格
+	if err := UpdateImageRatioByJSONString(`{"gpt-image-1":2}`); err != nil {
+		t.Fatalf("update image ratio failed: %s", err)
+	}
+	cases := []struct {
+		model, size, quality string
+		want                 float64
+	}{
+		{"dall-e-3", "1024x1792", "hd", 3},
+		{"dall-e-3", "1024x1024", "hd", 2},
+		{"dall-e-3", "1792x1024", "standard", 2},
+		{"dall-e-3", "1024x1024", "standard", 1},
+		{"dall-e-2", "256x256", "", 0.4},
+		{"gpt-image-1", "1024x1024", "high", 1},
+	}
+	for _, c := range cases {
+		if got := GetImageSizeRatio(c.model, c.size, c.quality); got != c.want {
+			t.Errorf("GetImageSizeRatio(%q, %q, %q) = %v, want %v", c.model, c.size, c.quality, got, c.want)
+		}
+	}
+}
+
+func TestGetImageSizeRatioOptionOverridesDefault(t *testing.T) {
+	if err := UpdateImageRatioByJSONString(`{"dall-e-3:1024x1792:hd":4}`); err != nil {
+		t.Fatalf("update image ratio failed: %s", err)
+	}
+	if got := GetImageSizeRatio("dall-e-3", "1024x1792", "hd"); got != 4 {
+		t.Errorf("configured ratio should override the default, got %v", got)
+	}
+	if got := GetImageSizeRatio("dall-e-3", "1792x1024", "hd"); got != 3 {
+		t.Errorf("unconfigured size should fall back to the default, got %v", got)
+	}
+}
@@ -135,6 +135,11 @@ export const CHANNEL_OPTIONS = [
     color: 'blue',
     label: '即梦',
   },
+  {
+    value: 52,
+    color: 'teal',
+    label: '自部署（vLLM / TGI / llama.cpp）',
+  },
 ];
 
 export const MODEL_TABLE_PAGE_SIZE = 10;
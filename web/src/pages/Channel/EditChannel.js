@@ -285,6 +285,8 @@ const EditChannel = (props) => {
               base_url: inputs['base_url'],
               type: inputs['type'],
               key: inputs['key'],
+              other: inputs['other'],
+              setting: inputs['setting'],
             },
             { skipErrorHandler: true },
           );
@@ -310,6 +312,22 @@ const EditChannel = (props) => {
     setLoading(false);
   };
 
+  const discoverAzureDeployments = async () => {
+    setLoading(true);
+    try {
+      const res = await API.post(`/api/channel/azure/discover/${channelId}`);
+      if (res.data.success) {
+        showSuccess(t('部署发现成功，已更新部署映射'));
+        await loadChannel();
+      } else {
+        showError(res.data.message);
+      }
+    } catch (error) {
+      showError(error.message);
+    }
+    setLoading(false);
+  };
+
   const fetchModels = async () => {
     try {
       let res = await API.get(`/api/channel/models`);
@@ -1129,6 +1147,18 @@ const EditChannel = (props) => {
                         description={t('2025年5月10日后添加的渠道，不需要再在部署的时候移除模型名称中的"."')}
                         className='!rounded-lg'
                       />
+                      <Banner
+                        type='info'
+                        description={t('获取模型列表会列出 Azure 部署；点击发现部署会按基础模型写入部署映射，保存渠道不会自动更新映射。Entra ID 鉴权、AI Foundry serverless 端点与管理 API 发现可在渠道额外设置的 azure 字段中配置')}
+                        className='!rounded-lg'
+                      />
+                      {isEdit && (
+                        <div>
+                          <Button theme='light' type='primary' onClick={discoverAzureDeployments}>
+                            {t('发现部署')}
+                          </Button>
+                        </div>
+                      )}
                       <div>
                         <Form.Input
                           field='base_url'
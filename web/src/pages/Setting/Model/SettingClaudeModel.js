@@ -36,6 +36,8 @@ export default function SettingClaudeModel(props) {
     'claude.thinking_adapter_enabled': true,
     'claude.default_max_tokens': '',
     'claude.thinking_adapter_budget_tokens_percentage': 0.8,
+    'claude.batch_discount': 0.5,
+    'claude.files_model': '',
   });
   const refForm = useRef();
   const [inputsRow, setInputsRow] = useState(inputs);
@@ -195,6 +197,31 @@ export default function SettingClaudeModel(props) {
                 />
               </Col>
             </Row>
+            <Row>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.InputNumber
+                  label={t('Message Batches 计费折扣')}
+                  field={'claude.batch_discount'}
+                  initValue={''}
+                  extraText={t('批处理按实际用量乘以该折扣计费，0-1之间的小数')}
+                  min={0}
+                  max={1}
+                  onChange={(value) =>
+                    setInputs({ ...inputs, 'claude.batch_discount': value })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Input
+                  label={t('Files API 上传使用的模型')}
+                  field={'claude.files_model'}
+                  extraText={t('上传文件时按该模型选择 Anthropic 渠道')}
+                  onChange={(value) =>
+                    setInputs({ ...inputs, 'claude.files_model': value })
+                  }
+                />
+              </Col>
+            </Row>
 
             <Row>
               <Button size='default' onClick={onSubmit}>
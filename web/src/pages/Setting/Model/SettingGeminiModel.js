@@ -28,6 +28,8 @@ export default function SettingGeminiModel(props) {
     'gemini.safety_settings': '',
     'gemini.version_settings': '',
     'gemini.supported_imagine_models': '',
+    'gemini.cache_storage_price': '',
+    'gemini.files_model': '',
     'gemini.thinking_adapter_enabled': false,
     'gemini.thinking_adapter_budget_tokens_percentage': 0.6,
   });
@@ -165,6 +167,35 @@ export default function SettingGeminiModel(props) {
                 />
               </Col>
             </Row>
+            <Row>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.TextArea
+                  field={'gemini.cache_storage_price'}
+                  label={t('上下文缓存存储价格')}
+                  placeholder={t('例如：') + '\n' + JSON.stringify({ default: 1, 'gemini-2.5-pro': 4.5 }, null, 2)}
+                  extraText={t('每百万 token 每小时的存储价格（美元），default为默认设置')}
+                  onChange={(value) => setInputs({ ...inputs, 'gemini.cache_storage_price': value })}
+                  autosize={{ minRows: 6, maxRows: 12 }}
+                  trigger='blur'
+                  stopValidateWithError
+                  rules={[
+                    {
+                      validator: (rule, value) => verifyJSON(value),
+                      message: t('不是合法的 JSON 字符串'),
+                    },
+                  ]}
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Input
+                  field={'gemini.files_model'}
+                  label={t('文件上传模型')}
+                  extraText={t('上传文件时按该模型选择渠道')}
+                  placeholder={'gemini-2.5-flash'}
+                  onChange={(value) => setInputs({ ...inputs, 'gemini.files_model': value })}
+                />
+              </Col>
+            </Row>
           </Form.Section>
 
           <Form.Section text={t('Gemini思考适配设置')}>